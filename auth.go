@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"strings"
+)
+
+func applyAuth(config *Config) error {
+	oauth2 := config.OAuth2.TokenURL != ""
+	schemes := 0
+	for _, enabled := range []bool{config.BasicAuth != "", config.BearerToken != "", oauth2, config.SigV4.Enabled} {
+		if enabled {
+			schemes++
+		}
+	}
+	if schemes > 1 {
+		return fmt.Errorf("use apenas um dos parâmetros --basic-auth, --bearer-token, --oauth2-token-url ou --aws-sigv4")
+	}
+	if schemes > 0 && config.Headers.Get("Authorization") != "" {
+		return fmt.Errorf("header Authorization já definido, remova-o ou não use --basic-auth/--bearer-token/--oauth2-token-url/--aws-sigv4")
+	}
+	if oauth2 && config.OAuth2.ClientID == "" {
+		return fmt.Errorf("parâmetro --oauth2-client-id é obrigatório com --oauth2-token-url")
+	}
+	if !oauth2 && (config.OAuth2.ClientID != "" || config.OAuth2.ClientSecret != "" || len(config.OAuth2.Scopes) > 0) {
+		return fmt.Errorf("parâmetro --oauth2-token-url é obrigatório para autenticação OAuth2")
+	}
+
+	if config.SigV4.Enabled {
+		if config.SigV4.Region == "" {
+			config.SigV4.Region = awsRegionFromEnv()
+		}
+		if config.SigV4.Region == "" {
+			return fmt.Errorf("--aws-sigv4 exige --aws-region, AWS_REGION ou AWS_DEFAULT_REGION")
+		}
+		if config.SigV4.Service == "" {
+			return fmt.Errorf("parâmetro --aws-service não pode ser vazio")
+		}
+		config.SigV4.creds = &awsCredentialProvider{}
+		if _, err := config.SigV4.creds.get(context.Background()); err != nil {
+			return fmt.Errorf("--aws-sigv4 exige credenciais da AWS: %w", err)
+		}
+	} else if config.SigV4.Region != "" {
+		return fmt.Errorf("parâmetro --aws-region só pode ser usado com --aws-sigv4")
+	}
+
+	if config.BasicAuth != "" {
+		if !strings.Contains(config.BasicAuth, ":") {
+			return fmt.Errorf("parâmetro --basic-auth inválido, use o formato \"usuário:senha\"")
+		}
+		config.Headers.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(config.BasicAuth)))
+	}
+	if config.BearerToken != "" {
+		config.Headers.Set("Authorization", "Bearer "+config.BearerToken)
+	}
+	if config.APIKey != "" {
+		key, value, ok := strings.Cut(config.APIKey, ":")
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if !ok || key == "" || value == "" {
+			return fmt.Errorf("parâmetro --api-key-header inválido, use o formato \"Header: chave\"")
+		}
+		config.Headers.Set(key, value)
+	}
+	return nil
+}
+
+func mask(secret string) string {
+	if len(secret) <= 8 {
+		return "****"
+	}
+	return secret[:4] + "****"
+}
+
+func printAuth(w io.Writer, config *Config) {
+	if config.BasicAuth != "" {
+		user, _, _ := strings.Cut(config.BasicAuth, ":")
+		fmt.Fprintf(w, "Autenticação: Basic (usuário %s, senha ****)\n", user)
+	}
+	if config.BearerToken != "" {
+		fmt.Fprintf(w, "Autenticação: Bearer %s\n", mask(config.BearerToken))
+	}
+	if config.OAuth2.TokenURL != "" {
+		fmt.Fprintf(w, "Autenticação: OAuth2 client credentials (client %s, secret %s, token %s)\n",
+			config.OAuth2.ClientID, mask(config.OAuth2.ClientSecret), config.OAuth2.TokenURL)
+	}
+	if config.SigV4.Enabled {
+		fmt.Fprintf(w, "Autenticação: AWS SigV4 (região %s, serviço %s, credenciais de %s)\n",
+			config.SigV4.Region, config.SigV4.Service, config.SigV4.creds.source)
+	}
+	if config.APIKey != "" {
+		key, value, _ := strings.Cut(config.APIKey, ":")
+		fmt.Fprintf(w, "API key: %s: %s\n", strings.TrimSpace(key), mask(strings.TrimSpace(value)))
+	}
+}
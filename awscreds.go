@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// awsCredentialsRefresh is how long before expiring temporary credentials are
+// fetched again.
+const awsCredentialsRefresh = 5 * time.Minute
+
+// awsCredentialProvider resolves the credentials through the standard AWS
+// chain and caches them; temporary ones are fetched again before expiring.
+type awsCredentialProvider struct {
+	mu      sync.Mutex
+	creds   awsCredentials
+	expires time.Time
+	source  string
+}
+
+func (p *awsCredentialProvider) get(ctx context.Context) (awsCredentials, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.source != "" && (p.expires.IsZero() || time.Until(p.expires) > awsCredentialsRefresh) {
+		return p.creds, nil
+	}
+	creds, expires, source, err := resolveAWSCredentials(ctx)
+	if err != nil {
+		return awsCredentials{}, err
+	}
+	p.creds, p.expires, p.source = creds, expires, source
+	return creds, nil
+}
+
+// resolveAWSCredentials looks for credentials in the same order as the AWS
+// SDKs: environment variables, the shared credentials file, the container
+// credentials endpoint of ECS and EKS and the EC2 instance metadata service.
+func resolveAWSCredentials(ctx context.Context) (awsCredentials, time.Time, string, error) {
+	creds := awsCredentials{
+		accessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+		secretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		sessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if creds.accessKey != "" && creds.secretKey != "" {
+		return creds, time.Time{}, "variáveis de ambiente", nil
+	}
+
+	creds, path, err := sharedAWSCredentials()
+	if err != nil {
+		return awsCredentials{}, time.Time{}, "", err
+	}
+	if creds.accessKey != "" {
+		return creds, time.Time{}, path, nil
+	}
+
+	if uri := containerCredentialsURI(); uri != "" {
+		creds, expires, err := fetchAWSCredentials(ctx, uri, map[string]string{"Authorization": containerAuthorization()})
+		if err != nil {
+			return awsCredentials{}, time.Time{}, "", fmt.Errorf("falha ao obter as credenciais do container: %w", err)
+		}
+		return creds, expires, "container", nil
+	}
+
+	if !strings.EqualFold(os.Getenv("AWS_EC2_METADATA_DISABLED"), "true") {
+		if creds, expires, err := instanceCredentials(ctx); err == nil {
+			return creds, expires, "metadata da instância EC2", nil
+		}
+	}
+	return awsCredentials{}, time.Time{}, "", errors.New("nenhuma credencial encontrada: defina AWS_ACCESS_KEY_ID e AWS_SECRET_ACCESS_KEY, configure ~/.aws/credentials ou execute com uma role da AWS")
+}
+
+// sharedAWSCredentials reads the profile of AWS_PROFILE, or default, from
+// AWS_SHARED_CREDENTIALS_FILE or ~/.aws/credentials. A missing file is not an
+// error.
+func sharedAWSCredentials() (awsCredentials, string, error) {
+	path := os.Getenv("AWS_SHARED_CREDENTIALS_FILE")
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return awsCredentials{}, "", nil
+		}
+		path = filepath.Join(home, ".aws", "credentials")
+	}
+	profile := os.Getenv("AWS_PROFILE")
+	if profile == "" {
+		profile = "default"
+	}
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return awsCredentials{}, "", nil
+	}
+	if err != nil {
+		return awsCredentials{}, "", err
+	}
+	defer file.Close()
+
+	var creds awsCredentials
+	var section string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok || section != profile {
+			continue
+		}
+		switch strings.TrimSpace(key) {
+		case "aws_access_key_id":
+			creds.accessKey = strings.TrimSpace(value)
+		case "aws_secret_access_key":
+			creds.secretKey = strings.TrimSpace(value)
+		case "aws_session_token":
+			creds.sessionToken = strings.TrimSpace(value)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return awsCredentials{}, "", err
+	}
+	if creds.accessKey == "" || creds.secretKey == "" {
+		return awsCredentials{}, "", nil
+	}
+	return creds, fmt.Sprintf("%s (perfil %s)", path, profile), nil
+}
+
+func containerCredentialsURI() string {
+	if uri := os.Getenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI"); uri != "" {
+		return "http://169.254.170.2" + uri
+	}
+	return os.Getenv("AWS_CONTAINER_CREDENTIALS_FULL_URI")
+}
+
+func containerAuthorization() string {
+	if path := os.Getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN_FILE"); path != "" {
+		if token, err := os.ReadFile(path); err == nil {
+			return strings.TrimSpace(string(token))
+		}
+	}
+	return os.Getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN")
+}
+
+// instanceCredentials gets the credentials of the role of the EC2 instance
+// through IMDSv2.
+func instanceCredentials(ctx context.Context) (awsCredentials, time.Time, error) {
+	const endpoint = "http://169.254.169.254/latest"
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, endpoint+"/api/token", nil)
+	if err != nil {
+		return awsCredentials{}, time.Time{}, err
+	}
+	req.Header.Set("X-aws-ec2-metadata-token-ttl-seconds", "21600")
+	token, err := metadataGet(req)
+	if err != nil {
+		return awsCredentials{}, time.Time{}, err
+	}
+	headers := map[string]string{"X-aws-ec2-metadata-token": token}
+	req, err = http.NewRequestWithContext(ctx, http.MethodGet, endpoint+"/meta-data/iam/security-credentials/", nil)
+	if err != nil {
+		return awsCredentials{}, time.Time{}, err
+	}
+	req.Header.Set("X-aws-ec2-metadata-token", token)
+	role, err := metadataGet(req)
+	if err != nil {
+		return awsCredentials{}, time.Time{}, err
+	}
+	role, _, _ = strings.Cut(strings.TrimSpace(role), "\n")
+	return fetchAWSCredentials(ctx, endpoint+"/meta-data/iam/security-credentials/"+role, headers)
+}
+
+func metadataGet(req *http.Request) (string, error) {
+	resp, err := uploadClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("status %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(resp.Body)
+	return string(data), err
+}
+
+// fetchAWSCredentials reads credentials in the JSON format shared by the
+// container endpoint and the instance metadata service.
+func fetchAWSCredentials(ctx context.Context, uri string, headers map[string]string) (awsCredentials, time.Time, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return awsCredentials{}, time.Time{}, err
+	}
+	for key, value := range headers {
+		if value != "" {
+			req.Header.Set(key, value)
+		}
+	}
+	data, err := metadataGet(req)
+	if err != nil {
+		return awsCredentials{}, time.Time{}, err
+	}
+	var out struct {
+		AccessKeyID     string    `json:"AccessKeyId"`
+		SecretAccessKey string    `json:"SecretAccessKey"`
+		Token           string    `json:"Token"`
+		Expiration      time.Time `json:"Expiration"`
+	}
+	if err := json.Unmarshal([]byte(data), &out); err != nil {
+		return awsCredentials{}, time.Time{}, err
+	}
+	if out.AccessKeyID == "" || out.SecretAccessKey == "" {
+		return awsCredentials{}, time.Time{}, errors.New("resposta sem AccessKeyId ou SecretAccessKey")
+	}
+	return awsCredentials{accessKey: out.AccessKeyID, secretKey: out.SecretAccessKey, sessionToken: out.Token}, out.Expiration, nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// comparison is one metric of a run compared with the baseline.
+type comparison struct {
+	name      string
+	unit      string
+	baseline  float64
+	current   float64
+	regressed bool
+}
+
+// change describes the difference between the runs: a relative change, or
+// percentage points for percentages.
+func (c comparison) change() string {
+	if c.unit == "%" {
+		return fmt.Sprintf("%+.2f p.p.", c.current-c.baseline)
+	}
+	if c.baseline == 0 {
+		return "sem base"
+	}
+	return fmt.Sprintf("%+.2f%%", (c.current-c.baseline)/c.baseline*100)
+}
+
+// loadBaseline reads a report previously written with --output=json.
+func loadBaseline(path string) (*jsonReport, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var baseline jsonReport
+	if err := json.Unmarshal(data, &baseline); err != nil {
+		return nil, fmt.Errorf("%s não é um relatório JSON: %w", path, err)
+	}
+	if baseline.TotalRequests == 0 {
+		return nil, fmt.Errorf("%s não contém requests", path)
+	}
+	return &baseline, nil
+}
+
+// compareReports compares current with baseline. Requests per second and the
+// latency percentiles regress when they get worse by more than maxRegression
+// percent; the error rate regresses when it grows by more than
+// maxErrorIncrease percentage points.
+func compareReports(baseline, current *jsonReport, maxRegression, maxErrorIncrease float64) []comparison {
+	comparisons := []comparison{
+		{name: "Requests/s", baseline: baseline.RequestsPerSecond, current: current.RequestsPerSecond},
+		{name: "Taxa de erro", unit: "%", baseline: 100 - baseline.SuccessRate, current: 100 - current.SuccessRate},
+		{name: "p50", unit: "ms", baseline: baseline.Latency.P50Ms, current: current.Latency.P50Ms},
+		{name: "p90", unit: "ms", baseline: baseline.Latency.P90Ms, current: current.Latency.P90Ms},
+		{name: "p95", unit: "ms", baseline: baseline.Latency.P95Ms, current: current.Latency.P95Ms},
+		{name: "p99", unit: "ms", baseline: baseline.Latency.P99Ms, current: current.Latency.P99Ms},
+	}
+	for i := range comparisons {
+		c := &comparisons[i]
+		switch c.unit {
+		case "%":
+			c.regressed = c.current-c.baseline > maxErrorIncrease
+		case "ms":
+			c.regressed = c.baseline > 0 && c.current > c.baseline*(1+maxRegression/100)
+		default:
+			c.regressed = c.current < c.baseline*(1-maxRegression/100)
+		}
+	}
+	return comparisons
+}
+
+func printComparison(w io.Writer, path string, comparisons []comparison) {
+	fmt.Fprintf(w, "\nComparação com o baseline (%s):\n", path)
+	for _, c := range comparisons {
+		status := ""
+		if c.regressed {
+			status = " REGRESSÃO"
+		}
+		fmt.Fprintf(w, "  %-13s %.2f%s → %.2f%s (%s)%s\n", c.name+":", c.baseline, c.unit, c.current, c.unit, c.change(), status)
+	}
+}
@@ -0,0 +1,539 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"stress-test/pkg/loadtest"
+)
+
+type fileTarget struct {
+	URL    string `yaml:"url"`
+	Weight int    `yaml:"weight"`
+}
+
+type fileTLS struct {
+	Insecure   bool   `yaml:"insecure"`
+	CACert     string `yaml:"ca_cert"`
+	ClientCert string `yaml:"client_cert"`
+	ClientKey  string `yaml:"client_key"`
+}
+
+type fileAssertions struct {
+	BodyContains []string `yaml:"body_contains"`
+	BodyRegex    []string `yaml:"body_regex"`
+	JSONPath     []string `yaml:"json_path"`
+}
+
+type fileOAuth2 struct {
+	TokenURL     string   `yaml:"token_url"`
+	ClientID     string   `yaml:"client_id"`
+	ClientSecret string   `yaml:"client_secret"`
+	Scopes       []string `yaml:"scopes"`
+}
+
+type fileAuth struct {
+	Basic        string     `yaml:"basic"`
+	BearerToken  string     `yaml:"bearer_token"`
+	APIKeyHeader string     `yaml:"api_key_header"`
+	OAuth2       fileOAuth2 `yaml:"oauth2"`
+	AWSSigV4     *fileSigV4 `yaml:"aws_sigv4"`
+}
+
+type fileSigV4 struct {
+	Region  string `yaml:"region"`
+	Service string `yaml:"service"`
+}
+
+type fileCompare struct {
+	Baseline         string  `yaml:"baseline"`
+	MaxRegression    float64 `yaml:"max_regression"`
+	MaxErrorIncrease float64 `yaml:"max_error_rate_increase"`
+}
+
+type fileInfluxDB struct {
+	URL       string            `yaml:"url"`
+	Database  string            `yaml:"database"`
+	Org       string            `yaml:"org"`
+	Bucket    string            `yaml:"bucket"`
+	Token     string            `yaml:"token"`
+	PerSecond bool              `yaml:"per_second"`
+	Tags      map[string]string `yaml:"tags"`
+}
+
+type fileTracing struct {
+	Propagation  bool   `yaml:"propagation"`
+	OTLPEndpoint string `yaml:"otlp_endpoint"`
+	ServiceName  string `yaml:"service_name"`
+}
+
+type fileOpenAPI struct {
+	Spec       string   `yaml:"spec"`
+	Server     string   `yaml:"server"`
+	Operations []string `yaml:"operations"`
+}
+
+type filePostman struct {
+	Collection  string `yaml:"collection"`
+	Environment string `yaml:"environment"`
+	Folder      string `yaml:"folder"`
+	Mode        string `yaml:"mode"`
+}
+
+// requestSourceFlags describe the requests of the test; when any of them is
+// set, the sources of the config file are ignored.
+var requestSourceFlags = []string{"url", "targets-file", "scenario", "har", "from-curl", "postman", "openapi", "stdin"}
+
+func anySet(set map[string]bool, names []string) bool {
+	for _, name := range names {
+		if set[name] {
+			return true
+		}
+	}
+	return false
+}
+
+type fileConfig struct {
+	Targets        []fileTarget      `yaml:"targets"`
+	Method         string            `yaml:"method"`
+	Headers        map[string]string `yaml:"headers"`
+	Body           string            `yaml:"body"`
+	BodyFile       string            `yaml:"body_file"`
+	Form           []string          `yaml:"form"`
+	FormFiles      []string          `yaml:"form_files"`
+	BodySize       string            `yaml:"body_size"`
+	BodyChunked    bool              `yaml:"body_chunked"`
+	ReadBody       bool              `yaml:"read_body"`
+	SaveBodies     string            `yaml:"save_bodies"`
+	SaveRate       float64           `yaml:"save_bodies_rate"`
+	SampleFailures int               `yaml:"sample_failures"`
+	ContentType    string            `yaml:"content_type"`
+	Requests       int               `yaml:"requests"`
+	Concurrency    int               `yaml:"concurrency"`
+	Duration       time.Duration     `yaml:"duration"`
+	MaxDuration    time.Duration     `yaml:"max_duration"`
+	Rate           float64           `yaml:"rate"`
+	RampUp         time.Duration     `yaml:"ramp_up"`
+	Stages         []string          `yaml:"stages"`
+	Preset         string            `yaml:"preset"`
+	Executor       string            `yaml:"executor"`
+	PortExhaustion string            `yaml:"port_exhaustion"`
+	MaxVUs         int               `yaml:"max_vus"`
+	Warmup         time.Duration     `yaml:"warmup"`
+	WarmupRequests int               `yaml:"warmup_requests"`
+	ThinkTime      time.Duration     `yaml:"think_time"`
+	ThinkJitter    time.Duration     `yaml:"think_time_jitter"`
+	Retries        int               `yaml:"retries"`
+	RetryBackoff   time.Duration     `yaml:"retry_backoff"`
+	RetryOn        []string          `yaml:"retry_on"`
+	Timeout        time.Duration     `yaml:"timeout"`
+	LatencyBuckets []time.Duration   `yaml:"latency_buckets"`
+	Resolution     time.Duration     `yaml:"timeline_interval"`
+	TLS            fileTLS           `yaml:"tls"`
+	Auth           fileAuth          `yaml:"auth"`
+	Assertions     fileAssertions    `yaml:"assertions"`
+	Script         string            `yaml:"script"`
+	AcceptEncoding []string          `yaml:"accept_encoding"`
+	SuccessCodes   string            `yaml:"success_codes"`
+	Thresholds     []string          `yaml:"thresholds"`
+	AbortOn        string            `yaml:"abort_on_error_rate"`
+	Name           string            `yaml:"name"`
+	Tags           map[string]string `yaml:"tags"`
+	Compare        fileCompare       `yaml:"compare"`
+	Scenario       string            `yaml:"scenario"`
+	HAR            string            `yaml:"har"`
+	Postman        filePostman       `yaml:"postman"`
+	OpenAPI        fileOpenAPI       `yaml:"openapi"`
+	RespectTiming  bool              `yaml:"respect_timing"`
+	Speedup        string            `yaml:"speedup"`
+	VUHeader       bool              `yaml:"vu_header"`
+	EnableCookies  bool              `yaml:"enable_cookies"`
+	Conditional    bool              `yaml:"conditional_requests"`
+	MaxRedirects   *int              `yaml:"max_redirects"`
+	MaxIdleConns   int               `yaml:"max_idle_conns"`
+	MaxIdlePerHost int               `yaml:"max_idle_conns_per_host"`
+	MaxHostConns   int               `yaml:"max_conns_per_host"`
+	PerClient      int               `yaml:"workers_per_client"`
+	RaiseNofile    bool              `yaml:"raise_nofile"`
+	Preflight      bool              `yaml:"preflight"`
+	Proxy          string            `yaml:"proxy"`
+	ProxyFromEnv   bool              `yaml:"proxy_from_env"`
+	Resolve        []string          `yaml:"resolve"`
+	DNSServer      string            `yaml:"dns_server"`
+	UnixSocket     string            `yaml:"unix_socket"`
+	LocalAddrs     []string          `yaml:"local_addr"`
+	IPFamily       string            `yaml:"ip_family"`
+	MaxBandwidth   string            `yaml:"max_bandwidth"`
+	Data           string            `yaml:"data"`
+	DataMode       string            `yaml:"data_mode"`
+	Output         string            `yaml:"output"`
+	OutputFile     string            `yaml:"output_file"`
+	OutputRaw      string            `yaml:"output_raw"`
+	MetricsListen  string            `yaml:"metrics_listen"`
+	StatsD         string            `yaml:"statsd"`
+	StatsDTags     []string          `yaml:"statsd_tags"`
+	InfluxDB       fileInfluxDB      `yaml:"influxdb"`
+	Tracing        fileTracing       `yaml:"tracing"`
+	Agents         []string          `yaml:"agents"`
+	AgentToken     string            `yaml:"agent_token"`
+	ReportHTML     string            `yaml:"report_html"`
+	Summary        string            `yaml:"summary"`
+	Store          string            `yaml:"store"`
+	Upload         string            `yaml:"upload"`
+	NotifyWebhook  string            `yaml:"notify_webhook"`
+	Progress       time.Duration     `yaml:"progress_interval"`
+	Quiet          bool              `yaml:"quiet"`
+	Verbose        bool              `yaml:"verbose"`
+	LogLevel       string            `yaml:"log_level"`
+	LogFormat      string            `yaml:"log_format"`
+}
+
+func loadConfigFile(path string) (*fileConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	file, err := parseConfigFile(data)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, p := range []*string{&file.Scenario, &file.HAR, &file.Postman.Collection, &file.Postman.Environment, &file.OpenAPI.Spec, &file.Data, &file.Script, &file.BodyFile, &file.UnixSocket, &file.TLS.CACert, &file.TLS.ClientCert, &file.TLS.ClientKey} {
+		if *p != "" && !filepath.IsAbs(*p) {
+			*p = filepath.Join(filepath.Dir(path), *p)
+		}
+	}
+	for i, field := range file.FormFiles {
+		name, p, _ := strings.Cut(field, "=")
+		if p = strings.TrimPrefix(p, "@"); p != "" && !filepath.IsAbs(p) {
+			file.FormFiles[i] = name + "=@" + filepath.Join(filepath.Dir(path), p)
+		}
+	}
+	return file, nil
+}
+
+func parseConfigFile(data []byte) (*fileConfig, error) {
+	file := &fileConfig{}
+	if err := yaml.Unmarshal(data, file); err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+// fileKeys returns the keys set in f that read or write files on the
+// machine running the test.
+func (f *fileConfig) fileKeys() []string {
+	paths := map[string]string{
+		"body_file": f.BodyFile, "save_bodies": f.SaveBodies, "script": f.Script, "data": f.Data,
+		"tls.ca_cert": f.TLS.CACert, "tls.client_cert": f.TLS.ClientCert, "tls.client_key": f.TLS.ClientKey,
+		"scenario": f.Scenario, "har": f.HAR, "postman.collection": f.Postman.Collection,
+		"postman.environment": f.Postman.Environment, "openapi.spec": f.OpenAPI.Spec,
+		"compare.baseline": f.Compare.Baseline, "unix_socket": f.UnixSocket, "output_file": f.OutputFile,
+		"output_raw": f.OutputRaw, "report_html": f.ReportHTML, "store": f.Store,
+		"form_files": strings.Join(f.FormFiles, ","),
+	}
+	var keys []string
+	for _, key := range sortedKeys(paths) {
+		if paths[key] != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+func (f *fileConfig) targets() ([]loadtest.Target, error) {
+	targets := make([]loadtest.Target, 0, len(f.Targets))
+	for i, target := range f.Targets {
+		if target.URL == "" {
+			return nil, fmt.Errorf("targets[%d]: url é obrigatória", i)
+		}
+		if target.Weight < 0 {
+			return nil, fmt.Errorf("targets[%d]: peso não pode ser negativo", i)
+		}
+		if target.Weight == 0 {
+			target.Weight = 1
+		}
+		targets = append(targets, loadtest.Target{URL: target.URL, Weight: target.Weight})
+	}
+	return targets, nil
+}
+
+// applyTo copies the file values into config, skipping every field whose
+// flag was explicitly set on the command line.
+func (f *fileConfig) applyTo(config *Config, set map[string]bool, body, bodyFile *string) error {
+	if !anySet(set, requestSourceFlags) {
+		targets, err := f.targets()
+		if err != nil {
+			return err
+		}
+		config.Targets = targets
+	}
+	if !set["name"] && f.Name != "" {
+		config.Name = f.Name
+	}
+	if !set["max-redirects"] && !set["no-follow-redirects"] && f.MaxRedirects != nil {
+		config.MaxRedirect = *f.MaxRedirects
+	}
+	if !set["max-idle-conns"] && f.MaxIdleConns != 0 {
+		config.Limits.MaxIdleConns = f.MaxIdleConns
+	}
+	if !set["max-idle-conns-per-host"] && f.MaxIdlePerHost != 0 {
+		config.Limits.MaxIdleConnsPerHost = f.MaxIdlePerHost
+	}
+	if !set["max-conns-per-host"] && f.MaxHostConns != 0 {
+		config.Limits.MaxConnsPerHost = f.MaxHostConns
+	}
+	if !set["workers-per-client"] && f.PerClient != 0 {
+		config.PerClient = f.PerClient
+	}
+	if !set["raise-nofile"] && f.RaiseNofile {
+		config.RaiseLimit = true
+	}
+	if !set["script"] && f.Script != "" {
+		config.Script = f.Script
+	}
+	if !set["preflight"] && f.Preflight {
+		config.Preflight = true
+	}
+	if !set["proxy-from-env"] && f.ProxyFromEnv {
+		config.ProxyEnv = true
+	}
+	if !set["dns-server"] && f.DNSServer != "" {
+		config.DNSServer = f.DNSServer
+	}
+	if !set["unix-socket"] && f.UnixSocket != "" {
+		config.UnixSocket = f.UnixSocket
+	}
+	if !anySet(set, requestSourceFlags) {
+		config.HAR = f.HAR
+		config.Postman.Collection = f.Postman.Collection
+		config.OpenAPI.Spec = f.OpenAPI.Spec
+	}
+	if !set["openapi-server"] && f.OpenAPI.Server != "" {
+		config.OpenAPI.Server = f.OpenAPI.Server
+	}
+	if !set["postman-env"] && f.Postman.Environment != "" {
+		config.Postman.Environment = f.Postman.Environment
+	}
+	if !set["postman-folder"] && f.Postman.Folder != "" {
+		config.Postman.Folder = f.Postman.Folder
+	}
+	if !set["postman-mode"] && f.Postman.Mode != "" {
+		config.Postman.Mode = f.Postman.Mode
+	}
+	if !set["respect-timing"] && f.RespectTiming {
+		config.Timing = true
+	}
+	if !set["enable-cookies"] && f.EnableCookies {
+		config.Cookies = true
+	}
+	if !set["conditional-requests"] && f.Conditional {
+		config.Conditional = true
+	}
+	if !set["body-chunked"] && f.BodyChunked {
+		config.Chunked = true
+	}
+	if !set["sample-failures"] && f.SampleFailures != 0 {
+		config.Samples = f.SampleFailures
+	}
+	if !set["save-bodies"] && f.SaveBodies != "" {
+		config.SaveBodies = f.SaveBodies
+	}
+	if !set["save-bodies-rate"] && f.SaveRate != 0 {
+		config.SaveRate = f.SaveRate
+	}
+	if !set["data"] && f.Data != "" {
+		config.Data = f.Data
+	}
+	if !set["data-mode"] && f.DataMode != "" {
+		config.DataMode = f.DataMode
+	}
+	if !set["method"] && f.Method != "" {
+		config.Method = f.Method
+	}
+	for key, value := range f.Headers {
+		if config.Headers.Get(key) == "" {
+			config.Headers.Set(key, value)
+		}
+	}
+	if !set["body"] && !set["body-file"] {
+		*body, *bodyFile = f.Body, f.BodyFile
+	}
+	if !set["content-type"] && f.ContentType != "" {
+		config.ContentType = f.ContentType
+	}
+	if !set["requests"] && f.Requests != 0 {
+		config.Requests = f.Requests
+	}
+	if !set["concurrency"] && f.Concurrency != 0 {
+		config.Concurrency = f.Concurrency
+	}
+	if !set["duration"] && f.Duration != 0 {
+		config.Duration = f.Duration
+	}
+	if !set["max-duration"] && f.MaxDuration != 0 {
+		config.MaxDuration = f.MaxDuration
+	}
+	if !set["rate"] && f.Rate != 0 {
+		config.Rate = f.Rate
+	}
+	if !set["ramp-up"] && f.RampUp != 0 {
+		config.RampUp = f.RampUp
+	}
+	if !set["preset"] && f.Preset != "" {
+		config.Preset = f.Preset
+	}
+	if !set["port-exhaustion"] && f.PortExhaustion != "" {
+		config.PortExhaust = f.PortExhaustion
+	}
+	if !set["executor"] && f.Executor != "" {
+		config.Executor = f.Executor
+	}
+	if !set["max-vus"] && f.MaxVUs != 0 {
+		config.MaxVUs = f.MaxVUs
+	}
+	if !set["warmup"] && f.Warmup != 0 {
+		config.Warmup = f.Warmup
+	}
+	if !set["warmup-requests"] && f.WarmupRequests != 0 {
+		config.WarmupReqs = f.WarmupRequests
+	}
+	if !set["think-time"] && f.ThinkTime != 0 {
+		config.ThinkTime = f.ThinkTime
+	}
+	if !set["think-time-jitter"] && f.ThinkJitter != 0 {
+		config.ThinkJitter = f.ThinkJitter
+	}
+	if !set["timeout"] && f.Timeout != 0 {
+		config.Timeout = f.Timeout
+	}
+	if !set["latency-buckets"] && len(f.LatencyBuckets) > 0 {
+		config.Buckets = f.LatencyBuckets
+	}
+	if !set["timeline-interval"] && f.Resolution != 0 {
+		config.Resolution = f.Resolution
+	}
+	if !set["basic-auth"] && !set["bearer-token"] && !set["oauth2-token-url"] {
+		config.BasicAuth, config.BearerToken = f.Auth.Basic, f.Auth.BearerToken
+		config.OAuth2.TokenURL = f.Auth.OAuth2.TokenURL
+	}
+	if oauth2 := f.Auth.OAuth2; oauth2.TokenURL != "" {
+		if !set["oauth2-client-id"] {
+			config.OAuth2.ClientID = oauth2.ClientID
+		}
+		if !set["oauth2-client-secret"] {
+			config.OAuth2.ClientSecret = oauth2.ClientSecret
+		}
+		if !set["oauth2-scopes"] {
+			config.OAuth2.Scopes = oauth2.Scopes
+		}
+	}
+	if sigv4 := f.Auth.AWSSigV4; sigv4 != nil && !set["aws-sigv4"] {
+		config.SigV4.Enabled = true
+		if !set["aws-region"] {
+			config.SigV4.Region = sigv4.Region
+		}
+		if !set["aws-service"] && sigv4.Service != "" {
+			config.SigV4.Service = sigv4.Service
+		}
+	}
+	if !set["api-key-header"] && f.Auth.APIKeyHeader != "" {
+		config.APIKey = f.Auth.APIKeyHeader
+	}
+	if !set["insecure"] && f.TLS.Insecure {
+		config.Insecure = true
+	}
+	if !set["ca-cert"] && f.TLS.CACert != "" {
+		config.CACert = f.TLS.CACert
+	}
+	if !set["client-cert"] && !set["client-key"] && f.TLS.ClientCert != "" {
+		config.ClientCert, config.ClientKey = f.TLS.ClientCert, f.TLS.ClientKey
+	}
+	if !set["assert-body-contains"] {
+		config.AssertContains = append(config.AssertContains, f.Assertions.BodyContains...)
+	}
+	if !set["assert-body-regex"] {
+		config.AssertRegex = append(config.AssertRegex, f.Assertions.BodyRegex...)
+	}
+	if !set["assert-json-path"] {
+		config.AssertJSONPath = append(config.AssertJSONPath, f.Assertions.JSONPath...)
+	}
+	if !set["output"] && f.Output != "" {
+		config.Output = f.Output
+	}
+	if !set["output-file"] && f.OutputFile != "" {
+		config.OutputFile = f.OutputFile
+	}
+	if !set["output-raw"] && f.OutputRaw != "" {
+		config.OutputRaw = f.OutputRaw
+	}
+	if !set["metrics-listen"] && f.MetricsListen != "" {
+		config.MetricsAddr = f.MetricsListen
+	}
+	if !set["statsd"] && f.StatsD != "" {
+		config.StatsD = f.StatsD
+	}
+	if influx := f.InfluxDB; !set["influxdb-url"] && influx.URL != "" {
+		config.Influx.URL, config.Influx.Database, config.Influx.Org, config.Influx.Bucket = influx.URL, influx.Database, influx.Org, influx.Bucket
+		if !set["influxdb-token"] {
+			config.Influx.Token = influx.Token
+		}
+		if !set["influxdb-per-second"] {
+			config.Influx.Aggregate = influx.PerSecond
+		}
+	}
+	if !set["agent-token"] && f.AgentToken != "" {
+		config.AgentToken = f.AgentToken
+	}
+	if !set["trace-propagation"] && f.Tracing.Propagation {
+		config.Tracing = true
+	}
+	if !set["otlp-endpoint"] && f.Tracing.OTLPEndpoint != "" {
+		config.OTLP = f.Tracing.OTLPEndpoint
+	}
+	if !set["otel-service-name"] && f.Tracing.ServiceName != "" {
+		config.OTelService = f.Tracing.ServiceName
+	}
+	if len(f.InfluxDB.Tags) > 0 {
+		config.Influx.Tags = f.InfluxDB.Tags
+	}
+	if !set["report-html"] && f.ReportHTML != "" {
+		config.ReportHTML = f.ReportHTML
+	}
+	if !set["compare"] && f.Compare.Baseline != "" {
+		config.Compare = f.Compare.Baseline
+	}
+	if !set["max-regression"] && f.Compare.MaxRegression != 0 {
+		config.MaxRegression = f.Compare.MaxRegression
+	}
+	if !set["max-error-rate-increase"] && f.Compare.MaxErrorIncrease != 0 {
+		config.MaxErrorDelta = f.Compare.MaxErrorIncrease
+	}
+	if !set["store"] && f.Store != "" {
+		config.Store = f.Store
+	}
+	if !set["notify-webhook"] && f.NotifyWebhook != "" {
+		config.Notify = f.NotifyWebhook
+	}
+	if !set["summary"] && f.Summary != "" {
+		config.Summary = f.Summary
+	}
+	if !set["progress-interval"] && f.Progress != 0 {
+		config.Progress = f.Progress
+	}
+	if !set["quiet"] && f.Quiet {
+		config.Quiet = true
+	}
+	if !set["verbose"] && f.Verbose {
+		config.Verbose = true
+	}
+	if !set["log-format"] && f.LogFormat != "" {
+		config.LogFormat = f.LogFormat
+	}
+	return nil
+}
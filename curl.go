@@ -0,0 +1,429 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"stress-test/pkg/loadtest"
+)
+
+// curlCommand is the request described by a curl command line.
+type curlCommand struct {
+	Method   string
+	URL      string
+	Headers  http.Header
+	Body     []byte
+	User     string
+	Insecure bool
+}
+
+// curlIgnoredFlags only change how curl prints or retries, so they are
+// accepted and ignored. The value tells whether the flag takes an argument.
+var curlIgnoredFlags = map[string]bool{
+	"-s": false, "--silent": false,
+	"-S": false, "--show-error": false,
+	"-v": false, "--verbose": false,
+	"-i": false, "--include": false,
+	"-L": false, "--location": false,
+	"-f": false, "--fail": false,
+	"-g": false, "--globoff": false,
+	"-N": false, "--no-buffer": false,
+	"--compressed":      false,
+	"--http1.1":         false,
+	"--http2":           false,
+	"-o":                true,
+	"--output":          true,
+	"-w":                true,
+	"--write-out":       true,
+	"-m":                true,
+	"--max-time":        true,
+	"--connect-timeout": true,
+	"--retry":           true,
+}
+
+// curlValueFlags are the supported flags that take an argument.
+var curlValueFlags = map[string]bool{
+	"-X": true, "--request": true,
+	"-H": true, "--header": true,
+	"-d": true, "--data": true, "--data-ascii": true, "--data-binary": true, "--data-raw": true, "--data-urlencode": true, "--json": true,
+	"-u": true, "--user": true,
+	"-A": true, "--user-agent": true,
+	"-e": true, "--referer": true,
+	"-b": true, "--cookie": true,
+	"--url": true,
+}
+
+func curlTakesValue(flag string) bool {
+	return curlValueFlags[flag] || curlIgnoredFlags[flag]
+}
+
+// loadCurlCommands accepts either a curl command or the path of a file with
+// one command per line; lines can be continued with a trailing backslash.
+func loadCurlCommands(value string) ([]*curlCommand, error) {
+	if fields := strings.Fields(value); len(fields) > 0 && fields[0] == "curl" {
+		command, err := parseCurl(value)
+		if err != nil {
+			return nil, err
+		}
+		return []*curlCommand{command}, nil
+	}
+
+	data, err := os.ReadFile(value)
+	if err != nil {
+		return nil, err
+	}
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\\\n", " ")
+	var commands []*curlCommand
+	for i, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		command, err := parseCurl(line)
+		if err != nil {
+			return nil, fmt.Errorf("comando %d: %w", i+1, err)
+		}
+		commands = append(commands, command)
+	}
+	if len(commands) == 0 {
+		return nil, fmt.Errorf("nenhum comando curl encontrado")
+	}
+	return commands, nil
+}
+
+func parseCurl(command string) (*curlCommand, error) {
+	args, err := splitShellWords(command)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) == 0 || args[0] != "curl" {
+		return nil, fmt.Errorf("o comando deve começar com curl")
+	}
+
+	args = expandCurlShortFlags(args[1:])
+	c := &curlCommand{Headers: make(http.Header)}
+	var data []string
+	var get, head bool
+	for i := 0; i < len(args); i++ {
+		name := args[i]
+		next := func() (string, error) {
+			if i+1 >= len(args) {
+				return "", fmt.Errorf("opção %s sem valor", name)
+			}
+			i++
+			return args[i], nil
+		}
+
+		if !strings.HasPrefix(name, "-") || name == "-" {
+			if c.URL != "" {
+				return nil, fmt.Errorf("mais de uma URL no comando: %s e %s", c.URL, name)
+			}
+			c.URL = name
+			continue
+		}
+		if takesValue, ok := curlIgnoredFlags[name]; ok {
+			if takesValue {
+				if _, err := next(); err != nil {
+					return nil, err
+				}
+			}
+			continue
+		}
+
+		switch name {
+		case "-X", "--request":
+			if c.Method, err = next(); err != nil {
+				return nil, err
+			}
+		case "-H", "--header":
+			header, err := next()
+			if err != nil {
+				return nil, err
+			}
+			key, val, ok := strings.Cut(header, ":")
+			if !ok || strings.TrimSpace(key) == "" {
+				return nil, fmt.Errorf("header inválido: %q", header)
+			}
+			c.Headers.Add(strings.TrimSpace(key), strings.TrimSpace(val))
+		case "-d", "--data", "--data-ascii", "--data-binary", "--data-raw", "--data-urlencode", "--json":
+			value, err := next()
+			if err != nil {
+				return nil, err
+			}
+			if strings.HasPrefix(value, "@") && name != "--data-raw" && name != "--data-urlencode" {
+				content, err := os.ReadFile(value[1:])
+				if err != nil {
+					return nil, err
+				}
+				value = string(content)
+			}
+			if name == "--data-urlencode" {
+				value = curlURLEncode(value)
+			}
+			if name == "--json" {
+				setDefaultHeader(c.Headers, "Content-Type", "application/json")
+				setDefaultHeader(c.Headers, "Accept", "application/json")
+			}
+			data = append(data, value)
+		case "-u", "--user":
+			if c.User, err = next(); err != nil {
+				return nil, err
+			}
+		case "-A", "--user-agent":
+			agent, err := next()
+			if err != nil {
+				return nil, err
+			}
+			c.Headers.Set("User-Agent", agent)
+		case "-e", "--referer":
+			referer, err := next()
+			if err != nil {
+				return nil, err
+			}
+			c.Headers.Set("Referer", referer)
+		case "-b", "--cookie":
+			cookie, err := next()
+			if err != nil {
+				return nil, err
+			}
+			if !strings.Contains(cookie, "=") {
+				return nil, fmt.Errorf("arquivos de cookies (%s %s) não são suportados", name, cookie)
+			}
+			c.Headers.Add("Cookie", cookie)
+		case "--url":
+			if c.URL, err = next(); err != nil {
+				return nil, err
+			}
+		case "-k", "--insecure":
+			c.Insecure = true
+		case "-G", "--get":
+			get = true
+		case "-I", "--head":
+			head = true
+		default:
+			return nil, fmt.Errorf("opção do curl não suportada: %s", name)
+		}
+	}
+
+	if c.URL == "" {
+		return nil, fmt.Errorf("URL não encontrada no comando")
+	}
+	if !strings.Contains(c.URL, "://") {
+		c.URL = "http://" + c.URL
+	}
+	body := strings.Join(data, "&")
+	switch {
+	case get && body != "":
+		separator := "?"
+		if strings.Contains(c.URL, "?") {
+			separator = "&"
+		}
+		c.URL += separator + body
+	case body != "":
+		c.Body = []byte(body)
+		setDefaultHeader(c.Headers, "Content-Type", "application/x-www-form-urlencoded")
+		if c.Method == "" {
+			c.Method = http.MethodPost
+		}
+	}
+	if head && c.Method == "" {
+		c.Method = http.MethodHead
+	}
+	if c.Method == "" {
+		c.Method = http.MethodGet
+	}
+	c.Method = strings.ToUpper(c.Method)
+	if !isValidMethod(c.Method) {
+		return nil, fmt.Errorf("método inválido: %q", c.Method)
+	}
+	return c, nil
+}
+
+// expandCurlShortFlags splits combined short flags (-sSL) and short flags
+// glued to their value (-XPOST) into separate arguments.
+func expandCurlShortFlags(args []string) []string {
+	expanded := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if len(arg) <= 2 || arg[0] != '-' || arg[1] == '-' {
+			expanded = append(expanded, arg)
+			if curlTakesValue(arg) && i+1 < len(args) {
+				i++
+				expanded = append(expanded, args[i])
+			}
+			continue
+		}
+		for j := 1; j < len(arg); j++ {
+			flag := "-" + arg[j:j+1]
+			expanded = append(expanded, flag)
+			if curlTakesValue(flag) {
+				if j+1 < len(arg) {
+					expanded = append(expanded, arg[j+1:])
+				} else if i+1 < len(args) {
+					i++
+					expanded = append(expanded, args[i])
+				}
+				break
+			}
+		}
+	}
+	return expanded
+}
+
+func setDefaultHeader(headers http.Header, key, value string) {
+	if headers.Get(key) == "" {
+		headers.Set(key, value)
+	}
+}
+
+// curlURLEncode encodes the content of --data-urlencode, which is either
+// "content" or "name=content".
+func curlURLEncode(value string) string {
+	name, content, ok := strings.Cut(value, "=")
+	if !ok {
+		return url.QueryEscape(value)
+	}
+	if name == "" {
+		return url.QueryEscape(content)
+	}
+	return name + "=" + url.QueryEscape(content)
+}
+
+// step turns the command into a scenario step, with the credentials of -u
+// in the Authorization header.
+func (c *curlCommand) step() loadtest.Step {
+	step := loadtest.Step{Method: c.Method, URL: c.URL, Headers: c.Headers.Clone(), Body: c.Body}
+	if c.User != "" {
+		step.Headers.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(c.User)))
+	}
+	return step
+}
+
+// splitShellWords splits a command line like a POSIX shell would, including
+// the $'...' quoting used by the "Copy as cURL" of browsers.
+func splitShellWords(s string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		switch {
+		case ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		case ch == '\\':
+			inWord = true
+			if i+1 < len(s) {
+				i++
+				if s[i] != '\n' {
+					word.WriteByte(s[i])
+				}
+			}
+		case ch == '\'':
+			inWord = true
+			end := strings.IndexByte(s[i+1:], '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("aspas simples não fechadas")
+			}
+			word.WriteString(s[i+1 : i+1+end])
+			i += end + 1
+		case ch == '$' && i+1 < len(s) && s[i+1] == '\'':
+			inWord = true
+			i += 2
+			for ; i < len(s) && s[i] != '\''; i++ {
+				if s[i] == '\\' && i+1 < len(s) {
+					i++
+					switch s[i] {
+					case 'n':
+						word.WriteByte('\n')
+					case 't':
+						word.WriteByte('\t')
+					case 'r':
+						word.WriteByte('\r')
+					default:
+						word.WriteByte(s[i])
+					}
+					continue
+				}
+				word.WriteByte(s[i])
+			}
+			if i >= len(s) {
+				return nil, fmt.Errorf("aspas simples não fechadas")
+			}
+		case ch == '"':
+			inWord = true
+			i++
+			for ; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' && i+1 < len(s) && strings.IndexByte("\"\\$`\n", s[i+1]) >= 0 {
+					i++
+					if s[i] == '\n' {
+						continue
+					}
+				}
+				word.WriteByte(s[i])
+			}
+			if i >= len(s) {
+				return nil, fmt.Errorf("aspas duplas não fechadas")
+			}
+		default:
+			inWord = true
+			word.WriteByte(ch)
+		}
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+// applyCurlCommands makes a single command the target of the test, with
+// flags taking precedence over its method, headers, body and credentials.
+// Several commands become the steps of a scenario.
+func applyCurlCommands(config *Config, set map[string]bool, commands []*curlCommand, body *string, bodyFile string) {
+	for _, command := range commands {
+		config.Insecure = config.Insecure || command.Insecure
+	}
+	if len(commands) > 1 {
+		scenario := &loadtest.Scenario{Name: "curl"}
+		names := make(map[string]int)
+		for _, command := range commands {
+			step := command.step()
+			step.Name = step.Method + " " + step.URL
+			if names[step.Name]++; names[step.Name] > 1 {
+				step.Name = fmt.Sprintf("%s (%d)", step.Name, names[step.Name])
+			}
+			scenario.Steps = append(scenario.Steps, step)
+		}
+		config.Scenario = scenario
+		return
+	}
+
+	command := commands[0]
+	config.Targets = []loadtest.Target{{URL: command.URL, Weight: 1}}
+	if !set["method"] {
+		config.Method = command.Method
+	}
+	if contentType := command.Headers.Get("Content-Type"); contentType != "" && config.ContentType == "" {
+		config.ContentType = contentType
+		command.Headers.Del("Content-Type")
+	}
+	for key, values := range command.Headers {
+		if _, ok := config.Headers[key]; !ok {
+			config.Headers[key] = values
+		}
+	}
+	if *body == "" && bodyFile == "" {
+		*body = string(command.Body)
+	}
+	if config.BasicAuth == "" {
+		config.BasicAuth = command.User
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"stress-test/pkg/loadtest"
+)
+
+const (
+	dashboardHistory = 60
+	dashboardWindow  = 5 * time.Second
+)
+
+var sparkTicks = []rune("▁▂▃▄▅▆▇█")
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+type sample struct {
+	at       time.Time
+	duration time.Duration
+}
+
+type dashboard struct {
+	mu sync.Mutex
+
+	w        io.Writer
+	runner   *loadtest.Runner
+	total    int
+	start    time.Time
+	done     chan struct{}
+	stopped  chan struct{}
+	complete int
+	errors   int
+	statuses map[int]int
+	second   int
+	rps      []int
+	recent   []sample
+}
+
+func newDashboard(w io.Writer, total int) *dashboard {
+	return &dashboard{
+		w:        w,
+		total:    total,
+		done:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+		statuses: make(map[int]int),
+	}
+}
+
+func (d *dashboard) record(result loadtest.Result) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := time.Now()
+	if result.LastStep {
+		d.complete++
+	}
+	d.second++
+	if result.Error != nil {
+		d.errors++
+		return
+	}
+	d.statuses[result.StatusCode]++
+	d.recent = append(d.recent, sample{at: now, duration: result.Duration})
+}
+
+func (d *dashboard) run(runner *loadtest.Runner) {
+	d.runner = runner
+	d.start = time.Now()
+
+	go func() {
+		defer close(d.stopped)
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-d.done:
+				d.render()
+				return
+			case <-ticker.C:
+				d.tick()
+				d.render()
+			}
+		}
+	}()
+}
+
+func (d *dashboard) stop() {
+	close(d.done)
+	<-d.stopped
+}
+
+func (d *dashboard) tick() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.rps = append(d.rps, d.second)
+	if len(d.rps) > dashboardHistory {
+		d.rps = d.rps[1:]
+	}
+	d.second = 0
+
+	cutoff := time.Now().Add(-dashboardWindow)
+	i := sort.Search(len(d.recent), func(i int) bool { return d.recent[i].at.After(cutoff) })
+	d.recent = append(d.recent[:0], d.recent[i:]...)
+}
+
+func (d *dashboard) render() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var b strings.Builder
+	b.WriteString("\033[H\033[2J")
+	b.WriteString("STRESS TEST - PAINEL AO VIVO\n")
+	b.WriteString(strings.Repeat("=", 50) + "\n")
+
+	elapsed := time.Since(d.start).Truncate(time.Second)
+	if d.total > 0 {
+		fmt.Fprintf(&b, "Tempo: %v | Concluídos: %d/%d (%.1f%%)\n", elapsed, d.complete, d.total, float64(d.complete)/float64(d.total)*100)
+	} else {
+		fmt.Fprintf(&b, "Tempo: %v | Concluídos: %d\n", elapsed, d.complete)
+	}
+	gauges := d.runner.Gauges()
+	fmt.Fprintf(&b, "Workers: %d/%d | Em andamento: %d (aguardando conexão: %d) | Na fila: %d\n",
+		gauges.Workers, d.runner.Concurrency(), gauges.InFlight, gauges.WaitingConn, gauges.Queued)
+
+	current := 0
+	if len(d.rps) > 0 {
+		current = d.rps[len(d.rps)-1]
+	}
+	fmt.Fprintf(&b, "\nRPS (último segundo): %d\n", current)
+	fmt.Fprintf(&b, "  %s\n", sparkline(d.rps))
+
+	durations := make([]time.Duration, len(d.recent))
+	for i, s := range d.recent {
+		durations[i] = s.duration
+	}
+	stats := loadtest.ComputeLatencyStats(durations)
+	fmt.Fprintf(&b, "\nLatência (últimos %v): p50 %v | p95 %v | p99 %v\n", dashboardWindow, stats.P50, stats.P95, stats.P99)
+
+	b.WriteString("\nCódigos de status:\n")
+	codes := make([]int, 0, len(d.statuses))
+	for code := range d.statuses {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	for _, code := range codes {
+		fmt.Fprintf(&b, "  %d: %d\n", code, d.statuses[code])
+	}
+	if d.errors > 0 {
+		fmt.Fprintf(&b, "  Errors: %d\n", d.errors)
+	}
+
+	io.WriteString(d.w, b.String())
+}
+
+func sparkline(values []int) string {
+	largest := 0
+	for _, v := range values {
+		if v > largest {
+			largest = v
+		}
+	}
+
+	var b strings.Builder
+	for _, v := range values {
+		i := 0
+		if largest > 0 {
+			i = v * (len(sparkTicks) - 1) / largest
+		}
+		b.WriteRune(sparkTicks[i])
+	}
+	return b.String()
+}
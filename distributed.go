@@ -0,0 +1,475 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"stress-test/pkg/loadtest"
+)
+
+// agentMaxDuration bounds the runs whose end is only decided by the
+// controller, in case it never cancels them.
+const agentMaxDuration = 24 * time.Hour
+
+// controllerFlags are handled by the controller itself and never forwarded
+// to the agents, either because they only affect the local output or because
+// the controller sends a per-agent value.
+var controllerFlags = map[string]bool{
+	"agents": true, "agent-token": true, "ui": true,
+	"output": true, "output-file": true, "output-raw": true, "report-html": true, "fail-if": true,
+	"metrics-listen": true, "statsd": true, "statsd-tags": true, "otlp-endpoint": true, "otel-service-name": true,
+	"influxdb-url": true, "influxdb-db": true, "influxdb-org": true, "influxdb-bucket": true,
+	"influxdb-token": true, "influxdb-per-second": true, "influxdb-tags": true,
+	"requests": true, "concurrency": true, "rate": true, "duration": true, "warmup": true, "warmup-requests": true,
+}
+
+// remoteFileFlags read or write files on the machine that runs the test, so
+// an agent only accepts them from a controller when started with
+// --allow-files.
+var remoteFileFlags = map[string]bool{
+	"config": true, "targets-file": true, "scenario": true, "from-curl": true, "har": true,
+	"postman": true, "postman-env": true, "openapi": true, "data": true, "body-file": true, "form-file": true,
+	"ca-cert": true, "client-cert": true, "client-key": true, "script": true, "save-bodies": true,
+	"compare": true, "store": true, "output-file": true, "output-raw": true, "report-html": true,
+}
+
+type agentJob struct {
+	Args []string `json:"args"`
+}
+
+// wireResult is the JSON form of a loadtest.Result streamed by an agent.
+type wireResult struct {
+	Target         string                  `json:"target"`
+	Method         string                  `json:"method,omitempty"`
+	Step           string                  `json:"step,omitempty"`
+	LastStep       bool                    `json:"last_step"`
+	Start          time.Time               `json:"start"`
+	Intended       time.Time               `json:"intended"`
+	StatusCode     int                     `json:"status"`
+	GRPCStatus     string                  `json:"grpc_status,omitempty"`
+	Attempts       int                     `json:"attempts,omitempty"`
+	Proto          string                  `json:"proto,omitempty"`
+	Duration       time.Duration           `json:"duration"`
+	Redirects      int                     `json:"redirects,omitempty"`
+	Bytes          int64                   `json:"bytes"`
+	BytesSent      int64                   `json:"bytes_sent,omitempty"`
+	ConnReused     bool                    `json:"conn_reused,omitempty"`
+	NewConn        bool                    `json:"new_conn,omitempty"`
+	Phases         loadtest.Phases         `json:"phases"`
+	ErrorCategory  string                  `json:"error_category,omitempty"`
+	Error          string                  `json:"error,omitempty"`
+	Assertion      string                  `json:"assertion,omitempty"`
+	AssertionError string                  `json:"assertion_error,omitempty"`
+	TraceID        string                  `json:"trace_id,omitempty"`
+	SpanID         string                  `json:"span_id,omitempty"`
+	Worker         int                     `json:"worker"`
+	Conditional    bool                    `json:"conditional,omitempty"`
+	IPFamily       loadtest.IPFamily       `json:"ip_family,omitempty"`
+	Metrics        map[string]float64      `json:"metrics,omitempty"`
+	SavedBody      string                  `json:"saved_body,omitempty"`
+	Sample         *loadtest.FailureSample `json:"sample,omitempty"`
+}
+
+func newWireResult(result loadtest.Result) wireResult {
+	w := wireResult{
+		Target:      result.Target,
+		Method:      result.Method,
+		Step:        result.Step,
+		LastStep:    result.LastStep,
+		Start:       result.Start,
+		Intended:    result.Intended,
+		StatusCode:  result.StatusCode,
+		GRPCStatus:  result.GRPCStatus,
+		Attempts:    result.Attempts,
+		Proto:       result.Proto,
+		Duration:    result.Duration,
+		Redirects:   result.Redirects,
+		Bytes:       result.Bytes,
+		BytesSent:   result.BytesSent,
+		ConnReused:  result.ConnReused,
+		NewConn:     result.NewConn,
+		Phases:      result.Phases,
+		TraceID:     result.TraceID,
+		SpanID:      result.SpanID,
+		Worker:      result.Worker,
+		Metrics:     result.Metrics,
+		Conditional: result.Conditional,
+		IPFamily:    result.IPFamily,
+		SavedBody:   result.SavedBody,
+		Sample:      result.Sample,
+	}
+	if result.Error != nil {
+		w.ErrorCategory, w.Error = loadtest.ClassifyError(result.Error), result.Error.Error()
+	}
+	if result.AssertionError != nil {
+		w.Assertion, w.AssertionError = result.AssertionError.Assertion, result.AssertionError.Err.Error()
+	}
+	return w
+}
+
+func (w wireResult) result() loadtest.Result {
+	result := loadtest.Result{
+		Target:      w.Target,
+		Method:      w.Method,
+		Step:        w.Step,
+		LastStep:    w.LastStep,
+		Start:       w.Start,
+		Intended:    w.Intended,
+		StatusCode:  w.StatusCode,
+		GRPCStatus:  w.GRPCStatus,
+		Attempts:    w.Attempts,
+		Proto:       w.Proto,
+		Duration:    w.Duration,
+		Redirects:   w.Redirects,
+		Bytes:       w.Bytes,
+		BytesSent:   w.BytesSent,
+		ConnReused:  w.ConnReused,
+		NewConn:     w.NewConn,
+		Phases:      w.Phases,
+		TraceID:     w.TraceID,
+		SpanID:      w.SpanID,
+		Worker:      w.Worker,
+		Metrics:     w.Metrics,
+		Conditional: w.Conditional,
+		IPFamily:    w.IPFamily,
+		SavedBody:   w.SavedBody,
+		Sample:      w.Sample,
+	}
+	if w.ErrorCategory != "" {
+		result.Error = &loadtest.RemoteError{Category: w.ErrorCategory, Message: w.Error}
+	}
+	if w.Assertion != "" {
+		result.AssertionError = &loadtest.AssertionError{Assertion: w.Assertion, Err: errors.New(w.AssertionError)}
+	}
+	return result
+}
+
+// share splits total between n parts, giving the remainder to the first ones.
+func share(total, n, i int) int {
+	s := total / n
+	if i < total%n {
+		s++
+	}
+	return s
+}
+
+// forwardArgs returns args without the flags in skip, keeping the values of
+// non-boolean flags passed as a separate argument.
+func forwardArgs(fs *flag.FlagSet, args []string, skip map[string]bool) []string {
+	var out []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		name := strings.TrimLeft(arg, "-")
+		if !strings.HasPrefix(arg, "-") || name == "" {
+			out = append(out, arg)
+			continue
+		}
+		name, _, hasValue := strings.Cut(name, "=")
+		takesValue := false
+		if f := fs.Lookup(name); f != nil && !hasValue {
+			boolFlag, ok := f.Value.(interface{ IsBoolFlag() bool })
+			takesValue = !ok || !boolFlag.IsBoolFlag()
+		}
+		if skip[name] {
+			if takesValue {
+				i++
+			}
+			continue
+		}
+		out = append(out, arg)
+		if takesValue && i+1 < len(args) {
+			i++
+			out = append(out, args[i])
+		}
+	}
+	return out
+}
+
+// agentPool runs a share of the test on every agent and streams their
+// results back to the controller's runner.
+type agentPool struct {
+	agents []string
+	token  string
+	jobs   [][]string
+	client *http.Client
+	// offsets shift the worker indexes of every agent so that they don't
+	// collide in the controller's report.
+	offsets []int
+}
+
+func newAgentPool(config *Config, runner *loadtest.Runner, args []string) (*agentPool, error) {
+	n := len(config.Agents)
+	concurrency := runner.Concurrency()
+	if concurrency < n {
+		return nil, fmt.Errorf("concorrência (%d) menor que o número de agentes (%d)", concurrency, n)
+	}
+
+	budget := runner.Budget()
+	duration := time.Duration(0)
+	switch {
+	case config.Duration > 0 && config.WarmupReqs == 0:
+		duration = config.Warmup + config.Duration
+	case budget == 0:
+		duration = agentMaxDuration
+	}
+
+	base := forwardArgs(flag.CommandLine, args, controllerFlags)
+	pool := &agentPool{agents: config.Agents, token: config.AgentToken, client: &http.Client{}}
+	offset := 0
+	for i := range config.Agents {
+		pool.offsets = append(pool.offsets, offset)
+		offset += max(share(concurrency, n, i), config.MaxVUs)
+		job := append(append([]string(nil), base...),
+			"--concurrency="+strconv.Itoa(share(concurrency, n, i)),
+			"--requests="+strconv.Itoa(share(budget, n, i)),
+			"--duration="+duration.String(),
+			"--rate="+strconv.FormatFloat(config.Rate/float64(n), 'f', -1, 64),
+			"--warmup=0s", "--warmup-requests=0",
+		)
+		if config.Tracing {
+			job = append(job, "--trace-propagation")
+		}
+		pool.jobs = append(pool.jobs, job)
+	}
+	return pool, nil
+}
+
+// produce stops every agent as soon as one of them fails.
+func (p *agentPool) produce(ctx context.Context, results chan<- loadtest.Result) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	errs := make(chan error, len(p.agents))
+	var wg sync.WaitGroup
+	for i, agent := range p.agents {
+		wg.Add(1)
+		go func(agent string, job []string, offset int) {
+			defer wg.Done()
+			if err := p.run(ctx, agent, job, offset, results); err != nil {
+				errs <- fmt.Errorf("agente %s: %w", agent, err)
+				cancel()
+			}
+		}(agent, p.jobs[i], p.offsets[i])
+	}
+	wg.Wait()
+	close(errs)
+	return <-errs
+}
+
+func (p *agentPool) run(ctx context.Context, agent string, args []string, offset int, results chan<- loadtest.Result) error {
+	body, err := json.Marshal(agentJob{Args: args})
+	if err != nil {
+		return err
+	}
+	endpoint := agent
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "http://" + endpoint
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(endpoint, "/")+"/run", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if p.token != "" {
+		req.Header.Set("Authorization", "Bearer "+p.token)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("status %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+
+	decoder := json.NewDecoder(bufio.NewReader(resp.Body))
+	for {
+		var w wireResult
+		if err := decoder.Decode(&w); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		result := w.result()
+		result.Worker += offset
+		select {
+		case <-ctx.Done():
+			return nil
+		case results <- result:
+		}
+	}
+}
+
+// runAgent serves the "agent" subcommand: it waits for jobs from a
+// controller, runs them and streams every result back as NDJSON.
+func runAgent(args []string) error {
+	fs := flag.NewFlagSet("agent", flag.ExitOnError)
+	listen := fs.String("listen", ":7070", "Endereço onde o agente aguarda jobs do controlador")
+	token := fs.String("token", "", "Token exigido do controlador no header Authorization: Bearer; sem ele o agente só aceita conexões locais")
+	allowFiles := fs.Bool("allow-files", false, "Aceita dos jobs flags que leem ou gravam arquivos no agente (--body-file, --data, --config, certificados...)")
+	fs.Parse(args)
+
+	if *token == "" {
+		set := false
+		fs.Visit(func(f *flag.Flag) { set = set || f.Name == "listen" })
+		switch {
+		case !set:
+			*listen = "127.0.0.1:7070"
+		case !isLoopback(*listen):
+			return fmt.Errorf("--token é obrigatório para aceitar jobs em %s; sem token use um endereço local (ex: --listen=127.0.0.1:7070)", *listen)
+		}
+	}
+	expected := []byte("Bearer " + *token)
+
+	var busy sync.Mutex
+	http.HandleFunc("/run", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "método não permitido", http.StatusMethodNotAllowed)
+			return
+		}
+		if *token != "" && subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) != 1 {
+			http.Error(w, "token inválido", http.StatusUnauthorized)
+			return
+		}
+		if !busy.TryLock() {
+			http.Error(w, "agente ocupado com outro teste", http.StatusConflict)
+			return
+		}
+		defer busy.Unlock()
+
+		var job agentJob
+		if err := json.NewDecoder(r.Body).Decode(&job); err != nil {
+			http.Error(w, "job inválido: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !*allowFiles {
+			if name := fileFlag(job.Args); name != "" {
+				http.Error(w, fmt.Sprintf("--%s lê ou grava arquivos no agente e só é aceito com stress-test agent --allow-files", name), http.StatusForbidden)
+				return
+			}
+		}
+		serveJob(w, r, job)
+	})
+
+	slog.Info("agente aguardando jobs", "listen", *listen)
+	return http.ListenAndServe(*listen, nil)
+}
+
+// fileFlag returns the first flag of args in remoteFileFlags, if any.
+func fileFlag(args []string) string {
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, "-") {
+			continue
+		}
+		name, _, _ := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if remoteFileFlags[name] {
+			return name
+		}
+	}
+	return ""
+}
+
+// isLoopback reports whether the listen address addr only accepts local
+// connections.
+func isLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func serveJob(w http.ResponseWriter, r *http.Request, job agentJob) {
+	fs := flag.NewFlagSet("stress-test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	config, err := parseFlags(fs, job.Args)
+	if err == nil && len(config.Agents) > 0 {
+		err = errors.New("um agente não pode distribuir o teste para outros agentes")
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// The status line is only sent with the first result, so that an error
+	// before the run starts can still be reported with a proper status.
+	var mu sync.Mutex
+	started := false
+	encoder := json.NewEncoder(w)
+	flusher, _ := w.(http.Flusher)
+	stream := func(result loadtest.Result) {
+		mu.Lock()
+		defer mu.Unlock()
+		if !started {
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			w.WriteHeader(http.StatusOK)
+			started = true
+		}
+		encoder.Encode(newWireResult(result))
+	}
+
+	runner, err := loadtest.New(append(config.options(), loadtest.WithResultHandler(stream))...)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	done := make(chan struct{})
+	if flusher != nil {
+		go func() {
+			ticker := time.NewTicker(100 * time.Millisecond)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-done:
+					return
+				case <-ticker.C:
+					mu.Lock()
+					if started {
+						flusher.Flush()
+					}
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+
+	slog.Info("job recebido", "from", r.RemoteAddr, "args", strings.Join(job.Args, " "))
+	report, err := runner.Run(r.Context())
+	close(done)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if err != nil {
+		slog.Error("falha no job", "error", err)
+		if !started {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	if !started {
+		w.WriteHeader(http.StatusOK)
+	}
+	slog.Info("job concluído", "requests", report.TotalRequests, "duration", report.TotalTime.Round(time.Millisecond))
+}
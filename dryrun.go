@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httputil"
+	"strings"
+	"time"
+
+	"stress-test/pkg/loadtest"
+)
+
+// maxDryRunBody is how much of the body of the sample request --dry-run
+// prints.
+const maxDryRunBody = 4 << 10
+
+// dryRun validates the configuration as a run would and prints its plan and
+// the first request it would send, without sending anything.
+func dryRun(w io.Writer, config *Config, options []loadtest.Option) error {
+	if config.Stdin {
+		options = append(options, loadtest.WithSource(make(chan loadtest.Step)))
+	}
+	runner, err := loadtest.New(options...)
+	if err != nil {
+		return err
+	}
+	printBanner(w, config, runner.Concurrency())
+
+	unit := "requests"
+	if config.Scenario != nil {
+		unit = "iterações"
+	}
+	switch iterations, duration := plannedLoad(config); {
+	case iterations > 0 && duration > 0:
+		fmt.Fprintf(w, "Carga prevista: ~%.0f %s em %v\n", iterations, unit, duration.Round(time.Second))
+	case iterations > 0:
+		fmt.Fprintf(w, "Carga prevista: %.0f %s, no ritmo das respostas do alvo\n", iterations, unit)
+	default:
+		fmt.Fprintf(w, "Carga prevista: %s no ritmo das respostas do alvo, sem taxa definida\n", unit)
+	}
+	if config.MaxDuration > 0 {
+		fmt.Fprintf(w, "Tempo máximo de execução: %v\n", config.MaxDuration)
+	}
+
+	req, err := runner.SampleRequest(context.Background())
+	if err != nil {
+		return fmt.Errorf("falha ao montar o request de exemplo: %w", err)
+	}
+	if req == nil {
+		fmt.Fprintln(w, "\nRequests lidos do stdin: nenhum request de exemplo")
+		return nil
+	}
+	var details []string
+	if config.Scenario != nil {
+		details = append(details, fmt.Sprintf("passo %q", config.Scenario.Steps[0].Name))
+	}
+	if config.Feeder != nil {
+		details = append(details, "linha 1 de "+config.Data)
+	}
+	fmt.Fprint(w, "\nPrimeiro request")
+	if len(details) > 0 {
+		fmt.Fprintf(w, " (%s)", strings.Join(details, ", "))
+	}
+	fmt.Fprintln(w, ":")
+	return printSampleRequest(w, config, req)
+}
+
+// plannedLoad estimates the iterations of the run and how long they take. The
+// duration is 0 when it depends on how fast the target answers, and so are the
+// iterations when a --duration isn't paced by a rate.
+func plannedLoad(config *Config) (iterations float64, duration time.Duration) {
+	limit := float64(config.Requests)
+	switch {
+	case len(config.Stages) > 0:
+		var from float64
+		for _, stage := range config.Stages {
+			seconds := stage.Duration.Seconds()
+			count := (from + stage.Rate) / 2 * seconds
+			if limit > 0 && iterations+count >= limit {
+				// The limit is reached within the stage, where the rate ramps
+				// linearly: solves from·t + a·t² = k as the schedule does.
+				k := limit - iterations
+				a := (stage.Rate - from) / (2 * seconds)
+				t := 2 * k / (from + math.Sqrt(math.Max(0, from*from+4*a*k)))
+				return limit, duration + time.Duration(t*float64(time.Second))
+			}
+			iterations += count
+			duration += stage.Duration
+			from = stage.Rate
+		}
+		return iterations, duration
+	case config.Rate > 0 && config.Duration > 0:
+		iterations = config.Rate * config.Duration.Seconds()
+		if limit > 0 && limit < iterations {
+			return limit, time.Duration(limit / config.Rate * float64(time.Second))
+		}
+		return iterations, config.Duration
+	case config.Rate > 0 && limit > 0:
+		return limit, time.Duration(limit / config.Rate * float64(time.Second))
+	}
+	return limit, 0
+}
+
+// printSampleRequest prints req as HTTP/1.1 would put it on the wire, with
+// credentials masked and the body cut at maxDryRunBody.
+func printSampleRequest(w io.Writer, config *Config, req *http.Request) error {
+	for _, name := range []string{"Authorization", "Proxy-Authorization"} {
+		scheme, credentials, ok := strings.Cut(req.Header.Get(name), " ")
+		if ok && scheme != "AWS4-HMAC-SHA256" {
+			req.Header.Set(name, scheme+" "+mask(credentials))
+		}
+	}
+	if key, _, ok := strings.Cut(config.APIKey, ":"); ok {
+		if value := req.Header.Get(strings.TrimSpace(key)); value != "" {
+			req.Header.Set(strings.TrimSpace(key), mask(value))
+		}
+	}
+	if config.OAuth2.TokenURL != "" {
+		req.Header.Set("Authorization", "Bearer (token obtido de "+config.OAuth2.TokenURL+" no início do teste)")
+	}
+
+	dump, err := httputil.DumpRequestOut(req, false)
+	if err != nil {
+		return err
+	}
+	w.Write(bytes.TrimRight(dump, "\r\n"))
+	fmt.Fprintln(w)
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil
+	}
+	defer req.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(req.Body, maxDryRunBody))
+	if err != nil {
+		return fmt.Errorf("falha ao ler o corpo: %w", err)
+	}
+	fmt.Fprintln(w)
+	if !strings.HasPrefix(http.DetectContentType(body), "text/") {
+		fmt.Fprintf(w, "(corpo binário, %s)\n", bodyLength(req.ContentLength))
+		return nil
+	}
+	w.Write(body)
+	fmt.Fprintln(w)
+	if len(body) == maxDryRunBody && req.ContentLength != maxDryRunBody {
+		fmt.Fprintf(w, "... (primeiros %d bytes de %s)\n", len(body), bodyLength(req.ContentLength))
+	}
+	return nil
+}
+
+func bodyLength(contentLength int64) string {
+	if contentLength < 0 {
+		return "tamanho desconhecido, enviado chunked"
+	}
+	return formatBytes(float64(contentLength))
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"stress-test/pkg/loadtest"
+)
+
+type headerFlags http.Header
+
+func (h headerFlags) String() string {
+	var parts []string
+	for key, values := range h {
+		for _, value := range values {
+			parts = append(parts, key+": "+value)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (h headerFlags) Set(value string) error {
+	key, val, ok := strings.Cut(value, ":")
+	key = strings.TrimSpace(key)
+	if !ok || key == "" {
+		return fmt.Errorf("header inválido %q, use o formato \"Chave: Valor\"", value)
+	}
+	http.Header(h).Add(key, strings.TrimSpace(val))
+	return nil
+}
+
+type tagFlags map[string]string
+
+func (t tagFlags) String() string {
+	parts := make([]string, 0, len(t))
+	for key, value := range t {
+		parts = append(parts, key+"="+value)
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ", ")
+}
+
+func (t tagFlags) Set(value string) error {
+	key, val, ok := strings.Cut(value, "=")
+	key = strings.TrimSpace(key)
+	if !ok || key == "" {
+		return fmt.Errorf("tag inválida %q, use o formato chave=valor", value)
+	}
+	t[key] = strings.TrimSpace(val)
+	return nil
+}
+
+type stringsFlag []string
+
+func (s *stringsFlag) String() string {
+	return strings.Join(*s, ", ")
+}
+
+func (s *stringsFlag) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+// parseResolve parses the curl syntax host:port:addr. IPv6 addresses may be
+// written between brackets.
+func parseResolve(value string) (hostPort, addr string, err error) {
+	host, rest, _ := strings.Cut(value, ":")
+	port, addr, _ := strings.Cut(rest, ":")
+	addr = strings.Trim(addr, "[]")
+	if host == "" || addr == "" {
+		return "", "", fmt.Errorf("%q, use o formato host:porta:endereço", value)
+	}
+	if _, err := strconv.Atoi(port); err != nil {
+		return "", "", fmt.Errorf("porta inválida em %q", value)
+	}
+	if net.ParseIP(addr) == nil {
+		return "", "", fmt.Errorf("endereço IP inválido em %q", value)
+	}
+	return net.JoinHostPort(host, port), addr, nil
+}
+
+type targetFlags []loadtest.Target
+
+func (t *targetFlags) String() string {
+	urls := make([]string, len(*t))
+	for i, target := range *t {
+		urls[i] = target.URL
+	}
+	return strings.Join(urls, ", ")
+}
+
+func (t *targetFlags) Set(value string) error {
+	target, err := parseTarget(value)
+	if err != nil {
+		return err
+	}
+	*t = append(*t, target)
+	return nil
+}
+
+func parseTarget(value string) (loadtest.Target, error) {
+	// Templates such as {{randInt 1 10}} contain spaces, so the weight is only
+	// looked for after the last placeholder.
+	url, weight := strings.TrimSpace(value), ""
+	if i := strings.LastIndexAny(url, " \t"); i != -1 && i > strings.LastIndex(url, "}}") {
+		url, weight = strings.TrimSpace(url[:i]), url[i+1:]
+	}
+	if url == "" || strings.ContainsAny(url, " \t") && !strings.Contains(url, "{{") {
+		return loadtest.Target{}, fmt.Errorf("alvo inválido %q, use o formato \"URL [peso]\"", value)
+	}
+
+	target := loadtest.Target{URL: url, Weight: 1}
+	if weight != "" {
+		n, err := strconv.Atoi(weight)
+		if err != nil || n <= 0 {
+			return loadtest.Target{}, fmt.Errorf("peso inválido para %s: %q", url, weight)
+		}
+		target.Weight = n
+	}
+	return target, nil
+}
+
+func loadTargetsFile(path string) ([]loadtest.Target, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var targets []loadtest.Target
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		target, err := parseTarget(text)
+		if err != nil {
+			return nil, fmt.Errorf("linha %d: %w", line, err)
+		}
+		targets = append(targets, target)
+	}
+	return targets, scanner.Err()
+}
+
+var supportedMethods = []string{
+	http.MethodGet,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodPatch,
+	http.MethodDelete,
+	http.MethodHead,
+	http.MethodOptions,
+}
+
+func isValidMethod(method string) bool {
+	for _, m := range supportedMethods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+func detectContentType(body []byte) string {
+	if json.Valid(body) {
+		return "application/json"
+	}
+	return http.DetectContentType(body)
+}
+
+// parseByteSize parses a size with decimal units, such as 512KB, 10MB or
+// 1.5GB. A number without unit is in bytes.
+func parseByteSize(s string) (int64, error) {
+	spec := strings.ToUpper(strings.TrimSpace(s))
+	unit := 1.0
+	for _, u := range []struct {
+		suffix     string
+		multiplier float64
+	}{{"KB", 1e3}, {"MB", 1e6}, {"GB", 1e9}, {"B", 1}} {
+		if strings.HasSuffix(spec, u.suffix) {
+			spec, unit = strings.TrimSuffix(spec, u.suffix), u.multiplier
+			break
+		}
+	}
+	value, err := strconv.ParseFloat(strings.TrimSpace(spec), 64)
+	if err != nil || value <= 0 {
+		return 0, fmt.Errorf("tamanho inválido %q, use por exemplo 512KB, 10MB ou 1GB", s)
+	}
+	return int64(value * unit), nil
+}
+
+// parseSpeedup parses a replay speed factor such as 10x or 0.5x.
+func parseSpeedup(s string) (float64, error) {
+	factor, err := strconv.ParseFloat(strings.TrimSuffix(strings.ToLower(strings.TrimSpace(s)), "x"), 64)
+	if err != nil || factor <= 0 || math.IsInf(factor, 0) {
+		return 0, fmt.Errorf("fator inválido %q, use por exemplo 10x ou 0.5x", s)
+	}
+	return factor, nil
+}
+
+func parseDurationList(value string) ([]time.Duration, error) {
+	var durations []time.Duration
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		d, err := time.ParseDuration(part)
+		if err != nil {
+			return nil, err
+		}
+		durations = append(durations, d)
+	}
+	return durations, nil
+}
+
+func buildAssertions(contains, regex, jsonPath []string) ([]loadtest.Assertion, error) {
+	var assertions []loadtest.Assertion
+	for _, substr := range contains {
+		assertions = append(assertions, loadtest.BodyContains(substr))
+	}
+	for _, pattern := range regex {
+		assertion, err := loadtest.BodyMatches(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("asserção de regex inválida: %w", err)
+		}
+		assertions = append(assertions, assertion)
+	}
+	for _, expr := range jsonPath {
+		path, expected, ok := strings.Cut(expr, "=")
+		if !ok {
+			return nil, fmt.Errorf("asserção de JSONPath inválida %q, use o formato \"$.caminho=valor\"", expr)
+		}
+		assertion, err := loadtest.JSONPathEquals(strings.TrimSpace(path), strings.TrimSpace(expected))
+		if err != nil {
+			return nil, fmt.Errorf("asserção de JSONPath inválida: %w", err)
+		}
+		assertions = append(assertions, assertion)
+	}
+	return assertions, nil
+}
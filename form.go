@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"stress-test/pkg/loadtest"
+)
+
+// parseForm reads the --form field=value and --form-file field=@path flags,
+// in that order.
+func parseForm(values, files []string) ([]loadtest.FormField, error) {
+	fields := make([]loadtest.FormField, 0, len(values)+len(files))
+	for _, arg := range values {
+		name, value, ok := strings.Cut(arg, "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("parâmetro --form inválido: %q, use campo=valor", arg)
+		}
+		fields = append(fields, loadtest.FormField{Name: name, Value: value})
+	}
+	for _, arg := range files {
+		name, path, ok := strings.Cut(arg, "=")
+		path = strings.TrimPrefix(path, "@")
+		if !ok || name == "" || path == "" {
+			return nil, fmt.Errorf("parâmetro --form-file inválido: %q, use campo=@arquivo", arg)
+		}
+		fields = append(fields, loadtest.FormField{Name: name, File: path})
+	}
+	return fields, nil
+}
+
+// formSummary describes the form for the banner, e.g. "2 campos, 1 arquivo
+// (150.00 MB)".
+func formSummary(fields []loadtest.FormField) string {
+	var values, files int
+	var size int64
+	for _, field := range fields {
+		if field.File == "" {
+			values++
+			continue
+		}
+		files++
+		if info, err := os.Stat(field.File); err == nil {
+			size += info.Size()
+		}
+	}
+	summary := fmt.Sprintf("%d campo(s)", values)
+	if files > 0 {
+		summary += fmt.Sprintf(", %d arquivo(s) (%s)", files, formatBytes(float64(size)))
+	}
+	return summary
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/net/http2"
+
+	"stress-test/pkg/loadtest"
+)
+
+// grpcConfig describes the unary method called by the grpc subcommand. The
+// messages come from Protos or, when none is given, from server reflection.
+type grpcConfig struct {
+	Address     string
+	Call        string
+	Protos      []string
+	ImportPaths []string
+	Payload     string
+	Plaintext   bool
+}
+
+// parseGRPCFlags parses the arguments of "stress-test grpc", which accepts
+// the load flags of the main command plus the ones describing the call.
+func parseGRPCFlags(fs *flag.FlagSet, args []string) (*Config, error) {
+	var call grpcConfig
+	var protos, importPaths stringsFlag
+	fs.StringVar(&call.Address, "address", "", "Endereço do servidor gRPC no formato host:porta")
+	fs.StringVar(&call.Call, "call", "", "Método unário chamado, no formato pacote.Serviço/Método")
+	fs.Var(&protos, "proto", "Arquivo .proto com o serviço (pode ser repetido; padrão: usa a reflexão do servidor)")
+	fs.Var(&importPaths, "import-path", "Diretório onde os imports dos arquivos .proto são procurados (pode ser repetido)")
+	fs.StringVar(&call.Payload, "payload", "{}", "Mensagem de request em JSON, ou @arquivo com o JSON")
+	fs.BoolVar(&call.Plaintext, "plaintext", false, "Conecta sem TLS (h2c)")
+
+	config, err := parseFlags(fs, args)
+	if err != nil {
+		return nil, err
+	}
+	call.Protos, call.ImportPaths = protos, importPaths
+	if call.Address == "" || call.Call == "" {
+		return nil, fmt.Errorf("parâmetros --address e --call são obrigatórios")
+	}
+	if len(config.Targets) > 0 || config.Scenario != nil || config.Stdin {
+		return nil, fmt.Errorf("o subcomando grpc não aceita outras fontes de requests")
+	}
+	if len(config.Agents) > 0 {
+		return nil, fmt.Errorf("o subcomando grpc não pode ser usado com --agents")
+	}
+	if strings.HasPrefix(call.Payload, "@") {
+		data, err := os.ReadFile(call.Payload[1:])
+		if err != nil {
+			return nil, fmt.Errorf("erro ao ler --payload: %w", err)
+		}
+		call.Payload = string(data)
+	}
+	// The payload is sent already encoded, so placeholders would break
+	// the length of the fields.
+	if strings.Contains(call.Payload, "{{") {
+		return nil, fmt.Errorf("parâmetro --payload não aceita templates")
+	}
+
+	config.Protocol = loadtest.ProtocolHTTP2
+	scheme := "https"
+	if call.Plaintext {
+		config.Protocol = loadtest.ProtocolHTTP2PriorKnowledge
+		scheme = "http"
+	}
+	base := scheme + "://" + call.Address
+
+	var registry *protoRegistry
+	if len(call.Protos) > 0 {
+		registry, err = parseProtoFiles(call.Protos, call.ImportPaths)
+	} else {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Timeout)
+		registry, err = loadReflection(ctx, grpcClient(config, call.Plaintext), base, call.Call)
+		cancel()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("erro ao carregar o serviço gRPC: %w", err)
+	}
+	name, method, err := registry.method(call.Call)
+	if err != nil {
+		return nil, err
+	}
+	if method.ClientStreaming || method.ServerStreaming {
+		return nil, fmt.Errorf("método %s não é unário", name)
+	}
+	message, err := registry.encodeJSON(method.Input, []byte(call.Payload))
+	if err != nil {
+		return nil, fmt.Errorf("parâmetro --payload inválido: %w", err)
+	}
+
+	headers := make(http.Header)
+	headers.Set("TE", "trailers")
+	if config.Timeout > 0 {
+		headers.Set("Grpc-Timeout", fmt.Sprintf("%dm", config.Timeout.Milliseconds()))
+	}
+	config.Targets = []loadtest.Target{{
+		Name:        name,
+		URL:         base + "/" + name,
+		Weight:      1,
+		Method:      http.MethodPost,
+		Headers:     headers,
+		Body:        grpcFrame(message),
+		ContentType: "application/grpc",
+	}}
+	call.Call = name
+	config.GRPC = call
+	return config, nil
+}
+
+// grpcFrame prefixes an uncompressed message with its length, as gRPC
+// sends messages over HTTP/2.
+func grpcFrame(message []byte) []byte {
+	frame := make([]byte, 5, 5+len(message))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(message)))
+	return append(frame, message...)
+}
+
+// grpcClient is used for the reflection requests made before the test.
+func grpcClient(config *Config, plaintext bool) *http.Client {
+	transport := &http2.Transport{TLSClientConfig: config.TLS}
+	if plaintext {
+		transport.AllowHTTP = true
+		transport.DialTLSContext = func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+			return (&net.Dialer{Timeout: 10 * time.Second}).DialContext(ctx, network, addr)
+		}
+	}
+	return &http.Client{Transport: transport}
+}
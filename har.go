@@ -0,0 +1,160 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"stress-test/pkg/loadtest"
+)
+
+// harFile is a HAR 1.2 archive. Only the requests are read by --har; the
+// other fields are written by the record subcommand for other tools.
+type harFile struct {
+	Log struct {
+		Version string `json:"version"`
+		Creator struct {
+			Name    string `json:"name"`
+			Version string `json:"version"`
+		} `json:"creator"`
+		Entries []harEntry `json:"entries"`
+	} `json:"log"`
+}
+
+type harEntry struct {
+	StartedDateTime time.Time   `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         harRequest  `json:"request"`
+	Response        harResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         struct {
+		Send    float64 `json:"send"`
+		Wait    float64 `json:"wait"`
+		Receive float64 `json:"receive"`
+	} `json:"timings"`
+}
+
+type harRequest struct {
+	Method      string       `json:"method"`
+	URL         string       `json:"url"`
+	HTTPVersion string       `json:"httpVersion"`
+	Cookies     []harHeader  `json:"cookies"`
+	Headers     []harHeader  `json:"headers"`
+	QueryString []harHeader  `json:"queryString"`
+	PostData    *harPostData `json:"postData,omitempty"`
+	HeadersSize int          `json:"headersSize"`
+	BodySize    int64        `json:"bodySize"`
+}
+
+type harPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+type harResponse struct {
+	Status      int         `json:"status"`
+	StatusText  string      `json:"statusText"`
+	HTTPVersion string      `json:"httpVersion"`
+	Cookies     []harHeader `json:"cookies"`
+	Headers     []harHeader `json:"headers"`
+	Content     struct {
+		Size     int64  `json:"size"`
+		MimeType string `json:"mimeType"`
+	} `json:"content"`
+	RedirectURL string `json:"redirectURL"`
+	HeadersSize int    `json:"headersSize"`
+	BodySize    int64  `json:"bodySize"`
+}
+
+type harHeader struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// harSkippedHeaders are set by the transport itself; copying them from the
+// capture would break the replayed requests.
+var harSkippedHeaders = map[string]bool{
+	"host":              true,
+	"content-length":    true,
+	"connection":        true,
+	"keep-alive":        true,
+	"transfer-encoding": true,
+	"accept-encoding":   true,
+}
+
+// loadHARFile turns the requests of a HAR export into a scenario, in the
+// order they were started. With respectTiming every step keeps its offset
+// from the first request of the capture, divided by speedup.
+func loadHARFile(path string, respectTiming bool, speedup float64) (*loadtest.Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	file := &harFile{}
+	if err := json.Unmarshal(data, file); err != nil {
+		return nil, err
+	}
+
+	entries := file.Log.Entries
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].StartedDateTime.Before(entries[j].StartedDateTime)
+	})
+
+	scenario := &loadtest.Scenario{Name: strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))}
+	var first time.Time
+	names := make(map[string]int)
+	for i, entry := range entries {
+		u, err := url.Parse(entry.Request.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			// data:, blob: and extension URLs never reach the network.
+			continue
+		}
+		method := strings.ToUpper(entry.Request.Method)
+		if !isValidMethod(method) {
+			return nil, fmt.Errorf("entries[%d]: método inválido: %q", i, entry.Request.Method)
+		}
+
+		// Step names must be unique; a page usually requests the same
+		// path more than once.
+		name := u.Path
+		if name == "" {
+			name = "/"
+		}
+		if names[name]++; names[name] > 1 {
+			name = fmt.Sprintf("%s (%d)", name, names[name])
+		}
+		step := loadtest.Step{
+			Name:    name,
+			Method:  method,
+			URL:     entry.Request.URL,
+			Headers: make(http.Header),
+		}
+		for _, header := range entry.Request.Headers {
+			if strings.HasPrefix(header.Name, ":") || harSkippedHeaders[strings.ToLower(header.Name)] {
+				continue
+			}
+			step.Headers.Add(header.Name, header.Value)
+		}
+		if postData := entry.Request.PostData; postData != nil && postData.Text != "" {
+			step.Body = []byte(postData.Text)
+			step.ContentType = postData.MimeType
+		}
+		if len(scenario.Steps) == 0 {
+			first = entry.StartedDateTime
+		}
+		if respectTiming {
+			step.Offset = time.Duration(float64(entry.StartedDateTime.Sub(first)) / speedup)
+		}
+		scenario.Steps = append(scenario.Steps, step)
+	}
+	if len(scenario.Steps) == 0 {
+		return nil, fmt.Errorf("nenhum request HTTP encontrado")
+	}
+	return scenario, nil
+}
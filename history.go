@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// storedRun is a row of the runs table, as listed by the history subcommand.
+type storedRun struct {
+	id        int64
+	runID     string
+	name      string
+	startedAt time.Time
+	tags      map[string]string
+	requests  int
+	errorRate float64
+	rps       float64
+	p95Ms     float64
+}
+
+func (r storedRun) matches(name string, tags map[string]string) bool {
+	if name != "" && r.name != name {
+		return false
+	}
+	for key, value := range tags {
+		if r.tags[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// runHistory serves the "history" subcommand.
+func runHistory(args []string) error {
+	fs := flag.NewFlagSet("stress-test history", flag.ExitOnError)
+	tags := make(map[string]string)
+	var path, name, compare string
+	var limit int
+	var maxRegression, maxErrorIncrease float64
+	fs.StringVar(&path, "store", "", "Banco SQLite gravado pelos testes executados com --store")
+	fs.IntVar(&limit, "limit", 20, "Número máximo de execuções listadas, da mais recente para a mais antiga")
+	fs.StringVar(&name, "name", "", "Lista apenas as execuções com o nome informado em --name")
+	fs.Var(tagFlags(tags), "tag", "Lista apenas as execuções com a tag chave=valor (pode ser repetido)")
+	fs.StringVar(&compare, "compare", "", "Compara duas execuções pelos ids ou IDs de execução no formato base,atual (ex: 12,15)")
+	fs.Float64Var(&maxRegression, "max-regression", 10, "Piora máxima, em %, de req/s e dos percentis de latência com --compare")
+	fs.Float64Var(&maxErrorIncrease, "max-error-rate-increase", 1, "Aumento máximo, em pontos percentuais, da taxa de erro com --compare")
+	fs.Parse(args)
+
+	if path == "" {
+		return fmt.Errorf("parâmetro --store é obrigatório")
+	}
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("parâmetro --store inválido: %w", err)
+	}
+	store, err := openStore(path)
+	if err != nil {
+		return err
+	}
+	defer store.close()
+
+	if compare == "" {
+		runs, err := store.runs(name, tags, limit)
+		if err != nil {
+			return err
+		}
+		printRuns(runs)
+		return nil
+	}
+
+	before, after, ok := strings.Cut(compare, ",")
+	baseID, currentID := strings.TrimSpace(before), strings.TrimSpace(after)
+	if !ok || baseID == "" || currentID == "" {
+		return fmt.Errorf("parâmetro --compare inválido: %q (use base,atual, ex: 12,15)", compare)
+	}
+	baseline, err := store.report(baseID)
+	if err != nil {
+		return err
+	}
+	current, err := store.report(currentID)
+	if err != nil {
+		return err
+	}
+	comparisons := compareReports(baseline, current, maxRegression, maxErrorIncrease)
+	printComparison(os.Stdout, "execução "+baseID, comparisons)
+	for _, c := range comparisons {
+		if c.regressed {
+			return failedError{fmt.Errorf("a execução %s regrediu em relação à execução %s", currentID, baseID)}
+		}
+	}
+	return nil
+}
+
+// runs lists the most recent runs with name, when given, and all of tags,
+// newest first.
+func (s *resultStore) runs(name string, tags map[string]string, limit int) ([]storedRun, error) {
+	rows, err := s.db.Query(`SELECT id, run_id, name, started_at, tags, total_requests, error_rate, rps, p95_ms FROM runs ORDER BY id DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var runs []storedRun
+	for rows.Next() && (limit <= 0 || len(runs) < limit) {
+		var run storedRun
+		var startedAt, encodedTags string
+		if err := rows.Scan(&run.id, &run.runID, &run.name, &startedAt, &encodedTags, &run.requests, &run.errorRate, &run.rps, &run.p95Ms); err != nil {
+			return nil, err
+		}
+		run.startedAt, _ = time.Parse(time.RFC3339, startedAt)
+		json.Unmarshal([]byte(encodedTags), &run.tags)
+		if run.matches(name, tags) {
+			runs = append(runs, run)
+		}
+	}
+	return runs, rows.Err()
+}
+
+// report loads the JSON report saved with the run, given its id in the
+// database or its run ID.
+func (s *resultStore) report(id string) (*jsonReport, error) {
+	var data string
+	if err := s.db.QueryRow(`SELECT report FROM runs WHERE CAST(id AS TEXT) = ? OR run_id = ?`, id, id).Scan(&data); err != nil {
+		return nil, fmt.Errorf("execução %s não encontrada: %w", id, err)
+	}
+	var report jsonReport
+	if err := json.Unmarshal([]byte(data), &report); err != nil {
+		return nil, fmt.Errorf("relatório da execução %s inválido: %w", id, err)
+	}
+	return &report, nil
+}
+
+func printRuns(runs []storedRun) {
+	if len(runs) == 0 {
+		fmt.Println("Nenhuma execução encontrada.")
+		return
+	}
+	fmt.Printf("%-6s %-22s %-20s %10s %8s %10s %10s  %s\n", "ID", "Execução", "Início", "Requests", "Erros", "Req/s", "p95", "Nome e tags")
+	for _, run := range runs {
+		labels := tagFlags(run.tags).String()
+		if run.name != "" {
+			labels = strings.TrimSpace(run.name + " " + labels)
+		}
+		fmt.Printf("%-6d %-22s %-20s %10d %7.2f%% %10.2f %8.2fms  %s\n", run.id, run.runID, run.startedAt.Local().Format("2006-01-02 15:04:05"),
+			run.requests, run.errorRate, run.rps, run.p95Ms, labels)
+	}
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	_ "embed"
+	"fmt"
+	"html/template"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"stress-test/pkg/loadtest"
+)
+
+//go:embed report.html.tmpl
+var htmlReportTemplate string
+
+var chartColors = []string{"#2e7d32", "#1565c0", "#f9a825", "#6a1b9a", "#00838f", "#ef6c00", "#5d4037", "#ad1457"}
+
+const (
+	chartWidth  = 640
+	chartHeight = 220
+	pieRadius   = 90
+)
+
+type htmlBar struct {
+	Label  string
+	Value  string
+	X      float64
+	Y      float64
+	Width  float64
+	Height float64
+}
+
+type htmlSlice struct {
+	Label   string
+	Count   int
+	Percent float64
+	Color   string
+	Path    string
+	Circle  bool
+}
+
+type htmlReport struct {
+	Report      *loadtest.Report
+	GeneratedAt string
+	URLs        []string
+	Percentiles []htmlBar
+	Histogram   []htmlBar
+	Statuses    []htmlSlice
+	Requests    string
+	Failures    string
+	TimelineMax int
+	Throughput  string
+	P95         string
+	P95Max      time.Duration
+	Seconds     int
+	Width       int
+	Height      int
+	Radius      int
+}
+
+func writeHTMLReport(path string, config *Config, report *loadtest.Report) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return renderHTMLReport(file, config, report)
+}
+
+func renderHTMLReport(w io.Writer, config *Config, report *loadtest.Report) error {
+	tmpl, err := template.New("report").Parse(htmlReportTemplate)
+	if err != nil {
+		return err
+	}
+	return tmpl.Execute(w, newHTMLReport(config, report))
+}
+
+func newHTMLReport(config *Config, report *loadtest.Report) *htmlReport {
+	data := &htmlReport{
+		Report:      report,
+		GeneratedAt: time.Now().Format("2006-01-02 15:04:05"),
+		Width:       chartWidth,
+		Height:      chartHeight,
+		Radius:      pieRadius,
+	}
+	for _, target := range config.Targets {
+		data.URLs = append(data.URLs, target.URL)
+	}
+
+	latency := report.Latency
+	data.Percentiles = horizontalBars([]string{"p50", "p90", "p95", "p99", "p99.9", "máx"},
+		[]time.Duration{latency.P50, latency.P90, latency.P95, latency.P99, latency.P999, latency.Max})
+
+	labels := make([]string, len(report.Histogram.Counts))
+	counts := make([]int, len(report.Histogram.Counts))
+	for i, count := range report.Histogram.Counts {
+		if i < len(report.Histogram.Bounds) {
+			labels[i] = "≤ " + report.Histogram.Bounds[i].String()
+		} else {
+			labels[i] = "> " + report.Histogram.Bounds[len(report.Histogram.Bounds)-1].String()
+		}
+		counts[i] = count
+	}
+	data.Histogram = verticalBars(labels, counts)
+
+	data.Throughput = formatBytes(report.Throughput()) + "/s"
+	data.Statuses = pieSlices(report)
+	data.Requests, data.Failures, data.TimelineMax = timelinePaths(report.Timeline)
+	data.P95, data.P95Max = latencyPath(report.Timeline)
+	// The chart plots requests per timeline point; the labels are per second.
+	interval := report.TimelineInterval
+	data.TimelineMax = int(float64(data.TimelineMax) / interval.Seconds())
+	data.Seconds = int((time.Duration(len(report.Timeline)) * interval).Seconds())
+	return data
+}
+
+func horizontalBars(labels []string, values []time.Duration) []htmlBar {
+	var max time.Duration
+	for _, v := range values {
+		if v > max {
+			max = v
+		}
+	}
+
+	const labelWidth, rowHeight = 60.0, 36.0
+	bars := make([]htmlBar, len(values))
+	for i, v := range values {
+		width := 0.0
+		if max > 0 {
+			width = float64(v) / float64(max) * (chartWidth - labelWidth - 120)
+		}
+		bars[i] = htmlBar{Label: labels[i], Value: v.String(), X: labelWidth, Y: float64(i)*rowHeight + 8, Width: width, Height: rowHeight - 12}
+	}
+	return bars
+}
+
+func verticalBars(labels []string, counts []int) []htmlBar {
+	max := 0
+	for _, c := range counts {
+		if c > max {
+			max = c
+		}
+	}
+	if len(counts) == 0 {
+		return nil
+	}
+
+	slot := float64(chartWidth) / float64(len(counts))
+	bars := make([]htmlBar, len(counts))
+	for i, c := range counts {
+		height := 0.0
+		if max > 0 {
+			height = float64(c) / float64(max) * (chartHeight - 50)
+		}
+		bars[i] = htmlBar{
+			Label:  labels[i],
+			Value:  fmt.Sprint(c),
+			X:      float64(i)*slot + slot*0.1,
+			Y:      chartHeight - 30 - height,
+			Width:  slot * 0.8,
+			Height: height,
+		}
+	}
+	return bars
+}
+
+func pieSlices(report *loadtest.Report) []htmlSlice {
+	codes := make([]int, 0, len(report.StatusCodes))
+	for code := range report.StatusCodes {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+
+	type entry struct {
+		label string
+		count int
+		color string
+	}
+	var entries []entry
+	for i, code := range codes {
+		if code == 0 {
+			entries = append(entries, entry{"Erros", report.StatusCodes[code], "#c62828"})
+			continue
+		}
+		entries = append(entries, entry{fmt.Sprint(code), report.StatusCodes[code], chartColors[i%len(chartColors)]})
+	}
+	if report.Timeouts > 0 {
+		entries = append(entries, entry{"Timeouts", report.Timeouts, "#757575"})
+	}
+
+	total := 0
+	for _, e := range entries {
+		total += e.count
+	}
+	if total == 0 {
+		return nil
+	}
+
+	slices := make([]htmlSlice, 0, len(entries))
+	angle := -math.Pi / 2
+	for _, e := range entries {
+		fraction := float64(e.count) / float64(total)
+		next := angle + fraction*2*math.Pi
+		x1, y1 := pieRadius*math.Cos(angle), pieRadius*math.Sin(angle)
+		x2, y2 := pieRadius*math.Cos(next), pieRadius*math.Sin(next)
+		large := 0
+		if fraction > 0.5 {
+			large = 1
+		}
+		slices = append(slices, htmlSlice{
+			Label:   e.label,
+			Count:   e.count,
+			Percent: fraction * 100,
+			Color:   e.color,
+			Path:    fmt.Sprintf("M0,0 L%.2f,%.2f A%d,%d 0 %d 1 %.2f,%.2f Z", x1, y1, pieRadius, pieRadius, large, x2, y2),
+			Circle:  e.count == total,
+		})
+		angle = next
+	}
+	return slices
+}
+
+func timelinePaths(timeline []loadtest.TimelinePoint) (requests, failures string, max int) {
+	for _, point := range timeline {
+		if point.Requests > max {
+			max = point.Requests
+		}
+	}
+	if len(timeline) == 0 || max == 0 {
+		return "", "", max
+	}
+
+	step := float64(chartWidth)
+	if len(timeline) > 1 {
+		step = float64(chartWidth) / float64(len(timeline)-1)
+	}
+	scale := float64(chartHeight-30) / float64(max)
+
+	var req, fail strings.Builder
+	for i, point := range timeline {
+		x := float64(i) * step
+		fmt.Fprintf(&req, "%.2f,%.2f ", x, chartHeight-10-float64(point.Requests)*scale)
+		fmt.Fprintf(&fail, "%.2f,%.2f ", x, chartHeight-10-float64(point.Failures)*scale)
+	}
+	return req.String(), fail.String(), max
+}
+
+func latencyPath(timeline []loadtest.TimelinePoint) (path string, max time.Duration) {
+	for _, point := range timeline {
+		if point.P95 > max {
+			max = point.P95
+		}
+	}
+	if len(timeline) == 0 || max == 0 {
+		return "", max
+	}
+
+	step := float64(chartWidth)
+	if len(timeline) > 1 {
+		step = float64(chartWidth) / float64(len(timeline)-1)
+	}
+	scale := float64(chartHeight-30) / float64(max)
+
+	var b strings.Builder
+	for i, point := range timeline {
+		fmt.Fprintf(&b, "%.2f,%.2f ", float64(i)*step, chartHeight-10-float64(point.P95)*scale)
+	}
+	return b.String(), max
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"stress-test/pkg/loadtest"
+)
+
+// influxConfig selects the write API: Database for InfluxDB 1.x, or Org and
+// Bucket (plus Token) for InfluxDB 2.x.
+type influxConfig struct {
+	URL       string
+	Database  string
+	Org       string
+	Bucket    string
+	Token     string
+	Aggregate bool
+	Tags      map[string]string
+}
+
+func (c *influxConfig) writeURL() (string, error) {
+	base, err := url.Parse(strings.TrimSuffix(c.URL, "/"))
+	if err != nil || base.Host == "" {
+		return "", fmt.Errorf("URL do InfluxDB inválida: %q", c.URL)
+	}
+	query := url.Values{"precision": {"ns"}}
+	switch {
+	case c.Bucket != "":
+		base.Path += "/api/v2/write"
+		query.Set("org", c.Org)
+		query.Set("bucket", c.Bucket)
+	case c.Database != "":
+		base.Path += "/write"
+		query.Set("db", c.Database)
+	default:
+		return "", fmt.Errorf("informe o database (InfluxDB 1.x) ou o bucket (InfluxDB 2.x)")
+	}
+	base.RawQuery = query.Encode()
+	return base.String(), nil
+}
+
+// influxGrace is how long an aggregated second stays open after it ends:
+// results from different workers arrive out of order, and a point written
+// twice for the same second would overwrite the first.
+const influxGrace = 5 * time.Second
+
+// influxSecond aggregates the results that ended within one second.
+type influxSecond struct {
+	requests int
+	failures int
+	latency  loadtest.LatencyHistogram
+}
+
+// influxSink writes results to InfluxDB using the line protocol, either one
+// point per request or one aggregated point per second. Points are buffered
+// and sent every second so the collector never waits on the network.
+type influxSink struct {
+	mu     sync.Mutex
+	url    string
+	token  string
+	tags   string
+	client *http.Client
+	buf    bytes.Buffer
+	err    error
+
+	aggregate bool
+	success   loadtest.StatusSet
+	seconds   map[int64]*influxSecond
+	written   int64
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+func newInfluxSink(config influxConfig, success loadtest.StatusSet, run map[string]string) (*influxSink, error) {
+	writeURL, err := config.writeURL()
+	if err != nil {
+		return nil, err
+	}
+	tags := make(map[string]string, len(config.Tags)+len(run))
+	for key, value := range run {
+		tags[key] = value
+	}
+	for key, value := range config.Tags {
+		tags[key] = value
+	}
+
+	s := &influxSink{
+		url:       writeURL,
+		token:     config.Token,
+		tags:      influxTags(tags),
+		client:    &http.Client{Timeout: 10 * time.Second},
+		aggregate: config.Aggregate,
+		success:   success,
+		seconds:   make(map[int64]*influxSecond),
+		done:      make(chan struct{}),
+	}
+	s.wg.Add(1)
+	go s.loop()
+	return s, nil
+}
+
+func (s *influxSink) loop() {
+	defer s.wg.Done()
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			s.mu.Lock()
+			s.writeSeconds(time.Now().Add(-influxGrace).Unix())
+			s.mu.Unlock()
+			s.flush()
+		}
+	}
+}
+
+func (s *influxSink) record(result loadtest.Result) {
+	end := result.Start.Add(result.Duration)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.aggregate {
+		// A result later than the grace counts in the first second not
+		// written yet.
+		second := max(end.Unix(), s.written+1)
+		point := s.seconds[second]
+		if point == nil {
+			point = &influxSecond{}
+			s.seconds[second] = point
+		}
+		point.requests++
+		if result.Error != nil || result.AssertionError != nil || !s.success.Contains(result.StatusCode) {
+			point.failures++
+		}
+		if result.Error == nil {
+			point.latency.Record(result.Duration)
+		}
+		return
+	}
+
+	tags := map[string]string{"target": result.Target}
+	if result.Step != "" {
+		tags["step"] = result.Step
+	}
+	fields := fmt.Sprintf("duration_ms=%g,status=%di,bytes=%di", milliseconds(result.Duration), result.StatusCode, result.Bytes)
+	switch {
+	case result.Error != nil:
+		fields += ",error=" + influxString(loadtest.ClassifyError(result.Error))
+	case result.AssertionError != nil:
+		fields += ",error=" + influxString("assertion")
+	}
+	fmt.Fprintf(&s.buf, "stress_test_request,%s,%s %s %d\n", s.tags, influxTags(tags), fields, end.UnixNano())
+}
+
+// writeSeconds adds the points of the aggregated seconds before until, a Unix
+// time, in order, and closes them.
+func (s *influxSink) writeSeconds(until int64) {
+	for _, second := range sortedKeys(s.seconds) {
+		if second >= until {
+			break
+		}
+		point := s.seconds[second]
+		stats := point.latency.Stats()
+		fmt.Fprintf(&s.buf, "stress_test_second,%s requests=%di,failures=%di,mean_ms=%g,p95_ms=%g,p99_ms=%g,max_ms=%g %d\n",
+			s.tags, point.requests, point.failures, milliseconds(stats.Mean), milliseconds(stats.P95), milliseconds(stats.P99), milliseconds(stats.Max), time.Unix(second, 0).UnixNano())
+		delete(s.seconds, second)
+	}
+	s.written = max(s.written, until-1)
+}
+
+func (s *influxSink) flush() {
+	s.mu.Lock()
+	if s.buf.Len() == 0 {
+		s.mu.Unlock()
+		return
+	}
+	body := bytes.Clone(s.buf.Bytes())
+	s.buf.Reset()
+	s.mu.Unlock()
+
+	err := s.post(body)
+	if err != nil {
+		s.mu.Lock()
+		if s.err == nil {
+			s.err = err
+		}
+		s.mu.Unlock()
+	}
+}
+
+func (s *influxSink) post(body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if s.token != "" {
+		req.Header.Set("Authorization", "Token "+s.token)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("InfluxDB respondeu %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// close sends the remaining points and returns the first write error.
+func (s *influxSink) close() error {
+	close(s.done)
+	s.wg.Wait()
+	s.mu.Lock()
+	s.writeSeconds(math.MaxInt64)
+	s.mu.Unlock()
+	s.flush()
+	return s.err
+}
+
+var influxTagEscaper = strings.NewReplacer(",", `\,`, " ", `\ `, "=", `\=`)
+
+func influxTags(tags map[string]string) string {
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if tags[key] == "" {
+			continue
+		}
+		parts = append(parts, influxTagEscaper.Replace(key)+"="+influxTagEscaper.Replace(tags[key]))
+	}
+	return strings.Join(parts, ",")
+}
+
+func influxString(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+}
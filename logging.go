@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// newLogger builds the logger of the tool's own messages, such as progress,
+// warnings and errors, as text or as JSON lines for log pipelines. The
+// report itself is not logged.
+func newLogger(w io.Writer, level slog.Level, format string) *slog.Logger {
+	options := &slog.HandlerOptions{Level: level}
+	if format == "json" {
+		return slog.New(slog.NewJSONHandler(w, options))
+	}
+	return slog.New(slog.NewTextHandler(w, options))
+}
+
+func parseLogLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(strings.ToLower(s))); err != nil {
+		return 0, fmt.Errorf("%q não é um nível, use debug, info, warn ou error", s)
+	}
+	return level, nil
+}
+
+// Exit codes, so that unattended runs (e.g. in CI) can tell a failed test
+// from one that couldn't run or didn't finish.
+const (
+	exitFailed  = 1 // thresholds violated or regression found
+	exitError   = 2 // invalid configuration, or the test couldn't run
+	exitAborted = 3 // aborted or interrupted, with a partial report
+)
+
+// failedError is returned by subcommands when the test ran but failed, as
+// opposed to an error that kept it from running.
+type failedError struct{ error }
+
+// fatal logs err and exits with exitError.
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(exitError)
+}
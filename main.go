@@ -2,194 +2,1473 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"log/slog"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"os/signal"
+	"sort"
 	"strings"
-	"sync"
+	"syscall"
 	"time"
+
+	"stress-test/pkg/loadtest"
 )
 
 type Config struct {
-	URL         string
+	Targets     []loadtest.Target
+	Scenario    *loadtest.Scenario
+	Data        string
+	DataMode    string
+	Feeder      *loadtest.Feeder
+	Method      string
+	Body        []byte
+	Form        []loadtest.FormField
+	BodySize    int64
+	Chunked     bool
+	BodyMode    loadtest.BodyMode
+	SaveBodies  string
+	SaveRate    float64
+	Samples     int
+	Stream      loadtest.BodyStream
+	ContentType string
+	Headers     http.Header
+	Encodings   []string
+	BasicAuth   string
+	BearerToken string
+	APIKey      string
+	OAuth2      loadtest.OAuth2ClientCredentials
+	SigV4       awsSigV4
 	Requests    int
 	Concurrency int
+	Duration    time.Duration
+	MaxDuration time.Duration
+	Rate        float64
+	Stages      []loadtest.Stage
+	Preset      string
+	RampUp      time.Duration
+	Executor    string
+	MaxVUs      int
+	Warmup      time.Duration
+	WarmupReqs  int
+	ThinkTime   time.Duration
+	ThinkJitter time.Duration
+	Retry       loadtest.RetryPolicy
+	Timeout     time.Duration
+	Buckets     []time.Duration
+	Resolution  time.Duration
+	Protocol    loadtest.Protocol
+	IPFamily    loadtest.IPFamily
+	Insecure    bool
+	CACert      string
+	ClientCert  string
+	ClientKey   string
+	TLS         *tls.Config
+	NoKeepAlive bool
+	PortExhaust string
+	Cookies     bool
+	Conditional bool
+	VUHeader    string
+	MaxRedirect int
+	Limits      loadtest.ConnectionLimits
+	PerClient   int
+	RaiseLimit  bool
+	Preflight   bool
+	DryRun      bool
+	Abort       *loadtest.AbortRule
+	Proxy       *url.URL
+	ProxyEnv    bool
+	Resolve     map[string]string
+	DNSServer   string
+	UnixSocket  string
+	LocalAddrs  []string
+	Bandwidth   float64
+	Stdin       bool
+	HAR         string
+	Postman     postmanConfig
+	OpenAPI     openAPIConfig
+	GRPC        grpcConfig
+	Timing      bool
+	Speedup     float64
+	UI          bool
+	Progress    time.Duration
+	Quiet       bool
+	Verbose     bool
+	LogLevel    slog.Level
+	LogFormat   string
+	MetricsAddr string
+	StatsD      string
+	StatsDTags  []string
+	Influx      influxConfig
+	Tracing     bool
+	OTLP        string
+	OTelService string
+	Agents      []string
+	AgentToken  string
+	Serve       string
+	ServeToken  string
+
+	AssertContains []string
+	AssertRegex    []string
+	AssertJSONPath []string
+	Assertions     []loadtest.Assertion
+	Script         string
+	Check          loadtest.ResponseCheck
+	Hook           loadtest.RequestHook
+	SuccessCodes   loadtest.StatusSet
+	Thresholds     []loadtest.Threshold
+	Name           string
+	Tags           map[string]string
+	Metadata       loadtest.Metadata
+	Compare        string
+	Baseline       *jsonReport
+	MaxRegression  float64
+	MaxErrorDelta  float64
+
+	Output     string
+	OutputFile string
+	OutputRaw  string
+	ReportHTML string
+	Summary    string
+	Store      string
+	Upload     *url.URL
+	Notify     string
 }
 
-type Result struct {
-	StatusCode int
-	Duration   time.Duration
-	Error      error
+func (c *Config) logWriter() *os.File {
+	if c.Output == "json" && c.OutputFile == "" {
+		return os.Stderr
+	}
+	return os.Stdout
 }
 
-type Report struct {
-	TotalTime       time.Duration
-	TotalRequests   int
-	SuccessRequests int
-	StatusCodes     map[int]int
+func parseFlags(fs *flag.FlagSet, args []string) (*Config, error) {
+	return parseConfig(fs, args, nil)
 }
 
-func parseFlags() (*Config, error) {
-	config := &Config{}
+// parseConfig parses args like parseFlags, taking the values missing from the
+// command line from file, or from the --config file when file is nil.
+func parseConfig(fs *flag.FlagSet, args []string, file *fileConfig) (*Config, error) {
+	config := &Config{Headers: make(http.Header), Tags: make(map[string]string)}
+	var body, bodyFile, buckets, targetsFile, scenarioFile, configFile, successCodes string
+	var oauth2Scopes, proxy, statsdTags, influxTags, agents, fromCurl, operations, stages, retryOn string
+	var retries int
+	var maxBandwidth, logLevel, upload, abortOn, acceptEncoding, bodySize, speedup string
+	var retryBackoff time.Duration
+	var http2, http2PriorKnowledge, http3, noFollowRedirects, discardBody, readBody, vuHeader, ipv4, ipv6 bool
+	var assertContains, assertRegex, assertJSONPath, failIf, resolve, form, formFiles, localAddrs stringsFlag
+	var targets targetFlags
 
-	flag.StringVar(&config.URL, "url", "", "URL do serviço a ser testado")
-	flag.IntVar(&config.Requests, "requests", 0, "Número total de requests")
-	flag.IntVar(&config.Concurrency, "concurrency", 0, "Número de chamadas simultâneas")
-	flag.Parse()
+	fs.StringVar(&configFile, "config", "", "Arquivo de configuração YAML ou JSON (flags da linha de comando têm precedência)")
+	fs.Var(&targets, "url", "URL do serviço a ser testado, opcionalmente seguida de peso: \"URL [peso]\" (pode ser repetido)")
+	fs.StringVar(&targetsFile, "targets-file", "", "Arquivo com uma URL por linha, opcionalmente seguida de peso")
+	fs.BoolVar(&config.Stdin, "stdin", false, "Lê os requests do stdin, um objeto JSON por linha com method, url, headers e body")
+	fs.StringVar(&scenarioFile, "scenario", "", "Arquivo YAML com a sequência de passos executada por cada usuário virtual")
+	fs.StringVar(&fromCurl, "from-curl", "", "Comando curl (ou arquivo com um comando por linha) cujos requests são testados")
+	fs.StringVar(&config.Postman.Collection, "postman", "", "Coleção do Postman (v2.x) cujos requests são testados")
+	fs.StringVar(&config.Postman.Environment, "postman-env", "", "Ambiente do Postman com os valores das variáveis da coleção")
+	fs.StringVar(&config.Postman.Folder, "postman-folder", "", "Testa apenas os requests da pasta informada da coleção")
+	fs.StringVar(&config.Postman.Mode, "postman-mode", postmanModeTargets, "Como os requests da coleção são executados: targets (um request sorteado por iteração) ou scenario (todos em sequência)")
+	fs.StringVar(&config.OpenAPI.Spec, "openapi", "", "Documento OpenAPI 3 ou Swagger 2 (YAML ou JSON) cujas operações são testadas")
+	fs.StringVar(&config.OpenAPI.Server, "openapi-server", "", "URL base da API (padrão: o primeiro servidor do documento)")
+	fs.StringVar(&operations, "operation", "", "operationIds testados, separados por vírgula (padrão: todas as operações GET)")
+	fs.StringVar(&config.HAR, "har", "", "Arquivo HAR (exportado do DevTools) cujos requests são reproduzidos em sequência por cada usuário virtual")
+	fs.BoolVar(&config.Timing, "respect-timing", false, "Mantém o intervalo original entre os requests do --har")
+	fs.StringVar(&speedup, "speedup", "", "Reproduz os intervalos originais do --har acelerados pelo fator informado; implica --respect-timing (ex: 10x, 0.5x)")
+	fs.StringVar(&config.Data, "data", "", "Arquivo CSV cujas colunas podem ser usadas como {{.coluna}} na URL, headers e corpo")
+	fs.StringVar(&config.DataMode, "data-mode", string(loadtest.FeedRoundRobin), "Ordem de leitura das linhas de --data: round-robin ou random")
+	fs.StringVar(&config.Method, "method", http.MethodGet, "Método HTTP utilizado nos requests")
+	fs.StringVar(&config.BasicAuth, "basic-auth", "", "Credenciais de autenticação Basic no formato \"usuário:senha\"")
+	fs.StringVar(&config.BearerToken, "bearer-token", "", "Token enviado no header Authorization: Bearer")
+	fs.StringVar(&config.APIKey, "api-key-header", "", "Header com a API key no formato \"Header: chave\" (ex: \"X-API-Key: abc\")")
+	fs.StringVar(&config.OAuth2.TokenURL, "oauth2-token-url", "", "URL do endpoint de token OAuth2 (client credentials)")
+	fs.StringVar(&config.OAuth2.ClientID, "oauth2-client-id", "", "Client id OAuth2")
+	fs.StringVar(&config.OAuth2.ClientSecret, "oauth2-client-secret", "", "Client secret OAuth2")
+	fs.StringVar(&oauth2Scopes, "oauth2-scopes", "", "Escopos OAuth2 separados por vírgula")
+	fs.BoolVar(&config.SigV4.Enabled, "aws-sigv4", false, "Assina cada request com AWS Signature Version 4, usando as credenciais da cadeia padrão da AWS")
+	fs.StringVar(&config.SigV4.Region, "aws-region", "", "Região usada na assinatura de --aws-sigv4 (padrão: AWS_REGION ou AWS_DEFAULT_REGION)")
+	fs.StringVar(&config.SigV4.Service, "aws-service", "execute-api", "Serviço usado na assinatura de --aws-sigv4 (ex: execute-api, s3, lambda)")
+	fs.StringVar(&body, "body", "", "Corpo enviado em cada request")
+	fs.StringVar(&bodyFile, "body-file", "", "Arquivo com o corpo enviado em cada request")
+	fs.StringVar(&bodySize, "body-size", "", "Envia em cada request um corpo sintético do tamanho informado, gerado durante o envio sem ocupar memória (ex: 512KB, 10MB, 1GB)")
+	fs.BoolVar(&config.Chunked, "body-chunked", false, "Envia o corpo de --body-size com Transfer-Encoding: chunked em vez de Content-Length")
+	fs.Var(&form, "form", "Campo enviado em um corpo multipart/form-data no formato campo=valor (pode ser repetido)")
+	fs.Var(&formFiles, "form-file", "Arquivo enviado em um corpo multipart/form-data no formato campo=@arquivo, lido do disco a cada request (pode ser repetido)")
+	fs.StringVar(&config.ContentType, "content-type", "", "Content-Type do corpo (detectado automaticamente se omitido)")
+	fs.StringVar(&acceptEncoding, "accept-encoding", "", "Codificações pedidas no Accept-Encoding, separadas por vírgula: gzip, deflate, br ou identity; o relatório compara os bytes transferidos e descompactados")
+	fs.Var(headerFlags(config.Headers), "header", "Header enviado em cada request no formato \"Chave: Valor\" (pode ser repetido)")
+	fs.IntVar(&config.Requests, "requests", 0, "Número total de requests")
+	fs.IntVar(&config.Concurrency, "concurrency", 0, "Número de chamadas simultâneas")
+	fs.DurationVar(&config.Duration, "duration", 0, "Duração máxima do teste (ex: 30s, 5m)")
+	fs.DurationVar(&config.MaxDuration, "max-duration", 0, "Limite de segurança do tempo total de execução: o teste é abortado ao atingi-lo e o processo encerrado à força se não terminar em seguida (ex: 15m)")
+	fs.Float64Var(&config.Rate, "rate", 0, "Taxa alvo de requests por segundo (0 = sem limite)")
+	fs.StringVar(&stages, "stages", "", "Perfil de carga em estágios no formato duração:taxa, separados por vírgula (ex: 1m:50rps,5m:200rps,1m:0rps)")
+	fs.StringVar(&config.Preset, "preset", "", "Perfil de carga pronto em torno de --rate: spike (pico de 5× a taxa) ou soak (carga constante por --duration)")
+	fs.DurationVar(&config.Timeout, "timeout", 30*time.Second, "Timeout de cada request")
+	fs.DurationVar(&config.RampUp, "ramp-up", 0, "Tempo para aumentar os workers de 1 até --concurrency (ex: 30s)")
+	fs.StringVar(&config.Executor, "executor", string(loadtest.ExecutorClosed), "Modelo de execução: closed (workers fixos) ou open (taxa de chegada constante, exige --rate)")
+	fs.IntVar(&config.MaxVUs, "max-vus", 0, "Máximo de VUs que o executor open pode criar (padrão: --concurrency)")
+	fs.DurationVar(&config.Warmup, "warmup", 0, "Duração do aquecimento cujos resultados são descartados do relatório (ex: 10s)")
+	fs.DurationVar(&config.ThinkTime, "think-time", 0, "Pausa de cada worker entre requests, simulando um usuário (ex: 500ms)")
+	fs.DurationVar(&config.ThinkJitter, "think-time-jitter", 0, "Variação aleatória aplicada a cada pausa de --think-time, para mais ou para menos (ex: 200ms)")
+	fs.IntVar(&retries, "retries", 0, "Número de retentativas de um request que falhou em uma condição de --retry-on")
+	fs.DurationVar(&retryBackoff, "retry-backoff", 100*time.Millisecond, "Espera antes da primeira retentativa, dobrada a cada nova tentativa até 30s")
+	fs.StringVar(&retryOn, "retry-on", loadtest.DefaultRetryOn, "Condições que disparam uma retentativa: códigos, intervalos ou classes de status, timeout e error")
+	fs.IntVar(&config.WarmupReqs, "warmup-requests", 0, "Número de requests de aquecimento descartados do relatório")
+	fs.BoolVar(&http2, "http2", false, "Habilita HTTP/2 (negociado via ALPN em conexões TLS)")
+	fs.BoolVar(&http2PriorKnowledge, "http2-prior-knowledge", false, "Usa HTTP/2 sem negociação (h2c em http://)")
+	fs.BoolVar(&http3, "http3", false, "Usa HTTP/3 (QUIC); exige URLs https://")
+	fs.BoolVar(&ipv4, "ipv4", false, "Conecta apenas por IPv4")
+	fs.BoolVar(&ipv6, "ipv6", false, "Conecta apenas por IPv6")
+	fs.BoolVar(&config.NoKeepAlive, "disable-keepalive", false, "Abre uma nova conexão para cada request")
+	fs.StringVar(&config.PortExhaust, "port-exhaustion", string(loadtest.PortExhaustionReport), "Reação quando o gerador esgota as portas locais: report (apenas reporta), throttle (pausa a carga) ou reuse (reativa o keep-alive)")
+	fs.StringVar(&proxy, "proxy", "", "Proxy usado em todos os requests (http://, https:// ou socks5://)")
+	fs.BoolVar(&config.ProxyEnv, "proxy-from-env", false, "Usa as variáveis HTTP_PROXY, HTTPS_PROXY e NO_PROXY quando --proxy não é informado")
+	fs.Var(&resolve, "resolve", "Conecta em outro endereço mantendo Host e SNI, no formato \"host:porta:endereço\" (pode ser repetido)")
+	fs.StringVar(&config.DNSServer, "dns-server", "", "Servidor DNS usado para resolver os hosts (ex: 10.0.0.2 ou 10.0.0.2:53)")
+	fs.StringVar(&maxBandwidth, "max-bandwidth", "", "Limita a banda de leitura e escrita de cada conexão, simulando redes lentas (ex: 64KB, 1.5Mbps, slow-3g, fast-3g, 4g)")
+	fs.Var(&localAddrs, "local-addr", "IP local de origem das conexões; repetido, as conexões alternam entre os endereços (ex: 10.0.0.11)")
+	fs.StringVar(&config.UnixSocket, "unix-socket", "", "Envia os requests pelo unix domain socket informado; a URL define o caminho e o Host")
+	fs.IntVar(&config.MaxRedirect, "max-redirects", loadtest.DefaultMaxRedirects, "Número máximo de redirecionamentos seguidos por request")
+	fs.BoolVar(&noFollowRedirects, "no-follow-redirects", false, "Não segue redirecionamentos, medindo a resposta 3xx do próprio endpoint")
+	fs.IntVar(&config.Limits.MaxIdleConns, "max-idle-conns", 0, "Máximo de conexões ociosas mantidas no pool de cada cliente (0 = sem limite)")
+	fs.IntVar(&config.Limits.MaxIdleConnsPerHost, "max-idle-conns-per-host", 0, "Máximo de conexões ociosas por host no pool de cada cliente (padrão: o número de workers do cliente)")
+	fs.IntVar(&config.Limits.MaxConnsPerHost, "max-conns-per-host", 0, "Máximo de conexões abertas por host em cada cliente (0 = sem limite)")
+	fs.IntVar(&config.PerClient, "workers-per-client", 0, "Cria um cliente HTTP, com seu próprio pool de conexões, a cada N workers (0 = um cliente para todos)")
+	fs.BoolVar(&config.DryRun, "dry-run", false, "Valida a configuração, imprime o plano de execução e o primeiro request como seria enviado e termina sem enviar nenhum request")
+	fs.BoolVar(&config.Preflight, "preflight", false, "Antes do teste resolve o DNS, abre uma conexão e envia um request a cada alvo, abortando se algum estiver inacessível ou exigir autenticação")
+	fs.BoolVar(&config.RaiseLimit, "raise-nofile", false, "Aumenta o limite de arquivos abertos (ulimit -n) quando ele não comporta a concorrência; acima do limite rígido requer privilégios")
+	fs.BoolVar(&config.Cookies, "enable-cookies", false, "Mantém um cookie jar por usuário virtual (sessões entre requests)")
+	fs.BoolVar(&vuHeader, "vu-header", false, "Envia em cada request o id do usuário virtual no header X-Load-VU (também disponível nos templates como {{.VU}})")
+	fs.BoolVar(&config.Conditional, "conditional-requests", false, "Guarda o ETag e o Last-Modified das respostas por usuário virtual e os reenvia como If-None-Match e If-Modified-Since; 304 passa a ser sucesso")
+	fs.BoolVar(&config.Insecure, "insecure", false, "Não valida o certificado TLS do servidor")
+	fs.StringVar(&config.CACert, "ca-cert", "", "Arquivo PEM com CA adicional para validar o servidor")
+	fs.StringVar(&config.ClientCert, "client-cert", "", "Certificado PEM do cliente (mTLS)")
+	fs.StringVar(&config.ClientKey, "client-key", "", "Chave privada PEM do cliente (mTLS)")
+	fs.StringVar(&successCodes, "success-codes", "200", "Códigos de status considerados sucesso: códigos, intervalos ou classes (ex: 200,201,3xx,400-404)")
+	fs.StringVar(&config.Name, "name", "", "Nome do teste, gravado com o ID da execução nos relatórios, métricas e notificações (ex: checkout-pico)")
+	fs.Var(tagFlags(config.Tags), "tag", "Tag do teste no formato chave=valor, gravada nos relatórios (ex: env=staging; pode ser repetido)")
+	fs.StringVar(&abortOn, "abort-on-error-rate", "", "Interrompe o teste se a taxa de erro na janela passar do limite, no formato taxa@janela (ex: 50%@10s)")
+	fs.Var(&failIf, "fail-if", "Condição que faz o teste falhar com código de saída diferente de zero (ex: error-rate>1%, p95>500ms, rps<100; pode ser repetido)")
+	fs.BoolVar(&discardBody, "discard-body", false, "Descarta o corpo das respostas à medida que chega, contando os bytes (padrão)")
+	fs.BoolVar(&readBody, "read-body", false, "Lê o corpo de cada resposta inteiro em memória, como um cliente que o processa")
+	fs.StringVar(&config.SaveBodies, "save-bodies", "", "Diretório onde salvar as respostas com falha e uma amostra das com sucesso, com status, headers e corpo")
+	fs.Float64Var(&config.SaveRate, "save-bodies-rate", 0.01, "Fração das respostas com sucesso salvas por --save-bodies, de 0 a 1")
+	fs.IntVar(&config.Samples, "sample-failures", 0, "Inclui no relatório as primeiras N falhas, com status, headers e o início do corpo da resposta")
+	fs.Var(&assertContains, "assert-body-contains", "Exige que o corpo da resposta contenha o texto (pode ser repetido)")
+	fs.Var(&assertRegex, "assert-body-regex", "Exige que o corpo da resposta corresponda à expressão regular (pode ser repetido)")
+	fs.Var(&assertJSONPath, "assert-json-path", "Exige que o valor no JSONPath seja igual ao informado: \"$.caminho=valor\" (pode ser repetido)")
+	fs.StringVar(&config.Script, "script", "", "Script Starlark com as funções before(request), que altera cada request antes do envio (ex: assinatura), e check(response), que valida cada resposta")
+	fs.DurationVar(&config.Resolution, "timeline-interval", 0, "Resolução da linha do tempo do relatório (padrão: 1s; com --preset, ajustada à duração)")
+	fs.StringVar(&buckets, "latency-buckets", "", "Limites dos buckets do histograma de latência (ex: 10ms,50ms,100ms,500ms,1s)")
+	fs.BoolVar(&config.UI, "ui", false, "Exibe um painel ao vivo no terminal durante o teste")
+	fs.DurationVar(&config.Progress, "progress-interval", 5*time.Second, "Intervalo entre as mensagens de progresso (0 desabilita)")
+	fs.BoolVar(&config.Quiet, "quiet", false, "Exibe apenas o relatório, sem cabeçalho nem progresso")
+	fs.BoolVar(&config.Verbose, "verbose", false, "Exibe cada request com falha e o seu erro (equivale a --log-level=debug)")
+	fs.StringVar(&logLevel, "log-level", "info", "Nível mínimo das mensagens de log: debug, info, warn ou error")
+	fs.StringVar(&config.LogFormat, "log-format", "text", "Formato das mensagens de log: text ou json")
+	fs.StringVar(&config.MetricsAddr, "metrics-listen", "", "Endereço onde as métricas no formato Prometheus são expostas durante o teste (ex: :9090)")
+	fs.StringVar(&config.StatsD, "statsd", "", "Endereço UDP de um agente StatsD/DogStatsD que recebe as métricas de cada request (ex: 127.0.0.1:8125)")
+	fs.StringVar(&statsdTags, "statsd-tags", "", "Tags adicionadas às métricas StatsD, separadas por vírgula (ex: env:staging,team:checkout)")
+	fs.StringVar(&config.Influx.URL, "influxdb-url", "", "URL do InfluxDB que recebe os resultados (ex: http://localhost:8086)")
+	fs.StringVar(&config.Influx.Database, "influxdb-db", "", "Database do InfluxDB 1.x")
+	fs.StringVar(&config.Influx.Org, "influxdb-org", "", "Organização do InfluxDB 2.x")
+	fs.StringVar(&config.Influx.Bucket, "influxdb-bucket", "", "Bucket do InfluxDB 2.x")
+	fs.StringVar(&config.Influx.Token, "influxdb-token", "", "Token de acesso do InfluxDB 2.x")
+	fs.BoolVar(&config.Influx.Aggregate, "influxdb-per-second", false, "Grava um ponto agregado por segundo em vez de um ponto por request")
+	fs.StringVar(&influxTags, "influxdb-tags", "", "Tags adicionadas aos pontos do InfluxDB no formato chave=valor, separadas por vírgula (ex: release=1.4.2,env=staging)")
+	fs.BoolVar(&config.Tracing, "trace-propagation", false, "Inicia um trace por request e o propaga no header W3C traceparent")
+	fs.StringVar(&config.OTLP, "otlp-endpoint", "", "Endpoint OTLP/HTTP que recebe um span por request (ex: http://localhost:4318); habilita --trace-propagation")
+	fs.StringVar(&config.OTelService, "otel-service-name", "stress-test", "Valor de service.name dos spans exportados")
+	fs.StringVar(&agents, "agents", "", "Endereços dos agentes (stress-test agent) que dividem o teste, separados por vírgula (ex: 10.0.0.5:7070,10.0.0.6:7070)")
+	fs.StringVar(&config.AgentToken, "agent-token", "", "Token enviado aos agentes iniciados com --token")
+	fs.StringVar(&config.Serve, "serve", "", "Inicia a API REST para disparar testes no endereço informado (ex: :8089); os demais parâmetros são ignorados")
+	fs.StringVar(&config.ServeToken, "serve-token", "", "Token exigido pela API de --serve no header Authorization: Bearer; sem ele a API só aceita conexões locais")
+	fs.StringVar(&config.Output, "output", "text", "Formato do relatório: text ou json")
+	fs.StringVar(&config.OutputFile, "output-file", "", "Arquivo onde o relatório será gravado (padrão: stdout)")
+	fs.StringVar(&config.ReportHTML, "report-html", "", "Arquivo onde um relatório HTML com gráficos será gravado")
+	fs.StringVar(&config.OutputRaw, "output-raw", "", "Arquivo CSV onde cada request é gravado durante o teste")
+	fs.StringVar(&config.Compare, "compare", "", "Relatório JSON de uma execução anterior com o qual o teste é comparado; regressões encerram com código de saída 1")
+	fs.Float64Var(&config.MaxRegression, "max-regression", 10, "Piora máxima, em %, de req/s e dos percentis de latência em relação ao --compare")
+	fs.Float64Var(&config.MaxErrorDelta, "max-error-rate-increase", 1, "Aumento máximo, em pontos percentuais, da taxa de erro em relação ao --compare")
+	fs.StringVar(&config.Store, "store", "", "Banco SQLite onde o resumo e os requests de cada execução são gravados (consulte com stress-test history)")
+	fs.StringVar(&upload, "upload", "", "Envia os relatórios JSON e HTML ao final do teste para s3://bucket/prefixo ou gs://bucket/prefixo")
+	fs.StringVar(&config.Notify, "notify-webhook", "", "URL que recebe um resumo do teste ao final (compatível com webhooks do Slack)")
+	fs.StringVar(&config.Summary, "summary", "", "Imprime ao final uma linha de resumo para scripts: kv (chave=valor) ou json")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if config.Serve != "" {
+		return config, nil
+	}
 
-	if config.URL == "" {
-		return nil, fmt.Errorf("parâmetro --url é obrigatório")
+	config.Targets = targets
+	config.AssertContains = assertContains
+	config.AssertRegex = assertRegex
+	config.AssertJSONPath = assertJSONPath
+	config.Buckets = loadtest.DefaultLatencyBuckets
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	if configFile != "" {
+		loaded, err := loadConfigFile(configFile)
+		if err != nil {
+			return nil, fmt.Errorf("erro ao ler --config: %w", err)
+		}
+		file = loaded
+	}
+	if file != nil {
+		for key, value := range file.Tags {
+			if _, ok := config.Tags[key]; !ok {
+				config.Tags[key] = value
+			}
+		}
+		if !set["success-codes"] && file.SuccessCodes != "" {
+			successCodes = file.SuccessCodes
+		}
+		if !set["fail-if"] {
+			failIf = append(failIf, file.Thresholds...)
+		}
+		if !set["abort-on-error-rate"] && file.AbortOn != "" {
+			abortOn = file.AbortOn
+		}
+		if !set["proxy"] && file.Proxy != "" {
+			proxy = file.Proxy
+		}
+		if !set["statsd-tags"] && len(file.StatsDTags) > 0 {
+			statsdTags = strings.Join(file.StatsDTags, ",")
+		}
+		if !set["log-level"] && file.LogLevel != "" {
+			logLevel = file.LogLevel
+		}
+		if !set["read-body"] && !set["discard-body"] && file.ReadBody {
+			readBody = true
+		}
+		if !set["body-size"] && file.BodySize != "" {
+			bodySize = file.BodySize
+		}
+		if !set["speedup"] && file.Speedup != "" {
+			speedup = file.Speedup
+		}
+		if !set["vu-header"] && file.VUHeader {
+			vuHeader = true
+		}
+		if !set["accept-encoding"] && len(file.AcceptEncoding) > 0 {
+			acceptEncoding = strings.Join(file.AcceptEncoding, ",")
+		}
+		if !set["upload"] && file.Upload != "" {
+			upload = file.Upload
+		}
+		if !set["max-bandwidth"] && file.MaxBandwidth != "" {
+			maxBandwidth = file.MaxBandwidth
+		}
+		if !set["retries"] && file.Retries != 0 {
+			retries = file.Retries
+		}
+		if !set["retry-backoff"] && file.RetryBackoff != 0 {
+			retryBackoff = file.RetryBackoff
+		}
+		if !set["retry-on"] && len(file.RetryOn) > 0 {
+			retryOn = strings.Join(file.RetryOn, ",")
+		}
+		if !set["stages"] && len(file.Stages) > 0 {
+			stages = strings.Join(file.Stages, ",")
+		}
+		if !set["agents"] && len(file.Agents) > 0 {
+			agents = strings.Join(file.Agents, ",")
+		}
+		if !set["operation"] && len(file.OpenAPI.Operations) > 0 {
+			operations = strings.Join(file.OpenAPI.Operations, ",")
+		}
+		if !set["resolve"] {
+			resolve = append(resolve, file.Resolve...)
+		}
+		if !set["local-addr"] {
+			localAddrs = append(localAddrs, file.LocalAddrs...)
+		}
+		if !set["form"] && !set["form-file"] && !set["body"] && !set["body-file"] {
+			form, formFiles = append(form, file.Form...), append(formFiles, file.FormFiles...)
+		}
+		if !anySet(set, requestSourceFlags) && file.Scenario != "" {
+			scenarioFile = file.Scenario
+		}
+		if !set["ipv4"] && !set["ipv6"] {
+			switch loadtest.IPFamily(file.IPFamily) {
+			case loadtest.IPFamilyAny:
+			case loadtest.IPv4:
+				ipv4 = true
+			case loadtest.IPv6:
+				ipv6 = true
+			default:
+				return nil, fmt.Errorf("arquivo --config inválido: ip_family %q (use ipv4 ou ipv6)", file.IPFamily)
+			}
+		}
+		if err := file.applyTo(config, set, &body, &bodyFile); err != nil {
+			return nil, fmt.Errorf("arquivo --config inválido: %w", err)
+		}
+	}
+	if targetsFile != "" {
+		fileTargets, err := loadTargetsFile(targetsFile)
+		if err != nil {
+			return nil, fmt.Errorf("erro ao ler --targets-file: %w", err)
+		}
+		config.Targets = append(config.Targets, fileTargets...)
+	}
+	sources := 0
+	for _, used := range []bool{len(config.Targets) > 0, scenarioFile != "", config.HAR != "", fromCurl != "", config.Postman.Collection != "", config.OpenAPI.Spec != "", config.Stdin} {
+		if used {
+			sources++
+		}
+	}
+	// The grpc subcommand builds its own target from --call.
+	if sources == 0 && fs.Lookup("call") == nil {
+		return nil, fmt.Errorf("parâmetro --url, --targets-file, --scenario, --har, --from-curl, --postman, --openapi ou --stdin é obrigatório")
 	}
-	if config.Requests <= 0 {
-		return nil, fmt.Errorf("parâmetro --requests deve ser maior que 0")
+	if sources > 1 {
+		return nil, fmt.Errorf("use apenas um entre --url/--targets-file, --scenario, --har, --from-curl, --postman, --openapi e --stdin")
+	}
+	if speedup != "" && config.HAR == "" {
+		return nil, fmt.Errorf("--speedup exige --har")
+	}
+	switch {
+	case config.Stdin:
+		if strings.TrimSpace(agents) != "" {
+			return nil, fmt.Errorf("--stdin não pode ser usado com --agents")
+		}
+	case config.HAR != "":
+		config.Speedup = 1
+		if speedup != "" {
+			factor, err := parseSpeedup(speedup)
+			if err != nil {
+				return nil, fmt.Errorf("parâmetro --speedup inválido: %w", err)
+			}
+			config.Speedup, config.Timing = factor, true
+		}
+		scenario, err := loadHARFile(config.HAR, config.Timing, config.Speedup)
+		if err != nil {
+			return nil, fmt.Errorf("erro ao ler --har: %w", err)
+		}
+		config.Scenario = scenario
+	case config.Postman.Collection != "":
+		if err := applyPostman(config); err != nil {
+			return nil, fmt.Errorf("erro ao ler --postman: %w", err)
+		}
+	case config.OpenAPI.Spec != "":
+		for _, id := range strings.Split(operations, ",") {
+			if id = strings.TrimSpace(id); id != "" {
+				config.OpenAPI.Operations = append(config.OpenAPI.Operations, id)
+			}
+		}
+		targets, err := loadOpenAPI(config.OpenAPI)
+		if err != nil {
+			return nil, fmt.Errorf("erro ao ler --openapi: %w", err)
+		}
+		config.Targets = targets
+	case fromCurl != "":
+		commands, err := loadCurlCommands(fromCurl)
+		if err != nil {
+			return nil, fmt.Errorf("erro ao ler --from-curl: %w", err)
+		}
+		applyCurlCommands(config, set, commands, &body, bodyFile)
+	case scenarioFile != "":
+		scenario, err := loadScenarioFile(scenarioFile)
+		if err != nil {
+			return nil, fmt.Errorf("erro ao ler --scenario: %w", err)
+		}
+		config.Scenario = scenario
+	}
+	if config.Data != "" {
+		file, err := os.Open(config.Data)
+		if err != nil {
+			return nil, fmt.Errorf("erro ao ler --data: %w", err)
+		}
+		config.Feeder, err = loadtest.ReadCSVFeeder(file, loadtest.FeedMode(config.DataMode))
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("parâmetro --data inválido: %w", err)
+		}
+	}
+	for _, agent := range strings.Split(agents, ",") {
+		if agent = strings.TrimSpace(agent); agent != "" {
+			config.Agents = append(config.Agents, agent)
+		}
+	}
+	for _, tag := range strings.Split(influxTags, ",") {
+		if tag = strings.TrimSpace(tag); tag == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(tag, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			return nil, fmt.Errorf("parâmetro --influxdb-tags inválido: %q, use chave=valor", tag)
+		}
+		if config.Influx.Tags == nil {
+			config.Influx.Tags = make(map[string]string)
+		}
+		config.Influx.Tags[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	if config.Influx.URL != "" {
+		if _, err := config.Influx.writeURL(); err != nil {
+			return nil, fmt.Errorf("configuração do InfluxDB inválida: %w", err)
+		}
+	}
+	if config.OTLP != "" {
+		if u, err := url.Parse(config.OTLP); err != nil || u.Host == "" {
+			return nil, fmt.Errorf("parâmetro --otlp-endpoint inválido: %q", config.OTLP)
+		}
+		config.Tracing = true
+	}
+	for _, tag := range strings.Split(statsdTags, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			config.StatsDTags = append(config.StatsDTags, statsdTag(tag))
+		}
+	}
+	for _, encoding := range strings.Split(acceptEncoding, ",") {
+		if encoding = strings.ToLower(strings.TrimSpace(encoding)); encoding != "" {
+			config.Encodings = append(config.Encodings, encoding)
+		}
+	}
+	for _, scope := range strings.Split(oauth2Scopes, ",") {
+		if scope = strings.TrimSpace(scope); scope != "" {
+			config.OAuth2.Scopes = append(config.OAuth2.Scopes, scope)
+		}
+	}
+	if err := applyAuth(config); err != nil {
+		return nil, err
+	}
+	config.Method = strings.ToUpper(strings.TrimSpace(config.Method))
+	if !isValidMethod(config.Method) {
+		return nil, fmt.Errorf("parâmetro --method inválido: %q (use um de %s)", config.Method, strings.Join(supportedMethods, ", "))
+	}
+	if body != "" && bodyFile != "" {
+		return nil, fmt.Errorf("use apenas um dos parâmetros --body ou --body-file")
+	}
+	if body != "" {
+		config.Body = []byte(body)
+	}
+	if bodyFile != "" {
+		data, err := os.ReadFile(bodyFile)
+		if err != nil {
+			return nil, fmt.Errorf("erro ao ler --body-file: %w", err)
+		}
+		config.Body = data
+	}
+	if len(form) > 0 || len(formFiles) > 0 {
+		if body != "" || bodyFile != "" {
+			return nil, fmt.Errorf("--form e --form-file não podem ser usados com --body ou --body-file")
+		}
+		if config.ContentType != "" {
+			return nil, fmt.Errorf("--content-type não pode ser usado com --form e --form-file")
+		}
+		fields, err := parseForm(form, formFiles)
+		if err != nil {
+			return nil, err
+		}
+		config.Stream, config.ContentType, err = loadtest.MultipartForm(fields)
+		if err != nil {
+			return nil, fmt.Errorf("erro ao ler --form-file: %w", err)
+		}
+		config.Form = fields
+	}
+	if vuHeader {
+		config.VUHeader = "X-Load-VU"
+	}
+	if bodySize != "" {
+		if body != "" || bodyFile != "" || len(config.Form) > 0 {
+			return nil, fmt.Errorf("--body-size não pode ser usado com --body, --body-file, --form ou --form-file")
+		}
+		size, err := parseByteSize(bodySize)
+		if err != nil {
+			return nil, fmt.Errorf("parâmetro --body-size inválido: %w", err)
+		}
+		config.BodySize = size
+		config.Stream = loadtest.SyntheticBody(size, config.Chunked)
+		if config.ContentType == "" {
+			config.ContentType = "application/octet-stream"
+		}
+	} else if config.Chunked {
+		return nil, fmt.Errorf("--body-chunked exige --body-size")
+	}
+	if len(config.Body) > 0 && config.ContentType == "" {
+		config.ContentType = detectContentType(config.Body)
+	}
+	if discardBody && readBody {
+		return nil, fmt.Errorf("use apenas um dos parâmetros --discard-body ou --read-body")
+	}
+	config.BodyMode = loadtest.BodyDiscard
+	if readBody {
+		config.BodyMode = loadtest.BodyRead
+	}
+	if config.Samples < 0 {
+		return nil, fmt.Errorf("parâmetro --sample-failures não pode ser negativo")
+	}
+	if config.SaveRate < 0 || config.SaveRate > 1 {
+		return nil, fmt.Errorf("parâmetro --save-bodies-rate deve estar entre 0 e 1")
+	}
+	if config.Requests < 0 || config.Duration < 0 {
+		return nil, fmt.Errorf("parâmetros --requests e --duration não podem ser negativos")
+	}
+	if config.MaxDuration < 0 {
+		return nil, fmt.Errorf("parâmetro --max-duration não pode ser negativo")
+	}
+	if config.Resolution < 0 {
+		return nil, fmt.Errorf("parâmetro --timeline-interval não pode ser negativo")
+	}
+	if stages != "" {
+		switch {
+		case config.Preset != "":
+			return nil, fmt.Errorf("use --stages ou --preset, não ambos")
+		case config.Rate > 0:
+			return nil, fmt.Errorf("use --stages ou --rate, não ambos")
+		case config.Duration > 0:
+			return nil, fmt.Errorf("use --stages ou --duration, não ambos; a duração é a soma dos estágios")
+		}
+		parsed, err := loadtest.ParseStages(stages)
+		if err != nil {
+			return nil, fmt.Errorf("parâmetro --stages inválido: %w", err)
+		}
+		config.Stages = parsed
+	}
+	if config.Preset != "" {
+		if err := applyPreset(config, config.Preset); err != nil {
+			return nil, err
+		}
+	}
+	if config.Resolution == 0 {
+		config.Resolution = time.Second
+	}
+	if len(config.Stages) > 0 {
+		switch {
+		case config.Warmup > 0 || config.WarmupReqs > 0:
+			return nil, fmt.Errorf("estágios não podem ser usados com warm-up; use um primeiro estágio de taxa baixa")
+		case config.Stdin:
+			return nil, fmt.Errorf("estágios não podem ser usados com --stdin")
+		case len(config.Agents) > 0:
+			return nil, fmt.Errorf("estágios não podem ser usados com --agents")
+		}
+	}
+	if config.Requests == 0 && config.Duration == 0 && !config.Stdin && len(config.Stages) == 0 {
+		return nil, fmt.Errorf("informe --requests maior que 0 e/ou --duration")
 	}
 	if config.Concurrency <= 0 {
 		return nil, fmt.Errorf("parâmetro --concurrency deve ser maior que 0")
 	}
-	if config.Concurrency > config.Requests {
-		config.Concurrency = config.Requests
+	if config.Rate < 0 {
+		return nil, fmt.Errorf("parâmetro --rate não pode ser negativo")
+	}
+	if config.Timeout <= 0 {
+		return nil, fmt.Errorf("parâmetro --timeout deve ser maior que 0")
+	}
+	if config.RampUp < 0 {
+		return nil, fmt.Errorf("parâmetro --ramp-up não pode ser negativo")
+	}
+	if config.ThinkTime < 0 || config.ThinkJitter < 0 {
+		return nil, fmt.Errorf("parâmetros --think-time e --think-time-jitter não podem ser negativos")
+	}
+	if retries > 0 {
+		policy, err := loadtest.ParseRetryPolicy(retries, retryBackoff, retryOn)
+		if err != nil {
+			return nil, fmt.Errorf("parâmetros de retentativa inválidos: %w", err)
+		}
+		config.Retry = policy
+	} else if retries < 0 {
+		return nil, fmt.Errorf("parâmetro --retries não pode ser negativo")
+	}
+	switch loadtest.Executor(config.Executor) {
+	case loadtest.ExecutorClosed:
+		if config.MaxVUs != 0 {
+			return nil, fmt.Errorf("parâmetro --max-vus exige --executor=open")
+		}
+	case loadtest.ExecutorOpen:
+		if config.Rate <= 0 && len(config.Stages) == 0 {
+			return nil, fmt.Errorf("--executor=open exige --rate maior que 0 ou --stages")
+		}
+		if config.RampUp > 0 {
+			return nil, fmt.Errorf("--executor=open não pode ser usado com --ramp-up")
+		}
+		if config.Stdin {
+			return nil, fmt.Errorf("--executor=open não pode ser usado com --stdin")
+		}
+		if len(config.Agents) > 0 {
+			return nil, fmt.Errorf("--executor=open não pode ser usado com --agents")
+		}
+		if config.MaxVUs < 0 {
+			return nil, fmt.Errorf("parâmetro --max-vus não pode ser negativo")
+		}
+		if config.MaxVUs > 0 && config.MaxVUs < config.Concurrency {
+			return nil, fmt.Errorf("parâmetro --max-vus não pode ser menor que --concurrency")
+		}
+	default:
+		return nil, fmt.Errorf("parâmetro --executor inválido: %q (use closed ou open)", config.Executor)
+	}
+	switch loadtest.PortExhaustion(config.PortExhaust) {
+	case loadtest.PortExhaustionReport, loadtest.PortExhaustionThrottle, loadtest.PortExhaustionReuse:
+	default:
+		return nil, fmt.Errorf("parâmetro --port-exhaustion inválido: %q (use report, throttle ou reuse)", config.PortExhaust)
+	}
+	if proxy != "" {
+		u, err := url.Parse(proxy)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("parâmetro --proxy inválido: %q", proxy)
+		}
+		config.Proxy = u
+	}
+	for _, value := range resolve {
+		hostPort, addr, err := parseResolve(value)
+		if err != nil {
+			return nil, fmt.Errorf("parâmetro --resolve inválido: %w", err)
+		}
+		if config.Resolve == nil {
+			config.Resolve = make(map[string]string)
+		}
+		config.Resolve[hostPort] = addr
+	}
+	config.LocalAddrs = localAddrs
+	if config.DNSServer != "" {
+		if _, _, err := net.SplitHostPort(config.DNSServer); err != nil {
+			config.DNSServer = net.JoinHostPort(strings.Trim(config.DNSServer, "[]"), "53")
+		}
+	}
+	if noFollowRedirects {
+		config.MaxRedirect = 0
+	}
+	if config.MaxRedirect < 0 {
+		return nil, fmt.Errorf("parâmetro --max-redirects não pode ser negativo")
+	}
+	if config.Limits.MaxIdleConns < 0 || config.Limits.MaxIdleConnsPerHost < 0 || config.Limits.MaxConnsPerHost < 0 || config.PerClient < 0 {
+		return nil, fmt.Errorf("parâmetros --max-idle-conns, --max-idle-conns-per-host, --max-conns-per-host e --workers-per-client não podem ser negativos")
+	}
+	if config.Warmup < 0 || config.WarmupReqs < 0 {
+		return nil, fmt.Errorf("parâmetros --warmup e --warmup-requests não podem ser negativos")
+	}
+	if config.Warmup > 0 && config.WarmupReqs > 0 {
+		return nil, fmt.Errorf("use apenas um entre --warmup e --warmup-requests")
+	}
+	if config.Progress < 0 {
+		return nil, fmt.Errorf("parâmetro --progress-interval não pode ser negativo")
+	}
+	if config.Quiet && (config.Verbose || config.UI) {
+		return nil, fmt.Errorf("--quiet não pode ser usado com --verbose ou --ui")
+	}
+	level, err := parseLogLevel(logLevel)
+	if err != nil {
+		return nil, fmt.Errorf("parâmetro --log-level inválido: %w", err)
+	}
+	config.LogLevel = level
+	if config.Verbose {
+		config.LogLevel = min(config.LogLevel, slog.LevelDebug)
+	}
+	if config.LogFormat != "text" && config.LogFormat != "json" {
+		return nil, fmt.Errorf("parâmetro --log-format inválido: %q (use text ou json)", config.LogFormat)
+	}
+	config.Protocol = loadtest.ProtocolHTTP1
+	protocols := 0
+	for _, selected := range []struct {
+		enabled  bool
+		protocol loadtest.Protocol
+	}{
+		{http2, loadtest.ProtocolHTTP2},
+		{http2PriorKnowledge, loadtest.ProtocolHTTP2PriorKnowledge},
+		{http3, loadtest.ProtocolHTTP3},
+	} {
+		if selected.enabled {
+			config.Protocol = selected.protocol
+			protocols++
+		}
+	}
+	if protocols > 1 {
+		return nil, fmt.Errorf("use apenas um dos parâmetros --http2, --http2-prior-knowledge ou --http3")
+	}
+	switch {
+	case ipv4 && ipv6:
+		return nil, fmt.Errorf("use apenas um dos parâmetros --ipv4 ou --ipv6")
+	case ipv4:
+		config.IPFamily = loadtest.IPv4
+	case ipv6:
+		config.IPFamily = loadtest.IPv6
+	}
+	if maxBandwidth != "" {
+		if config.Protocol == loadtest.ProtocolHTTP3 {
+			return nil, fmt.Errorf("--max-bandwidth não pode ser usado com --http3")
+		}
+		bandwidth, err := loadtest.ParseBandwidth(maxBandwidth)
+		if err != nil {
+			return nil, fmt.Errorf("parâmetro --max-bandwidth inválido: %w", err)
+		}
+		config.Bandwidth = bandwidth
+	}
+	tlsConfig, err := buildTLSConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	config.TLS = tlsConfig
+	if config.Assertions, err = buildAssertions(config.AssertContains, config.AssertRegex, config.AssertJSONPath); err != nil {
+		return nil, err
+	}
+	if config.Script != "" {
+		script, err := loadScript(config.Script)
+		if err != nil {
+			return nil, fmt.Errorf("parâmetro --script inválido: %w", err)
+		}
+		config.Hook, config.Check = script.requestHook(), script.responseCheck()
+	}
+	if config.SigV4.Enabled {
+		config.Hook = config.SigV4.hook(config.Hook)
+	}
+	// A 304 is the expected answer to a conditional request.
+	if config.Conditional && !set["success-codes"] && successCodes == "200" {
+		successCodes = "200,304"
+	}
+	if config.SuccessCodes, err = loadtest.ParseStatusSet(successCodes); err != nil {
+		return nil, fmt.Errorf("parâmetro --success-codes inválido: %w", err)
+	}
+	for _, expr := range failIf {
+		threshold, err := loadtest.ParseThreshold(expr)
+		if err != nil {
+			return nil, fmt.Errorf("parâmetro --fail-if inválido: %w", err)
+		}
+		config.Thresholds = append(config.Thresholds, threshold)
+	}
+	if abortOn != "" {
+		rule, err := loadtest.ParseAbortRule(abortOn)
+		if err != nil {
+			return nil, fmt.Errorf("parâmetro --abort-on-error-rate inválido: %w", err)
+		}
+		config.Abort = &rule
+	}
+	if config.MaxRegression < 0 || config.MaxErrorDelta < 0 {
+		return nil, fmt.Errorf("parâmetros --max-regression e --max-error-rate-increase não podem ser negativos")
+	}
+	if config.Compare != "" {
+		if config.Baseline, err = loadBaseline(config.Compare); err != nil {
+			return nil, fmt.Errorf("parâmetro --compare inválido: %w", err)
+		}
+	}
+	if buckets != "" {
+		bounds, err := parseDurationList(buckets)
+		if err != nil {
+			return nil, fmt.Errorf("parâmetro --latency-buckets inválido: %w", err)
+		}
+		if len(bounds) > 0 {
+			config.Buckets = bounds
+		}
+	}
+	if config.Output != "text" && config.Output != "json" {
+		return nil, fmt.Errorf("parâmetro --output inválido: %q (use text ou json)", config.Output)
+	}
+	config.Metadata = collectMetadata(config.Name, config.Tags)
+	for _, path := range []*string{&config.OutputFile, &config.OutputRaw, &config.ReportHTML, &config.SaveBodies} {
+		*path = strings.ReplaceAll(*path, "{run}", config.Metadata.RunID)
+	}
+	if config.Notify != "" {
+		if _, err := parseWebhookURL(config.Notify); err != nil {
+			return nil, fmt.Errorf("parâmetro --notify-webhook inválido: %w", err)
+		}
+	}
+	if upload != "" {
+		if config.Upload, err = parseUploadURL(upload); err != nil {
+			return nil, fmt.Errorf("parâmetro --upload inválido: %w", err)
+		}
+	}
+	if config.Summary != "" && config.Summary != "kv" && config.Summary != "json" {
+		return nil, fmt.Errorf("parâmetro --summary inválido: %q (use kv ou json)", config.Summary)
 	}
 
 	return config, nil
 }
 
-func worker(ctx context.Context, client *http.Client, url string, jobs <-chan int, results chan<- Result) {
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case _, ok := <-jobs:
-			if !ok {
-				return
-			}
-
-			startTime := time.Now()
-			req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-			if err != nil {
-				results <- Result{Error: err, Duration: time.Since(startTime)}
-				continue
-			}
-
-			resp, err := client.Do(req)
-			duration := time.Since(startTime)
-
-			if err != nil {
-				results <- Result{Error: err, Duration: duration}
-				continue
-			}
-
-			resp.Body.Close()
-			results <- Result{
-				StatusCode: resp.StatusCode,
-				Duration:   duration,
-			}
+func (c *Config) options() []loadtest.Option {
+	options := []loadtest.Option{
+		loadtest.WithTargets(c.Targets...),
+		loadtest.WithMethod(c.Method),
+		loadtest.WithBody(c.Body, c.ContentType),
+		loadtest.WithHeaders(c.Headers),
+		loadtest.WithAcceptEncoding(c.Encodings...),
+		loadtest.WithBodyMode(c.BodyMode),
+		loadtest.WithFailureSamples(c.Samples),
+		loadtest.WithRequests(c.Requests),
+		loadtest.WithConcurrency(c.Concurrency),
+		loadtest.WithDuration(c.Duration),
+		loadtest.WithRate(c.Rate),
+		loadtest.WithStages(c.Stages...),
+		loadtest.WithRampUp(c.RampUp),
+		loadtest.WithExecutor(loadtest.Executor(c.Executor)),
+		loadtest.WithMaxVUs(c.MaxVUs),
+		loadtest.WithWarmup(c.Warmup),
+		loadtest.WithWarmupRequests(c.WarmupReqs),
+		loadtest.WithThinkTime(c.ThinkTime, c.ThinkJitter),
+		loadtest.WithRetryPolicy(c.Retry),
+		loadtest.WithTimeout(c.Timeout),
+		loadtest.WithLatencyBuckets(c.Buckets),
+		loadtest.WithTimelineInterval(c.Resolution),
+		loadtest.WithProtocol(c.Protocol),
+		loadtest.WithTLSConfig(c.TLS),
+		loadtest.WithKeepAlive(!c.NoKeepAlive),
+		loadtest.WithPortExhaustion(loadtest.PortExhaustion(c.PortExhaust)),
+		loadtest.WithCookies(c.Cookies),
+		loadtest.WithConditionalRequests(c.Conditional),
+		loadtest.WithMaxRedirects(c.MaxRedirect),
+		loadtest.WithConnectionLimits(c.Limits),
+		loadtest.WithWorkersPerClient(c.PerClient),
+		loadtest.WithProxy(c.Proxy),
+		loadtest.WithProxyFromEnvironment(c.ProxyEnv),
+		loadtest.WithDNSServer(c.DNSServer),
+		loadtest.WithUnixSocket(c.UnixSocket),
+		loadtest.WithIPFamily(c.IPFamily),
+		loadtest.WithMaxBandwidth(c.Bandwidth),
+		loadtest.WithTraceContext(c.Tracing),
+		loadtest.WithVUHeader(c.VUHeader),
+		loadtest.WithAssertions(c.Assertions...),
+		loadtest.WithSuccessCodes(c.SuccessCodes),
+		loadtest.WithThresholds(c.Thresholds...),
+		loadtest.WithMetadata(c.Metadata),
+	}
+	for _, addr := range c.LocalAddrs {
+		options = append(options, loadtest.WithLocalAddr(addr))
+	}
+	for hostPort, addr := range c.Resolve {
+		options = append(options, loadtest.WithResolve(hostPort, addr))
+	}
+	if c.Stream != nil {
+		options = append(options, loadtest.WithBodyStream(c.Stream, c.ContentType))
+	}
+	if c.SaveBodies != "" {
+		options = append(options, loadtest.WithSaveBodies(c.SaveBodies, c.SaveRate))
+	}
+	if c.Scenario != nil {
+		options = append(options, loadtest.WithScenario(*c.Scenario))
+	}
+	if c.Feeder != nil {
+		options = append(options, loadtest.WithFeeder(c.Feeder))
+	}
+	if c.OAuth2.TokenURL != "" {
+		options = append(options, loadtest.WithOAuth2(c.OAuth2))
+	}
+	if c.Abort != nil {
+		options = append(options, loadtest.WithAbortRule(*c.Abort))
+	}
+	if c.Hook != nil {
+		options = append(options, loadtest.WithRequestHook(c.Hook))
+	}
+	if c.Check != nil {
+		options = append(options, loadtest.WithResponseCheck(c.Check))
+	}
+	return options
+}
 
+func printBanner(w io.Writer, config *Config, concurrency int) {
+	if config.DryRun {
+		fmt.Fprintln(w, "Simulação (--dry-run): nenhum request será enviado")
+	} else {
+		fmt.Fprintf(w, "Iniciando teste de carga...\n")
+	}
+	fmt.Fprintf(w, "Execução: %s\n", runLabel(config.Metadata))
+	for _, target := range config.Targets {
+		if target.Name != "" {
+			fmt.Fprintf(w, "Alvo: %s (%s %s)\n", target.Name, target.Method, target.URL)
+		} else if len(config.Targets) > 1 {
+			fmt.Fprintf(w, "URL: %s (peso %d)\n", target.URL, target.Weight)
+		} else {
+			fmt.Fprintf(w, "URL: %s\n", target.URL)
+		}
+	}
+	if config.Stdin {
+		fmt.Fprintln(w, "Fonte: requests lidos do stdin (JSON por linha)")
+	} else if config.Scenario != nil {
+		fmt.Fprintf(w, "Cenário: %s (%d passos)\n", config.Scenario.Name, len(config.Scenario.Steps))
+		if config.HAR != "" && config.Timing && config.Speedup != 1 {
+			fmt.Fprintf(w, "Intervalos: os da captura, em velocidade %gx\n", config.Speedup)
+		} else if config.HAR != "" && config.Timing {
+			fmt.Fprintln(w, "Intervalos: mantidos como na captura")
+		}
+		for i, step := range config.Scenario.Steps {
+			fmt.Fprintf(w, "  %d. %s %s\n", i+1, step.Method, step.URL)
+		}
+	} else if config.GRPC.Call != "" {
+		source := "reflexão do servidor"
+		if len(config.GRPC.Protos) > 0 {
+			source = strings.Join(config.GRPC.Protos, ", ")
+		}
+		fmt.Fprintf(w, "gRPC: %s (%s)\n", config.GRPC.Call, source)
+	} else if config.Postman.Collection == "" && config.OpenAPI.Spec == "" {
+		fmt.Fprintf(w, "Método: %s\n", config.Method)
+	}
+	if config.Feeder != nil {
+		fmt.Fprintf(w, "Dados: %s (%d linhas, %s)\n", config.Data, config.Feeder.Len(), config.Feeder.Mode())
+	}
+	fmt.Fprintf(w, "Protocolo: %s\n", config.Protocol)
+	if config.IPFamily != loadtest.IPFamilyAny {
+		fmt.Fprintf(w, "Família de endereço: somente %s\n", ipFamilyName(config.IPFamily))
+	}
+	if config.NoKeepAlive {
+		fmt.Fprint(w, "Keep-alive: desabilitado")
+		if config.PortExhaust == string(loadtest.PortExhaustionReuse) {
+			fmt.Fprint(w, " até o esgotamento das portas locais")
+		}
+		fmt.Fprintln(w)
+	}
+	if config.PortExhaust != string(loadtest.PortExhaustionReport) {
+		fmt.Fprintln(w, "Portas locais esgotadas: a carga é pausada até que sejam liberadas")
+	}
+	if config.Cookies {
+		fmt.Fprintln(w, "Cookies: um cookie jar por usuário virtual")
+	}
+	if config.VUHeader != "" {
+		fmt.Fprintf(w, "Usuário virtual: id enviado no header %s\n", config.VUHeader)
+	}
+	if config.Conditional {
+		fmt.Fprintln(w, "Cache: requests condicionais com ETag/Last-Modified por usuário virtual")
+	}
+	if config.MetricsAddr != "" {
+		fmt.Fprintf(w, "Métricas Prometheus: http://%s/metrics\n", config.MetricsAddr)
+	}
+	if config.StatsD != "" {
+		fmt.Fprintf(w, "StatsD: %s\n", config.StatsD)
+	}
+	if config.Influx.URL != "" {
+		fmt.Fprintf(w, "InfluxDB: %s\n", config.Influx.URL)
+	}
+	if config.OTLP != "" {
+		fmt.Fprintf(w, "Tracing: spans exportados para %s\n", config.OTLP)
+	} else if config.Tracing {
+		fmt.Fprintln(w, "Tracing: header traceparent propagado")
+	}
+	if len(config.Agents) > 0 {
+		fmt.Fprintf(w, "Agentes: %s\n", strings.Join(config.Agents, ", "))
+	}
+	if config.Proxy != nil {
+		fmt.Fprintf(w, "Proxy: %s\n", config.Proxy.Redacted())
+	} else if config.ProxyEnv {
+		fmt.Fprintln(w, "Proxy: variáveis de ambiente")
+	}
+	hostPorts := make([]string, 0, len(config.Resolve))
+	for hostPort := range config.Resolve {
+		hostPorts = append(hostPorts, hostPort)
+	}
+	sort.Strings(hostPorts)
+	for _, hostPort := range hostPorts {
+		fmt.Fprintf(w, "Resolve: %s → %s\n", hostPort, config.Resolve[hostPort])
+	}
+	if config.UnixSocket != "" {
+		fmt.Fprintf(w, "Unix socket: %s\n", config.UnixSocket)
+	}
+	if len(config.LocalAddrs) > 0 {
+		fmt.Fprintf(w, "Endereços locais: %s\n", strings.Join(config.LocalAddrs, ", "))
+	}
+	if config.Bandwidth > 0 {
+		fmt.Fprintf(w, "Banda máxima por conexão: %s/s\n", formatBytes(config.Bandwidth))
+	}
+	if config.DNSServer != "" {
+		fmt.Fprintf(w, "Servidor DNS: %s\n", config.DNSServer)
+	}
+	if config.PerClient > 0 {
+		fmt.Fprintf(w, "Clientes HTTP: um a cada %d workers\n", config.PerClient)
+	}
+	if config.MaxRedirect == 0 {
+		fmt.Fprintln(w, "Redirecionamentos: não seguidos")
+	} else if config.MaxRedirect != loadtest.DefaultMaxRedirects {
+		fmt.Fprintf(w, "Redirecionamentos: até %d\n", config.MaxRedirect)
+	}
+	fmt.Fprintf(w, "Códigos de sucesso: %s\n", config.SuccessCodes)
+	if config.Abort != nil {
+		fmt.Fprintf(w, "Abortar se a taxa de erro passar de %g%% em %v\n", config.Abort.Rate, config.Abort.Window)
+	}
+	for _, assertion := range config.Assertions {
+		fmt.Fprintf(w, "Asserção: %s\n", assertion)
+	}
+	if config.Script != "" {
+		fmt.Fprintf(w, "Script: %s\n", config.Script)
+	}
+	if config.Insecure {
+		fmt.Fprintln(w, "TLS: verificação de certificado desabilitada")
+	}
+	if len(config.Body) > 0 {
+		fmt.Fprintf(w, "Corpo: %d bytes (%s)\n", len(config.Body), config.ContentType)
+	}
+	if config.BodySize > 0 {
+		mode := "Content-Length"
+		if config.Chunked {
+			mode = "chunked"
+		}
+		fmt.Fprintf(w, "Corpo: %s sintéticos por request (%s, %s)\n", formatBytes(float64(config.BodySize)), config.ContentType, mode)
+	}
+	if len(config.Form) > 0 {
+		fmt.Fprintf(w, "Corpo: multipart/form-data com %s\n", formSummary(config.Form))
+	}
+	printAuth(w, config)
+	if len(config.Headers) > 0 {
+		fmt.Fprintf(w, "Headers: %d\n", len(config.Headers))
+	}
+	if config.BodyMode == loadtest.BodyRead {
+		fmt.Fprintln(w, "Respostas: corpo lido inteiro em memória")
+	}
+	if config.SaveBodies != "" {
+		fmt.Fprintf(w, "Respostas salvas em %s: falhas e %g%% das com sucesso\n", config.SaveBodies, config.SaveRate*100)
+	}
+	if len(config.Encodings) > 0 {
+		fmt.Fprintf(w, "Accept-Encoding: %s (respostas descompactadas pelo teste)\n", strings.Join(config.Encodings, ", "))
+	}
+	if config.Requests > 0 && config.Scenario != nil {
+		fmt.Fprintf(w, "Total de iterações: %d\n", config.Requests)
+	} else if config.Requests > 0 {
+		fmt.Fprintf(w, "Total de requests: %d\n", config.Requests)
+	}
+	if config.Duration > 0 {
+		fmt.Fprintf(w, "Duração: %v\n", config.Duration)
+	}
+	fmt.Fprintf(w, "Concorrência: %d\n", concurrency)
+	if config.Executor == string(loadtest.ExecutorOpen) {
+		maxVUs := max(config.MaxVUs, concurrency)
+		fmt.Fprintf(w, "Executor: open (taxa de chegada, até %d VUs)\n", maxVUs)
+	}
+	fmt.Fprintf(w, "Timeout por request: %v\n", config.Timeout)
+	if config.RampUp > 0 {
+		fmt.Fprintf(w, "Ramp-up: 1 → %d workers em %v\n", concurrency, config.RampUp)
+	}
+	if config.Rate > 0 {
+		fmt.Fprintf(w, "Taxa alvo: %.2f req/s\n", config.Rate)
+	}
+	if config.Preset != "" {
+		fmt.Fprintf(w, "Preset: %s\n", config.Preset)
+	}
+	if len(config.Stages) > 0 {
+		fmt.Fprintln(w, "Estágios:")
+		for i, stage := range config.Stages {
+			fmt.Fprintf(w, "  %d. %v até %.2f req/s\n", i+1, stage.Duration, stage.Rate)
 		}
 	}
+	if config.Warmup > 0 {
+		fmt.Fprintf(w, "Warm-up: %v (resultados descartados)\n", config.Warmup)
+	}
+	if config.ThinkTime > 0 || config.ThinkJitter > 0 {
+		fmt.Fprintf(w, "Think time: %v ± %v\n", config.ThinkTime, config.ThinkJitter)
+	}
+	if config.Retry.Retries > 0 {
+		fmt.Fprintf(w, "Retentativas: até %d (backoff %v, em %s)\n", config.Retry.Retries, config.Retry.Backoff, config.Retry)
+	}
+	if config.WarmupReqs > 0 {
+		fmt.Fprintf(w, "Warm-up: %d requests (resultados descartados)\n", config.WarmupReqs)
+	}
+	fmt.Fprintln(w)
 }
 
-func runLoadTest(config *Config) *Report {
-	fmt.Printf("Iniciando teste de carga...\n")
-	fmt.Printf("URL: %s\n", config.URL)
-	fmt.Printf("Total de requests: %d\n", config.Requests)
-	fmt.Printf("Concorrência: %d\n", config.Concurrency)
-	fmt.Println()
-
-	client := &http.Client{
-		Timeout: 30 * time.Second,
-	}
+func usageError(err error) {
+	fmt.Fprintf(os.Stderr, "Erro: %v\n", err)
+	fmt.Fprintf(os.Stderr, "\nUso: %s --url=<URL> [--url=<URL> ...] --requests=<NUM> --concurrency=<NUM> [--method=<METHOD>]\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "     %s grpc --address=<HOST:PORTA> --call=<pacote.Serviço/Método> [--proto=<ARQUIVO>] [--payload=<JSON>] --requests=<NUM> --concurrency=<NUM>\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "     %s ws --url=<ws://...> --connections=<NUM> [--messages=<NUM>] [--duration=<DURAÇÃO>] [--rate=<NUM>]\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "     %s history --store=<ARQUIVO> [--name=<NOME>] [--tag=<CHAVE=VALOR>] [--compare=<ID>,<ID>]\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "     %s record --target=<URL> --output=<ARQUIVO.har> [--listen=<HOST:PORTA>] [--duration=<DURAÇÃO>]\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "     %s tcp|udp --address=<HOST:PORTA> [--payload=<DADOS>] [--read-reply] --requests=<NUM> --concurrency=<NUM>\n", os.Args[0])
+	flag.PrintDefaults()
+	os.Exit(exitError)
+}
 
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+// maxDurationGrace is how long a run aborted by --max-duration has to write
+// its reports before the process is killed.
+const maxDurationGrace = 30 * time.Second
 
-	jobs := make(chan int, config.Requests)
-	results := make(chan Result, config.Requests)
+var errMaxDuration = errors.New("tempo máximo de execução atingido")
 
-	var wg sync.WaitGroup
-	for i := 0; i < config.Concurrency; i++ {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			worker(ctx, client, config.URL, jobs, results)
-		}()
+func main() {
+	started := time.Now()
+	subcommands := map[string]func([]string) error{
+		"agent":   runAgent,
+		"history": runHistory,
+		"record":  runRecord,
+		"ws":      runWebSocket,
+		"tcp":     func(args []string) error { return runSocket("tcp", args) },
+		"udp":     func(args []string) error { return runSocket("udp", args) },
+	}
+	if len(os.Args) > 1 && subcommands[os.Args[1]] != nil {
+		slog.SetDefault(newLogger(os.Stderr, slog.LevelInfo, "text"))
+		if err := subcommands[os.Args[1]](os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "Erro: %v\n", err)
+			if errors.As(err, new(failedError)) {
+				os.Exit(exitFailed)
+			}
+			os.Exit(exitError)
+		}
+		return
 	}
 
-	startTime := time.Now()
-	go func() {
-		defer close(jobs)
-		for i := 0; i < config.Requests; i++ {
-			jobs <- i
+	var config *Config
+	var err error
+	if len(os.Args) > 1 && os.Args[1] == "grpc" {
+		config, err = parseGRPCFlags(flag.CommandLine, os.Args[2:])
+	} else {
+		config, err = parseFlags(flag.CommandLine, os.Args[1:])
+	}
+	if err != nil {
+		usageError(err)
+	}
+	slog.SetDefault(newLogger(os.Stderr, config.LogLevel, config.LogFormat))
+	if config.Serve != "" {
+		if err := runServer(config.Serve, config.ServeToken); err != nil {
+			fatal("falha no servidor da API", err)
 		}
-	}()
+		return
+	}
 
-	report := &Report{
-		StatusCodes: make(map[int]int),
+	if config.MaxDuration > 0 {
+		time.AfterFunc(config.MaxDuration+maxDurationGrace, func() {
+			slog.Error("o teste não terminou após atingir --max-duration, encerrando o processo", "max_duration", config.MaxDuration)
+			os.Exit(exitAborted)
+		})
 	}
 
-	for i := 0; i < config.Requests; i++ {
-		result := <-results
-		report.TotalRequests++
+	out := config.logWriter()
+	options := config.options()
+	if config.DryRun {
+		if err := dryRun(out, config, options); err != nil {
+			usageError(err)
+		}
+		return
+	}
+	if config.Upload != nil {
+		if err := checkUploadCredentials(config.Upload); err != nil {
+			fatal("--upload para o S3 exige credenciais", err)
+		}
+	}
 
-		if result.Error != nil {
-			report.StatusCodes[0]++
+	var dash *dashboard
+	if config.UI {
+		if isTerminal(out) {
+			dash = newDashboard(out, config.Requests)
+			options = append(options, loadtest.WithResultHandler(dash.record))
 		} else {
-			report.StatusCodes[result.StatusCode]++
-			if result.StatusCode == 200 {
-				report.SuccessRequests++
-			}
+			slog.Warn("--ui ignorado, a saída não é um terminal")
+		}
+	}
+	var progress *progressPrinter
+	if dash == nil && !config.Quiet && config.Progress > 0 {
+		unit := "requests"
+		if config.Scenario != nil {
+			unit = "iterations"
 		}
+		progress = newProgressPrinter(unit, config.Progress)
+		options = append(options, loadtest.WithProgress(progress.update))
+	}
+	if slog.Default().Enabled(context.Background(), slog.LevelDebug) {
+		options = append(options, loadtest.WithResultHandler(logFailures(config.SuccessCodes)))
+	}
 
-		if (i+1)%100 == 0 || i+1 == config.Requests {
-			fmt.Printf("Progress: %d/%d requests completed\n", i+1, config.Requests)
+	var raw *rawWriter
+	if config.OutputRaw != "" {
+		raw, err = newRawWriter(config.OutputRaw)
+		if err != nil {
+			fatal("falha ao criar o arquivo de resultados brutos", err)
 		}
+		options = append(options, loadtest.WithResultHandler(raw.write))
 	}
 
-	report.TotalTime = time.Since(startTime)
+	var store *resultStore
+	if config.Store != "" {
+		store, err = openStore(config.Store)
+		if err != nil {
+			fatal("falha ao abrir o banco de resultados", err)
+		}
+		defer store.close()
+		if err := store.begin(); err != nil {
+			fatal("falha ao abrir o banco de resultados", err)
+		}
+		options = append(options, loadtest.WithResultHandler(store.record))
+	}
 
-	cancel()
-	wg.Wait()
-	close(results)
+	var metrics *metricsServer
+	if config.MetricsAddr != "" {
+		metrics, err = newMetricsServer(config.MetricsAddr, config.Buckets, runTags(config.Metadata))
+		if err != nil {
+			fatal("falha ao iniciar o servidor de métricas", err)
+		}
+		options = append(options, loadtest.WithResultHandler(metrics.record))
+	}
 
-	return report
-}
+	var statsd *statsdSink
+	if config.StatsD != "" {
+		statsd, err = newStatsdSink(config.StatsD, config.StatsDTags, runTags(config.Metadata))
+		if err != nil {
+			fatal("falha ao conectar ao StatsD", err)
+		}
+		options = append(options, loadtest.WithResultHandler(statsd.record))
+	}
 
-func printReport(report *Report) {
-	fmt.Println("\n" + strings.Repeat("=", 50))
-	fmt.Println("RELATÓRIO DE TESTE DE CARGA")
-	fmt.Println(strings.Repeat("=", 50))
+	var influx *influxSink
+	if config.Influx.URL != "" {
+		influx, err = newInfluxSink(config.Influx, config.SuccessCodes, runTags(config.Metadata))
+		if err != nil {
+			fatal("falha ao configurar o InfluxDB", err)
+		}
+		options = append(options, loadtest.WithResultHandler(influx.record))
+	}
 
-	fmt.Printf("Tempo total de execução: %v\n", report.TotalTime)
-	fmt.Printf("Total de requests realizados: %d\n", report.TotalRequests)
-	fmt.Printf("Requests com status 200: %d\n", report.SuccessRequests)
+	var otlp *otlpExporter
+	if config.OTLP != "" {
+		otlp = newOTLPExporter(config.OTLP, config.OTelService, runTags(config.Metadata))
+		options = append(options, loadtest.WithResultHandler(otlp.record))
+	}
 
-	successRate := float64(report.SuccessRequests) / float64(report.TotalRequests) * 100
-	fmt.Printf("Taxa de sucesso: %.2f%%\n", successRate)
+	if config.Stdin {
+		steps := make(chan loadtest.Step, config.Concurrency)
+		go readRequestSpecs(os.Stdin, steps)
+		options = append(options, loadtest.WithSource(steps))
+	}
 
-	requestsPerSecond := float64(report.TotalRequests) / report.TotalTime.Seconds()
-	fmt.Printf("Requests por segundo: %.2f\n", requestsPerSecond)
+	runner, err := loadtest.New(options...)
+	if err != nil {
+		usageError(err)
+	}
+	if metrics != nil {
+		metrics.start(runner)
+	}
+	var agents *agentPool
+	if len(config.Agents) > 0 {
+		if agents, err = newAgentPool(config, runner, os.Args[1:]); err != nil {
+			usageError(err)
+		}
+	}
 
-	fmt.Println("\nDistribuição de códigos de status:")
-	for statusCode, count := range report.StatusCodes {
-		percentage := float64(count) / float64(report.TotalRequests) * 100
-		if statusCode == 0 {
-			fmt.Printf("  Errors: %d (%.2f%%)\n", count, percentage)
-		} else {
-			fmt.Printf("  %d: %d (%.2f%%)\n", statusCode, count, percentage)
+	if agents == nil {
+		if err := checkOpenFiles(max(config.Concurrency, config.MaxVUs), config.RaiseLimit); err != nil {
+			fatal("limite de arquivos abertos insuficiente", err)
+		}
+		if config.Preflight {
+			if err := runner.Preflight(context.Background()); err != nil {
+				fatal("preflight falhou, o teste não foi iniciado", err)
+			}
+			slog.Info("preflight concluído: alvos acessíveis")
 		}
 	}
-	fmt.Println(strings.Repeat("=", 50))
-}
 
-func main() {
-	config, err := parseFlags()
+	if !config.Quiet {
+		printBanner(out, config, runner.Concurrency())
+	}
+
+	base := context.Background()
+	if config.MaxDuration > 0 {
+		var cancel context.CancelFunc
+		base, cancel = context.WithDeadlineCause(base, started.Add(config.MaxDuration), errMaxDuration)
+		defer cancel()
+	}
+	ctx, stop := signal.NotifyContext(base, os.Interrupt, syscall.SIGTERM)
+	// The first signal stops the run gracefully; after it the default
+	// behavior is restored, so a second Ctrl+C kills a run that hangs.
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	if dash != nil {
+		dash.run(runner)
+	}
+	if progress != nil {
+		progress.run()
+	}
+	var report *loadtest.Report
+	if agents != nil {
+		report, err = runner.RunFrom(ctx, agents.produce)
+	} else {
+		report, err = runner.Run(ctx)
+	}
+	if dash != nil {
+		dash.stop()
+	}
+	if progress != nil {
+		progress.stop()
+	}
+	stop()
+	if metrics != nil {
+		metrics.stop()
+	}
+	if statsd != nil {
+		statsd.close()
+	}
+	if influx != nil {
+		if err := influx.close(); err != nil {
+			slog.Warn("falha ao gravar resultados no InfluxDB", "error", err)
+		}
+	}
+	if otlp != nil {
+		if err := otlp.close(); err != nil {
+			slog.Warn("falha ao exportar spans", "error", err)
+		}
+	}
+	if raw != nil {
+		if err := raw.close(); err != nil {
+			fatal("falha ao gravar o arquivo de resultados brutos", err)
+		}
+	}
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Erro: %v\n", err)
-		fmt.Fprintf(os.Stderr, "\nUso: %s --url=<URL> --requests=<NUM> --concurrency=<NUM>\n", os.Args[0])
-		flag.PrintDefaults()
-		os.Exit(1)
+		fatal("falha ao executar o teste", err)
+	}
+	if errors.Is(context.Cause(ctx), errMaxDuration) && report.Aborted == "" {
+		report.Aborted = fmt.Sprintf("tempo máximo de execução de %v atingido (--max-duration)", config.MaxDuration)
+	}
+
+	if err := writeReport(config, report); err != nil {
+		fatal("falha ao gravar o relatório", err)
+	}
+	if config.ReportHTML != "" {
+		if err := writeHTMLReport(config.ReportHTML, config, report); err != nil {
+			fatal("falha ao gravar o relatório HTML", err)
+		}
+	}
+	if store != nil {
+		id, err := store.save(report)
+		if err != nil {
+			fatal("falha ao gravar a execução no banco de resultados", err)
+		}
+		slog.Info("execução gravada", "store", config.Store, "id", id)
+	}
+	reportURL := config.ReportHTML
+	if config.Upload != nil {
+		uploaded, err := uploadReports(context.Background(), config.Upload, config, report)
+		if err != nil {
+			fatal("falha ao enviar os relatórios", err)
+		}
+		for _, object := range uploaded {
+			slog.Info("relatório enviado", "url", object)
+		}
+		reportURL = browserURL(uploaded[len(uploaded)-1])
+	}
+	var failures []string
+	if config.Baseline != nil {
+		current := newJSONReport(report)
+		comparisons := compareReports(config.Baseline, &current, config.MaxRegression, config.MaxErrorDelta)
+		printComparison(out, config.Compare, comparisons)
+		for _, c := range comparisons {
+			if c.regressed {
+				slog.Error("regressão em relação ao baseline", "metric", c.name, "baseline", c.baseline, "actual", c.current)
+				failures = append(failures, fmt.Sprintf("Regressão em %s: %.2f%s → %.2f%s (%s)", c.name, c.baseline, c.unit, c.current, c.unit, c.change()))
+			}
+		}
+	}
+	if config.Summary != "" {
+		printSummary(out, config.Summary, report)
 	}
 
-	report := runLoadTest(config)
-	printReport(report)
+	if report.Aborted != "" {
+		slog.Error("teste abortado", "reason", report.Aborted)
+		failures = append(failures, "Teste abortado: "+report.Aborted)
+	}
+	for _, result := range report.Thresholds {
+		if result.Violated {
+			slog.Error("threshold violado", "threshold", result.Expression, "actual", result.Actual)
+			failures = append(failures, fmt.Sprintf("Threshold violado: %s (valor atual: %s)", result.Expression, result.Actual))
+		}
+	}
+	if config.Notify != "" {
+		if err := notifyWebhook(context.Background(), config.Notify, config, report, failures, reportURL); err != nil {
+			slog.Warn("falha ao enviar a notificação", "error", err)
+		}
+	}
+	switch {
+	case report.Aborted != "" || report.Interrupted:
+		os.Exit(exitAborted)
+	case len(failures) > 0:
+		os.Exit(exitFailed)
+	}
 }
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"stress-test/pkg/loadtest"
+)
+
+// collectMetadata identifies the run with a new ID, the --name and the tags
+// of --tag, and describes its environment. Details that can't be found are
+// left empty.
+func collectMetadata(name string, tags map[string]string) loadtest.Metadata {
+	metadata := loadtest.Metadata{RunID: newRunID(), Name: name, Tags: tags, GitSHA: gitSHA(), Version: toolVersion()}
+	metadata.Hostname, _ = os.Hostname()
+	return metadata
+}
+
+// newRunID returns an ID such as 20261015-091720-a1b2c3. It sorts by start
+// time, and the random suffix tells apart runs started in the same second,
+// e.g. by concurrent CI jobs.
+func newRunID() string {
+	suffix := make([]byte, 3)
+	rand.Read(suffix)
+	return time.Now().UTC().Format("20060102-150405") + "-" + hex.EncodeToString(suffix)
+}
+
+// runTags are the tags that identify the run in metrics: its ID and, when
+// given, its name.
+func runTags(metadata loadtest.Metadata) map[string]string {
+	tags := map[string]string{"run": metadata.RunID}
+	if metadata.Name != "" {
+		tags["name"] = metadata.Name
+	}
+	return tags
+}
+
+// runLabel describes the run for people, e.g. "checkout-peak
+// (20261015-091720-a1b2c3)".
+func runLabel(metadata loadtest.Metadata) string {
+	if metadata.Name == "" {
+		return metadata.RunID
+	}
+	return fmt.Sprintf("%s (%s)", metadata.Name, metadata.RunID)
+}
+
+// gitSHA is the commit checked out in the working directory, usually the
+// commit of the release being tested in a CI pipeline.
+func gitSHA() string {
+	for _, env := range []string{"GITHUB_SHA", "CI_COMMIT_SHA"} {
+		if sha := os.Getenv(env); sha != "" {
+			return sha
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, "git", "rev-parse", "HEAD").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func toolVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	if info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+	for _, setting := range info.Settings {
+		if setting.Key == "vcs.revision" {
+			return "(devel) " + setting.Value
+		}
+	}
+	return info.Main.Version
+}
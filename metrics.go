@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"stress-test/pkg/loadtest"
+)
+
+type metricsKey struct {
+	target string
+	status string
+}
+
+// metricsServer exposes the results collected so far in the Prometheus text
+// exposition format.
+type metricsServer struct {
+	mu         sync.Mutex
+	runner     *loadtest.Runner
+	run        map[string]string
+	buckets    []time.Duration
+	requests   map[metricsKey]int
+	errors     map[string]int
+	assertions int
+	counts     []int
+	sum        time.Duration
+	count      int
+	bytes      int64
+
+	server   *http.Server
+	listener net.Listener
+}
+
+func newMetricsServer(addr string, buckets []time.Duration, run map[string]string) (*metricsServer, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	buckets = append([]time.Duration(nil), buckets...)
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i] < buckets[j] })
+	m := &metricsServer{
+		buckets:  buckets,
+		run:      run,
+		requests: make(map[metricsKey]int),
+		errors:   make(map[string]int),
+		counts:   make([]int, len(buckets)),
+		listener: listener,
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", m.serveHTTP)
+	m.server = &http.Server{Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+	return m, nil
+}
+
+func (m *metricsServer) start(runner *loadtest.Runner) {
+	m.mu.Lock()
+	m.runner = runner
+	m.mu.Unlock()
+	go m.server.Serve(m.listener)
+}
+
+func (m *metricsServer) stop() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	m.server.Shutdown(ctx)
+}
+
+func (m *metricsServer) record(result loadtest.Result) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := metricsKey{target: result.Target, status: strconv.Itoa(result.StatusCode)}
+	if result.Error != nil {
+		key.status = "error"
+		m.errors[loadtest.ClassifyError(result.Error)]++
+	}
+	m.requests[key]++
+	m.bytes += result.Bytes
+	if result.Error != nil {
+		return
+	}
+	if result.AssertionError != nil {
+		m.assertions++
+	}
+	for i, bound := range m.buckets {
+		if result.Duration <= bound {
+			m.counts[i]++
+		}
+	}
+	m.sum += result.Duration
+	m.count++
+}
+
+func (m *metricsServer) serveHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.write(w)
+}
+
+func (m *metricsServer) write(w io.Writer) {
+	keys := make([]metricsKey, 0, len(m.requests))
+	for key := range m.requests {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].target != keys[j].target {
+			return keys[i].target < keys[j].target
+		}
+		return keys[i].status < keys[j].status
+	})
+	// The run is identified by an info metric, to be joined on the instance,
+	// rather than by labels on every series.
+	labels := make([]string, 0, len(m.run))
+	for _, key := range sortedKeys(m.run) {
+		labels = append(labels, fmt.Sprintf("%s=%q", key, m.run[key]))
+	}
+	fmt.Fprintln(w, "# HELP stress_test_run_info The run being executed.")
+	fmt.Fprintln(w, "# TYPE stress_test_run_info gauge")
+	fmt.Fprintf(w, "stress_test_run_info{%s} 1\n", strings.Join(labels, ","))
+
+	fmt.Fprintln(w, "# HELP stress_test_requests_total Requests completed, by target and status code.")
+	fmt.Fprintln(w, "# TYPE stress_test_requests_total counter")
+	for _, key := range keys {
+		fmt.Fprintf(w, "stress_test_requests_total{target=%q,status=%q} %d\n", key.target, key.status, m.requests[key])
+	}
+
+	categories := make([]string, 0, len(m.errors))
+	for category := range m.errors {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+	fmt.Fprintln(w, "# HELP stress_test_errors_total Requests that failed without a response, by category.")
+	fmt.Fprintln(w, "# TYPE stress_test_errors_total counter")
+	for _, category := range categories {
+		fmt.Fprintf(w, "stress_test_errors_total{category=%q} %d\n", category, m.errors[category])
+	}
+
+	fmt.Fprintln(w, "# HELP stress_test_assertion_failures_total Responses that failed an assertion.")
+	fmt.Fprintln(w, "# TYPE stress_test_assertion_failures_total counter")
+	fmt.Fprintf(w, "stress_test_assertion_failures_total %d\n", m.assertions)
+
+	fmt.Fprintln(w, "# HELP stress_test_received_bytes_total Response body bytes received.")
+	fmt.Fprintln(w, "# TYPE stress_test_received_bytes_total counter")
+	fmt.Fprintf(w, "stress_test_received_bytes_total %d\n", m.bytes)
+
+	fmt.Fprintln(w, "# HELP stress_test_request_duration_seconds Latency of the requests that got a response.")
+	fmt.Fprintln(w, "# TYPE stress_test_request_duration_seconds histogram")
+	for i, bound := range m.buckets {
+		fmt.Fprintf(w, "stress_test_request_duration_seconds_bucket{le=\"%g\"} %d\n", bound.Seconds(), m.counts[i])
+	}
+	fmt.Fprintf(w, "stress_test_request_duration_seconds_bucket{le=\"+Inf\"} %d\n", m.count)
+	fmt.Fprintf(w, "stress_test_request_duration_seconds_sum %g\n", m.sum.Seconds())
+	fmt.Fprintf(w, "stress_test_request_duration_seconds_count %d\n", m.count)
+
+	if m.runner != nil {
+		gauges := m.runner.Gauges()
+		for _, gauge := range []struct {
+			name, help string
+			value      int
+		}{
+			{"stress_test_workers", "Workers, or VUs, started so far.", gauges.Workers},
+			{"stress_test_in_flight_requests", "Requests currently awaiting a response.", gauges.InFlight},
+			{"stress_test_waiting_connection_requests", "Requests waiting for a connection to be dialed or freed.", gauges.WaitingConn},
+			{"stress_test_queued_iterations", "Iterations scheduled but not picked by a worker yet.", gauges.Queued},
+		} {
+			fmt.Fprintf(w, "# HELP %s %s\n", gauge.name, gauge.help)
+			fmt.Fprintf(w, "# TYPE %s gauge\n", gauge.name)
+			fmt.Fprintf(w, "%s %d\n", gauge.name, gauge.value)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"stress-test/pkg/loadtest"
+)
+
+// webhookPayload is posted to --notify-webhook. Text is what Slack (and
+// compatible services, such as Mattermost) display; the other fields are
+// for generic receivers.
+type webhookPayload struct {
+	Text      string            `json:"text"`
+	RunID     string            `json:"run_id"`
+	Name      string            `json:"name,omitempty"`
+	Status    string            `json:"status"`
+	Failures  []string          `json:"failures,omitempty"`
+	Total     int               `json:"total"`
+	ErrorRate float64           `json:"error_rate"`
+	P95Ms     float64           `json:"p95_ms"`
+	RPS       float64           `json:"rps"`
+	ReportURL string            `json:"report_url,omitempty"`
+	Tags      map[string]string `json:"tags,omitempty"`
+}
+
+func parseWebhookURL(raw string) (string, error) {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("URL inválida %q, use http:// ou https://", raw)
+	}
+	return raw, nil
+}
+
+// notifyWebhook announces the end of the run. failures lists the violated
+// thresholds and the regressions; the run passed when it is empty.
+func notifyWebhook(ctx context.Context, webhook string, config *Config, report *loadtest.Report, failures []string, reportURL string) error {
+	payload := webhookPayload{
+		Status:    "passed",
+		RunID:     report.Metadata.RunID,
+		Name:      report.Metadata.Name,
+		Failures:  failures,
+		Total:     report.TotalRequests,
+		ErrorRate: report.ErrorRate(),
+		P95Ms:     milliseconds(report.Latency.P95),
+		RPS:       report.RequestsPerSecond(),
+		ReportURL: reportURL,
+		Tags:      report.Metadata.Tags,
+	}
+
+	var text strings.Builder
+	headline := "✅ Teste de carga concluído"
+	if len(failures) > 0 {
+		payload.Status = "failed"
+		headline = "❌ Teste de carga falhou"
+	} else if report.Interrupted {
+		headline = "⚠️ Teste de carga interrompido"
+	}
+	fmt.Fprintf(&text, "*%s*", headline)
+	if len(config.Targets) > 0 {
+		fmt.Fprintf(&text, ": %s", config.Targets[0].URL)
+		if len(config.Targets) > 1 {
+			fmt.Fprintf(&text, " (+%d)", len(config.Targets)-1)
+		}
+	}
+	if len(report.Metadata.Tags) > 0 {
+		fmt.Fprintf(&text, " [%s]", tagFlags(report.Metadata.Tags))
+	}
+	fmt.Fprintf(&text, "\nExecução: %s", runLabel(report.Metadata))
+	fmt.Fprintf(&text, "\nRequests: %d | Taxa de erro: %.2f%% | p95: %v | Req/s: %.2f | Duração: %v",
+		report.TotalRequests, payload.ErrorRate, report.Latency.P95, payload.RPS, report.TotalTime.Round(time.Millisecond))
+	for _, failure := range failures {
+		fmt.Fprintf(&text, "\n• %s", failure)
+	}
+	if reportURL != "" {
+		fmt.Fprintf(&text, "\nRelatório: %s", reportURL)
+	}
+	payload.Text = text.String()
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,354 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"stress-test/pkg/loadtest"
+)
+
+// openAPIConfig selects the operations of an OpenAPI (3.x) or Swagger (2.0)
+// document to be tested. Without Operations every GET operation is used.
+type openAPIConfig struct {
+	Spec       string
+	Server     string
+	Operations []string
+}
+
+var openAPIMethods = []string{"get", "put", "post", "delete", "options", "head", "patch"}
+
+type openAPISpec struct {
+	root map[string]any
+}
+
+func loadOpenAPI(config openAPIConfig) ([]loadtest.Target, error) {
+	data, err := os.ReadFile(config.Spec)
+	if err != nil {
+		return nil, err
+	}
+	var root map[string]any
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, err
+	}
+	s := &openAPISpec{root: root}
+
+	base := config.Server
+	if base == "" {
+		if base, err = s.server(); err != nil {
+			return nil, err
+		}
+	}
+	if u, err := url.Parse(base); err != nil || u.Host == "" {
+		return nil, fmt.Errorf("URL do servidor %q não é absoluta, informe --openapi-server", base)
+	}
+	base = strings.TrimSuffix(base, "/")
+
+	wanted := make(map[string]bool, len(config.Operations))
+	for _, id := range config.Operations {
+		wanted[id] = true
+	}
+	found := make(map[string]bool, len(wanted))
+
+	paths := asMap(root["paths"])
+	keys := make([]string, 0, len(paths))
+	for path := range paths {
+		keys = append(keys, path)
+	}
+	sort.Strings(keys)
+
+	var targets []loadtest.Target
+	for _, path := range keys {
+		item := s.resolve(paths[path])
+		for _, method := range openAPIMethods {
+			op := asMap(item[method])
+			if op == nil {
+				continue
+			}
+			name, _ := op["operationId"].(string)
+			if name == "" {
+				name = strings.ToUpper(method) + " " + path
+			}
+			if len(wanted) > 0 && !wanted[name] || len(wanted) == 0 && method != "get" {
+				continue
+			}
+			found[name] = true
+
+			target, err := s.target(base, path, method, item, op)
+			if err != nil {
+				return nil, fmt.Errorf("operação %s: %w", name, err)
+			}
+			target.Name = name
+			targets = append(targets, target)
+		}
+	}
+	for _, id := range config.Operations {
+		if !found[id] {
+			return nil, fmt.Errorf("operação %q não encontrada", id)
+		}
+	}
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("nenhuma operação GET encontrada, informe --operation")
+	}
+	return targets, nil
+}
+
+// server returns the first server of the document, with its variables set
+// to their defaults.
+func (s *openAPISpec) server() (string, error) {
+	if servers, ok := s.root["servers"].([]any); ok && len(servers) > 0 {
+		server := asMap(servers[0])
+		u, _ := server["url"].(string)
+		for name, variable := range asMap(server["variables"]) {
+			u = strings.ReplaceAll(u, "{"+name+"}", fmt.Sprint(asMap(variable)["default"]))
+		}
+		return u, nil
+	}
+	if host, ok := s.root["host"].(string); ok {
+		scheme := "https"
+		if schemes, ok := s.root["schemes"].([]any); ok && len(schemes) > 0 {
+			scheme = fmt.Sprint(schemes[0])
+		}
+		basePath, _ := s.root["basePath"].(string)
+		return scheme + "://" + host + basePath, nil
+	}
+	return "", fmt.Errorf("o documento não define servidores, informe --openapi-server")
+}
+
+func (s *openAPISpec) target(base, path, method string, item, op map[string]any) (loadtest.Target, error) {
+	target := loadtest.Target{Weight: 1, Method: strings.ToUpper(method), Headers: make(http.Header)}
+
+	// Operation parameters override the ones of the path with the same name
+	// and location.
+	params := make(map[string]map[string]any)
+	var order []string
+	for _, list := range []any{item["parameters"], op["parameters"]} {
+		items, _ := list.([]any)
+		for _, p := range items {
+			param := s.resolve(p)
+			key := fmt.Sprint(param["in"], ":", param["name"])
+			if _, ok := params[key]; !ok {
+				order = append(order, key)
+			}
+			params[key] = param
+		}
+	}
+
+	query := url.Values{}
+	for _, key := range order {
+		param := params[key]
+		name, _ := param["name"].(string)
+		in, _ := param["in"].(string)
+		required, _ := param["required"].(bool)
+		if in == "body" {
+			// Swagger 2.0 request bodies are parameters.
+			body, err := json.Marshal(s.example(param["schema"], 0))
+			if err != nil {
+				return target, err
+			}
+			target.Body, target.ContentType = body, "application/json"
+			continue
+		}
+		if !required && in != "path" {
+			continue
+		}
+		value := parameterString(s.parameterExample(param))
+		switch in {
+		case "path":
+			path = strings.ReplaceAll(path, "{"+name+"}", url.PathEscape(value))
+		case "query":
+			query.Set(name, value)
+		case "header":
+			target.Headers.Set(name, value)
+		case "cookie":
+			target.Headers.Add("Cookie", name+"="+value)
+		}
+	}
+	target.URL = base + path
+	if len(query) > 0 {
+		target.URL += "?" + query.Encode()
+	}
+
+	if requestBody := s.resolve(op["requestBody"]); requestBody != nil {
+		content := asMap(requestBody["content"])
+		types := make([]string, 0, len(content))
+		for contentType := range content {
+			types = append(types, contentType)
+		}
+		sort.Strings(types)
+		if len(types) > 0 {
+			contentType := types[0]
+			if _, ok := content["application/json"]; ok {
+				contentType = "application/json"
+			}
+			media := asMap(content[contentType])
+			var example any
+			if ex, ok := media["example"]; ok {
+				example = ex
+			} else if ex := s.firstExample(media["examples"]); ex != nil {
+				example = ex
+			} else {
+				example = s.example(media["schema"], 0)
+			}
+			body, err := encodeExample(contentType, example)
+			if err != nil {
+				return target, err
+			}
+			target.Body, target.ContentType = body, contentType
+		}
+	}
+	return target, nil
+}
+
+func encodeExample(contentType string, example any) ([]byte, error) {
+	if text, ok := example.(string); ok && !strings.Contains(contentType, "json") {
+		return []byte(text), nil
+	}
+	if contentType == "application/x-www-form-urlencoded" {
+		form := url.Values{}
+		for key, value := range asMap(example) {
+			form.Set(key, parameterString(value))
+		}
+		return []byte(form.Encode()), nil
+	}
+	return json.Marshal(example)
+}
+
+func (s *openAPISpec) parameterExample(param map[string]any) any {
+	if ex, ok := param["example"]; ok {
+		return ex
+	}
+	if ex := s.firstExample(param["examples"]); ex != nil {
+		return ex
+	}
+	if schema, ok := param["schema"]; ok {
+		return s.example(schema, 0)
+	}
+	// Swagger 2.0 declares the type on the parameter itself.
+	return s.example(param, 0)
+}
+
+// firstExample returns the value of the first entry of an examples map.
+func (s *openAPISpec) firstExample(examples any) any {
+	m := asMap(examples)
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if value, ok := s.resolve(m[name])["value"]; ok {
+			return value
+		}
+	}
+	return nil
+}
+
+// example builds a value for schema from its example, default or enum, or
+// from its type when none is given.
+func (s *openAPISpec) example(schema any, depth int) any {
+	m := s.resolve(schema)
+	if m == nil || depth > 8 {
+		return nil
+	}
+	for _, key := range []string{"example", "default"} {
+		if value, ok := m[key]; ok {
+			return value
+		}
+	}
+	if enum, ok := m["enum"].([]any); ok && len(enum) > 0 {
+		return enum[0]
+	}
+	if all, ok := m["allOf"].([]any); ok {
+		merged := make(map[string]any)
+		for _, part := range all {
+			for key, value := range asMap(s.example(part, depth+1)) {
+				merged[key] = value
+			}
+		}
+		return merged
+	}
+	for _, key := range []string{"oneOf", "anyOf"} {
+		if options, ok := m[key].([]any); ok && len(options) > 0 {
+			return s.example(options[0], depth+1)
+		}
+	}
+
+	schemaType, _ := m["type"].(string)
+	if schemaType == "" && m["properties"] != nil {
+		schemaType = "object"
+	}
+	switch schemaType {
+	case "object":
+		object := make(map[string]any)
+		for name, property := range asMap(m["properties"]) {
+			object[name] = s.example(property, depth+1)
+		}
+		return object
+	case "array":
+		return []any{s.example(m["items"], depth+1)}
+	case "integer":
+		return 1
+	case "number":
+		return 1.5
+	case "boolean":
+		return true
+	case "string":
+		switch m["format"] {
+		case "uuid":
+			return "3fa85f64-5717-4562-b3fc-2c963f66afa6"
+		case "date":
+			return "2024-01-01"
+		case "date-time":
+			return "2024-01-01T00:00:00Z"
+		case "email":
+			return "user@example.com"
+		}
+		return "example"
+	}
+	return nil
+}
+
+// resolve follows local $ref pointers, e.g. #/components/schemas/Pet.
+func (s *openAPISpec) resolve(value any) map[string]any {
+	m := asMap(value)
+	for i := 0; i < 16 && m != nil; i++ {
+		ref, ok := m["$ref"].(string)
+		if !ok || !strings.HasPrefix(ref, "#/") {
+			return m
+		}
+		var node any = s.root
+		for _, part := range strings.Split(ref[2:], "/") {
+			part = strings.NewReplacer("~1", "/", "~0", "~").Replace(part)
+			node = asMap(node)[part]
+		}
+		m = asMap(node)
+	}
+	return m
+}
+
+func asMap(value any) map[string]any {
+	m, _ := value.(map[string]any)
+	return m
+}
+
+func parameterString(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case []any:
+		parts := make([]string, len(v))
+		for i, item := range v {
+			parts[i] = parameterString(item)
+		}
+		return strings.Join(parts, ",")
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"stress-test/pkg/loadtest"
+)
+
+// OTLP/JSON span kind and status codes.
+const (
+	otlpSpanKindClient  = 3
+	otlpStatusError     = 2
+	otlpMaxBatchedSpans = 1000
+)
+
+type otlpValue struct {
+	StringValue string `json:"stringValue,omitempty"`
+	IntValue    string `json:"intValue,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes"`
+	Status            otlpStatus      `json:"status"`
+}
+
+func stringAttribute(key, value string) otlpAttribute {
+	return otlpAttribute{Key: key, Value: otlpValue{StringValue: value}}
+}
+
+func newOTLPSpan(result loadtest.Result) otlpSpan {
+	span := otlpSpan{
+		TraceID:           result.TraceID,
+		SpanID:            result.SpanID,
+		Name:              result.Method,
+		Kind:              otlpSpanKindClient,
+		StartTimeUnixNano: strconv.FormatInt(result.Start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(result.Start.Add(result.Duration).UnixNano(), 10),
+		Attributes: []otlpAttribute{
+			stringAttribute("http.request.method", result.Method),
+			stringAttribute("url.full", result.Target),
+		},
+	}
+	if result.Step != "" {
+		span.Attributes = append(span.Attributes, stringAttribute("stress_test.step", result.Step))
+	}
+	switch {
+	case result.Error != nil:
+		span.Attributes = append(span.Attributes, stringAttribute("error.type", loadtest.ClassifyError(result.Error)))
+		span.Status = otlpStatus{Code: otlpStatusError, Message: result.Error.Error()}
+	default:
+		span.Attributes = append(span.Attributes, otlpAttribute{Key: "http.response.status_code", Value: otlpValue{IntValue: strconv.Itoa(result.StatusCode)}})
+		if result.StatusCode >= 500 {
+			span.Status = otlpStatus{Code: otlpStatusError}
+		}
+		if result.AssertionError != nil {
+			span.Status = otlpStatus{Code: otlpStatusError, Message: result.AssertionError.Error()}
+		}
+	}
+	return span
+}
+
+// otlpExporter sends one client span per request to an OTLP/HTTP collector
+// using the JSON encoding. Spans are batched and sent every second.
+type otlpExporter struct {
+	mu      sync.Mutex
+	url     string
+	service string
+	run     map[string]string
+	client  *http.Client
+	spans   []otlpSpan
+	err     error
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+func newOTLPExporter(endpoint, service string, run map[string]string) *otlpExporter {
+	e := &otlpExporter{
+		url:     strings.TrimSuffix(endpoint, "/") + "/v1/traces",
+		service: service,
+		run:     run,
+		client:  &http.Client{Timeout: 10 * time.Second},
+		done:    make(chan struct{}),
+	}
+	e.wg.Add(1)
+	go e.loop()
+	return e
+}
+
+func (e *otlpExporter) loop() {
+	defer e.wg.Done()
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-e.done:
+			return
+		case <-ticker.C:
+			e.flush()
+		}
+	}
+}
+
+func (e *otlpExporter) record(result loadtest.Result) {
+	if result.TraceID == "" {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	// Spans are dropped rather than buffered without limit when the
+	// collector can't keep up.
+	if len(e.spans) < otlpMaxBatchedSpans*10 {
+		e.spans = append(e.spans, newOTLPSpan(result))
+	}
+}
+
+func (e *otlpExporter) flush() {
+	e.mu.Lock()
+	spans := e.spans
+	e.spans = nil
+	e.mu.Unlock()
+
+	for len(spans) > 0 {
+		n := min(len(spans), otlpMaxBatchedSpans)
+		if err := e.post(spans[:n]); err != nil {
+			e.mu.Lock()
+			if e.err == nil {
+				e.err = err
+			}
+			e.mu.Unlock()
+			return
+		}
+		spans = spans[n:]
+	}
+}
+
+func (e *otlpExporter) post(spans []otlpSpan) error {
+	attributes := []otlpAttribute{stringAttribute("service.name", e.service)}
+	for _, key := range sortedKeys(e.run) {
+		attributes = append(attributes, stringAttribute("stress_test."+key, e.run[key]))
+	}
+	payload := map[string]any{
+		"resourceSpans": []any{map[string]any{
+			"resource": map[string]any{
+				"attributes": attributes,
+			},
+			"scopeSpans": []any{map[string]any{
+				"scope": map[string]string{"name": "stress-test"},
+				"spans": spans,
+			}},
+		}},
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	resp, err := e.client.Post(e.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("coletor OTLP respondeu %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// close sends the remaining spans and returns the first export error.
+func (e *otlpExporter) close() error {
+	close(e.done)
+	e.wg.Wait()
+	e.flush()
+	return e.err
+}
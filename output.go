@@ -0,0 +1,1090 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"unicode/utf8"
+
+	"stress-test/pkg/loadtest"
+)
+
+type jsonLatency struct {
+	MinMs    float64 `json:"min_ms"`
+	MaxMs    float64 `json:"max_ms"`
+	MeanMs   float64 `json:"mean_ms"`
+	StdDevMs float64 `json:"stddev_ms"`
+	P50Ms    float64 `json:"p50_ms"`
+	P90Ms    float64 `json:"p90_ms"`
+	P95Ms    float64 `json:"p95_ms"`
+	P99Ms    float64 `json:"p99_ms"`
+	P999Ms   float64 `json:"p99_9_ms"`
+}
+
+type jsonPhases struct {
+	DNS      jsonLatency `json:"dns"`
+	Connect  jsonLatency `json:"connect"`
+	TLS      jsonLatency `json:"tls"`
+	TTFB     jsonLatency `json:"ttfb"`
+	Download jsonLatency `json:"download"`
+}
+
+type jsonHistogramBucket struct {
+	LeMs  float64 `json:"le_ms,omitempty"`
+	Inf   bool    `json:"inf,omitempty"`
+	Count int     `json:"count"`
+}
+
+type jsonTarget struct {
+	Name            string      `json:"name,omitempty"`
+	Method          string      `json:"method"`
+	URL             string      `json:"url"`
+	Weight          int         `json:"weight"`
+	TotalRequests   int         `json:"total_requests"`
+	SuccessRequests int         `json:"success_requests"`
+	FailedRequests  int         `json:"failed_requests"`
+	ErrorRate       float64     `json:"error_rate"`
+	Latency         jsonLatency `json:"latency"`
+}
+
+type jsonStep struct {
+	Name            string      `json:"name"`
+	Method          string      `json:"method"`
+	URL             string      `json:"url"`
+	TotalRequests   int         `json:"total_requests"`
+	SuccessRequests int         `json:"success_requests"`
+	FailedRequests  int         `json:"failed_requests"`
+	ErrorRate       float64     `json:"error_rate"`
+	Latency         jsonLatency `json:"latency"`
+}
+
+type jsonStage struct {
+	DurationMs        float64     `json:"duration_ms"`
+	StartRate         float64     `json:"start_rate"`
+	TargetRate        float64     `json:"target_rate"`
+	TotalRequests     int         `json:"total_requests"`
+	SuccessRequests   int         `json:"success_requests"`
+	FailedRequests    int         `json:"failed_requests"`
+	RequestsPerSecond float64     `json:"requests_per_second"`
+	Latency           jsonLatency `json:"latency"`
+}
+
+type jsonClass struct {
+	Requests int         `json:"requests"`
+	Latency  jsonLatency `json:"latency"`
+}
+
+type jsonShare struct {
+	Requests int     `json:"requests"`
+	Percent  float64 `json:"percent"`
+}
+
+type jsonRetries struct {
+	MaxRetries        int     `json:"max_retries"`
+	BackoffMs         float64 `json:"backoff_ms"`
+	On                string  `json:"on"`
+	Attempts          int     `json:"attempts"`
+	RetriedRequests   int     `json:"retried_requests"`
+	RecoveredRequests int     `json:"recovered_requests"`
+}
+
+type jsonTimelinePoint struct {
+	Second   int     `json:"second"`
+	Requests int     `json:"requests"`
+	Failures int     `json:"failures"`
+	P95Ms    float64 `json:"p95_ms"`
+}
+
+type jsonThreshold struct {
+	Expression string `json:"expression"`
+	Actual     string `json:"actual"`
+	Violated   bool   `json:"violated"`
+}
+
+type jsonConnectionReport struct {
+	TotalTimeMs      float64        `json:"total_time_ms"`
+	Connections      int            `json:"connections"`
+	Connected        int            `json:"connected"`
+	ConnectLatency   jsonLatency    `json:"connect_latency"`
+	MessagesSent     int            `json:"messages_sent"`
+	MessagesReceived int            `json:"messages_received"`
+	RoundTrip        jsonLatency    `json:"round_trip"`
+	Disconnects      int            `json:"disconnects"`
+	Timeouts         int            `json:"timeouts"`
+	Errors           map[string]int `json:"errors"`
+}
+
+type jsonMetadata struct {
+	RunID     string            `json:"run_id,omitempty"`
+	Name      string            `json:"name,omitempty"`
+	StartedAt time.Time         `json:"started_at"`
+	Tags      map[string]string `json:"tags,omitempty"`
+	Hostname  string            `json:"hostname,omitempty"`
+	GitSHA    string            `json:"git_sha,omitempty"`
+	Version   string            `json:"version,omitempty"`
+}
+
+type jsonWorker struct {
+	Worker       int       `json:"worker"`
+	Requests     int       `json:"requests"`
+	Failures     int       `json:"failures"`
+	MeanMs       float64   `json:"mean_ms"`
+	MaxMs        float64   `json:"max_ms"`
+	LastResponse time.Time `json:"last_response"`
+}
+
+type jsonMetric struct {
+	Count int     `json:"count"`
+	Sum   float64 `json:"sum"`
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max"`
+	Mean  float64 `json:"mean"`
+}
+
+type jsonSavedBodies struct {
+	Dir   string  `json:"dir"`
+	Rate  float64 `json:"sample_rate"`
+	Files int     `json:"files"`
+}
+
+type jsonCompression struct {
+	AcceptEncoding  string         `json:"accept_encoding"`
+	Encodings       map[string]int `json:"encodings"`
+	Compressed      int            `json:"compressed_responses"`
+	WireBytes       int64          `json:"wire_bytes"`
+	DecodedBytes    int64          `json:"decoded_bytes"`
+	Ratio           float64        `json:"ratio"`
+	DecodeTimeMs    float64        `json:"decode_time_ms"`
+	MaxDecodeTimeMs float64        `json:"max_decode_time_ms"`
+}
+
+type jsonFailureSample struct {
+	Time          time.Time         `json:"time"`
+	Step          string            `json:"step,omitempty"`
+	Method        string            `json:"method,omitempty"`
+	URL           string            `json:"url"`
+	Status        int               `json:"status"`
+	Failure       string            `json:"failure"`
+	Headers       map[string]string `json:"headers,omitempty"`
+	Body          string            `json:"body,omitempty"`
+	BodyTruncated bool              `json:"body_truncated,omitempty"`
+}
+
+type jsonGenerator struct {
+	CPUs            int     `json:"cpus"`
+	CPUPercent      float64 `json:"cpu_percent"`
+	PeakCPUPercent  float64 `json:"peak_cpu_percent"`
+	PeakMemoryBytes uint64  `json:"peak_memory_bytes"`
+	PeakHeapBytes   uint64  `json:"peak_heap_bytes"`
+	PeakGoroutines  int     `json:"peak_goroutines"`
+	GCCycles        int     `json:"gc_cycles"`
+	GCPauseTotalMs  float64 `json:"gc_pause_total_ms"`
+	GCPauseMaxMs    float64 `json:"gc_pause_max_ms"`
+	PeakOpenFiles   int     `json:"peak_open_files,omitempty"`
+}
+
+type jsonReport struct {
+	Metadata          jsonMetadata          `json:"metadata"`
+	Interrupted       bool                  `json:"interrupted"`
+	Aborted           string                `json:"aborted,omitempty"`
+	TotalTimeMs       float64               `json:"total_time_ms"`
+	TotalRequests     int                   `json:"total_requests"`
+	Iterations        int                   `json:"iterations,omitempty"`
+	SuccessRequests   int                   `json:"success_requests"`
+	SuccessCodes      string                `json:"success_codes"`
+	SuccessRate       float64               `json:"success_rate"`
+	Concurrency       int                   `json:"concurrency"`
+	Protocol          string                `json:"protocol"`
+	MaxBandwidth      float64               `json:"max_bandwidth_bytes_per_second,omitempty"`
+	Executor          string                `json:"executor"`
+	MaxVUs            int                   `json:"max_vus,omitempty"`
+	VUs               int                   `json:"vus,omitempty"`
+	DroppedIterations int                   `json:"dropped_iterations,omitempty"`
+	RampUpMs          float64               `json:"ramp_up_ms,omitempty"`
+	WarmupMs          float64               `json:"warmup_ms,omitempty"`
+	WarmupRequests    int                   `json:"warmup_requests,omitempty"`
+	ThinkTimeMs       float64               `json:"think_time_ms,omitempty"`
+	ThinkTimeJitterMs float64               `json:"think_time_jitter_ms,omitempty"`
+	Retries           *jsonRetries          `json:"retries,omitempty"`
+	RequestsPerSecond float64               `json:"requests_per_second"`
+	TotalBytes        int64                 `json:"total_bytes"`
+	Compression       *jsonCompression      `json:"compression,omitempty"`
+	SavedBodies       *jsonSavedBodies      `json:"saved_bodies,omitempty"`
+	AvgResponseBytes  float64               `json:"avg_response_bytes"`
+	MaxResponseBytes  int64                 `json:"max_response_bytes"`
+	BytesPerSecond    float64               `json:"throughput_bytes_per_second"`
+	BytesSent         int64                 `json:"bytes_sent"`
+	SentPerSecond     float64               `json:"upload_bytes_per_second"`
+	StatusCodes       map[int]int           `json:"status_codes"`
+	StatusClassCounts map[string]jsonShare  `json:"status_classes"`
+	GRPCStatusCodes   map[string]int        `json:"grpc_status_codes,omitempty"`
+	Redirects         int                   `json:"redirects"`
+	Conditional       int                   `json:"conditional_requests"`
+	NotModified       int                   `json:"not_modified"`
+	Protocols         map[string]int        `json:"protocols"`
+	IPFamilies        map[string]int        `json:"ip_families"`
+	ReusedConnections int                   `json:"reused_connections"`
+	NewConnections    int                   `json:"new_connections"`
+	Timeouts          int                   `json:"timeouts"`
+	Errors            map[string]int        `json:"errors"`
+	ErrorCategories   map[string]int        `json:"error_categories"`
+	FailedAssertions  int                   `json:"failed_assertions"`
+	AssertionFailures map[string]int        `json:"assertion_failures"`
+	CustomMetrics     map[string]jsonMetric `json:"custom_metrics,omitempty"`
+	FailureSamples    []jsonFailureSample   `json:"failure_samples,omitempty"`
+	Latency           jsonLatency           `json:"latency"`
+	CorrectedLatency  *jsonLatency          `json:"latency_corrected,omitempty"`
+	StatusClasses     map[string]jsonClass  `json:"latency_by_status_class"`
+	Phases            jsonPhases            `json:"phases"`
+	Histogram         []jsonHistogramBucket `json:"histogram"`
+	Targets           []jsonTarget          `json:"targets,omitempty"`
+	Scenario          string                `json:"scenario,omitempty"`
+	Steps             []jsonStep            `json:"steps,omitempty"`
+	Stages            []jsonStage           `json:"stages,omitempty"`
+	Thresholds        []jsonThreshold       `json:"thresholds,omitempty"`
+	Generator         *jsonGenerator        `json:"generator,omitempty"`
+	GeneratorWarnings []string              `json:"generator_warnings,omitempty"`
+	Workers           []jsonWorker          `json:"workers,omitempty"`
+	Timeline          []jsonTimelinePoint   `json:"timeline"`
+	TimelineInterval  float64               `json:"timeline_interval_ms"`
+}
+
+func printFailureSample(w io.Writer, n int, sample loadtest.FailureSample) {
+	status := "sem resposta"
+	if sample.Status != 0 {
+		status = strconv.Itoa(sample.Status)
+	}
+	line := fmt.Sprintf("  %d. %s %s %s → %s", n, sample.Time.Format("15:04:05.000"), sample.Method, sample.URL, status)
+	if sample.Failure != "status "+status {
+		line += ": " + sample.Failure
+	}
+	fmt.Fprintln(w, line)
+	for _, name := range sortedKeys(sample.Header) {
+		fmt.Fprintf(w, "     %s: %s\n", name, strings.Join(sample.Header[name], ", "))
+	}
+	if len(sample.Body) > 0 {
+		body := strings.ReplaceAll(strings.ToValidUTF8(string(sample.Body), "�"), "\n", "\n     ")
+		if sample.BodyTruncated {
+			body += " […]"
+		}
+		fmt.Fprintf(w, "\n     %s\n", body)
+	}
+}
+
+// formatBytes renders a byte count with decimal units, e.g. 1.50 MB.
+func formatBytes(n float64) string {
+	units := []string{"B", "KB", "MB", "GB", "TB"}
+	i := 0
+	for n >= 1000 && i < len(units)-1 {
+		n /= 1000
+		i++
+	}
+	if i == 0 {
+		return fmt.Sprintf("%.0f B", n)
+	}
+	return fmt.Sprintf("%.2f %s", n, units[i])
+}
+
+// ipFamilyName spells family as in "IPv4".
+func ipFamilyName(family loadtest.IPFamily) string {
+	return "IPv" + strings.TrimPrefix(string(family), "ipv")
+}
+
+// formatMetric renders a custom metric value with at most two decimals.
+func formatMetric(v float64) string {
+	return strconv.FormatFloat(math.Round(v*100)/100, 'f', -1, 64)
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+func newJSONLatency(stats loadtest.LatencyStats) jsonLatency {
+	return jsonLatency{
+		MinMs:    milliseconds(stats.Min),
+		MaxMs:    milliseconds(stats.Max),
+		MeanMs:   milliseconds(stats.Mean),
+		StdDevMs: milliseconds(stats.StdDev),
+		P50Ms:    milliseconds(stats.P50),
+		P90Ms:    milliseconds(stats.P90),
+		P95Ms:    milliseconds(stats.P95),
+		P99Ms:    milliseconds(stats.P99),
+		P999Ms:   milliseconds(stats.P999),
+	}
+}
+
+func newJSONReport(report *loadtest.Report) jsonReport {
+	out := jsonReport{
+		Metadata: jsonMetadata{
+			RunID:     report.Metadata.RunID,
+			Name:      report.Metadata.Name,
+			StartedAt: report.StartTime,
+			Tags:      report.Metadata.Tags,
+			Hostname:  report.Metadata.Hostname,
+			GitSHA:    report.Metadata.GitSHA,
+			Version:   report.Metadata.Version,
+		},
+		Interrupted:       report.Interrupted,
+		Aborted:           report.Aborted,
+		TotalTimeMs:       milliseconds(report.TotalTime),
+		TotalRequests:     report.TotalRequests,
+		Scenario:          report.Scenario,
+		SuccessRequests:   report.SuccessRequests,
+		SuccessCodes:      report.SuccessCodes.String(),
+		SuccessRate:       report.SuccessRate(),
+		Concurrency:       report.Concurrency,
+		Protocol:          string(report.Protocol),
+		MaxBandwidth:      report.MaxBandwidth,
+		Executor:          string(report.Executor),
+		MaxVUs:            report.MaxVUs,
+		VUs:               report.VUs,
+		DroppedIterations: report.DroppedIterations,
+		RampUpMs:          milliseconds(report.RampUp),
+		WarmupMs:          milliseconds(report.Warmup),
+		WarmupRequests:    report.WarmupRequests,
+		ThinkTimeMs:       milliseconds(report.ThinkTime),
+		ThinkTimeJitterMs: milliseconds(report.ThinkTimeJitter),
+		RequestsPerSecond: report.RequestsPerSecond(),
+		TotalBytes:        report.TotalBytes,
+		AvgResponseBytes:  report.AverageResponseSize(),
+		MaxResponseBytes:  report.MaxResponseSize,
+		BytesPerSecond:    report.Throughput(),
+		BytesSent:         report.BytesSent,
+		SentPerSecond:     report.UploadThroughput(),
+		StatusCodes:       report.StatusCodes,
+		GRPCStatusCodes:   report.GRPCStatusCodes,
+		Redirects:         report.Redirects,
+		Conditional:       report.Conditional,
+		NotModified:       report.NotModified,
+		Protocols:         report.Protocols,
+		IPFamilies:        report.IPFamilies,
+		ReusedConnections: report.ReusedConnections,
+		NewConnections:    report.NewConnections,
+		Timeouts:          report.Timeouts,
+		Errors:            report.Errors,
+		ErrorCategories:   report.ErrorCategories,
+		FailedAssertions:  report.FailedAssertions,
+		AssertionFailures: report.AssertionFailures,
+		Latency:           newJSONLatency(report.Latency),
+		Phases: jsonPhases{
+			DNS:      newJSONLatency(report.Phases.DNS),
+			Connect:  newJSONLatency(report.Phases.Connect),
+			TLS:      newJSONLatency(report.Phases.TLS),
+			TTFB:     newJSONLatency(report.Phases.TTFB),
+			Download: newJSONLatency(report.Phases.Download),
+		},
+	}
+	if retry := report.Retry; retry != nil {
+		out.Retries = &jsonRetries{
+			MaxRetries:        retry.Retries,
+			BackoffMs:         milliseconds(retry.Backoff),
+			On:                retry.String(),
+			Attempts:          report.Attempts,
+			RetriedRequests:   report.RetriedRequests,
+			RecoveredRequests: report.RecoveredRequests,
+		}
+	}
+	if report.CorrectedLatency != nil {
+		corrected := newJSONLatency(*report.CorrectedLatency)
+		out.CorrectedLatency = &corrected
+	}
+	out.StatusClassCounts = make(map[string]jsonShare)
+	for class, count := range report.StatusClassCounts() {
+		out.StatusClassCounts[class] = jsonShare{Requests: count, Percent: report.StatusClassRate(class)}
+	}
+	out.StatusClasses = make(map[string]jsonClass, len(report.StatusClasses))
+	for _, class := range report.StatusClasses {
+		out.StatusClasses[class.Class] = jsonClass{Requests: class.Requests, Latency: newJSONLatency(class.Latency)}
+	}
+	for _, target := range report.Targets {
+		out.Targets = append(out.Targets, jsonTarget{
+			Name:            target.Name,
+			Method:          target.Method,
+			URL:             target.URL,
+			Weight:          target.Weight,
+			TotalRequests:   target.TotalRequests,
+			SuccessRequests: target.SuccessRequests,
+			FailedRequests:  target.FailedRequests,
+			ErrorRate:       target.ErrorRate(),
+			Latency:         newJSONLatency(target.Latency),
+		})
+	}
+	if report.Scenario != "" {
+		out.Iterations = report.Iterations
+	}
+	for _, step := range report.Steps {
+		out.Steps = append(out.Steps, jsonStep{
+			Name:            step.Name,
+			Method:          step.Method,
+			URL:             step.URL,
+			TotalRequests:   step.TotalRequests,
+			SuccessRequests: step.SuccessRequests,
+			FailedRequests:  step.FailedRequests,
+			ErrorRate:       step.ErrorRate(),
+			Latency:         newJSONLatency(step.Latency),
+		})
+	}
+	for _, stage := range report.Stages {
+		out.Stages = append(out.Stages, jsonStage{
+			DurationMs:        milliseconds(stage.Duration),
+			StartRate:         stage.StartRate,
+			TargetRate:        stage.Rate,
+			TotalRequests:     stage.TotalRequests,
+			SuccessRequests:   stage.SuccessRequests,
+			FailedRequests:    stage.FailedRequests,
+			RequestsPerSecond: stage.RequestsPerSecond(),
+			Latency:           newJSONLatency(stage.Latency),
+		})
+	}
+	for _, result := range report.Thresholds {
+		out.Thresholds = append(out.Thresholds, jsonThreshold{
+			Expression: result.Expression,
+			Actual:     result.Actual,
+			Violated:   result.Violated,
+		})
+	}
+	if g := report.Generator; g != nil {
+		out.Generator = &jsonGenerator{
+			CPUs:            g.CPUs,
+			CPUPercent:      g.CPUPercent,
+			PeakCPUPercent:  g.PeakCPUPercent,
+			PeakMemoryBytes: g.PeakMemory,
+			PeakHeapBytes:   g.PeakHeap,
+			PeakGoroutines:  g.PeakGoroutines,
+			GCCycles:        g.GCCycles,
+			GCPauseTotalMs:  milliseconds(g.GCPauseTotal),
+			GCPauseMaxMs:    milliseconds(g.GCPauseMax),
+			PeakOpenFiles:   g.PeakOpenFiles,
+		}
+	}
+	if c := report.Compression; c != nil {
+		out.Compression = &jsonCompression{
+			AcceptEncoding:  c.AcceptEncoding,
+			Encodings:       c.Encodings,
+			Compressed:      c.Compressed,
+			WireBytes:       c.WireBytes,
+			DecodedBytes:    c.Bytes,
+			Ratio:           c.Ratio(),
+			DecodeTimeMs:    milliseconds(c.DecodeTime),
+			MaxDecodeTimeMs: milliseconds(c.MaxDecodeTime),
+		}
+	}
+	if s := report.SavedBodies; s != nil {
+		out.SavedBodies = &jsonSavedBodies{Dir: s.Dir, Rate: s.Rate, Files: s.Files}
+	}
+	for _, sample := range report.FailureSamples {
+		var headers map[string]string
+		for name, values := range sample.Header {
+			if headers == nil {
+				headers = make(map[string]string, len(sample.Header))
+			}
+			headers[name] = strings.Join(values, ", ")
+		}
+		out.FailureSamples = append(out.FailureSamples, jsonFailureSample{
+			Time:          sample.Time,
+			Step:          sample.Step,
+			Method:        sample.Method,
+			URL:           sample.URL,
+			Status:        sample.Status,
+			Failure:       sample.Failure,
+			Headers:       headers,
+			Body:          string(sample.Body),
+			BodyTruncated: sample.BodyTruncated,
+		})
+	}
+	for name, metric := range report.CustomMetrics {
+		if out.CustomMetrics == nil {
+			out.CustomMetrics = make(map[string]jsonMetric)
+		}
+		out.CustomMetrics[name] = jsonMetric{Count: metric.Count, Sum: metric.Sum, Min: metric.Min, Max: metric.Max, Mean: metric.Mean()}
+	}
+	out.GeneratorWarnings = report.GeneratorWarnings
+	for _, worker := range report.Workers {
+		out.Workers = append(out.Workers, jsonWorker{
+			Worker:       worker.Worker,
+			Requests:     worker.Requests,
+			Failures:     worker.Failures,
+			MeanMs:       milliseconds(worker.MeanLatency),
+			MaxMs:        milliseconds(worker.MaxLatency),
+			LastResponse: worker.LastResponse,
+		})
+	}
+	out.TimelineInterval = milliseconds(report.TimelineInterval)
+	out.Timeline = make([]jsonTimelinePoint, len(report.Timeline))
+	for i, point := range report.Timeline {
+		out.Timeline[i] = jsonTimelinePoint{
+			Second:   int((time.Duration(i) * report.TimelineInterval).Seconds()),
+			Requests: point.Requests,
+			Failures: point.Failures,
+			P95Ms:    milliseconds(point.P95),
+		}
+	}
+	for i, count := range report.Histogram.Counts {
+		bucket := jsonHistogramBucket{Count: count}
+		if i < len(report.Histogram.Bounds) {
+			bucket.LeMs = milliseconds(report.Histogram.Bounds[i])
+		} else {
+			bucket.Inf = true
+		}
+		out.Histogram = append(out.Histogram, bucket)
+	}
+	return out
+}
+
+func writeJSONReport(w io.Writer, report *loadtest.Report) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(newJSONReport(report))
+}
+
+func writeReport(config *Config, report *loadtest.Report) error {
+	var w io.Writer = os.Stdout
+	if config.OutputFile != "" {
+		file, err := os.Create(config.OutputFile)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		w = file
+	}
+
+	if config.Output == "json" {
+		return writeJSONReport(w, report)
+	}
+	printReport(w, report, config.Verbose)
+	return nil
+}
+
+// printSummary writes the headline numbers of report in a single line, as
+// key=value pairs or as a JSON object, for shell scripts.
+func printSummary(w io.Writer, format string, report *loadtest.Report) {
+	errorRate := report.ErrorRate()
+	if format == "json" {
+		json.NewEncoder(w).Encode(struct {
+			RunID     string  `json:"run_id"`
+			Total     int     `json:"total"`
+			Success   int     `json:"success"`
+			ErrorRate float64 `json:"error_rate"`
+			P95Ms     float64 `json:"p95_ms"`
+			RPS       float64 `json:"rps"`
+		}{report.Metadata.RunID, report.TotalRequests, report.SuccessRequests, errorRate, milliseconds(report.Latency.P95), report.RequestsPerSecond()})
+		return
+	}
+	fmt.Fprintf(w, "run=%s total=%d success=%d error_rate=%.2f p95_ms=%.2f rps=%.2f\n", report.Metadata.RunID,
+		report.TotalRequests, report.SuccessRequests, errorRate, milliseconds(report.Latency.P95), report.RequestsPerSecond())
+}
+
+func printReport(w io.Writer, report *loadtest.Report, verbose bool) {
+	io.WriteString(w, formatReport(report, verbose))
+}
+
+// formatReport renders the text report. Every map of the report is sorted,
+// so two runs can be diffed line by line. verbose adds the stats of every
+// worker.
+func formatReport(report *loadtest.Report, verbose bool) string {
+	w := &strings.Builder{}
+	fmt.Fprintln(w, "\n"+strings.Repeat("=", 50))
+	fmt.Fprintln(w, "RELATÓRIO DE TESTE DE CARGA")
+	fmt.Fprintln(w, strings.Repeat("=", 50))
+
+	if report.Interrupted {
+		fmt.Fprintln(w, "*** TESTE INTERROMPIDO - resultados parciais ***")
+	}
+	if report.Aborted != "" {
+		fmt.Fprintf(w, "*** TESTE ABORTADO - %s ***\n", report.Aborted)
+	}
+
+	if report.Metadata.RunID != "" {
+		fmt.Fprintf(w, "Execução: %s\n", runLabel(report.Metadata))
+	}
+	if !report.StartTime.IsZero() {
+		fmt.Fprintf(w, "Início: %s\n", report.StartTime.Format("2006-01-02 15:04:05 MST"))
+	}
+	if len(report.Metadata.Tags) > 0 {
+		fmt.Fprintf(w, "Tags: %s\n", tagFlags(report.Metadata.Tags))
+	}
+	var environment []string
+	for _, detail := range []struct{ name, value string }{
+		{"host", report.Metadata.Hostname},
+		{"git", report.Metadata.GitSHA},
+		{"versão", report.Metadata.Version},
+	} {
+		if detail.value != "" {
+			environment = append(environment, detail.name+" "+detail.value)
+		}
+	}
+	if len(environment) > 0 {
+		fmt.Fprintf(w, "Ambiente: %s\n", strings.Join(environment, " | "))
+	}
+
+	fmt.Fprintf(w, "Tempo total de execução: %v\n", report.TotalTime)
+	fmt.Fprintf(w, "Total de requests realizados: %d\n", report.TotalRequests)
+	if report.Scenario != "" {
+		fmt.Fprintf(w, "Iterações do cenário %s: %d\n", report.Scenario, report.Iterations)
+	}
+	fmt.Fprintf(w, "Requests com status de sucesso (%s): %d\n", report.SuccessCodes, report.SuccessRequests)
+	fmt.Fprintf(w, "Protocolo: %s\n", report.Protocol)
+	if report.MaxBandwidth > 0 {
+		fmt.Fprintf(w, "Banda máxima por conexão: %s/s\n", formatBytes(report.MaxBandwidth))
+	}
+	if report.Executor == loadtest.ExecutorOpen {
+		fmt.Fprintf(w, "Executor: open (taxa de chegada, %d de até %d VUs usados)\n", report.VUs, report.MaxVUs)
+		fmt.Fprintf(w, "Iterações descartadas (sem VU livre): %d\n", report.DroppedIterations)
+	} else {
+		fmt.Fprintf(w, "Executor: closed (%d workers fixos)\n", report.Concurrency)
+	}
+	if report.RampUp > 0 {
+		fmt.Fprintf(w, "Ramp-up: 1 → %d workers em %v\n", report.Concurrency, report.RampUp)
+	}
+	if report.WarmupRequests > 0 {
+		fmt.Fprintf(w, "Warm-up: %d requests descartados\n", report.WarmupRequests)
+	}
+	if report.ThinkTime > 0 || report.ThinkTimeJitter > 0 {
+		fmt.Fprintf(w, "Think time: %v ± %v\n", report.ThinkTime, report.ThinkTimeJitter)
+	}
+
+	fmt.Fprintf(w, "Taxa de sucesso: %.2f%%\n", report.SuccessRate())
+	fmt.Fprintf(w, "Requests por segundo: %.2f\n", report.RequestsPerSecond())
+	fmt.Fprintf(w, "Dados recebidos: %s (média de %s por resposta, máx %s)\n",
+		formatBytes(float64(report.TotalBytes)), formatBytes(report.AverageResponseSize()), formatBytes(float64(report.MaxResponseSize)))
+	fmt.Fprintf(w, "Vazão: %s/s\n", formatBytes(report.Throughput()))
+	if report.BytesSent > 0 {
+		fmt.Fprintf(w, "Dados enviados: %s (%s/s)\n", formatBytes(float64(report.BytesSent)), formatBytes(report.UploadThroughput()))
+	}
+
+	fmt.Fprintln(w, "\nLatência:")
+	fmt.Fprintf(w, "  Mín: %v | Máx: %v\n", report.Latency.Min, report.Latency.Max)
+	fmt.Fprintf(w, "  Média: %v | Desvio padrão: %v\n", report.Latency.Mean, report.Latency.StdDev)
+	fmt.Fprintf(w, "  p50: %v | p90: %v | p95: %v | p99: %v | p99.9: %v\n", report.Latency.P50, report.Latency.P90, report.Latency.P95, report.Latency.P99, report.Latency.P999)
+	if corrected := report.CorrectedLatency; corrected != nil {
+		fmt.Fprintln(w, "\nLatência corrigida (a partir do horário planejado de envio):")
+		fmt.Fprintf(w, "  Média: %v | Máx: %v\n", corrected.Mean, corrected.Max)
+		fmt.Fprintf(w, "  p50: %v | p90: %v | p95: %v | p99: %v | p99.9: %v\n", corrected.P50, corrected.P90, corrected.P95, corrected.P99, corrected.P999)
+	}
+	if len(report.StatusClasses) > 1 {
+		fmt.Fprintln(w, "\nLatência por classe de status:")
+		for _, class := range report.StatusClasses {
+			name := class.Class
+			if name == loadtest.ErrorClass {
+				name = "erros"
+			}
+			fmt.Fprintf(w, "  %s (%d requests): Média: %v | p50: %v | p95: %v | p99: %v\n", name, class.Requests, class.Latency.Mean, class.Latency.P50, class.Latency.P95, class.Latency.P99)
+		}
+	}
+
+	printPhases(w, report.Phases)
+	printHistogram(w, report.Histogram)
+
+	if len(report.Targets) > 1 {
+		fmt.Fprintln(w, "\nResultados por alvo:")
+		rows := make([]endpointRow, len(report.Targets))
+		for i, target := range report.Targets {
+			name := fmt.Sprintf("%s (peso %d)", target.URL, target.Weight)
+			if target.Name != "" {
+				name = fmt.Sprintf("%s (%s %s, peso %d)", target.Name, target.Method, target.URL, target.Weight)
+			}
+			rows[i] = endpointRow{name, target.RequestStats}
+		}
+		printEndpoints(w, rows, report)
+	}
+
+	if len(report.Steps) > 0 {
+		fmt.Fprintln(w, "\nResultados por passo:")
+		rows := make([]endpointRow, len(report.Steps))
+		for i, step := range report.Steps {
+			rows[i] = endpointRow{fmt.Sprintf("%d. %s (%s %s)", i+1, step.Name, step.Method, step.URL), step.RequestStats}
+		}
+		printEndpoints(w, rows, report)
+	}
+
+	if len(report.Stages) > 0 {
+		fmt.Fprintln(w, "\nResultados por estágio:")
+		for i, stage := range report.Stages {
+			fmt.Fprintf(w, "  %d. %v: %.2f → %.2f req/s (atingido: %.2f req/s)\n", i+1, stage.Duration, stage.StartRate, stage.Rate, stage.RequestsPerSecond())
+			fmt.Fprintf(w, "    Requests: %d | Sucesso: %d | Falhas: %d\n", stage.TotalRequests, stage.SuccessRequests, stage.FailedRequests)
+			fmt.Fprintf(w, "    Média: %v | p95: %v | p99: %v\n", stage.Latency.Mean, stage.Latency.P95, stage.Latency.P99)
+		}
+	}
+
+	fmt.Fprintln(w, "\nDistribuição por classe de status:")
+	classes := report.StatusClassCounts()
+	for _, class := range sortedKeys(classes) {
+		success := ""
+		if report.SuccessCodes.ContainsClass(class) {
+			success = " (sucesso)"
+		}
+		fmt.Fprintf(w, "  %s: %d (%.2f%%)%s\n", class, classes[class], report.StatusClassRate(class), success)
+	}
+
+	fmt.Fprintln(w, "\nDistribuição de códigos de status:")
+	for _, statusCode := range sortedKeys(report.StatusCodes) {
+		if statusCode == 0 {
+			continue
+		}
+		count := report.StatusCodes[statusCode]
+		percentage := float64(count) / float64(report.TotalRequests) * 100
+		if loadtest.IsRedirect(statusCode) {
+			fmt.Fprintf(w, "  %d (redirecionamento): %d (%.2f%%)\n", statusCode, count, percentage)
+		} else {
+			fmt.Fprintf(w, "  %d: %d (%.2f%%)\n", statusCode, count, percentage)
+		}
+	}
+	if count := report.StatusCodes[0]; count > 0 {
+		fmt.Fprintf(w, "  Errors: %d (%.2f%%)\n", count, float64(count)/float64(report.TotalRequests)*100)
+	}
+	if report.Redirects > 0 {
+		fmt.Fprintf(w, "  Redirecionamentos seguidos: %d\n", report.Redirects)
+	}
+	if report.Timeouts > 0 {
+		percentage := float64(report.Timeouts) / float64(report.TotalRequests) * 100
+		fmt.Fprintf(w, "  Timeouts: %d (%.2f%%)\n", report.Timeouts, percentage)
+	}
+
+	if len(report.GRPCStatusCodes) > 0 {
+		fmt.Fprintln(w, "\nDistribuição de status gRPC:")
+		for _, name := range sortedKeys(report.GRPCStatusCodes) {
+			count := report.GRPCStatusCodes[name]
+			fmt.Fprintf(w, "  %s: %d (%.2f%%)\n", name, count, float64(count)/float64(report.TotalRequests)*100)
+		}
+	}
+
+	if retry := report.Retry; retry != nil {
+		fmt.Fprintf(w, "\nRetentativas (até %d, backoff %v, em %s):\n", retry.Retries, retry.Backoff, retry)
+		fmt.Fprintf(w, "  Tentativas: %d (%d retentativas)\n", report.Attempts, report.Attempts-report.TotalRequests)
+		fmt.Fprintf(w, "  Requests com falha na primeira tentativa: %d\n", report.RetriedRequests)
+		fmt.Fprintf(w, "  Recuperados após retentativa: %d\n", report.RecoveredRequests)
+		fmt.Fprintf(w, "  Falharam mesmo após retentativas: %d\n", report.RetriedRequests-report.RecoveredRequests)
+	}
+
+	if report.Conditional > 0 {
+		fmt.Fprintln(w, "\nRequests condicionais (If-None-Match/If-Modified-Since):")
+		fmt.Fprintf(w, "  Enviados: %d | 304 Not Modified: %d (%.2f%%)\n", report.Conditional, report.NotModified,
+			100*float64(report.NotModified)/float64(report.Conditional))
+	}
+
+	if report.ReusedConnections+report.NewConnections > 0 {
+		fmt.Fprintln(w, "\nConexões:")
+		fmt.Fprintf(w, "  Reutilizadas: %d | Novas: %d\n", report.ReusedConnections, report.NewConnections)
+	}
+
+	if c := report.Compression; c != nil {
+		fmt.Fprintf(w, "\nCompressão (Accept-Encoding: %s):\n", c.AcceptEncoding)
+		var encodings []string
+		for _, encoding := range byCount(c.Encodings) {
+			encodings = append(encodings, fmt.Sprintf("%s %d", encoding, c.Encodings[encoding]))
+		}
+		fmt.Fprintf(w, "  Respostas: %s\n", strings.Join(encodings, " | "))
+		if c.Compressed > 0 {
+			fmt.Fprintf(w, "  Transferido: %s | Descompactado: %s (%.1f× menor na rede)\n",
+				formatBytes(float64(c.WireBytes)), formatBytes(float64(c.Bytes)), c.Ratio())
+			fmt.Fprintf(w, "  Tempo de descompressão: total %v | média %v | máx %v\n",
+				c.DecodeTime, c.DecodeTime/time.Duration(c.Compressed), c.MaxDecodeTime)
+		}
+	}
+
+	if s := report.SavedBodies; s != nil {
+		fmt.Fprintf(w, "\nRespostas salvas em %s: %d (falhas e %g%% das respostas com sucesso)\n", s.Dir, s.Files, s.Rate*100)
+	}
+
+	if len(report.Protocols) > 0 {
+		fmt.Fprintln(w, "\nProtocolos negociados:")
+		for _, proto := range sortedKeys(report.Protocols) {
+			fmt.Fprintf(w, "  %s: %d\n", proto, report.Protocols[proto])
+		}
+	}
+
+	if len(report.IPFamilies) > 0 {
+		fmt.Fprintln(w, "\nFamílias de endereço:")
+		for _, family := range sortedKeys(report.IPFamilies) {
+			fmt.Fprintf(w, "  %s: %d requests\n", ipFamilyName(loadtest.IPFamily(family)), report.IPFamilies[family])
+		}
+	}
+
+	if report.FailedAssertions > 0 {
+		fmt.Fprintf(w, "\nAsserções com falha: %d\n", report.FailedAssertions)
+		for _, assertion := range byCount(report.AssertionFailures) {
+			fmt.Fprintf(w, "  %s: %d\n", assertion, report.AssertionFailures[assertion])
+		}
+	}
+
+	if len(report.CustomMetrics) > 0 {
+		fmt.Fprintln(w, "\nMétricas customizadas:")
+		for _, name := range sortedKeys(report.CustomMetrics) {
+			metric := report.CustomMetrics[name]
+			fmt.Fprintf(w, "  %s: %d valores | média: %s | mín: %s | máx: %s | soma: %s\n", name, metric.Count,
+				formatMetric(metric.Mean()), formatMetric(metric.Min), formatMetric(metric.Max), formatMetric(metric.Sum))
+		}
+	}
+
+	if len(report.ErrorCategories) > 0 {
+		fmt.Fprintln(w, "\nErros por categoria:")
+		for _, category := range byCount(report.ErrorCategories) {
+			fmt.Fprintf(w, "  %s: %d\n", category, report.ErrorCategories[category])
+		}
+	}
+
+	if len(report.Errors) > 0 {
+		fmt.Fprintln(w, "\nErros:")
+		for _, message := range byCount(report.Errors) {
+			fmt.Fprintf(w, "  %d× %s\n", report.Errors[message], message)
+		}
+	}
+
+	if len(report.FailureSamples) > 0 {
+		fmt.Fprintf(w, "\nAmostras de falhas (primeiras %d):\n", len(report.FailureSamples))
+		for i, sample := range report.FailureSamples {
+			printFailureSample(w, i+1, sample)
+		}
+	}
+
+	printWorkers(w, report, verbose)
+
+	if g := report.Generator; g != nil {
+		fmt.Fprintln(w, "\nRecursos do gerador de carga:")
+		if g.CPUPercent > 0 {
+			fmt.Fprintf(w, "  CPU: média %.0f%% | pico %.0f%% (%d núcleo(s), 100%% = um núcleo)\n", g.CPUPercent, g.PeakCPUPercent, g.CPUs)
+		}
+		fmt.Fprintf(w, "  Memória: pico %s (heap %s) | Goroutines: pico %d\n", formatBytes(float64(g.PeakMemory)), formatBytes(float64(g.PeakHeap)), g.PeakGoroutines)
+		fmt.Fprintf(w, "  GC: %d ciclos | pausa total %v | maior pausa %v\n", g.GCCycles, g.GCPauseTotal, g.GCPauseMax)
+		if g.PeakOpenFiles > 0 {
+			fmt.Fprintf(w, "  Arquivos abertos: pico %d\n", g.PeakOpenFiles)
+		}
+	}
+
+	if len(report.GeneratorWarnings) > 0 {
+		fmt.Fprintln(w, "\nAvisos do gerador de carga (os resultados podem não refletir o alvo):")
+		for _, warning := range report.GeneratorWarnings {
+			fmt.Fprintf(w, "  ⚠ %s\n", warning)
+		}
+	}
+
+	if len(report.Thresholds) > 0 {
+		fmt.Fprintln(w, "\nThresholds:")
+		for _, result := range report.Thresholds {
+			status := "OK"
+			if result.Violated {
+				status = "VIOLADO"
+			}
+			fmt.Fprintf(w, "  [%s] %s (valor atual: %s)\n", status, result.Expression, result.Actual)
+		}
+	}
+	fmt.Fprintln(w, strings.Repeat("=", 50))
+	return w.String()
+}
+
+func sortedKeys[K int | int64 | string, V any](m map[K]V) []K {
+	keys := make([]K, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
+
+// byCount sorts the keys of m from the most to the least frequent.
+func byCount(m map[string]int) []string {
+	keys := sortedKeys(m)
+	sort.SliceStable(keys, func(i, j int) bool { return m[keys[i]] > m[keys[j]] })
+	return keys
+}
+
+// maxWorkerWarnings caps the workers pointed out by printWorkers.
+const maxWorkerWarnings = 10
+
+// printWorkers summarizes how the requests were spread between the workers
+// and points out the ones that stopped responding before the end or did much
+// less than the others, which a ramp-up or the open executor explain.
+func printWorkers(w io.Writer, report *loadtest.Report, verbose bool) {
+	workers := report.Workers
+	if len(workers) < 2 {
+		return
+	}
+	counts := make([]int, len(workers))
+	var last time.Time
+	for i, worker := range workers {
+		counts[i] = worker.Requests
+		if worker.LastResponse.After(last) {
+			last = worker.LastResponse
+		}
+	}
+	sort.Ints(counts)
+	median := counts[len(counts)/2]
+	fmt.Fprintf(w, "\nDistribuição entre workers (%d):\n", len(workers))
+	fmt.Fprintf(w, "  Requests por worker: mín %d | mediana %d | máx %d\n", counts[0], median, counts[len(counts)-1])
+
+	idle := max(report.TotalTime/10, time.Second)
+	staggered := report.RampUp > 0 || report.Executor == loadtest.ExecutorOpen
+	var warnings []string
+	for _, worker := range workers {
+		switch {
+		case worker.Requests == 0:
+			warnings = append(warnings, fmt.Sprintf("worker %d não completou nenhum request", worker.Worker))
+		case last.Sub(worker.LastResponse) > idle:
+			warnings = append(warnings, fmt.Sprintf("worker %d ficou sem respostas nos últimos %v do teste",
+				worker.Worker, last.Sub(worker.LastResponse).Round(time.Millisecond)))
+		case !staggered && worker.Requests < median/2:
+			warnings = append(warnings, fmt.Sprintf("worker %d fez %d requests, menos da metade da mediana", worker.Worker, worker.Requests))
+		}
+	}
+	for i, warning := range warnings {
+		if i == maxWorkerWarnings {
+			fmt.Fprintf(w, "  ... e mais %d workers\n", len(warnings)-maxWorkerWarnings)
+			break
+		}
+		fmt.Fprintf(w, "  ⚠ %s\n", warning)
+	}
+
+	if verbose {
+		fmt.Fprintf(w, "  %6s %10s %8s %14s %14s\n", "Worker", "Requests", "Falhas", "Média", "Máx")
+		for _, worker := range workers {
+			fmt.Fprintf(w, "  %6d %10d %8d %14v %14v\n", worker.Worker, worker.Requests, worker.Failures,
+				worker.MeanLatency.Round(time.Microsecond), worker.MaxLatency.Round(time.Microsecond))
+		}
+	}
+}
+
+type endpointRow struct {
+	name  string
+	stats loadtest.RequestStats
+}
+
+// printEndpoints prints a table with the requests, error rate and latency of
+// each target or step, followed by the whole run.
+func printEndpoints(w io.Writer, rows []endpointRow, report *loadtest.Report) {
+	rows = append(rows, endpointRow{"Total", loadtest.RequestStats{
+		TotalRequests:   report.TotalRequests,
+		SuccessRequests: report.SuccessRequests,
+		FailedRequests:  report.TotalRequests - report.SuccessRequests,
+		Latency:         report.Latency,
+	}})
+	// tabwriter aligns every column to the same side, so the names, aligned to
+	// the left, are padded by hand.
+	width := 0
+	for _, row := range rows {
+		width = max(width, utf8.RuneCountInString(row.name))
+	}
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(table, "%-*s\tRequests\tErros\tMédia\tp50\tp90\tp95\tp99\t\n", width, "")
+	for _, row := range rows {
+		latency := row.stats.Latency
+		name := row.name + strings.Repeat(" ", width-utf8.RuneCountInString(row.name))
+		fmt.Fprintf(table, "%s\t%d\t%.2f%%\t%v\t%v\t%v\t%v\t%v\t\n", name, row.stats.TotalRequests, row.stats.ErrorRate(),
+			latency.Mean.Round(time.Microsecond), latency.P50.Round(time.Microsecond), latency.P90.Round(time.Microsecond),
+			latency.P95.Round(time.Microsecond), latency.P99.Round(time.Microsecond))
+	}
+	table.Flush()
+}
+
+func printPhases(w io.Writer, phases loadtest.PhaseStats) {
+	fmt.Fprintln(w, "\nLatência por fase (média | p95 | p99):")
+	for _, phase := range []struct {
+		name  string
+		stats loadtest.LatencyStats
+	}{
+		{"DNS", phases.DNS},
+		{"Conexão TCP", phases.Connect},
+		{"Handshake TLS", phases.TLS},
+		{"Primeiro byte", phases.TTFB},
+		{"Download", phases.Download},
+	} {
+		fmt.Fprintf(w, "  %-14s %v | %v | %v\n", phase.name+":", phase.stats.Mean, phase.stats.P95, phase.stats.P99)
+	}
+}
+
+func printHistogram(w io.Writer, histogram loadtest.Histogram) {
+	const barWidth = 40
+
+	total, largest := 0, 0
+	for _, count := range histogram.Counts {
+		total += count
+		if count > largest {
+			largest = count
+		}
+	}
+	if total == 0 {
+		return
+	}
+
+	fmt.Fprintln(w, "\nHistograma de latência:")
+	for i, count := range histogram.Counts {
+		label := "> " + histogram.Bounds[len(histogram.Bounds)-1].String()
+		if i < len(histogram.Bounds) {
+			label = "<= " + histogram.Bounds[i].String()
+		}
+		bar := strings.Repeat("█", count*barWidth/largest)
+		fmt.Fprintf(w, "  %10s | %-*s %d (%.2f%%)\n", label, barWidth, bar, count, float64(count)/float64(total)*100)
+	}
+}
+
+func newJSONConnectionReport(report *loadtest.ConnectionReport) jsonConnectionReport {
+	return jsonConnectionReport{
+		TotalTimeMs:      milliseconds(report.TotalTime),
+		Connections:      report.Connections,
+		Connected:        report.Connected,
+		ConnectLatency:   newJSONLatency(report.ConnectLatency),
+		MessagesSent:     report.MessagesSent,
+		MessagesReceived: report.MessagesReceived,
+		RoundTrip:        newJSONLatency(report.RoundTrip),
+		Disconnects:      report.Disconnects,
+		Timeouts:         report.Timeouts,
+		Errors:           report.Errors,
+	}
+}
+
+func printConnectionReport(w io.Writer, report *loadtest.ConnectionReport) {
+	separator := strings.Repeat("=", 50)
+	fmt.Fprintln(w, separator)
+	fmt.Fprintln(w, "RELATÓRIO DE TESTE DE CONEXÕES")
+	fmt.Fprintln(w, separator)
+	fmt.Fprintf(w, "Tempo total de execução: %v\n", report.TotalTime)
+	fmt.Fprintf(w, "Conexões estabelecidas: %d de %d\n", report.Connected, report.Connections)
+	latency := report.ConnectLatency
+	fmt.Fprintf(w, "Tempo de conexão: média %v | p95 %v | máx %v\n", latency.Mean, latency.P95, latency.Max)
+
+	fmt.Fprintf(w, "\nMensagens enviadas: %d\n", report.MessagesSent)
+	fmt.Fprintf(w, "Mensagens recebidas: %d\n", report.MessagesReceived)
+	if report.TotalTime > 0 {
+		fmt.Fprintf(w, "Mensagens por segundo: %.2f\n", float64(report.MessagesReceived)/report.TotalTime.Seconds())
+	}
+	if report.MessagesReceived > 0 {
+		rt := report.RoundTrip
+		fmt.Fprintln(w, "\nLatência de ida e volta:")
+		fmt.Fprintf(w, "  Mín: %v | Máx: %v\n", rt.Min, rt.Max)
+		fmt.Fprintf(w, "  Média: %v | Desvio padrão: %v\n", rt.Mean, rt.StdDev)
+		fmt.Fprintf(w, "  p50: %v | p90: %v | p95: %v | p99: %v\n", rt.P50, rt.P90, rt.P95, rt.P99)
+	}
+
+	fmt.Fprintln(w, "\nConexões encerradas antes do fim:")
+	fmt.Fprintf(w, "  Desconectadas pelo servidor: %d\n", report.Disconnects)
+	fmt.Fprintf(w, "  Timeouts: %d\n", report.Timeouts)
+	for _, category := range report.ErrorCategories() {
+		fmt.Fprintf(w, "  Erro %s: %d\n", category, report.Errors[category])
+	}
+	fmt.Fprintln(w, separator)
+}
+
+func writeConnectionReport(output, outputFile string, report *loadtest.ConnectionReport) error {
+	var w io.Writer = os.Stdout
+	if outputFile != "" {
+		file, err := os.Create(outputFile)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		w = file
+	}
+	if output == "json" {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(newJSONConnectionReport(report))
+	}
+	printConnectionReport(w, report)
+	return nil
+}
@@ -0,0 +1,60 @@
+package loadtest
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AbortRule stops the run early when the error rate over the last Window
+// goes above Rate, in percent, so that a clearly broken or wrong target
+// isn't hammered for the whole test.
+type AbortRule struct {
+	Rate   float64
+	Window time.Duration
+}
+
+// ParseAbortRule parses a rule written as rate@window, e.g. "50%@10s".
+func ParseAbortRule(s string) (AbortRule, error) {
+	rate, window, ok := strings.Cut(strings.ReplaceAll(s, " ", ""), "@")
+	if !ok {
+		return AbortRule{}, fmt.Errorf("regra %q inválida, use taxa@janela (ex: 50%%@10s)", s)
+	}
+	var rule AbortRule
+	var err error
+	if rule.Rate, err = strconv.ParseFloat(strings.TrimSuffix(rate, "%"), 64); err != nil || rule.Rate <= 0 || rule.Rate > 100 {
+		return AbortRule{}, fmt.Errorf("regra %q: taxa %q deve estar entre 0 e 100%%", s, rate)
+	}
+	if rule.Window, err = time.ParseDuration(window); err != nil || rule.Window <= 0 {
+		return AbortRule{}, fmt.Errorf("regra %q: janela %q inválida", s, window)
+	}
+	return rule, nil
+}
+
+func (a AbortRule) String() string {
+	return fmt.Sprintf("%g%%@%v", a.Rate, a.Window)
+}
+
+// check returns the reason to abort when the error rate of the timeline
+// intervals that make up the window before interval current exceeds the
+// rule. Nothing is checked before a whole window has elapsed.
+func (a AbortRule) check(report *Report, current int) (string, bool) {
+	n := max(int(a.Window/report.TimelineInterval), 1)
+	if current < n {
+		return "", false
+	}
+	var requests, failures int
+	for _, point := range report.Timeline[current-n : current] {
+		requests += point.Requests
+		failures += point.Failures
+	}
+	if requests == 0 {
+		return "", false
+	}
+	rate := 100 * float64(failures) / float64(requests)
+	if rate <= a.Rate {
+		return "", false
+	}
+	return fmt.Sprintf("taxa de erro de %.2f%% nos últimos %v, acima do limite de %g%%", rate, a.Window, a.Rate), true
+}
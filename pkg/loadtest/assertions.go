@@ -0,0 +1,198 @@
+package loadtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type Assertion interface {
+	Check(body []byte) error
+	String() string
+}
+
+type bodyContains struct {
+	substr string
+}
+
+func BodyContains(substr string) Assertion {
+	return bodyContains{substr: substr}
+}
+
+func (a bodyContains) Check(body []byte) error {
+	if !bytes.Contains(body, []byte(a.substr)) {
+		return fmt.Errorf("corpo não contém %q", a.substr)
+	}
+	return nil
+}
+
+func (a bodyContains) String() string {
+	return fmt.Sprintf("body contains %q", a.substr)
+}
+
+type bodyMatches struct {
+	re *regexp.Regexp
+}
+
+func BodyMatches(pattern string) (Assertion, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	return bodyMatches{re: re}, nil
+}
+
+func (a bodyMatches) Check(body []byte) error {
+	if !a.re.Match(body) {
+		return fmt.Errorf("corpo não corresponde a /%s/", a.re)
+	}
+	return nil
+}
+
+func (a bodyMatches) String() string {
+	return fmt.Sprintf("body matches /%s/", a.re)
+}
+
+type jsonPathEquals struct {
+	path     string
+	segments []any
+	expected string
+}
+
+// JSONPathEquals asserts that the value at path equals expected. path supports
+// the dot/bracket subset of JSONPath ($.items[0].name, $['key']); non-string
+// values are compared against their JSON encoding.
+func JSONPathEquals(path, expected string) (Assertion, error) {
+	segments, err := parseJSONPath(path)
+	if err != nil {
+		return nil, err
+	}
+	return jsonPathEquals{path: path, segments: segments, expected: expected}, nil
+}
+
+func (a jsonPathEquals) Check(body []byte) error {
+	var doc any
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return fmt.Errorf("corpo não é JSON válido: %w", err)
+	}
+	value, ok := lookupJSONPath(doc, a.segments)
+	if !ok {
+		return fmt.Errorf("%s não encontrado", a.path)
+	}
+	if actual := jsonValueString(value); actual != a.expected {
+		return fmt.Errorf("%s = %s, esperado %s", a.path, actual, a.expected)
+	}
+	return nil
+}
+
+func (a jsonPathEquals) String() string {
+	return fmt.Sprintf("%s == %s", a.path, a.expected)
+}
+
+func parseJSONPath(path string) ([]any, error) {
+	if !strings.HasPrefix(path, "$") {
+		return nil, fmt.Errorf("JSONPath %q deve começar com $", path)
+	}
+
+	var segments []any
+	rest := path[1:]
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end == -1 {
+				end = len(rest)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("JSONPath %q inválido", path)
+			}
+			segments = append(segments, rest[:end])
+			rest = rest[end:]
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end == -1 {
+				return nil, fmt.Errorf("JSONPath %q inválido: ] ausente", path)
+			}
+			inner := rest[1:end]
+			rest = rest[end+1:]
+			if len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0] {
+				segments = append(segments, inner[1:len(inner)-1])
+				continue
+			}
+			index, err := strconv.Atoi(inner)
+			if err != nil {
+				return nil, fmt.Errorf("JSONPath %q inválido: índice %q", path, inner)
+			}
+			segments = append(segments, index)
+		default:
+			return nil, fmt.Errorf("JSONPath %q inválido", path)
+		}
+	}
+	return segments, nil
+}
+
+func lookupJSONPath(doc any, segments []any) (any, bool) {
+	current := doc
+	for _, segment := range segments {
+		switch key := segment.(type) {
+		case string:
+			object, ok := current.(map[string]any)
+			if !ok {
+				return nil, false
+			}
+			if current, ok = object[key]; !ok {
+				return nil, false
+			}
+		case int:
+			array, ok := current.([]any)
+			if !ok {
+				return nil, false
+			}
+			if key < 0 {
+				key += len(array)
+			}
+			if key < 0 || key >= len(array) {
+				return nil, false
+			}
+			current = array[key]
+		}
+	}
+	return current, true
+}
+
+func jsonValueString(value any) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(encoded)
+}
+
+type AssertionError struct {
+	Assertion string
+	Err       error
+}
+
+func (e *AssertionError) Error() string {
+	return e.Assertion + ": " + e.Err.Error()
+}
+
+func (e *AssertionError) Unwrap() error {
+	return e.Err
+}
+
+func checkAssertions(assertions []Assertion, body []byte) *AssertionError {
+	for _, assertion := range assertions {
+		if err := assertion.Check(body); err != nil {
+			return &AssertionError{Assertion: assertion.String(), Err: err}
+		}
+	}
+	return nil
+}
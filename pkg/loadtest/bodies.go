@@ -0,0 +1,128 @@
+package loadtest
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// BodyMode is how the runner receives response bodies.
+type BodyMode string
+
+const (
+	// BodyDiscard drains each body as it arrives, counting its bytes without
+	// keeping them. It is the default.
+	BodyDiscard BodyMode = "discard"
+	// BodyRead reads each body whole into memory, like a client that parses
+	// it, so that buffering large responses costs the runner what it would
+	// cost a real client.
+	BodyRead BodyMode = "read"
+)
+
+// maxSavedBodies caps the failed and the sampled responses saved by
+// WithSaveBodies, each, so that a run full of errors doesn't fill the disk.
+const maxSavedBodies = 1000
+
+// SavedBodies summarizes the responses saved by WithSaveBodies.
+type SavedBodies struct {
+	Dir   string
+	Rate  float64
+	Files int
+}
+
+func (s *SavedBodies) add(result Result) {
+	if result.SavedBody != "" {
+		s.Files++
+	}
+}
+
+// bodySaver writes every failed response, and a sample of Rate of the
+// successful ones, to files in dir.
+type bodySaver struct {
+	dir      string
+	rate     float64
+	seq      atomic.Int64
+	failures atomic.Int64
+	samples  atomic.Int64
+
+	mu     sync.Mutex
+	errors int
+	err    error
+}
+
+// failure describes why result failed, or is "" when it succeeded.
+func (r *Runner) failure(result Result) string {
+	switch {
+	case result.AssertionError != nil:
+		return result.AssertionError.Error()
+	case !r.success.Contains(result.StatusCode):
+		return fmt.Sprintf("status %d", result.StatusCode)
+	case result.grpcFailed():
+		return "grpc-status " + result.GRPCStatus
+	}
+	return ""
+}
+
+// warning describes the responses that couldn't be saved, if any.
+func (s *bodySaver) warning() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.errors == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d respostas não foram salvas em %s: %v", s.errors, s.dir, s.err)
+}
+
+// save returns the path of the file it wrote, or "" when the response was
+// left out of the sample or couldn't be saved.
+func (s *bodySaver) save(step Step, resp *http.Response, body []byte, failure string) string {
+	if failure == "" && (rand.Float64() >= s.rate || s.samples.Add(1) > maxSavedBodies) {
+		return ""
+	}
+	if failure != "" && s.failures.Add(1) > maxSavedBodies {
+		return ""
+	}
+	kind := "amostra"
+	if failure != "" {
+		kind = "falha"
+	}
+	path := filepath.Join(s.dir, fmt.Sprintf("%06d-%d-%s.http", s.seq.Add(1), resp.StatusCode, kind))
+
+	// The file holds the status line, headers and body of the response, after
+	// comments with the request and why it was saved.
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# %s %s\n", resp.Request.Method, resp.Request.URL)
+	if step.Name != "" && step.Name != step.Method+" "+step.URL {
+		fmt.Fprintf(&buf, "# passo: %s\n", step.Name)
+	}
+	if failure != "" {
+		fmt.Fprintf(&buf, "# falha: %s\n", failure)
+	}
+	fmt.Fprintf(&buf, "%s %s\n", resp.Proto, resp.Status)
+	names := make([]string, 0, len(resp.Header))
+	for name := range resp.Header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, value := range resp.Header[name] {
+			fmt.Fprintf(&buf, "%s: %s\n", name, value)
+		}
+	}
+	buf.WriteString("\n")
+	buf.Write(body)
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		s.mu.Lock()
+		s.errors++
+		s.err = err
+		s.mu.Unlock()
+		return ""
+	}
+	return path
+}
@@ -0,0 +1,120 @@
+package loadtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+)
+
+// Capture extracts a value from a scenario step response into a variable
+// available to the following steps of the same iteration as {{.name}}.
+type Capture interface {
+	Variable() string
+	Extract(header http.Header, body []byte) (string, error)
+	String() string
+}
+
+type jsonPathCapture struct {
+	variable string
+	path     string
+	segments []any
+}
+
+func CaptureJSONPath(variable, path string) (Capture, error) {
+	segments, err := parseJSONPath(path)
+	if err != nil {
+		return nil, err
+	}
+	return jsonPathCapture{variable: variable, path: path, segments: segments}, nil
+}
+
+func (c jsonPathCapture) Variable() string {
+	return c.variable
+}
+
+func (c jsonPathCapture) Extract(_ http.Header, body []byte) (string, error) {
+	var doc any
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return "", fmt.Errorf("corpo não é JSON válido: %w", err)
+	}
+	value, ok := lookupJSONPath(doc, c.segments)
+	if !ok {
+		return "", fmt.Errorf("%s não encontrado", c.path)
+	}
+	return jsonValueString(value), nil
+}
+
+func (c jsonPathCapture) String() string {
+	return fmt.Sprintf("capture %s from %s", c.variable, c.path)
+}
+
+type regexCapture struct {
+	variable string
+	re       *regexp.Regexp
+}
+
+// CaptureRegex captures the first submatch of pattern, or the whole match
+// when the pattern has no groups.
+func CaptureRegex(variable, pattern string) (Capture, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	return regexCapture{variable: variable, re: re}, nil
+}
+
+func (c regexCapture) Variable() string {
+	return c.variable
+}
+
+func (c regexCapture) Extract(_ http.Header, body []byte) (string, error) {
+	match := c.re.FindSubmatch(body)
+	if match == nil {
+		return "", fmt.Errorf("corpo não corresponde a /%s/", c.re)
+	}
+	if len(match) > 1 {
+		return string(match[1]), nil
+	}
+	return string(match[0]), nil
+}
+
+func (c regexCapture) String() string {
+	return fmt.Sprintf("capture %s from /%s/", c.variable, c.re)
+}
+
+type headerCapture struct {
+	variable string
+	name     string
+}
+
+func CaptureHeader(variable, name string) Capture {
+	return headerCapture{variable: variable, name: http.CanonicalHeaderKey(name)}
+}
+
+func (c headerCapture) Variable() string {
+	return c.variable
+}
+
+func (c headerCapture) Extract(header http.Header, _ []byte) (string, error) {
+	values, ok := header[c.name]
+	if !ok || len(values) == 0 {
+		return "", fmt.Errorf("header %s ausente", c.name)
+	}
+	return values[0], nil
+}
+
+func (c headerCapture) String() string {
+	return fmt.Sprintf("capture %s from header %s", c.variable, c.name)
+}
+
+func runCaptures(captures []Capture, header http.Header, body []byte, vars map[string]string) *AssertionError {
+	for _, capture := range captures {
+		value, err := capture.Extract(header, body)
+		if err != nil {
+			return &AssertionError{Assertion: capture.String(), Err: err}
+		}
+		vars[capture.Variable()] = value
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package loadtest
+
+import (
+	"net/http"
+	"time"
+)
+
+// CheckAssertion is the assertion under which the responses failed by the
+// ResponseCheck are counted in Report.AssertionFailures.
+const CheckAssertion = "check"
+
+// CheckedResponse is what a ResponseCheck sees of a response. Body is
+// truncated to the same size as the one given to assertions.
+type CheckedResponse struct {
+	Step     string
+	Method   string
+	URL      string
+	Status   int
+	Header   http.Header
+	Body     []byte
+	Duration time.Duration
+}
+
+// ResponseCheck validates a response with logic too complex for assertions.
+// A non-nil error fails the response as a failed assertion. metrics are custom
+// values, aggregated by name in Report.CustomMetrics whether the check passes
+// or not. It is called from every worker at once, so it must be safe for
+// concurrent use.
+type ResponseCheck func(resp *CheckedResponse) (metrics map[string]float64, err error)
+
+// MetricStats aggregates the values a ResponseCheck reported under a name.
+type MetricStats struct {
+	Count int
+	Sum   float64
+	Min   float64
+	Max   float64
+}
+
+func (m MetricStats) Mean() float64 {
+	if m.Count == 0 {
+		return 0
+	}
+	return m.Sum / float64(m.Count)
+}
+
+func (m *MetricStats) add(v float64) {
+	if m.Count == 0 || v < m.Min {
+		m.Min = v
+	}
+	if m.Count == 0 || v > m.Max {
+		m.Max = v
+	}
+	m.Count++
+	m.Sum += v
+}
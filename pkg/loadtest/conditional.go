@@ -0,0 +1,54 @@
+package loadtest
+
+import "net/http"
+
+// validatorCache is the HTTP cache of a single worker: it keeps the ETag and
+// Last-Modified of the GET and HEAD responses and turns the next request to
+// the same URL into a conditional one, as a browser revalidating its cache
+// would. It isn't safe for concurrent use, as a worker sends one request at a
+// time.
+type validatorCache struct {
+	next       http.RoundTripper
+	validators map[string]validators
+}
+
+type validators struct {
+	etag         string
+	lastModified string
+}
+
+func newValidatorCache(next http.RoundTripper) *validatorCache {
+	return &validatorCache{next: next, validators: make(map[string]validators)}
+}
+
+func (c *validatorCache) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return c.next.RoundTrip(req)
+	}
+	key := req.URL.String()
+	// Validators set by the test itself take precedence.
+	if v, ok := c.validators[key]; ok && !isConditional(req) {
+		req = req.Clone(req.Context())
+		if v.etag != "" {
+			req.Header.Set("If-None-Match", v.etag)
+		}
+		if v.lastModified != "" {
+			req.Header.Set("If-Modified-Since", v.lastModified)
+		}
+	}
+	resp, err := c.next.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		return resp, err
+	}
+	v := validators{etag: resp.Header.Get("ETag"), lastModified: resp.Header.Get("Last-Modified")}
+	if v == (validators{}) {
+		delete(c.validators, key)
+	} else {
+		c.validators[key] = v
+	}
+	return resp, nil
+}
+
+func isConditional(req *http.Request) bool {
+	return req.Header.Get("If-None-Match") != "" || req.Header.Get("If-Modified-Since") != ""
+}
@@ -0,0 +1,117 @@
+package loadtest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ConnectionReport summarizes a WebSocketTest or a SocketTest.
+type ConnectionReport struct {
+	TotalTime        time.Duration
+	Connections      int
+	Connected        int
+	ConnectLatency   LatencyStats
+	MessagesSent     int
+	MessagesReceived int
+	RoundTrip        LatencyStats
+	Disconnects      int
+	Timeouts         int
+	Errors           map[string]int
+}
+
+// connectionStats collects the results of the connections of a test.
+type connectionStats struct {
+	mu         sync.Mutex
+	report     *ConnectionReport
+	connect    LatencyHistogram
+	roundTrips LatencyHistogram
+}
+
+func newConnectionStats(connections int) *connectionStats {
+	return &connectionStats{report: &ConnectionReport{Connections: connections, Errors: make(map[string]int)}}
+}
+
+func (s *connectionStats) attempted() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.report.Connections++
+}
+
+func (s *connectionStats) connected(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.report.Connected++
+	s.connect.Record(d)
+}
+
+func (s *connectionStats) sent() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.report.MessagesSent++
+}
+
+func (s *connectionStats) received(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.report.MessagesReceived++
+	s.roundTrips.Record(d)
+}
+
+// failed records the error that ended a connection. A connection closed by
+// the server counts as a disconnect.
+func (s *connectionStats) failed(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case errors.Is(err, io.EOF), errors.Is(err, net.ErrClosed):
+		s.report.Disconnects++
+	case isTimeout(err):
+		s.report.Timeouts++
+	default:
+		s.report.Errors[ClassifyError(err)]++
+	}
+}
+
+func (s *connectionStats) finish(elapsed time.Duration) *ConnectionReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.report.TotalTime = elapsed
+	s.report.ConnectLatency = s.connect.Stats()
+	s.report.RoundTrip = s.roundTrips.Stats()
+	return s.report
+}
+
+// ErrorCount returns the number of connections that ended with an error,
+// timeouts included.
+func (r *ConnectionReport) ErrorCount() int {
+	count := r.Timeouts
+	for _, n := range r.Errors {
+		count += n
+	}
+	return count
+}
+
+// ErrorCategories returns the categories of Errors sorted by name.
+func (r *ConnectionReport) ErrorCategories() []string {
+	categories := make([]string, 0, len(r.Errors))
+	for category := range r.Errors {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+	return categories
+}
+
+// stopped reports whether the test ended. Dials and reads may fail with a
+// timeout at the deadline of ctx right before ctx itself is done.
+func stopped(ctx context.Context) bool {
+	if ctx.Err() != nil {
+		return true
+	}
+	deadline, ok := ctx.Deadline()
+	return ok && !time.Now().Before(deadline)
+}
@@ -0,0 +1,172 @@
+package loadtest
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/quic-go/quic-go"
+)
+
+// normalizeResolve validates the host overrides and keys them by lowercase
+// host:port, the form the transports dial.
+func normalizeResolve(overrides map[string]string) (map[string]string, error) {
+	resolve := make(map[string]string, len(overrides))
+	for hostPort, addr := range overrides {
+		host, port, err := net.SplitHostPort(hostPort)
+		if err != nil || host == "" || port == "" {
+			return nil, fmt.Errorf("resolve inválido %q, use host:porta", hostPort)
+		}
+		ip := net.ParseIP(strings.Trim(addr, "[]"))
+		if ip == nil {
+			return nil, fmt.Errorf("endereço inválido %q para %s", addr, hostPort)
+		}
+		resolve[net.JoinHostPort(strings.ToLower(host), port)] = net.JoinHostPort(ip.String(), port)
+	}
+	return resolve, nil
+}
+
+// IPFamily is the address family of the connections of a run.
+type IPFamily string
+
+const (
+	// IPFamilyAny connects to whichever address the resolver returns first.
+	// It is the default.
+	IPFamilyAny IPFamily = ""
+	IPv4        IPFamily = "ipv4"
+	IPv6        IPFamily = "ipv6"
+)
+
+func ipFamily(ip net.IP) IPFamily {
+	if ip.To4() != nil {
+		return IPv4
+	}
+	return IPv6
+}
+
+// connFamily is the family of the remote address of conn, or IPFamilyAny
+// when it isn't an IP connection.
+func connFamily(conn net.Conn) IPFamily {
+	if addr, ok := conn.RemoteAddr().(*net.TCPAddr); ok {
+		return ipFamily(addr.IP)
+	}
+	return IPFamilyAny
+}
+
+// network narrows network ("tcp", "udp" or "ip") to the family.
+func (f IPFamily) network(network string) string {
+	switch f {
+	case IPv4:
+		return network + "4"
+	case IPv6:
+		return network + "6"
+	}
+	return network
+}
+
+// normalizeLocalAddrs validates the addresses of WithLocalAddr, which must be
+// of family unless it is IPFamilyAny.
+func normalizeLocalAddrs(addrs []string, family IPFamily) ([]string, error) {
+	normalized := make([]string, len(addrs))
+	for i, addr := range addrs {
+		ip := net.ParseIP(strings.Trim(addr, "[]"))
+		if ip == nil {
+			return nil, fmt.Errorf("endereço local inválido %q, informe um IP", addr)
+		}
+		if family != IPFamilyAny && ipFamily(ip) != family {
+			return nil, fmt.Errorf("endereço local %s não é %s", ip, family)
+		}
+		normalized[i] = ip.String()
+	}
+	return normalized, nil
+}
+
+func (r *Runner) newResolver() *net.Resolver {
+	if r.dnsServer == "" {
+		return nil
+	}
+	dialer := &net.Dialer{Timeout: 5 * time.Second}
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, network, r.dnsServer)
+		},
+	}
+}
+
+// overrideAddr returns the address configured with WithResolve for addr, or
+// addr itself. Only the dialed address changes: the Host header and the TLS
+// server name still come from the URL.
+func (r *Runner) overrideAddr(addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	if override, ok := r.resolve[net.JoinHostPort(strings.ToLower(host), port)]; ok {
+		return override
+	}
+	return addr
+}
+
+// localDialers returns a copy of dialer bound to each of the addresses of
+// WithLocalAddr, or dialer itself when there are none.
+func (r *Runner) localDialers(dialer *net.Dialer) []*net.Dialer {
+	if len(r.localAddrs) == 0 {
+		return []*net.Dialer{dialer}
+	}
+	dialers := make([]*net.Dialer, len(r.localAddrs))
+	for i, addr := range r.localAddrs {
+		bound := *dialer
+		bound.LocalAddr = &net.TCPAddr{IP: net.ParseIP(addr)}
+		dialers[i] = &bound
+	}
+	return dialers
+}
+
+func (r *Runner) dialContext(dialer *net.Dialer) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	dialer.Resolver = r.newResolver()
+	dialers := r.localDialers(dialer)
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		var conn net.Conn
+		var err error
+		if r.unixSocket != "" {
+			conn, err = dialer.DialContext(ctx, "unix", r.unixSocket)
+		} else {
+			// Connections rotate across the local addresses, shared by all
+			// the clients of the run.
+			dialer := dialers[(r.dials.Add(1)-1)%uint64(len(dialers))]
+			conn, err = dialer.DialContext(ctx, r.ipFamily.network(network), r.overrideAddr(addr))
+		}
+		if err != nil || r.bandwidth <= 0 {
+			return conn, err
+		}
+		return newThrottledConn(conn, r.bandwidth), nil
+	}
+}
+
+// dialQUIC resolves the address itself because quic-go only accepts a host
+// name when it resolves it with the system resolver, and doesn't choose the
+// family.
+func (r *Runner) dialQUIC(ctx context.Context, addr string, tlsCfg *tls.Config, cfg *quic.Config) (quic.EarlyConnection, error) {
+	addr = r.overrideAddr(addr)
+	if resolver := r.newResolver(); resolver != nil || r.ipFamily != IPFamilyAny {
+		if resolver == nil {
+			resolver = net.DefaultResolver
+		}
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		if net.ParseIP(host) == nil {
+			ips, err := resolver.LookupIP(ctx, r.ipFamily.network("ip"), host)
+			if err != nil {
+				return nil, err
+			}
+			addr = net.JoinHostPort(ips[0].String(), port)
+		}
+	}
+	return quic.DialAddrEarly(ctx, addr, tlsCfg, cfg)
+}
@@ -0,0 +1,129 @@
+package loadtest
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/andybalholm/brotli"
+)
+
+// Encodings accepted by WithAcceptEncoding. Responses with any of them but
+// identity are decoded by the runner.
+var supportedEncodings = []string{"gzip", "deflate", "br", "identity"}
+
+// CompressionStats compares the compressed responses as they were
+// transferred with their decoded size, for runs with WithAcceptEncoding.
+type CompressionStats struct {
+	AcceptEncoding string
+	// Encodings counts the responses by Content-Encoding; uncompressed ones
+	// are counted as identity.
+	Encodings map[string]int
+	// Compressed is the number of compressed responses, which took WireBytes
+	// to transfer and Bytes once decoded.
+	Compressed    int
+	WireBytes     int64
+	Bytes         int64
+	DecodeTime    time.Duration
+	MaxDecodeTime time.Duration
+}
+
+// Ratio is how many times smaller the compressed responses were on the wire.
+func (c *CompressionStats) Ratio() float64 {
+	if c.WireBytes == 0 {
+		return 0
+	}
+	return float64(c.Bytes) / float64(c.WireBytes)
+}
+
+func (c *CompressionStats) add(result Result) {
+	if result.Encoding == "" {
+		c.Encodings["identity"]++
+		return
+	}
+	c.Encodings[result.Encoding]++
+	c.Compressed++
+	c.WireBytes += result.WireBytes
+	c.Bytes += result.Bytes
+	c.DecodeTime += result.DecodeTime
+	c.MaxDecodeTime = max(c.MaxDecodeTime, result.DecodeTime)
+}
+
+func validateEncodings(encodings []string) error {
+	for _, encoding := range encodings {
+		supported := false
+		for _, name := range supportedEncodings {
+			supported = supported || encoding == name
+		}
+		if !supported {
+			return fmt.Errorf("codificação %q não suportada (use %s)", encoding, strings.Join(supportedEncodings, ", "))
+		}
+	}
+	return nil
+}
+
+// decodingReader decodes a body compressed with Content-Encoding, counting
+// the bytes received and the time spent decoding them, apart from the time
+// spent waiting for them.
+type decodingReader struct {
+	encoding string
+	source   *timedReader
+	decoder  io.Reader
+	elapsed  time.Duration
+}
+
+// newDecodingReader returns nil when resp isn't compressed with an encoding
+// the runner decodes.
+func newDecodingReader(resp *http.Response) *decodingReader {
+	encoding := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding")))
+	switch encoding {
+	case "gzip", "x-gzip", "deflate", "br":
+		return &decodingReader{encoding: encoding, source: &timedReader{r: resp.Body}}
+	default:
+		return nil
+	}
+}
+
+func (d *decodingReader) Read(p []byte) (int, error) {
+	start := time.Now()
+	defer func() { d.elapsed += time.Since(start) }()
+	// Creating the decoder already reads the gzip and zlib headers.
+	if d.decoder == nil {
+		var err error
+		switch d.encoding {
+		case "gzip", "x-gzip":
+			d.decoder, err = gzip.NewReader(d.source)
+		case "deflate":
+			d.decoder, err = zlib.NewReader(d.source)
+		case "br":
+			d.decoder = brotli.NewReader(d.source)
+		}
+		if err != nil {
+			return 0, fmt.Errorf("falha ao decodificar %s: %w", d.encoding, err)
+		}
+	}
+	return d.decoder.Read(p)
+}
+
+func (d *decodingReader) decodeTime() time.Duration {
+	return max(d.elapsed-d.source.wait, 0)
+}
+
+// timedReader counts the bytes read from r and the time spent reading them.
+type timedReader struct {
+	r    io.Reader
+	n    int64
+	wait time.Duration
+}
+
+func (t *timedReader) Read(p []byte) (int, error) {
+	start := time.Now()
+	n, err := t.r.Read(p)
+	t.wait += time.Since(start)
+	t.n += int64(n)
+	return n, err
+}
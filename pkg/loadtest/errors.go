@@ -0,0 +1,116 @@
+package loadtest
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"regexp"
+	"strings"
+	"syscall"
+)
+
+const (
+	ErrorDNS               = "dns"
+	ErrorPortExhaustion    = "port_exhaustion"
+	ErrorConnectionRefused = "connection_refused"
+	ErrorConnectionReset   = "connection_reset"
+	ErrorTLS               = "tls"
+	ErrorTimeout           = "timeout"
+	ErrorBodyRead          = "body_read"
+	ErrorOther             = "other"
+)
+
+// OtherErrors counts, in Report.Errors, the messages past the first
+// maxErrorMessages distinct ones.
+const OtherErrors = "outros erros"
+
+const maxErrorMessages = 100
+
+var (
+	// localAddrPattern matches the local address of dial and read errors, as
+	// in "read tcp 10.0.0.1:54321->10.0.0.2:80", whose ephemeral port
+	// changes with every connection.
+	localAddrPattern = regexp.MustCompile(`[^\s"]+:\d+->`)
+	// quotedURLPattern matches the URL quoted by *url.Error, keeping its
+	// scheme and host: templated paths and queries differ per request.
+	quotedURLPattern = regexp.MustCompile(`"([a-z0-9+.-]+://[^/"?#]*)[^"]*"`)
+)
+
+// ErrorMessage returns the message of err without the parts that change from
+// one request to the next, so that the same failure is counted once.
+func ErrorMessage(err error) string {
+	message := localAddrPattern.ReplaceAllString(err.Error(), "")
+	return quotedURLPattern.ReplaceAllString(message, `"$1"`)
+}
+
+type bodyReadError struct {
+	err error
+}
+
+func (e *bodyReadError) Error() string {
+	return "erro ao ler corpo da resposta: " + e.err.Error()
+}
+
+func (e *bodyReadError) Unwrap() error {
+	return e.err
+}
+
+// RemoteError is an error reported by another process, such as a remote
+// agent, that keeps the category it was classified with there.
+type RemoteError struct {
+	Category string
+	Message  string
+}
+
+func (e *RemoteError) Error() string {
+	return e.Message
+}
+
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var remoteErr *RemoteError
+	if errors.As(err, &remoteErr) {
+		return remoteErr.Category == ErrorTimeout
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+func ClassifyError(err error) string {
+	var remoteErr *RemoteError
+	var bodyErr *bodyReadError
+	var dnsErr *net.DNSError
+	var recordErr tls.RecordHeaderError
+	var certErr *tls.CertificateVerificationError
+	var alertErr tls.AlertError
+	var unknownAuthErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidErr x509.CertificateInvalidError
+
+	switch {
+	case errors.As(err, &remoteErr):
+		return remoteErr.Category
+	case isTimeout(err):
+		return ErrorTimeout
+	case errors.As(err, &bodyErr):
+		return ErrorBodyRead
+	case errors.As(err, &dnsErr):
+		return ErrorDNS
+	case isPortExhaustion(err):
+		return ErrorPortExhaustion
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return ErrorConnectionRefused
+	case errors.Is(err, syscall.ECONNRESET):
+		return ErrorConnectionReset
+	case errors.As(err, &recordErr), errors.As(err, &certErr), errors.As(err, &alertErr),
+		errors.As(err, &unknownAuthErr), errors.As(err, &hostnameErr), errors.As(err, &invalidErr),
+		strings.Contains(err.Error(), "tls:"):
+		return ErrorTLS
+	default:
+		return ErrorOther
+	}
+}
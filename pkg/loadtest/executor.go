@@ -0,0 +1,65 @@
+package loadtest
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Executor is the execution model of a run.
+type Executor string
+
+const (
+	// ExecutorClosed runs a fixed pool of workers, each starting its next
+	// iteration once the previous one finished. A slow target lowers the
+	// throughput instead of piling up requests.
+	ExecutorClosed Executor = "closed"
+	// ExecutorOpen starts iterations at the configured rate regardless of how
+	// long the previous ones take, adding VUs as needed up to the maximum.
+	// Arrivals that find every VU busy are dropped and counted.
+	ExecutorOpen Executor = "open"
+)
+
+// runOpen hands every arrival scheduled by dispatch to an idle VU, spawning a
+// new one while under maxVUs. The schedule is never delayed by busy VUs.
+func (r *Runner) runOpen(ctx context.Context, limit int, results chan<- Result) {
+	arrivals := make(chan time.Time)
+	go dispatch(ctx, arrivals, limit, r.schedule())
+
+	jobs := make(chan time.Time)
+	var wg sync.WaitGroup
+	spawn := func() {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.worker(ctx, jobs, results)
+		}()
+	}
+	vus := r.concurrency
+	for i := 0; i < vus; i++ {
+		spawn()
+	}
+	r.vus.Store(int64(vus))
+	r.dropped.Store(0)
+
+	for intended := range arrivals {
+		select {
+		case jobs <- intended:
+			continue
+		default:
+		}
+		if vus >= r.maxVUs {
+			r.dropped.Add(1)
+			continue
+		}
+		vus++
+		r.vus.Store(int64(vus))
+		spawn()
+		select {
+		case <-ctx.Done():
+		case jobs <- intended:
+		}
+	}
+	close(jobs)
+	wg.Wait()
+}
@@ -0,0 +1,85 @@
+package loadtest
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"strings"
+	"sync/atomic"
+)
+
+type FeedMode string
+
+const (
+	FeedRoundRobin FeedMode = "round-robin"
+	FeedRandom     FeedMode = "random"
+)
+
+// Feeder hands a data row to every iteration. The row columns are available
+// to URL, header and body templates as {{.column}}.
+type Feeder struct {
+	rows []map[string]string
+	mode FeedMode
+	next atomic.Uint64
+}
+
+func NewFeeder(rows []map[string]string, mode FeedMode) (*Feeder, error) {
+	if len(rows) == 0 {
+		return nil, errors.New("feeder sem linhas de dados")
+	}
+	switch mode {
+	case "":
+		mode = FeedRoundRobin
+	case FeedRoundRobin, FeedRandom:
+	default:
+		return nil, fmt.Errorf("modo de leitura %q inválido (use %s ou %s)", mode, FeedRoundRobin, FeedRandom)
+	}
+	return &Feeder{rows: rows, mode: mode}, nil
+}
+
+// ReadCSVFeeder builds a Feeder from CSV data whose first record names the
+// columns.
+func ReadCSVFeeder(r io.Reader, mode FeedMode) (*Feeder, error) {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, errors.New("CSV vazio")
+	}
+
+	header := records[0]
+	for i, column := range header {
+		header[i] = strings.TrimSpace(column)
+		if header[i] == "" {
+			return nil, fmt.Errorf("coluna %d do CSV sem nome", i+1)
+		}
+	}
+	rows := make([]map[string]string, 0, len(records)-1)
+	for _, record := range records[1:] {
+		row := make(map[string]string, len(header))
+		for i, column := range header {
+			row[column] = record[i]
+		}
+		rows = append(rows, row)
+	}
+	return NewFeeder(rows, mode)
+}
+
+func (f *Feeder) Len() int {
+	return len(f.rows)
+}
+
+func (f *Feeder) Mode() FeedMode {
+	return f.mode
+}
+
+// Next returns the next row. It is safe for concurrent use.
+func (f *Feeder) Next() map[string]string {
+	if f.mode == FeedRandom {
+		return f.rows[rand.Intn(len(f.rows))]
+	}
+	return f.rows[(f.next.Add(1)-1)%uint64(len(f.rows))]
+}
@@ -0,0 +1,37 @@
+package loadtest
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// grpcCodes are the names of the gRPC status codes, indexed by code.
+var grpcCodes = []string{
+	"OK", "CANCELLED", "UNKNOWN", "INVALID_ARGUMENT", "DEADLINE_EXCEEDED", "NOT_FOUND",
+	"ALREADY_EXISTS", "PERMISSION_DENIED", "RESOURCE_EXHAUSTED", "FAILED_PRECONDITION",
+	"ABORTED", "OUT_OF_RANGE", "UNIMPLEMENTED", "INTERNAL", "UNAVAILABLE", "DATA_LOSS",
+	"UNAUTHENTICATED",
+}
+
+func isGRPCResponse(resp *http.Response) bool {
+	return strings.HasPrefix(resp.Header.Get("Content-Type"), "application/grpc")
+}
+
+// grpcStatus returns the name of the status of a gRPC response. It must be
+// called after the body was read, since the status is usually a trailer;
+// responses without a body send it as a header instead.
+func grpcStatus(resp *http.Response) string {
+	value := resp.Trailer.Get("Grpc-Status")
+	if value == "" {
+		value = resp.Header.Get("Grpc-Status")
+	}
+	code, err := strconv.Atoi(value)
+	if err != nil {
+		return "UNKNOWN"
+	}
+	if code >= 0 && code < len(grpcCodes) {
+		return grpcCodes[code]
+	}
+	return value
+}
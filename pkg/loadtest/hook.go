@@ -0,0 +1,27 @@
+package loadtest
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+)
+
+// RequestHook is called with every request right before it is sent, once
+// headers, authentication and trace context are set, so that it can sign the
+// request or add headers computed per request, such as timestamps. body is
+// the body of req, which the hook may replace with SetRequestBody. An error
+// fails the request without sending it. It is called from every worker at
+// once, so it must be safe for concurrent use.
+type RequestHook func(req *http.Request, body []byte) error
+
+// SetRequestBody replaces the body of req, e.g. from a RequestHook.
+func SetRequestBody(req *http.Request, body []byte) {
+	req.ContentLength = int64(len(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	req.Body, _ = req.GetBody()
+	if len(body) == 0 {
+		req.Body, req.GetBody = http.NoBody, nil
+	}
+}
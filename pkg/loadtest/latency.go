@@ -0,0 +1,155 @@
+package loadtest
+
+import (
+	"math"
+	"math/bits"
+	"time"
+)
+
+// subBucketBits sets the precision of LatencyHistogram: every power of two is
+// split in 2^subBucketBits linear sub-buckets, so quantiles are off by less
+// than 1% of the value.
+const subBucketBits = 7
+
+// LatencyHistogram accumulates durations in memory that doesn't grow with the
+// number of samples, in the style of HdrHistogram: min, max, mean and standard
+// deviation are exact, and quantiles come from log-linear buckets with a
+// relative error below 1%, including the extreme ones such as p99.99.
+// The zero value is an empty histogram. It is not safe for concurrent use.
+type LatencyHistogram struct {
+	count  int64
+	min    time.Duration
+	max    time.Duration
+	mean   float64
+	m2     float64
+	offset int
+	counts []int64
+}
+
+// NewLatencyHistogram returns a histogram holding durations.
+func NewLatencyHistogram(durations ...time.Duration) *LatencyHistogram {
+	h := &LatencyHistogram{}
+	for _, d := range durations {
+		h.Record(d)
+	}
+	return h
+}
+
+// Record adds a duration to the histogram. Negative durations count as 0.
+func (h *LatencyHistogram) Record(d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+	h.count++
+	if h.count == 1 || d < h.min {
+		h.min = d
+	}
+	h.max = max(h.max, d)
+	delta := float64(d) - h.mean
+	h.mean += delta / float64(h.count)
+	h.m2 += delta * (float64(d) - h.mean)
+	h.add(bucketIndex(int64(d)), 1)
+}
+
+// add counts n samples in bucket i. counts only spans the buckets between the
+// fastest and slowest values seen, which keeps the per-second histograms of
+// the timeline small.
+func (h *LatencyHistogram) add(i int, n int64) {
+	switch {
+	case h.counts == nil:
+		h.offset = i
+		h.counts = make([]int64, 1)
+	case i < h.offset:
+		grown := make([]int64, len(h.counts)+h.offset-i)
+		copy(grown[h.offset-i:], h.counts)
+		h.counts, h.offset = grown, i
+	case i-h.offset >= len(h.counts):
+		h.counts = append(h.counts, make([]int64, i-h.offset-len(h.counts)+1)...)
+	}
+	h.counts[i-h.offset] += n
+}
+
+// Merge adds every duration recorded in other to h, e.g. to combine the
+// histograms of several runs or agents.
+func (h *LatencyHistogram) Merge(other *LatencyHistogram) {
+	if other == nil || other.count == 0 {
+		return
+	}
+	if h.count == 0 || other.min < h.min {
+		h.min = other.min
+	}
+	h.max = max(h.max, other.max)
+	count := h.count + other.count
+	delta := other.mean - h.mean
+	h.m2 += other.m2 + delta*delta*float64(h.count)*float64(other.count)/float64(count)
+	h.mean += delta * float64(other.count) / float64(count)
+	h.count = count
+	for i, n := range other.counts {
+		if n > 0 {
+			h.add(i+other.offset, n)
+		}
+	}
+}
+
+// Count returns the number of recorded durations.
+func (h *LatencyHistogram) Count() int64 {
+	return h.count
+}
+
+// Quantile returns the duration below which a fraction q, between 0 and 1,
+// of the recorded durations fall: Quantile(0.999) is the p99.9.
+func (h *LatencyHistogram) Quantile(q float64) time.Duration {
+	if h == nil || h.count == 0 {
+		return 0
+	}
+	// Nearest rank, as ComputeLatencyStats does on the sorted durations.
+	rank := int64(math.Ceil(q * float64(h.count)))
+	var seen int64
+	for i, n := range h.counts {
+		if seen += n; seen >= rank {
+			return min(max(time.Duration(bucketHighest(i+h.offset)), h.min), h.max)
+		}
+	}
+	return h.max
+}
+
+// Stats summarizes the histogram as ComputeLatencyStats does.
+func (h *LatencyHistogram) Stats() LatencyStats {
+	if h == nil || h.count == 0 {
+		return LatencyStats{}
+	}
+	return LatencyStats{
+		Min:    h.min,
+		Max:    h.max,
+		Mean:   time.Duration(h.mean),
+		StdDev: time.Duration(math.Sqrt(h.m2 / float64(h.count))),
+		P50:    h.Quantile(0.50),
+		P90:    h.Quantile(0.90),
+		P95:    h.Quantile(0.95),
+		P99:    h.Quantile(0.99),
+		P999:   h.Quantile(0.999),
+	}
+}
+
+// bucketIndex maps v to its bucket: values below 2^(subBucketBits+1) have a
+// bucket each, larger ones share a bucket with the values that only differ
+// past their subBucketBits+1 most significant bits.
+func bucketIndex(v int64) int {
+	const linear = 2 << subBucketBits
+	if v < linear {
+		return int(v)
+	}
+	shift := bits.Len64(uint64(v)) - subBucketBits - 1
+	return (shift+1)<<subBucketBits + int(v>>shift) - 1<<subBucketBits
+}
+
+// bucketHighest returns the largest value that falls in bucket i.
+func bucketHighest(i int) int64 {
+	const linear = 2 << subBucketBits
+	if i < linear {
+		return int64(i)
+	}
+	shift := i>>subBucketBits - 1
+	sub := int64(i&(1<<subBucketBits-1) + 1<<subBucketBits)
+	return (sub+1)<<shift - 1
+}
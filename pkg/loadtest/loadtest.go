@@ -0,0 +1,569 @@
+// Package loadtest is the HTTP load testing engine behind the stress-test CLI.
+// It can be embedded by other Go programs through Runner:
+//
+//	runner, err := loadtest.New(
+//		loadtest.WithURL("http://localhost:8080"),
+//		loadtest.WithRequests(1000),
+//		loadtest.WithConcurrency(10),
+//	)
+//	if err != nil {
+//		return err
+//	}
+//	report, err := runner.Run(ctx)
+package loadtest
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// resultsBuffer is how many results, on top of one per worker, can wait for
+// the collector before the workers block.
+const resultsBuffer = 1024
+
+type Runner struct {
+	targets     []Target
+	steps       []Step
+	scenario    *Scenario
+	source      <-chan Step
+	feeder      *Feeder
+	method      string
+	body        []byte
+	stream      BodyStream
+	contentType string
+	headers     http.Header
+	requests    int
+	concurrency int
+	duration    time.Duration
+	rate        float64
+	stages      []Stage
+	rampUp      time.Duration
+	executor    Executor
+	maxVUs      int
+	warmup      time.Duration
+	warmupReqs  int
+	thinkTime   time.Duration
+	thinkJitter time.Duration
+	retry       *RetryPolicy
+	timeout     time.Duration
+	buckets     []time.Duration
+	resolution  time.Duration
+	protocol    Protocol
+	tlsConfig   *tls.Config
+	assertions  []Assertion
+	check       ResponseCheck
+	hook        RequestHook
+	encodings   []string
+	bodyMode    BodyMode
+	saver       *bodySaver
+	samples     int
+	success     StatusSet
+	thresholds  []Threshold
+	abort       *AbortRule
+	metadata    Metadata
+	limits      ConnectionLimits
+	perClient   int
+
+	disableKeepAlive bool
+	portExhaustion   PortExhaustion
+	cookies          bool
+	conditional      bool
+	maxRedirects     int
+	proxy            *url.URL
+	proxyFromEnv     bool
+	resolve          map[string]string
+	dnsServer        string
+	unixSocket       string
+	localAddrs       []string
+	ipFamily         IPFamily
+	bandwidth        float64
+	traceContext     bool
+	vuHeader         string
+	oauth2           *OAuth2ClientCredentials
+	onProgress       func(completed, total int)
+	onResult         []func(Result)
+
+	client   *http.Client
+	clients  []*http.Client
+	tokens   *tokenSource
+	workers  atomic.Int64
+	inFlight atomic.Int64
+	waitConn atomic.Int64
+	queue    atomic.Pointer[chan time.Time]
+	vus      atomic.Int64
+	dropped  atomic.Int64
+	sampled  atomic.Int64
+	dials    atomic.Uint64
+	ports    portState
+	reuse    atomic.Bool
+}
+
+func New(opts ...Option) (*Runner, error) {
+	r := &Runner{
+		method:    http.MethodGet,
+		headers:   make(http.Header),
+		timeout:   30 * time.Second,
+		buckets:   DefaultLatencyBuckets,
+		protocol:  ProtocolHTTP1,
+		executor:  ExecutorClosed,
+		tlsConfig: &tls.Config{},
+		success:   DefaultSuccessCodes,
+		bodyMode:  BodyDiscard,
+
+		portExhaustion: PortExhaustionReport,
+
+		maxRedirects: DefaultMaxRedirects,
+		resolution:   time.Second,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	if r.source != nil {
+		if len(r.targets) > 0 || r.scenario != nil {
+			return nil, errors.New("use alvos, um cenário ou uma fonte de requests, não mais de um")
+		}
+		// Requests from a source arrive ready to be sent, without templates.
+		for key, values := range r.headers {
+			for _, value := range values {
+				if strings.Contains(value, "{{") {
+					return nil, fmt.Errorf("header %s: templates não são suportados com uma fonte de requests", key)
+				}
+			}
+		}
+	} else if r.scenario != nil {
+		if len(r.targets) > 0 {
+			return nil, errors.New("use alvos ou um cenário, não ambos")
+		}
+		if err := r.scenario.normalize(r.headers); err != nil {
+			return nil, err
+		}
+	} else if len(r.targets) == 0 {
+		return nil, errors.New("ao menos um alvo é obrigatório")
+	}
+	names := make(map[string]bool, len(r.targets))
+	for _, target := range r.targets {
+		if target.URL == "" {
+			return nil, errors.New("alvo sem URL")
+		}
+		if target.Name != "" {
+			if names[target.Name] {
+				return nil, fmt.Errorf("alvo %q duplicado", target.Name)
+			}
+			names[target.Name] = true
+		}
+		if target.Weight <= 0 {
+			return nil, fmt.Errorf("peso do alvo %s deve ser maior que 0", target.URL)
+		}
+	}
+	steps, err := r.targetSteps()
+	if err != nil {
+		return nil, err
+	}
+	r.steps = steps
+	if len(r.stages) > 0 {
+		if err := validateStages(r.stages); err != nil {
+			return nil, err
+		}
+		if r.rate > 0 || r.duration > 0 {
+			return nil, errors.New("os estágios definem a taxa e a duração do teste; não informe taxa nem duração")
+		}
+		if r.warmup > 0 || r.warmupReqs > 0 {
+			return nil, errors.New("warm-up não é suportado com estágios")
+		}
+		if r.source != nil {
+			return nil, errors.New("estágios não são suportados com uma fonte de requests")
+		}
+		r.duration = stagesDuration(r.stages)
+	}
+	if r.requests < 0 || r.duration < 0 {
+		return nil, errors.New("requests e duração não podem ser negativos")
+	}
+	if r.requests == 0 && r.duration == 0 && r.source == nil {
+		return nil, errors.New("informe requests maior que 0 e/ou uma duração")
+	}
+	if r.concurrency <= 0 {
+		return nil, errors.New("concorrência deve ser maior que 0")
+	}
+	if r.rate < 0 {
+		return nil, errors.New("taxa não pode ser negativa")
+	}
+	if r.rampUp < 0 {
+		return nil, errors.New("ramp-up não pode ser negativo")
+	}
+	switch r.executor {
+	case ExecutorClosed:
+	case ExecutorOpen:
+		if r.rate <= 0 && len(r.stages) == 0 {
+			return nil, errors.New("o executor open exige uma taxa maior que 0 ou estágios")
+		}
+		if r.rampUp > 0 {
+			return nil, errors.New("ramp-up não é suportado com o executor open")
+		}
+		if r.source != nil {
+			return nil, errors.New("o executor open não é suportado com uma fonte de requests")
+		}
+	default:
+		return nil, fmt.Errorf("executor %q não suportado (use closed ou open)", r.executor)
+	}
+	if r.maxVUs < 0 {
+		return nil, errors.New("número máximo de VUs não pode ser negativo")
+	}
+	if r.thinkTime < 0 || r.thinkJitter < 0 {
+		return nil, errors.New("think time e jitter não podem ser negativos")
+	}
+	if r.retry != nil && r.retry.Backoff < 0 {
+		return nil, errors.New("backoff de retentativa não pode ser negativo")
+	}
+	if r.warmup < 0 || r.warmupReqs < 0 {
+		return nil, errors.New("warm-up não pode ser negativo")
+	}
+	if r.warmup > 0 && r.warmupReqs > 0 {
+		return nil, errors.New("informe o warm-up por duração ou por número de requests, não ambos")
+	}
+	if r.disableKeepAlive && (r.protocol == ProtocolHTTP2PriorKnowledge || r.protocol == ProtocolHTTP3) {
+		return nil, fmt.Errorf("desabilitar keep-alive não é suportado com o protocolo %s", r.protocol)
+	}
+	switch r.portExhaustion {
+	case PortExhaustionReport, PortExhaustionThrottle, PortExhaustionReuse:
+	default:
+		return nil, fmt.Errorf("reação ao esgotamento de portas desconhecida: %q (use report, throttle ou reuse)", r.portExhaustion)
+	}
+	if r.timeout <= 0 {
+		return nil, errors.New("timeout deve ser maior que 0")
+	}
+	if r.bandwidth < 0 {
+		return nil, errors.New("banda máxima não pode ser negativa")
+	}
+	if r.bandwidth > 0 && r.protocol == ProtocolHTTP3 {
+		return nil, errors.New("limite de banda não é suportado com HTTP/3")
+	}
+	if r.proxy != nil || r.proxyFromEnv {
+		if r.protocol == ProtocolHTTP2PriorKnowledge || r.protocol == ProtocolHTTP3 {
+			return nil, fmt.Errorf("proxy não é suportado com o protocolo %s", r.protocol)
+		}
+	}
+	if r.unixSocket != "" {
+		if r.protocol == ProtocolHTTP3 {
+			return nil, errors.New("unix socket não é suportado com HTTP/3")
+		}
+		if r.proxy != nil || r.proxyFromEnv {
+			return nil, errors.New("use unix socket ou proxy, não ambos")
+		}
+	}
+	switch r.ipFamily {
+	case IPFamilyAny, IPv4, IPv6:
+	default:
+		return nil, fmt.Errorf("família de endereço desconhecida: %q (use ipv4 ou ipv6)", r.ipFamily)
+	}
+	if r.ipFamily != IPFamilyAny && r.unixSocket != "" {
+		return nil, fmt.Errorf("use unix socket ou %s, não ambos", r.ipFamily)
+	}
+	if len(r.localAddrs) > 0 {
+		if r.protocol == ProtocolHTTP3 {
+			return nil, errors.New("endereço local não é suportado com HTTP/3")
+		}
+		if r.unixSocket != "" {
+			return nil, errors.New("use unix socket ou endereço local, não ambos")
+		}
+		localAddrs, err := normalizeLocalAddrs(r.localAddrs, r.ipFamily)
+		if err != nil {
+			return nil, err
+		}
+		r.localAddrs = localAddrs
+	}
+	if r.proxy != nil {
+		switch r.proxy.Scheme {
+		case "http", "https", "socks5":
+		default:
+			return nil, fmt.Errorf("esquema de proxy %q não suportado (use http, https ou socks5)", r.proxy.Scheme)
+		}
+	}
+	resolve, err := normalizeResolve(r.resolve)
+	if err != nil {
+		return nil, err
+	}
+	r.resolve = resolve
+	if r.dnsServer != "" {
+		if _, _, err := net.SplitHostPort(r.dnsServer); err != nil {
+			return nil, fmt.Errorf("servidor DNS inválido %q, use host:porta", r.dnsServer)
+		}
+	}
+	if r.maxRedirects < 0 {
+		return nil, errors.New("número máximo de redirecionamentos não pode ser negativo")
+	}
+	if r.oauth2 != nil && (r.oauth2.TokenURL == "" || r.oauth2.ClientID == "") {
+		return nil, errors.New("OAuth2 exige URL do token e client id")
+	}
+	if r.resolution <= 0 {
+		return nil, errors.New("intervalo da linha do tempo deve ser maior que 0")
+	}
+	if r.limits.MaxIdleConns < 0 || r.limits.MaxIdleConnsPerHost < 0 || r.limits.MaxConnsPerHost < 0 || r.perClient < 0 {
+		return nil, errors.New("limites de conexões e workers por cliente não podem ser negativos")
+	}
+	if r.abort != nil && (r.abort.Rate <= 0 || r.abort.Rate > 100 || r.abort.Window <= 0) {
+		return nil, fmt.Errorf("regra de aborto %v inválida: a taxa deve estar entre 0 e 100%% e a janela ser maior que 0", r.abort)
+	}
+	if err := validateEncodings(r.encodings); err != nil {
+		return nil, err
+	}
+	if r.bodyMode != BodyDiscard && r.bodyMode != BodyRead {
+		return nil, fmt.Errorf("modo de leitura do corpo %q não suportado (use discard ou read)", r.bodyMode)
+	}
+	if r.samples < 0 {
+		return nil, errors.New("número de amostras de falhas não pode ser negativo")
+	}
+	if r.saver != nil {
+		if r.saver.rate < 0 || r.saver.rate > 1 {
+			return nil, errors.New("a fração de respostas salvas deve estar entre 0 e 1")
+		}
+		if err := os.MkdirAll(r.saver.dir, 0o755); err != nil {
+			return nil, fmt.Errorf("falha ao criar o diretório das respostas salvas: %w", err)
+		}
+	}
+	buckets, err := normalizeBuckets(r.buckets)
+	if err != nil {
+		return nil, fmt.Errorf("buckets de latência inválidos: %w", err)
+	}
+	r.buckets = buckets
+
+	if r.requests > 0 && r.concurrency > r.requests {
+		r.concurrency = r.requests
+	}
+	if r.maxVUs < r.concurrency {
+		r.maxVUs = r.concurrency
+	}
+
+	clients, workers := 1, r.maxVUs
+	if r.perClient > 0 && r.perClient < r.maxVUs {
+		clients, workers = (r.maxVUs+r.perClient-1)/r.perClient, r.perClient
+	}
+	for i := 0; i < clients; i++ {
+		transport, err := r.newTransport(workers)
+		if err != nil {
+			return nil, err
+		}
+		r.clients = append(r.clients, &http.Client{
+			Transport:     transport,
+			Timeout:       r.timeout,
+			CheckRedirect: r.checkRedirect,
+		})
+	}
+	r.client = r.clients[0]
+	if r.oauth2 != nil {
+		r.tokens = &tokenSource{config: *r.oauth2, client: r.client}
+	}
+	return r, nil
+}
+
+// Concurrency returns the effective number of workers, which is capped at the
+// request count. With the open executor it is the number of VUs started up
+// front.
+func (r *Runner) Concurrency() int {
+	return r.concurrency
+}
+
+// InFlight returns the number of requests currently awaiting a response. It is
+// safe to call concurrently with Run.
+func (r *Runner) InFlight() int {
+	return int(r.inFlight.Load())
+}
+
+// Gauges is a snapshot of the load generator during a run, to tell whether
+// the configured concurrency is actually achieved.
+type Gauges struct {
+	// Workers is the number of workers, or VUs, started so far.
+	Workers int
+	// InFlight is the number of requests awaiting a response, including the
+	// ones in WaitingConn.
+	InFlight int
+	// WaitingConn is the number of requests still waiting for a connection,
+	// either being dialed or freed by another request.
+	WaitingConn int
+	// Queued is the number of iterations scheduled but not picked by a
+	// worker yet. Without a rate the queue is always kept full.
+	Queued int
+}
+
+// Gauges returns the current gauges. It is safe to call concurrently with
+// Run.
+func (r *Runner) Gauges() Gauges {
+	gauges := Gauges{
+		Workers:     int(r.workers.Load()),
+		InFlight:    int(r.inFlight.Load()),
+		WaitingConn: int(r.waitConn.Load()),
+	}
+	if queue := r.queue.Load(); queue != nil {
+		gauges.Queued = len(*queue)
+	}
+	return gauges
+}
+
+// Budget returns how many iterations have to be executed for the run,
+// including the warm-up ones, or 0 when the run is bounded only by time.
+func (r *Runner) Budget() int {
+	// Warm-up results are discarded, so they are issued on top of the measured
+	// requests. A time-based warm-up can't be sized up front and leaves the
+	// run unbounded until the collector cancels.
+	if r.requests == 0 || r.warmup > 0 {
+		return 0
+	}
+	return r.requests + r.warmupReqs
+}
+
+func (r *Runner) Run(parent context.Context) (*Report, error) {
+	if r.tokens != nil {
+		if _, err := r.tokens.get(parent); err != nil {
+			return nil, err
+		}
+	}
+
+	return r.RunFrom(parent, func(ctx context.Context, results chan<- Result) error {
+		limit := r.Budget()
+		r.workers.Store(0)
+		r.ports = portState{}
+		r.reuse.Store(false)
+		if r.executor == ExecutorOpen {
+			r.runOpen(ctx, limit, results)
+			return nil
+		}
+		jobs := make(chan time.Time, r.concurrency)
+		r.queue.Store(&jobs)
+		defer r.queue.Store(nil)
+
+		var wg sync.WaitGroup
+		r.startWorkers(ctx, &wg, func() {
+			go func() {
+				defer wg.Done()
+				r.worker(ctx, jobs, results)
+			}()
+		})
+		go dispatch(ctx, jobs, limit, r.schedule())
+		wg.Wait()
+		return nil
+	})
+}
+
+// RunFrom collects the results sent by produce instead of running the
+// workers in-process, e.g. to aggregate the results of remote agents. Warm-up,
+// duration, request count, handlers and thresholds are applied as in Run.
+// produce must return once ctx is cancelled; a non-nil error aborts the run.
+func (r *Runner) RunFrom(parent context.Context, produce func(ctx context.Context, results chan<- Result) error) (*Report, error) {
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+
+	// The collector aggregates results as they arrive, so the channel only
+	// absorbs bursts and memory doesn't grow with the number of requests.
+	results := make(chan Result, resultsBuffer+r.maxVUs)
+	var produceErr error
+	monitor := startMonitor()
+	startTime := time.Now()
+	go func() {
+		defer close(results)
+		if err := produce(ctx, results); err != nil && ctx.Err() == nil {
+			produceErr = err
+			cancel()
+		}
+	}()
+
+	// The duration is measured from the end of the warm-up, which for a
+	// request-count warm-up is only known once the last warm-up result arrives.
+	var timer *time.Timer
+	defer func() {
+		if timer != nil {
+			timer.Stop()
+		}
+	}()
+
+	c := newCollector(r, startTime.Add(r.warmup))
+	if r.duration > 0 && r.warmupReqs == 0 {
+		timer = time.AfterFunc(r.warmup+r.duration, cancel)
+	}
+	warmupIterations := 0
+	for result := range results {
+		if r.warmup > 0 && result.Start.Before(c.start) {
+			c.report.WarmupRequests++
+			continue
+		}
+		if warmupIterations < r.warmupReqs {
+			c.report.WarmupRequests++
+			if result.LastStep {
+				warmupIterations++
+			}
+			if warmupIterations == r.warmupReqs {
+				c.start = time.Now()
+				if r.duration > 0 {
+					timer = time.AfterFunc(r.duration, cancel)
+				}
+			}
+			continue
+		}
+		if r.requests > 0 && c.report.Iterations == r.requests {
+			continue
+		}
+
+		c.add(result)
+		if r.abort != nil && c.report.Aborted == "" {
+			if reason, ok := r.abort.check(c.report, len(c.report.Timeline)-1); ok {
+				c.report.Aborted = reason
+				cancel()
+			}
+		}
+		for _, fn := range r.onResult {
+			fn(result)
+		}
+		if !result.LastStep {
+			continue
+		}
+
+		completed := c.report.Iterations
+		if r.onProgress != nil {
+			r.onProgress(completed, r.requests)
+		}
+		if completed == r.requests {
+			cancel()
+		}
+	}
+
+	generator := monitor.stop()
+	if produceErr != nil {
+		return nil, produceErr
+	}
+
+	elapsed := time.Since(c.start)
+	if elapsed < 0 {
+		elapsed = 0
+	}
+	report := c.finish(elapsed)
+	// Workers that never completed a request are the most stuck of all.
+	for i := len(report.Workers); i < int(r.workers.Load()); i++ {
+		report.Workers = append(report.Workers, WorkerStats{Worker: i})
+	}
+	report.Interrupted = parent.Err() != nil
+	if r.executor == ExecutorOpen {
+		report.MaxVUs = r.maxVUs
+		report.VUs = int(r.vus.Load())
+		report.DroppedIterations = int(r.dropped.Load())
+	}
+	report.Generator = generator
+	report.GeneratorWarnings = r.generatorWarnings(report)
+	if r.saver != nil {
+		if warning := r.saver.warning(); warning != "" {
+			report.GeneratorWarnings = append(report.GeneratorWarnings, warning)
+		}
+	}
+	for _, threshold := range r.thresholds {
+		report.Thresholds = append(report.Thresholds, threshold.Evaluate(report))
+	}
+	return report, nil
+}
@@ -0,0 +1,13 @@
+package loadtest
+
+// Metadata identifies a run, so that reports stored over time can be grouped
+// and filtered, e.g. by release, environment or scenario. It is copied to
+// Report.Metadata as is.
+type Metadata struct {
+	RunID    string
+	Name     string
+	Tags     map[string]string
+	Hostname string
+	GitSHA   string
+	Version  string
+}
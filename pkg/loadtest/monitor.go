@@ -0,0 +1,141 @@
+package loadtest
+
+import (
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GeneratorStats is the resource usage of the load generator process during
+// the run, sampled every second. CPU time and open files are read from /proc
+// and stay at 0 on systems without it.
+type GeneratorStats struct {
+	// CPUs is the number of cores the Go scheduler can use (GOMAXPROCS).
+	CPUs int
+	// CPUPercent is the average CPU usage over the run, where 100% is one
+	// fully used core.
+	CPUPercent     float64
+	PeakCPUPercent float64
+	// PeakMemory is the memory obtained from the OS by the Go runtime.
+	PeakMemory     uint64
+	PeakHeap       uint64
+	PeakGoroutines int
+	GCCycles       int
+	GCPauseTotal   time.Duration
+	GCPauseMax     time.Duration
+	PeakOpenFiles  int
+}
+
+const monitorInterval = time.Second
+
+// monitor samples GeneratorStats in the background until stop.
+type monitor struct {
+	stats      GeneratorStats
+	start      time.Time
+	startCPU   time.Duration
+	lastAt     time.Time
+	lastCPU    time.Duration
+	startGC    uint32
+	lastGC     uint32
+	startPause uint64
+	done       chan struct{}
+	stopped    chan struct{}
+}
+
+func startMonitor() *monitor {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	m := &monitor{
+		stats:      GeneratorStats{CPUs: runtime.GOMAXPROCS(0)},
+		start:      time.Now(),
+		startGC:    mem.NumGC,
+		lastGC:     mem.NumGC,
+		startPause: mem.PauseTotalNs,
+		done:       make(chan struct{}),
+		stopped:    make(chan struct{}),
+	}
+	m.startCPU, _ = processCPUTime()
+	m.lastAt, m.lastCPU = m.start, m.startCPU
+	go m.loop()
+	return m
+}
+
+func (m *monitor) loop() {
+	defer close(m.stopped)
+	ticker := time.NewTicker(monitorInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.done:
+			return
+		case <-ticker.C:
+			m.sample()
+		}
+	}
+}
+
+func (m *monitor) sample() {
+	now := time.Now()
+	if cpu, ok := processCPUTime(); ok {
+		// The last sample, taken at stop, can cover a few milliseconds only,
+		// too short for a meaningful peak.
+		if elapsed := now.Sub(m.lastAt); elapsed >= monitorInterval/2 {
+			m.stats.PeakCPUPercent = max(m.stats.PeakCPUPercent, 100*float64(cpu-m.lastCPU)/float64(elapsed))
+		}
+		if total := now.Sub(m.start); total > 0 {
+			m.stats.CPUPercent = 100 * float64(cpu-m.startCPU) / float64(total)
+		}
+		m.lastAt, m.lastCPU = now, cpu
+	}
+
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	m.stats.PeakMemory = max(m.stats.PeakMemory, mem.Sys)
+	m.stats.PeakHeap = max(m.stats.PeakHeap, mem.HeapAlloc)
+	// PauseNs only keeps the 256 most recent pauses.
+	for n := max(m.lastGC, mem.NumGC-min(mem.NumGC, 256)); n < mem.NumGC; n++ {
+		m.stats.GCPauseMax = max(m.stats.GCPauseMax, time.Duration(mem.PauseNs[n%256]))
+	}
+	m.lastGC = mem.NumGC
+	m.stats.GCCycles = int(mem.NumGC - m.startGC)
+	m.stats.GCPauseTotal = time.Duration(mem.PauseTotalNs - m.startPause)
+	m.stats.PeakGoroutines = max(m.stats.PeakGoroutines, runtime.NumGoroutine())
+	if files, err := os.ReadDir("/proc/self/fd"); err == nil {
+		m.stats.PeakOpenFiles = max(m.stats.PeakOpenFiles, len(files))
+	}
+}
+
+// stop takes a last sample and returns the stats of the whole run.
+func (m *monitor) stop() *GeneratorStats {
+	close(m.done)
+	<-m.stopped
+	m.sample()
+	return &m.stats
+}
+
+// processCPUTime returns the user and system CPU time of the process, from
+// the utime and stime fields of /proc/self/stat.
+func processCPUTime() (time.Duration, bool) {
+	data, err := os.ReadFile("/proc/self/stat")
+	if err != nil {
+		return 0, false
+	}
+	// The command name, in parentheses, may contain spaces.
+	i := strings.LastIndexByte(string(data), ')')
+	if i < 0 {
+		return 0, false
+	}
+	fields := strings.Fields(string(data[i+1:]))
+	if len(fields) < 13 {
+		return 0, false
+	}
+	utime, err1 := strconv.ParseInt(fields[11], 10, 64)
+	stime, err2 := strconv.ParseInt(fields[12], 10, 64)
+	if err1 != nil || err2 != nil {
+		return 0, false
+	}
+	// Linux reports them in clock ticks, 100 per second on every architecture.
+	return time.Duration(utime+stime) * 10 * time.Millisecond, true
+}
@@ -0,0 +1,129 @@
+package loadtest
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// BodyStream opens a new request body for every request, for bodies too large
+// to be held in memory. size is the length of the body, or -1 when it isn't
+// known up front, in which case it is sent chunked.
+type BodyStream func() (body io.ReadCloser, size int64, err error)
+
+// FormField is a field of a multipart/form-data body: Value or, when File is
+// set, the contents of that file.
+type FormField struct {
+	Name  string
+	Value string
+	File  string
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+// MultipartForm encodes fields as a multipart/form-data body and returns it
+// with its Content-Type. Files are read from disk by every request instead of
+// being held in memory, so they can be as large as needed; their size is
+// taken once, so they must not change during the run.
+func MultipartForm(fields []FormField) (BodyStream, string, error) {
+	// The encoded form is a sequence of fixed parts, read from memory, and
+	// files, read from disk.
+	type segment struct {
+		data []byte
+		file string
+	}
+	var segments []segment
+	var size int64
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+	for _, field := range fields {
+		if field.File == "" {
+			if err := writer.WriteField(field.Name, field.Value); err != nil {
+				return nil, "", err
+			}
+			continue
+		}
+		info, err := os.Stat(field.File)
+		if err != nil {
+			return nil, "", err
+		}
+		if !info.Mode().IsRegular() {
+			return nil, "", fmt.Errorf("%s não é um arquivo", field.File)
+		}
+		contentType := mime.TypeByExtension(filepath.Ext(field.File))
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		header := make(textproto.MIMEHeader)
+		header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`,
+			quoteEscaper.Replace(field.Name), quoteEscaper.Replace(filepath.Base(field.File))))
+		header.Set("Content-Type", contentType)
+		if _, err := writer.CreatePart(header); err != nil {
+			return nil, "", err
+		}
+		segments = append(segments, segment{data: bytes.Clone(buf.Bytes())}, segment{file: field.File})
+		size += int64(buf.Len()) + info.Size()
+		buf.Reset()
+	}
+	if err := writer.Close(); err != nil {
+		return nil, "", err
+	}
+	segments = append(segments, segment{data: bytes.Clone(buf.Bytes())})
+	size += int64(buf.Len())
+
+	stream := func() (io.ReadCloser, int64, error) {
+		body := &multipartBody{}
+		readers := make([]io.Reader, 0, len(segments))
+		for _, s := range segments {
+			if s.file == "" {
+				readers = append(readers, bytes.NewReader(s.data))
+				continue
+			}
+			file, err := os.Open(s.file)
+			if err != nil {
+				body.Close()
+				return nil, 0, err
+			}
+			body.files = append(body.files, file)
+			readers = append(readers, file)
+		}
+		body.Reader = io.MultiReader(readers...)
+		return body, size, nil
+	}
+	return stream, writer.FormDataContentType(), nil
+}
+
+// multipartBody reads the parts of a form and closes its files.
+type multipartBody struct {
+	io.Reader
+	files []*os.File
+}
+
+func (b *multipartBody) Close() error {
+	for _, file := range b.files {
+		file.Close()
+	}
+	return nil
+}
+
+// setBodyStream makes stream the body of req, opened again when the request
+// is replayed, e.g. after a redirect.
+func setBodyStream(req *http.Request, stream BodyStream) error {
+	body, size, err := stream()
+	if err != nil {
+		return err
+	}
+	req.Body, req.ContentLength = body, size
+	req.GetBody = func() (io.ReadCloser, error) {
+		body, _, err := stream()
+		return body, err
+	}
+	return nil
+}
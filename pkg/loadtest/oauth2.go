@@ -0,0 +1,118 @@
+package loadtest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tokenRefreshMargin renews the token slightly before it expires, so requests
+// in flight don't carry a token that lapses on the server.
+const tokenRefreshMargin = 30 * time.Second
+
+// OAuth2ClientCredentials configures the OAuth2 client credentials grant used
+// to authenticate every request with a Bearer token.
+type OAuth2ClientCredentials struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+}
+
+type tokenSource struct {
+	config OAuth2ClientCredentials
+	client *http.Client
+
+	mu          sync.Mutex
+	accessToken string
+	expires     time.Time
+}
+
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+// get returns a valid access token, fetching a new one when the cached token
+// is missing or about to expire.
+func (s *tokenSource) get(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.accessToken != "" && (s.expires.IsZero() || time.Now().Before(s.expires)) {
+		return s.accessToken, nil
+	}
+
+	token, expiresIn, err := s.fetch(ctx)
+	if err != nil {
+		return "", fmt.Errorf("oauth2: %w", err)
+	}
+	s.accessToken = token
+	s.expires = time.Time{}
+	if expiresIn > 0 {
+		lifetime := time.Duration(expiresIn) * time.Second
+		margin := tokenRefreshMargin
+		if margin > lifetime/2 {
+			margin = lifetime / 2
+		}
+		s.expires = time.Now().Add(lifetime - margin)
+	}
+	return s.accessToken, nil
+}
+
+// invalidate discards token if it is still the cached one, forcing the next
+// request to fetch a new token.
+func (s *tokenSource) invalidate(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.accessToken == token {
+		s.accessToken = ""
+	}
+}
+
+func (s *tokenSource) fetch(ctx context.Context) (string, int, error) {
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(s.config.Scopes) > 0 {
+		form.Set("scope", strings.Join(s.config.Scopes, " "))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.config.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", 0, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(s.config.ClientID), url.QueryEscape(s.config.ClientSecret))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", 0, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", 0, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", 0, fmt.Errorf("endpoint de token retornou %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var token tokenResponse
+	if err := json.Unmarshal(body, &token); err != nil {
+		return "", 0, fmt.Errorf("resposta do endpoint de token inválida: %w", err)
+	}
+	if token.AccessToken == "" {
+		return "", 0, errors.New("resposta do endpoint de token sem access_token")
+	}
+	return token.AccessToken, token.ExpiresIn, nil
+}
@@ -0,0 +1,452 @@
+package loadtest
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+type Option func(*Runner)
+
+func WithTargets(targets ...Target) Option {
+	return func(r *Runner) {
+		r.targets = append(r.targets, targets...)
+	}
+}
+
+func WithURL(url string) Option {
+	return WithTargets(Target{URL: url, Weight: 1})
+}
+
+// WithScenario makes every worker run the steps of s in order instead of
+// picking a single target per request. It can't be combined with WithTargets.
+func WithScenario(s Scenario) Option {
+	return func(r *Runner) {
+		r.scenario = &s
+	}
+}
+
+// WithSource takes every request from source instead of the targets. Workers
+// stop once source is closed, so the run ends when it is drained unless the
+// request count or the duration ends it first. Steps are sent as-is, without
+// templating.
+func WithSource(source <-chan Step) Option {
+	return func(r *Runner) {
+		r.source = source
+	}
+}
+
+// WithFeeder templates every iteration with the next row of f.
+func WithFeeder(f *Feeder) Option {
+	return func(r *Runner) {
+		r.feeder = f
+	}
+}
+
+func WithMethod(method string) Option {
+	return func(r *Runner) {
+		r.method = method
+	}
+}
+
+func WithBody(body []byte, contentType string) Option {
+	return func(r *Runner) {
+		r.body = body
+		r.contentType = contentType
+	}
+}
+
+// WithBodyStream sends the body opened by stream, instead of one held in
+// memory, with every request that doesn't have a body of its own.
+func WithBodyStream(stream BodyStream, contentType string) Option {
+	return func(r *Runner) {
+		r.stream = stream
+		r.contentType = contentType
+	}
+}
+
+func WithHeaders(headers http.Header) Option {
+	return func(r *Runner) {
+		for key, values := range headers {
+			r.headers[key] = append(r.headers[key], values...)
+		}
+	}
+}
+
+func WithRequests(requests int) Option {
+	return func(r *Runner) {
+		r.requests = requests
+	}
+}
+
+func WithConcurrency(concurrency int) Option {
+	return func(r *Runner) {
+		r.concurrency = concurrency
+	}
+}
+
+func WithDuration(duration time.Duration) Option {
+	return func(r *Runner) {
+		r.duration = duration
+	}
+}
+
+func WithRate(rate float64) Option {
+	return func(r *Runner) {
+		r.rate = rate
+	}
+}
+
+// WithStages paces the run by a staged load profile instead of a constant
+// rate. The run lasts for the sum of the stage durations.
+func WithStages(stages ...Stage) Option {
+	return func(r *Runner) {
+		r.stages = stages
+	}
+}
+
+func WithRampUp(rampUp time.Duration) Option {
+	return func(r *Runner) {
+		r.rampUp = rampUp
+	}
+}
+
+// WithThinkTime makes every worker pause between requests, like a user
+// reading a page would. Each pause varies uniformly by up to jitter either way.
+func WithThinkTime(thinkTime, jitter time.Duration) Option {
+	return func(r *Runner) {
+		r.thinkTime = thinkTime
+		r.thinkJitter = jitter
+	}
+}
+
+// WithRetryPolicy retries failed requests as the policy allows. Each result
+// then accounts for every attempt of its request.
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(r *Runner) {
+		r.retry = nil
+		if policy.Retries > 0 {
+			r.retry = &policy
+		}
+	}
+}
+
+// WithWarmup sends traffic for the given duration before measuring starts.
+// Results of requests started during the warm-up are left out of the Report.
+func WithWarmup(warmup time.Duration) Option {
+	return func(r *Runner) {
+		r.warmup = warmup
+	}
+}
+
+// WithWarmupRequests discards the first n results before measuring starts.
+// They are issued in addition to the requests set by WithRequests.
+func WithWarmupRequests(n int) Option {
+	return func(r *Runner) {
+		r.warmupReqs = n
+	}
+}
+
+func WithTimeout(timeout time.Duration) Option {
+	return func(r *Runner) {
+		r.timeout = timeout
+	}
+}
+
+func WithLatencyBuckets(buckets []time.Duration) Option {
+	return func(r *Runner) {
+		r.buckets = buckets
+	}
+}
+
+// WithTimelineInterval sets the resolution of Report.Timeline, one second by
+// default. Long runs use a coarser interval to keep the timeline small.
+func WithTimelineInterval(interval time.Duration) Option {
+	return func(r *Runner) {
+		r.resolution = interval
+	}
+}
+
+// WithExecutor selects the execution model, ExecutorClosed by default.
+func WithExecutor(executor Executor) Option {
+	return func(r *Runner) {
+		r.executor = executor
+	}
+}
+
+// WithMaxVUs caps the VUs the open executor may spawn. It defaults to the
+// concurrency, i.e. no VUs are added beyond the initial ones.
+func WithMaxVUs(maxVUs int) Option {
+	return func(r *Runner) {
+		r.maxVUs = maxVUs
+	}
+}
+
+// WithMaxBandwidth limits the read and write throughput of every connection
+// to the given bytes per second, simulating clients on slow links.
+func WithMaxBandwidth(bytesPerSecond float64) Option {
+	return func(r *Runner) {
+		r.bandwidth = bytesPerSecond
+	}
+}
+
+func WithProtocol(protocol Protocol) Option {
+	return func(r *Runner) {
+		r.protocol = protocol
+	}
+}
+
+func WithTLSConfig(config *tls.Config) Option {
+	return func(r *Runner) {
+		r.tlsConfig = config
+	}
+}
+
+// WithAssertions validates every response body; a response failing any
+// assertion is not counted as a success.
+func WithAssertions(assertions ...Assertion) Option {
+	return func(r *Runner) {
+		r.assertions = append(r.assertions, assertions...)
+	}
+}
+
+// WithResponseCheck runs check on every response that passed the assertions.
+func WithResponseCheck(check ResponseCheck) Option {
+	return func(r *Runner) {
+		r.check = check
+	}
+}
+
+// WithRequestHook runs hook on every request before it is sent.
+func WithRequestHook(hook RequestHook) Option {
+	return func(r *Runner) {
+		r.hook = hook
+	}
+}
+
+// WithAcceptEncoding asks for responses compressed with encodings, e.g. gzip,
+// deflate and br, instead of the transparent gzip of net/http, and decodes them
+// in the runner, so that the report compares their transferred and decoded
+// sizes. "identity" asks for uncompressed responses.
+func WithAcceptEncoding(encodings ...string) Option {
+	return func(r *Runner) {
+		r.encodings = encodings
+	}
+}
+
+// WithBodyMode sets how response bodies are received, BodyDiscard by default.
+func WithBodyMode(mode BodyMode) Option {
+	return func(r *Runner) {
+		r.bodyMode = mode
+	}
+}
+
+// WithSaveBodies saves every failed response, and a sample of rate, from 0 to
+// 1, of the successful ones, to files in dir, to help debug failures. Up to
+// 1000 responses of each kind are saved.
+func WithSaveBodies(dir string, rate float64) Option {
+	return func(r *Runner) {
+		r.saver = &bodySaver{dir: dir, rate: rate}
+	}
+}
+
+// WithFailureSamples keeps the first n failed requests in
+// Report.FailureSamples, with the status, headers and the start of the body
+// of their responses.
+func WithFailureSamples(n int) Option {
+	return func(r *Runner) {
+		r.samples = n
+	}
+}
+
+func WithSuccessCodes(codes StatusSet) Option {
+	return func(r *Runner) {
+		r.success = codes
+	}
+}
+
+func WithThresholds(thresholds ...Threshold) Option {
+	return func(r *Runner) {
+		r.thresholds = append(r.thresholds, thresholds...)
+	}
+}
+
+// WithAbortRule stops the run as soon as the error rate breaks rule. The
+// report then tells why in Aborted.
+func WithAbortRule(rule AbortRule) Option {
+	return func(r *Runner) {
+		r.abort = &rule
+	}
+}
+
+// WithMetadata attaches tags and details about the environment to the
+// report.
+func WithMetadata(metadata Metadata) Option {
+	return func(r *Runner) {
+		r.metadata = metadata
+	}
+}
+
+// WithConnectionLimits tunes the connection pool of the HTTP/1.1 and HTTP/2
+// transports.
+func WithConnectionLimits(limits ConnectionLimits) Option {
+	return func(r *Runner) {
+		r.limits = limits
+	}
+}
+
+// WithWorkersPerClient gives every n workers their own http.Client and
+// transport, so that high-concurrency runs don't contend for a single
+// connection pool. With 0 every worker shares one client.
+func WithWorkersPerClient(n int) Option {
+	return func(r *Runner) {
+		r.perClient = n
+	}
+}
+
+// WithKeepAlive controls connection reuse. Disabling it forces a new
+// connection per request (HTTP/1.1 and HTTP/2 over TLS only).
+func WithKeepAlive(enabled bool) Option {
+	return func(r *Runner) {
+		r.disableKeepAlive = !enabled
+	}
+}
+
+// WithPortExhaustion sets what the runner does when connections fail because
+// the load generator ran out of local ports. The default is
+// PortExhaustionReport.
+func WithPortExhaustion(mode PortExhaustion) Option {
+	return func(r *Runner) {
+		r.portExhaustion = mode
+	}
+}
+
+// WithProxy sends every request through an http, https or socks5 proxy.
+func WithProxy(proxy *url.URL) Option {
+	return func(r *Runner) {
+		r.proxy = proxy
+	}
+}
+
+// WithProxyFromEnvironment honors HTTP_PROXY, HTTPS_PROXY and NO_PROXY when no
+// explicit proxy is set. They are ignored by default so results don't depend
+// on the shell the test runs from.
+func WithProxyFromEnvironment(enabled bool) Option {
+	return func(r *Runner) {
+		r.proxyFromEnv = enabled
+	}
+}
+
+// WithResolve makes connections to hostPort ("host:port") go to addr, like
+// curl --resolve. It can be used multiple times.
+func WithResolve(hostPort, addr string) Option {
+	return func(r *Runner) {
+		if r.resolve == nil {
+			r.resolve = make(map[string]string)
+		}
+		r.resolve[hostPort] = addr
+	}
+}
+
+// WithDNSServer resolves host names with the DNS server at addr ("host:port")
+// instead of the system resolver.
+func WithDNSServer(addr string) Option {
+	return func(r *Runner) {
+		r.dnsServer = addr
+	}
+}
+
+// WithUnixSocket sends every connection to the unix domain socket at path.
+// The URLs still define the request line and the Host header.
+func WithUnixSocket(path string) Option {
+	return func(r *Runner) {
+		r.unixSocket = path
+	}
+}
+
+// WithLocalAddr binds outgoing connections to the local IP addr. It can be
+// used multiple times: each new connection takes the next address, so that a
+// multi-homed machine spreads its ephemeral ports across all of them.
+func WithLocalAddr(addr string) Option {
+	return func(r *Runner) {
+		r.localAddrs = append(r.localAddrs, addr)
+	}
+}
+
+// WithIPFamily makes every connection use addresses of family, so that each
+// path to a dual-stack target can be tested on its own.
+func WithIPFamily(family IPFamily) Option {
+	return func(r *Runner) {
+		r.ipFamily = family
+	}
+}
+
+// WithMaxRedirects limits how many redirects a request follows. With 0 the
+// 3xx response itself is measured and reported.
+func WithMaxRedirects(n int) Option {
+	return func(r *Runner) {
+		r.maxRedirects = n
+	}
+}
+
+// WithCookies gives every worker its own cookie jar, kept across its
+// iterations, so session cookies behave as they would for a real user.
+func WithCookies(enabled bool) Option {
+	return func(r *Runner) {
+		r.cookies = enabled
+	}
+}
+
+// WithConditionalRequests gives every worker its own cache of the ETag and
+// Last-Modified of the responses it got, sent back as If-None-Match and
+// If-Modified-Since when it requests the same URL again.
+func WithConditionalRequests(enabled bool) Option {
+	return func(r *Runner) {
+		r.conditional = enabled
+	}
+}
+
+// WithOAuth2 authenticates every request with a Bearer token obtained through
+// the client credentials grant. The token is fetched before the run starts and
+// renewed when it expires or the server answers 401.
+func WithOAuth2(config OAuth2ClientCredentials) Option {
+	return func(r *Runner) {
+		r.oauth2 = &config
+	}
+}
+
+// WithTraceContext starts a new trace for every request and propagates it in
+// a W3C traceparent header. The ids are available in Result.TraceID and
+// Result.SpanID.
+func WithTraceContext(enabled bool) Option {
+	return func(r *Runner) {
+		r.traceContext = enabled
+	}
+}
+
+// WithVUHeader sends the id of the virtual user that made every request in
+// the header name, so that the server can tell the users apart, e.g. in its
+// logs. The id is also available to the templates as {{.VU}}.
+func WithVUHeader(name string) Option {
+	return func(r *Runner) {
+		r.vuHeader = name
+	}
+}
+
+// WithResultHandler registers a callback invoked with every collected result.
+// It runs on the collector goroutine, so it must not block. Handlers can be
+// registered multiple times and are called in order.
+func WithResultHandler(fn func(Result)) Option {
+	return func(r *Runner) {
+		r.onResult = append(r.onResult, fn)
+	}
+}
+
+// WithProgress registers a callback invoked after every collected result.
+// total is 0 when the run is bounded only by duration.
+func WithProgress(fn func(completed, total int)) Option {
+	return func(r *Runner) {
+		r.onProgress = fn
+	}
+}
@@ -0,0 +1,117 @@
+package loadtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// PortExhaustion is what the runner does when connections fail because the
+// load generator ran out of local (ephemeral) ports.
+type PortExhaustion string
+
+const (
+	// PortExhaustionReport only counts the failed requests, classified as
+	// ErrorPortExhaustion and flagged in the generator warnings. It is the
+	// default.
+	PortExhaustionReport PortExhaustion = "report"
+	// PortExhaustionThrottle pauses every worker once the ports run out, for
+	// twice as long each time they keep running out, so that closed
+	// connections leave TIME_WAIT and free their ports.
+	PortExhaustionThrottle PortExhaustion = "throttle"
+	// PortExhaustionReuse turns keep-alive back on for the rest of the run
+	// once the ports run out, when it was disabled with WithKeepAlive, and
+	// pauses like PortExhaustionThrottle until connections can be opened
+	// again.
+	PortExhaustionReuse PortExhaustion = "reuse"
+)
+
+const (
+	minPortPause = 50 * time.Millisecond
+	maxPortPause = 2 * time.Second
+)
+
+// isPortExhaustion reports whether err is a connection that couldn't get a
+// local port: connect fails with EADDRNOTAVAIL, or bind with EADDRINUSE when
+// the connection is bound to a local address.
+func isPortExhaustion(err error) bool {
+	return errors.Is(err, syscall.EADDRNOTAVAIL) || errors.Is(err, syscall.EADDRINUSE)
+}
+
+// portState is how a run reacted to running out of local ports.
+type portState struct {
+	mu      sync.Mutex
+	until   time.Time
+	backoff time.Duration
+	pauses  int
+	reused  bool
+}
+
+// exhausted handles a request that failed for lack of a local port.
+func (r *Runner) exhausted(now time.Time) {
+	s := &r.ports
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r.portExhaustion == PortExhaustionReport {
+		return
+	}
+	if r.portExhaustion == PortExhaustionReuse && r.disableKeepAlive && !s.reused {
+		s.reused = true
+		r.reuse.Store(true)
+	}
+	// Requests already in flight when the pause started fail too, and don't
+	// extend it.
+	if now.Before(s.until) {
+		return
+	}
+	if s.backoff > 0 && now.Sub(s.until) < maxPortPause {
+		s.backoff = min(2*s.backoff, maxPortPause)
+	} else {
+		s.backoff = minPortPause
+	}
+	s.until = now.Add(s.backoff)
+	s.pauses++
+}
+
+// waitPorts holds a worker while the run is paused for lack of local ports.
+// It returns false once ctx is cancelled.
+func (r *Runner) waitPorts(ctx context.Context) bool {
+	if r.portExhaustion == PortExhaustionReport {
+		return true
+	}
+	r.ports.mu.Lock()
+	wait := time.Until(r.ports.until)
+	r.ports.mu.Unlock()
+	if wait <= 0 {
+		return true
+	}
+	timer := time.NewTimer(wait)
+	select {
+	case <-ctx.Done():
+		timer.Stop()
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// portWarnings describes how the run ran out of local ports, if it did.
+func (r *Runner) portWarnings(report *Report) []string {
+	failed := report.ErrorCategories[ErrorPortExhaustion]
+	if failed == 0 {
+		return nil
+	}
+	warnings := []string{fmt.Sprintf("%d requests falharam sem porta local livre para conectar: o gerador esgotou as portas efêmeras; reutilize conexões, use mais endereços locais ou reduza a taxa de conexões novas", failed)}
+	r.ports.mu.Lock()
+	defer r.ports.mu.Unlock()
+	if r.ports.pauses > 0 {
+		warnings = append(warnings, fmt.Sprintf("a carga foi pausada %d vezes para liberar portas locais", r.ports.pauses))
+	}
+	if r.ports.reused {
+		warnings = append(warnings, "keep-alive reativado após o esgotamento das portas locais: os requests seguintes reutilizaram conexões")
+	}
+	return warnings
+}
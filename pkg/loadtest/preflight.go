@@ -0,0 +1,162 @@
+package loadtest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+)
+
+// Preflight checks that the targets can be reached before the load starts:
+// for every target it resolves the host, opens a connection and sends a
+// single request with the client of the run, so proxy, TLS and host overrides
+// apply. It fails on network errors and on authentication challenges (401 and
+// 407), which usually mean the test is missing credentials. With a scenario
+// only the first step is probed, as the next ones may depend on its captures;
+// requests read from a source are not probed.
+func (r *Runner) Preflight(ctx context.Context) error {
+	var steps []Step
+	switch {
+	case r.source != nil:
+		return nil
+	case r.scenario != nil:
+		steps = r.scenario.Steps[:1]
+	default:
+		steps = r.steps
+	}
+	// Reading a row with Next would take it from the run.
+	var row map[string]string
+	if r.feeder != nil {
+		row = r.feeder.rows[0]
+	}
+	vars := iterationVars(0, row)
+	for _, step := range steps {
+		if err := r.probe(ctx, step, vars); err != nil {
+			return fmt.Errorf("%s %s: %w", step.Method, step.URL, err)
+		}
+	}
+	return nil
+}
+
+func (r *Runner) probe(ctx context.Context, step Step, vars map[string]string) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	step, err := step.render(vars)
+	if err != nil {
+		return err
+	}
+	target, err := url.Parse(step.URL)
+	if err != nil {
+		return err
+	}
+	// Through a proxy or a unix socket the host is never resolved nor dialed
+	// by the client, so only the request is checked.
+	if r.proxy == nil && !r.proxyFromEnv && r.unixSocket == "" {
+		addr := net.JoinHostPort(target.Hostname(), target.Port())
+		if target.Port() == "" {
+			addr = net.JoinHostPort(target.Hostname(), map[string]string{"http": "80", "https": "443"}[target.Scheme])
+		}
+		if err := r.lookup(ctx, addr); err != nil {
+			return fmt.Errorf("falha ao resolver %s: %w", target.Hostname(), err)
+		}
+		if r.protocol != ProtocolHTTP3 {
+			conn, err := r.dialContext(&net.Dialer{})(ctx, "tcp", addr)
+			if err != nil {
+				return fmt.Errorf("falha ao conectar em %s: %w", addr, err)
+			}
+			conn.Close()
+		}
+	}
+
+	req, err := r.newRequest(ctx, step)
+	if err != nil {
+		return err
+	}
+	if r.tokens != nil {
+		token, err := r.tokens.get(ctx)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	if r.hook != nil {
+		if err := r.hook(req, step.Body); err != nil {
+			return fmt.Errorf("hook do request: %w", err)
+		}
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request de teste falhou: %w", err)
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, maxAssertionBodySize))
+	resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusUnauthorized:
+		return fmt.Errorf("o alvo exige autenticação (status 401, WWW-Authenticate: %s): verifique as credenciais do teste",
+			resp.Header.Get("WWW-Authenticate"))
+	case http.StatusProxyAuthRequired:
+		return fmt.Errorf("o proxy exige autenticação (status 407, Proxy-Authenticate: %s): verifique as credenciais do proxy",
+			resp.Header.Get("Proxy-Authenticate"))
+	}
+	return nil
+}
+
+// lookup resolves the host of addr as the transports do, skipping IPs and
+// hosts overridden with WithResolve.
+func (r *Runner) lookup(ctx context.Context, addr string) error {
+	host, _, _ := net.SplitHostPort(addr)
+	if net.ParseIP(host) != nil || r.overrideAddr(addr) != addr {
+		return nil
+	}
+	resolver := r.newResolver()
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	_, err := resolver.LookupHost(ctx, host)
+	return err
+}
+
+// SampleRequest builds, without sending it, the first request of the run as a
+// worker would: templates rendered with the first row of the feeder, headers,
+// body, trace context and the hook applied. OAuth2 tokens aren't fetched, so
+// the request has no Authorization header from them. It returns nil when the
+// requests are read from a source.
+func (r *Runner) SampleRequest(ctx context.Context) (*http.Request, error) {
+	var step Step
+	switch {
+	case r.source != nil:
+		return nil, nil
+	case r.scenario != nil:
+		step = r.scenario.Steps[0]
+	default:
+		step = r.steps[0]
+	}
+	var row map[string]string
+	if r.feeder != nil {
+		row = r.feeder.rows[0]
+	}
+	vars := iterationVars(0, row)
+	step, err := step.render(vars)
+	if err != nil {
+		return nil, err
+	}
+	req, err := r.newRequest(ctx, step)
+	if err != nil {
+		return nil, err
+	}
+	if r.vuHeader != "" {
+		req.Header.Set(r.vuHeader, vars["VU"])
+	}
+	if r.traceContext {
+		injectTraceContext(req, &Result{})
+	}
+	if r.hook != nil {
+		if err := r.hook(req, step.Body); err != nil {
+			return nil, fmt.Errorf("hook do request: %w", err)
+		}
+	}
+	return req, nil
+}
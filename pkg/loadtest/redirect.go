@@ -0,0 +1,23 @@
+package loadtest
+
+import "net/http"
+
+// DefaultMaxRedirects matches the limit of the net/http default client.
+const DefaultMaxRedirects = 10
+
+type redirectsKey struct{}
+
+func (r *Runner) checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) > r.maxRedirects {
+		return http.ErrUseLastResponse
+	}
+	if count, ok := req.Context().Value(redirectsKey{}).(*int); ok {
+		*count = len(via)
+	}
+	return nil
+}
+
+// IsRedirect reports whether code is a 3xx status.
+func IsRedirect(code int) bool {
+	return code >= 300 && code < 400
+}
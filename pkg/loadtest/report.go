@@ -0,0 +1,585 @@
+package loadtest
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type Result struct {
+	Target         string
+	Method         string
+	Step           string
+	LastStep       bool
+	Start          time.Time
+	Intended       time.Time
+	StatusCode     int
+	Proto          string
+	Duration       time.Duration
+	Redirects      int
+	Bytes          int64
+	BytesSent      int64
+	ConnReused     bool
+	NewConn        bool
+	Phases         Phases
+	Error          error
+	AssertionError *AssertionError
+	TraceID        string
+	SpanID         string
+	// Attempts is the number of times the request was sent, more than one
+	// when it was retried.
+	Attempts int
+	// GRPCStatus is the name of the gRPC status, e.g. UNAVAILABLE, when the
+	// response is a gRPC response. Any status other than OK is a failure.
+	GRPCStatus string
+	// Worker is the index of the worker, or VU, that sent the request.
+	Worker int
+	// Conditional is set when the request carried If-None-Match or
+	// If-Modified-Since.
+	Conditional bool
+	// IPFamily is the address family of the connection that carried the
+	// request, when it went over TCP.
+	IPFamily IPFamily
+	// Encoding is the Content-Encoding of a response decoded by the runner,
+	// with WithAcceptEncoding, which took WireBytes to transfer and
+	// DecodeTime to decode. Bytes is the decoded size.
+	Encoding   string
+	WireBytes  int64
+	DecodeTime time.Duration
+	// Metrics are the custom values reported by the ResponseCheck.
+	Metrics map[string]float64
+	// SavedBody is the file where WithSaveBodies saved the response.
+	SavedBody string
+	// Sample is the failed response kept for WithFailureSamples.
+	Sample *FailureSample
+}
+
+func (r Result) grpcFailed() bool {
+	return r.GRPCStatus != "" && r.GRPCStatus != "OK"
+}
+
+// CorrectedDuration is the latency measured from the intended send time,
+// which includes the time the request waited for a free worker. Without a
+// rate it equals Duration.
+func (r Result) CorrectedDuration() time.Duration {
+	if r.Intended.IsZero() || !r.Start.After(r.Intended) {
+		return r.Duration
+	}
+	return r.Duration + r.Start.Sub(r.Intended)
+}
+
+type Report struct {
+	StartTime         time.Time
+	Metadata          Metadata
+	TotalTime         time.Duration
+	TotalRequests     int
+	Iterations        int
+	SuccessRequests   int
+	SuccessCodes      StatusSet
+	Concurrency       int
+	RampUp            time.Duration
+	Warmup            time.Duration
+	WarmupRequests    int
+	ThinkTime         time.Duration
+	ThinkTimeJitter   time.Duration
+	Retry             *RetryPolicy
+	Attempts          int
+	RetriedRequests   int
+	RecoveredRequests int
+	Protocol          Protocol
+	MaxBandwidth      float64
+	Executor          Executor
+	MaxVUs            int
+	VUs               int
+	DroppedIterations int
+	Interrupted       bool
+	// Aborted is why the run was stopped by its AbortRule, if it was.
+	Aborted           string
+	StatusCodes       map[int]int
+	GRPCStatusCodes   map[string]int
+	Redirects         int
+	Protocols         map[string]int
+	IPFamilies        map[string]int
+	ReusedConnections int
+	NewConnections    int
+	// Conditional is the number of requests sent with If-None-Match or
+	// If-Modified-Since, of which NotModified got a 304.
+	Conditional       int
+	NotModified       int
+	Timeouts          int
+	TotalBytes        int64
+	BytesSent         int64
+	Compression       *CompressionStats
+	SavedBodies       *SavedBodies
+	Responses         int
+	MaxResponseSize   int64
+	Errors            map[string]int
+	ErrorCategories   map[string]int
+	FailedAssertions  int
+	AssertionFailures map[string]int
+	FailureSamples    []FailureSample
+	CustomMetrics     map[string]MetricStats
+	Latency           LatencyStats
+	CorrectedLatency  *LatencyStats
+	// Distribution holds the latency of every response, for quantiles other
+	// than the ones of Latency.
+	Distribution      *LatencyHistogram
+	StatusClasses     []StatusClassLatency
+	Phases            PhaseStats
+	Histogram         Histogram
+	Targets           []TargetReport
+	Scenario          string
+	Steps             []StepReport
+	Stages            []StageReport
+	Thresholds        []ThresholdResult
+	Generator         *GeneratorStats
+	GeneratorWarnings []string
+	Workers           []WorkerStats
+	Timeline          []TimelinePoint
+	TimelineInterval  time.Duration
+}
+
+// TimelinePoint aggregates the requests completed during one interval of the
+// run, one second by default. Report.Timeline[i] covers [i, i+1) intervals
+// after start. P95 only considers requests that got a response.
+type TimelinePoint struct {
+	Requests int
+	Failures int
+	P95      time.Duration
+}
+
+// ErrorClass is the StatusClassLatency of the requests that got no response.
+const ErrorClass = "errors"
+
+// StatusClassLatency is the latency of the requests whose response fell in
+// Class, e.g. 2xx, so that fast failures don't hide a slow success path.
+// Requests that got no response are grouped in ErrorClass, timed until the
+// error.
+type StatusClassLatency struct {
+	Class    string
+	Requests int
+	Latency  LatencyStats
+}
+
+// WorkerStats is what a single worker, or VU, did during the run. Comparing
+// the workers reveals an unbalanced distribution of work or a stuck worker.
+type WorkerStats struct {
+	Worker      int
+	Requests    int
+	Failures    int
+	MeanLatency time.Duration
+	MaxLatency  time.Duration
+	// LastResponse is when the last request of the worker completed.
+	LastResponse time.Time
+}
+
+type RequestStats struct {
+	TotalRequests   int
+	SuccessRequests int
+	FailedRequests  int
+	Latency         LatencyStats
+}
+
+// ErrorRate is the percentage of the requests that didn't succeed.
+func (s RequestStats) ErrorRate() float64 {
+	if s.TotalRequests == 0 {
+		return 0
+	}
+	return float64(s.TotalRequests-s.SuccessRequests) / float64(s.TotalRequests) * 100
+}
+
+type TargetReport struct {
+	Name   string
+	Method string
+	URL    string
+	Weight int
+	RequestStats
+}
+
+type StepReport struct {
+	Name   string
+	Method string
+	URL    string
+	RequestStats
+}
+
+func (r *Report) SuccessRate() float64 {
+	if r.TotalRequests == 0 {
+		return 0
+	}
+	return float64(r.SuccessRequests) / float64(r.TotalRequests) * 100
+}
+
+// ErrorRate is the percentage of failed requests, 0 when there were none.
+func (r *Report) ErrorRate() float64 {
+	if r.TotalRequests == 0 {
+		return 0
+	}
+	return 100 - r.SuccessRate()
+}
+
+// StatusClassCounts aggregates StatusCodes by class, e.g. 2xx, which is what
+// most SLOs are written against. Requests without a response are left out.
+func (r *Report) StatusClassCounts() map[string]int {
+	counts := make(map[string]int)
+	for code, count := range r.StatusCodes {
+		if code >= 100 {
+			counts[fmt.Sprintf("%dxx", code/100)] += count
+		}
+	}
+	return counts
+}
+
+// StatusClassRate is the percentage of all requests whose response fell in
+// class, e.g. 5xx.
+func (r *Report) StatusClassRate(class string) float64 {
+	if r.TotalRequests == 0 {
+		return 0
+	}
+	return float64(r.StatusClassCounts()[class]) / float64(r.TotalRequests) * 100
+}
+
+func (r *Report) RequestsPerSecond() float64 {
+	if r.TotalTime <= 0 {
+		return 0
+	}
+	return float64(r.TotalRequests) / r.TotalTime.Seconds()
+}
+
+// AverageResponseSize is the mean body size, in bytes, of the requests that
+// got a response.
+func (r *Report) AverageResponseSize() float64 {
+	if r.Responses == 0 {
+		return 0
+	}
+	return float64(r.TotalBytes) / float64(r.Responses)
+}
+
+// Throughput is the rate at which response bodies were received, in bytes
+// per second.
+func (r *Report) Throughput() float64 {
+	if r.TotalTime <= 0 {
+		return 0
+	}
+	return float64(r.TotalBytes) / r.TotalTime.Seconds()
+}
+
+// UploadThroughput is the rate at which request bodies were sent, in bytes
+// per second.
+func (r *Report) UploadThroughput() float64 {
+	if r.TotalTime <= 0 {
+		return 0
+	}
+	return float64(r.BytesSent) / r.TotalTime.Seconds()
+}
+
+// statsGroup accumulates the per-target or per-step breakdown of a Report.
+type statsGroup struct {
+	stats   *RequestStats
+	latency LatencyHistogram
+}
+
+type collector struct {
+	report    *Report
+	start     time.Time
+	success   StatusSet
+	samples   int
+	corrected *LatencyHistogram
+	groups    map[string]*statsGroup
+	stages    []*statsGroup
+	phases    [5]LatencyHistogram
+	classes   map[string]*LatencyHistogram
+	seconds   []*LatencyHistogram
+	workers   []*WorkerStats
+	busy      []time.Duration
+}
+
+func newCollector(r *Runner, start time.Time) *collector {
+	c := &collector{
+		start: start,
+		report: &Report{
+			StartTime:         start,
+			Metadata:          r.metadata,
+			Concurrency:       r.concurrency,
+			RampUp:            r.rampUp,
+			Warmup:            r.warmup,
+			ThinkTime:         r.thinkTime,
+			ThinkTimeJitter:   r.thinkJitter,
+			Retry:             r.retry,
+			Protocol:          r.protocol,
+			MaxBandwidth:      r.bandwidth,
+			Executor:          r.executor,
+			TimelineInterval:  r.resolution,
+			SuccessCodes:      r.success,
+			StatusCodes:       make(map[int]int),
+			GRPCStatusCodes:   make(map[string]int),
+			Protocols:         make(map[string]int),
+			IPFamilies:        make(map[string]int),
+			Errors:            make(map[string]int),
+			ErrorCategories:   make(map[string]int),
+			AssertionFailures: make(map[string]int),
+			Distribution:      &LatencyHistogram{},
+			Histogram:         newHistogram(r.buckets),
+		},
+		success: r.success,
+		samples: r.samples,
+		groups:  make(map[string]*statsGroup),
+		classes: make(map[string]*LatencyHistogram),
+	}
+	if r.rate > 0 || len(r.stages) > 0 {
+		c.corrected = &LatencyHistogram{}
+	}
+	if r.saver != nil {
+		c.report.SavedBodies = &SavedBodies{Dir: r.saver.dir, Rate: r.saver.rate}
+	}
+	if len(r.encodings) > 0 {
+		c.report.Compression = &CompressionStats{AcceptEncoding: strings.Join(r.encodings, ", "), Encodings: make(map[string]int)}
+	}
+	var from float64
+	for _, stage := range r.stages {
+		c.report.Stages = append(c.report.Stages, StageReport{Stage: stage, StartRate: from})
+		from = stage.Rate
+	}
+	for i := range c.report.Stages {
+		c.stages = append(c.stages, &statsGroup{stats: &c.report.Stages[i].RequestStats})
+	}
+	if r.scenario != nil {
+		c.report.Scenario = r.scenario.Name
+		c.report.Steps = make([]StepReport, len(r.scenario.Steps))
+		for i, step := range r.scenario.Steps {
+			c.report.Steps[i] = StepReport{Name: step.Name, Method: step.Method, URL: step.URL}
+			c.groups[step.Name] = &statsGroup{stats: &c.report.Steps[i].RequestStats}
+		}
+		return c
+	}
+	c.report.Targets = make([]TargetReport, len(r.targets))
+	for i, target := range r.targets {
+		c.report.Targets[i] = TargetReport{Name: target.Name, Method: r.steps[i].Method, URL: target.URL, Weight: target.Weight}
+		key := target.URL
+		if target.Name != "" {
+			key = target.Name
+		}
+		c.groups[key] = &statsGroup{stats: &c.report.Targets[i].RequestStats}
+	}
+	return c
+}
+
+func (c *collector) add(result Result) {
+	report := c.report
+	report.TotalRequests++
+	report.Redirects += result.Redirects
+	if result.ConnReused {
+		report.ReusedConnections++
+	}
+	if result.NewConn {
+		report.NewConnections++
+	}
+	if result.Proto != "" {
+		report.Protocols[result.Proto]++
+	}
+	if result.IPFamily != IPFamilyAny {
+		report.IPFamilies[string(result.IPFamily)]++
+	}
+	if result.LastStep {
+		report.Iterations++
+	}
+	if result.Conditional {
+		report.Conditional++
+		if result.StatusCode == http.StatusNotModified {
+			report.NotModified++
+		}
+	}
+	report.Attempts += max(result.Attempts, 1)
+	if result.Attempts > 1 {
+		report.RetriedRequests++
+	}
+	key := result.Target
+	if result.Step != "" {
+		key = result.Step
+	}
+	group := c.groups[key]
+	if group == nil {
+		// Requests from a source have no per-target stats: their URLs aren't
+		// known up front and may all be different.
+		group = &statsGroup{stats: &RequestStats{}}
+	}
+	group.stats.TotalRequests++
+	c.addStage(result)
+	c.addWorker(result)
+	report.TotalBytes += result.Bytes
+	report.BytesSent += result.BytesSent
+	for name, v := range result.Metrics {
+		if report.CustomMetrics == nil {
+			report.CustomMetrics = make(map[string]MetricStats)
+		}
+		metric := report.CustomMetrics[name]
+		metric.add(v)
+		report.CustomMetrics[name] = metric
+	}
+	second := c.second(result.Start.Add(result.Duration))
+	point := &report.Timeline[second]
+	point.Requests++
+	c.addSample(result)
+
+	if result.Error != nil {
+		if isTimeout(result.Error) {
+			report.Timeouts++
+		} else {
+			report.StatusCodes[0]++
+		}
+		message := ErrorMessage(result.Error)
+		if _, ok := report.Errors[message]; !ok && len(report.Errors) >= maxErrorMessages {
+			message = OtherErrors
+		}
+		report.Errors[message]++
+		report.ErrorCategories[ClassifyError(result.Error)]++
+		c.class(ErrorClass).Record(result.Duration)
+		group.stats.FailedRequests++
+		point.Failures++
+		return
+	}
+
+	report.StatusCodes[result.StatusCode]++
+	if report.Compression != nil {
+		report.Compression.add(result)
+	}
+	if report.SavedBodies != nil {
+		report.SavedBodies.add(result)
+	}
+	if result.GRPCStatus != "" {
+		report.GRPCStatusCodes[result.GRPCStatus]++
+	}
+	report.Distribution.Record(result.Duration)
+	report.Histogram.add(result.Duration)
+	report.Responses++
+	report.MaxResponseSize = max(report.MaxResponseSize, result.Bytes)
+	if c.corrected != nil {
+		c.corrected.Record(result.CorrectedDuration())
+	}
+	for i, d := range []time.Duration{result.Phases.DNS, result.Phases.Connect, result.Phases.TLS, result.Phases.TTFB, result.Phases.Download} {
+		if d > 0 {
+			c.phases[i].Record(d)
+		}
+	}
+	group.latency.Record(result.Duration)
+	c.class(fmt.Sprintf("%dxx", result.StatusCode/100)).Record(result.Duration)
+	c.seconds[second].Record(result.Duration)
+	if result.AssertionError != nil {
+		report.FailedAssertions++
+		report.AssertionFailures[result.AssertionError.Assertion]++
+		group.stats.FailedRequests++
+		point.Failures++
+		return
+	}
+	if c.success.Contains(result.StatusCode) && !result.grpcFailed() {
+		report.SuccessRequests++
+		group.stats.SuccessRequests++
+		if result.Attempts > 1 {
+			report.RecoveredRequests++
+		}
+	} else {
+		point.Failures++
+	}
+}
+
+// addStage counts result in the stage it started in. Requests still running
+// when the last stage ends belong to it.
+func (c *collector) addStage(result Result) {
+	if len(c.stages) == 0 {
+		return
+	}
+	offset := result.Start.Sub(c.start)
+	i := 0
+	for end := c.report.Stages[0].Duration; i < len(c.stages)-1 && offset >= end; end += c.report.Stages[i].Duration {
+		i++
+	}
+	group := c.stages[i]
+	group.stats.TotalRequests++
+	if result.Error != nil || result.AssertionError != nil {
+		group.stats.FailedRequests++
+	} else if c.success.Contains(result.StatusCode) && !result.grpcFailed() {
+		group.stats.SuccessRequests++
+	}
+	if result.Error == nil {
+		group.latency.Record(result.Duration)
+	}
+}
+
+func (c *collector) addWorker(result Result) {
+	i := max(result.Worker, 0)
+	for len(c.workers) <= i {
+		c.workers = append(c.workers, &WorkerStats{Worker: len(c.workers)})
+		c.busy = append(c.busy, 0)
+	}
+	worker := c.workers[i]
+	worker.Requests++
+	if result.Error != nil || result.AssertionError != nil || !c.success.Contains(result.StatusCode) || result.grpcFailed() {
+		worker.Failures++
+	}
+	c.busy[i] += result.Duration
+	worker.MaxLatency = max(worker.MaxLatency, result.Duration)
+	if end := result.Start.Add(result.Duration); end.After(worker.LastResponse) {
+		worker.LastResponse = end
+	}
+}
+
+// second returns the timeline index for at, growing the timeline as needed.
+func (c *collector) second(at time.Time) int {
+	second := int(at.Sub(c.start) / c.report.TimelineInterval)
+	if second < 0 {
+		second = 0
+	}
+	for len(c.report.Timeline) <= second {
+		c.report.Timeline = append(c.report.Timeline, TimelinePoint{})
+		c.seconds = append(c.seconds, &LatencyHistogram{})
+	}
+	return second
+}
+
+func (c *collector) class(name string) *LatencyHistogram {
+	recorder := c.classes[name]
+	if recorder == nil {
+		recorder = &LatencyHistogram{}
+		c.classes[name] = recorder
+	}
+	return recorder
+}
+
+func (c *collector) finish(elapsed time.Duration) *Report {
+	report := c.report
+	report.TotalTime = elapsed
+	report.Latency = report.Distribution.Stats()
+	report.Phases = PhaseStats{
+		DNS:      c.phases[0].Stats(),
+		Connect:  c.phases[1].Stats(),
+		TLS:      c.phases[2].Stats(),
+		TTFB:     c.phases[3].Stats(),
+		Download: c.phases[4].Stats(),
+	}
+	if c.corrected != nil {
+		corrected := c.corrected.Stats()
+		report.CorrectedLatency = &corrected
+	}
+	for _, class := range []string{"1xx", "2xx", "3xx", "4xx", "5xx", ErrorClass} {
+		if recorder := c.classes[class]; recorder != nil {
+			report.StatusClasses = append(report.StatusClasses, StatusClassLatency{Class: class, Requests: int(recorder.Count()), Latency: recorder.Stats()})
+		}
+	}
+	for _, group := range c.groups {
+		group.stats.Latency = group.latency.Stats()
+	}
+	for _, group := range c.stages {
+		group.stats.Latency = group.latency.Stats()
+	}
+	for i, worker := range c.workers {
+		if worker.Requests > 0 {
+			worker.MeanLatency = c.busy[i] / time.Duration(worker.Requests)
+		}
+		report.Workers = append(report.Workers, *worker)
+	}
+	for i, recorder := range c.seconds {
+		report.Timeline[i].P95 = recorder.Quantile(0.95)
+	}
+	return report
+}
@@ -0,0 +1,115 @@
+package loadtest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// RetryPolicy retries a failed request up to Retries times, like a client
+// library would. The n-th retry waits Backoff·2^(n-1), up to
+// maxRetryBackoff (or Backoff, when longer). Statuses lists the
+// response codes that are retried; Timeouts and Errors retry timeouts and any
+// other transport error. Failed assertions are never retried.
+type RetryPolicy struct {
+	Retries  int
+	Backoff  time.Duration
+	Statuses StatusSet
+	Timeouts bool
+	Errors   bool
+	spec     string
+}
+
+// maxRetryBackoff bounds the wait between retries once the doubling
+// backoff grows past it.
+const maxRetryBackoff = 30 * time.Second
+
+// DefaultRetryOn is the retry condition used when none is given.
+const DefaultRetryOn = "502,503,504,timeout"
+
+// ParseRetryPolicy builds a RetryPolicy from conditions such as
+// "502,503,timeout". Besides status codes, ranges and classes it accepts
+// "timeout" and "error", any other transport error.
+func ParseRetryPolicy(retries int, backoff time.Duration, on string) (RetryPolicy, error) {
+	policy := RetryPolicy{Retries: retries, Backoff: backoff, spec: on}
+	if retries < 0 || backoff < 0 {
+		return RetryPolicy{}, fmt.Errorf("retentativas e backoff não podem ser negativos")
+	}
+	var codes []string
+	for _, part := range strings.Split(on, ",") {
+		switch part = strings.ToLower(strings.TrimSpace(part)); part {
+		case "":
+		case "timeout":
+			policy.Timeouts = true
+		case "error":
+			policy.Errors = true
+		default:
+			codes = append(codes, part)
+		}
+	}
+	if len(codes) > 0 {
+		statuses, err := ParseStatusSet(strings.Join(codes, ","))
+		if err != nil {
+			return RetryPolicy{}, err
+		}
+		policy.Statuses = statuses
+	} else if !policy.Timeouts && !policy.Errors {
+		return RetryPolicy{}, fmt.Errorf("nenhuma condição de retentativa informada")
+	}
+	return policy, nil
+}
+
+// String returns the retry conditions, e.g. "502,503,timeout".
+func (p RetryPolicy) String() string {
+	return p.spec
+}
+
+// backoff returns the wait before the n-th retry, doubling Backoff without
+// overflowing.
+func (p RetryPolicy) backoff(n int) time.Duration {
+	limit := max(p.Backoff, maxRetryBackoff)
+	d := p.Backoff
+	for i := 1; i < n && d < limit; i++ {
+		d *= 2
+	}
+	return min(d, limit)
+}
+
+func (p RetryPolicy) retryable(result Result) bool {
+	if result.Error != nil {
+		if isTimeout(result.Error) {
+			return p.Timeouts
+		}
+		return p.Errors
+	}
+	return p.Statuses.Contains(result.StatusCode)
+}
+
+// send runs step, retrying it as the retry policy allows. The result is the
+// one of the last attempt, but its latency spans every attempt and backoff,
+// as seen by the client.
+func (r *Runner) send(ctx context.Context, client *http.Client, step Step, vars map[string]string) Result {
+	result := r.do(ctx, client, step, vars)
+	result.Attempts = 1
+	if r.retry == nil {
+		return result
+	}
+	start := result.Start
+	for result.Attempts <= r.retry.Retries && r.retry.retryable(result) && ctx.Err() == nil {
+		timer := time.NewTimer(r.retry.backoff(result.Attempts))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return result
+		case <-timer.C:
+		}
+		attempts := result.Attempts + 1
+		result = r.do(ctx, client, step, vars)
+		result.Attempts = attempts
+		result.Start = start
+		result.Duration = time.Since(start)
+	}
+	return result
+}
@@ -0,0 +1,60 @@
+package loadtest
+
+import (
+	"bytes"
+	"net/http"
+	"time"
+)
+
+// maxSampleBody is how much of the body a FailureSample keeps.
+const maxSampleBody = 2 << 10
+
+// FailureSample is one of the first failed requests of a run, kept with
+// WithFailureSamples so that a failure can be understood from the report.
+// Requests that failed without a response have no Status, Header and Body.
+type FailureSample struct {
+	Time          time.Time
+	Step          string
+	Method        string
+	URL           string
+	Status        int
+	Failure       string
+	Header        http.Header
+	Body          []byte
+	BodyTruncated bool
+}
+
+func newFailureSample(step Step, resp *http.Response, body []byte, failure string) *FailureSample {
+	sample := &FailureSample{
+		Step:    step.Name,
+		Method:  resp.Request.Method,
+		URL:     resp.Request.URL.String(),
+		Status:  resp.StatusCode,
+		Failure: failure,
+		Header:  resp.Header,
+	}
+	if len(body) > maxSampleBody {
+		body, sample.BodyTruncated = body[:maxSampleBody], true
+	}
+	// The sample outlives the buffer of the body, which may be much larger.
+	sample.Body = bytes.Clone(body)
+	return sample
+}
+
+// addSample keeps result as a FailureSample while the report has fewer than
+// the limit. Requests that got a response carry a sample with its headers
+// and body, made by the worker.
+func (c *collector) addSample(result Result) {
+	if len(c.report.FailureSamples) >= c.samples {
+		return
+	}
+	sample := result.Sample
+	if sample == nil {
+		if result.Error == nil {
+			return
+		}
+		sample = &FailureSample{Step: result.Step, Method: result.Method, URL: result.Target, Failure: result.Error.Error()}
+	}
+	sample.Time = result.Start
+	c.report.FailureSamples = append(c.report.FailureSamples, *sample)
+}
@@ -0,0 +1,38 @@
+package loadtest
+
+import "fmt"
+
+// generatorWarnings flags signs that the load generator, rather than the
+// target, limited the run, so its numbers shouldn't be read as the capacity
+// of the target.
+func (r *Runner) generatorWarnings(report *Report) []string {
+	var warnings []string
+	if r.limits.MaxConnsPerHost > 0 && r.limits.MaxConnsPerHost < r.maxVUs {
+		warnings = append(warnings, fmt.Sprintf("o limite de %d conexões por host é menor que os %d workers: requests esperaram por uma conexão livre",
+			r.limits.MaxConnsPerHost, r.maxVUs))
+	}
+	if !r.disableKeepAlive && r.protocol == ProtocolHTTP1 && report.NewConnections > 2*r.maxVUs+10 {
+		warnings = append(warnings, fmt.Sprintf("%d conexões novas para %d workers: o pool descartou conexões ociosas; aumente o limite de conexões ociosas por host",
+			report.NewConnections, r.maxVUs))
+	}
+	if report.DroppedIterations > 0 {
+		warnings = append(warnings, fmt.Sprintf("%d iterações descartadas sem VU livre: aumente o máximo de VUs", report.DroppedIterations))
+	}
+	if r.rate > 0 && !report.Interrupted && report.TotalTime >= r.resolution {
+		if achieved := float64(report.Iterations) / report.TotalTime.Seconds(); achieved < 0.9*r.rate {
+			warnings = append(warnings, fmt.Sprintf("taxa atingida (%.2f/s) abaixo de 90%% da taxa alvo (%.2f/s): workers insuficientes ou gerador saturado",
+				achieved, r.rate))
+		}
+	}
+	if g := report.Generator; g != nil {
+		if g.CPUs > 0 && g.CPUPercent >= 85*float64(g.CPUs) {
+			warnings = append(warnings, fmt.Sprintf("o gerador usou em média %.0f%% de CPU com %d núcleos disponíveis: a máquina de carga, e não o alvo, pode ter sido o gargalo",
+				g.CPUPercent, g.CPUs))
+		}
+		if report.TotalTime > 0 && g.GCPauseTotal > report.TotalTime/20 {
+			warnings = append(warnings, fmt.Sprintf("pausas do GC do gerador somaram %v (%.1f%% do teste): latências medidas incluem essas pausas",
+				g.GCPauseTotal, 100*g.GCPauseTotal.Seconds()/report.TotalTime.Seconds()))
+		}
+	}
+	return append(warnings, r.portWarnings(report)...)
+}
@@ -0,0 +1,113 @@
+package loadtest
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Step is a single request of a Scenario. Headers and Assertions are applied
+// on top of the ones configured on the Runner. URL, header values and Body may
+// reference variables set by the Captures of previous steps, e.g. {{.token}}.
+// Stream, when set, is sent instead of Body.
+type Step struct {
+	Name        string
+	Method      string
+	URL         string
+	Headers     http.Header
+	Body        []byte
+	Stream      BodyStream
+	ContentType string
+	Assertions  []Assertion
+	Captures    []Capture
+	// Offset delays the step until that long after the iteration started,
+	// e.g. to keep the pacing of a recorded session. A step is never sent
+	// before the previous one finished.
+	Offset time.Duration
+
+	tmpl *stepTemplate
+}
+
+// Scenario is an ordered sequence of steps that every worker executes as a
+// virtual user. When a scenario is set, requests count scenario iterations and
+// an iteration stops at the first step that fails.
+type Scenario struct {
+	Name  string
+	Steps []Step
+}
+
+// normalize validates the steps and gives each one the headers of the Runner,
+// so that their templates are rendered along with the step's own.
+func (s *Scenario) normalize(headers http.Header) error {
+	if len(s.Steps) == 0 {
+		return errors.New("cenário sem passos")
+	}
+
+	steps := make([]Step, len(s.Steps))
+	names := make(map[string]bool, len(steps))
+	for i, step := range s.Steps {
+		if step.URL == "" {
+			return fmt.Errorf("passo %d do cenário sem URL", i+1)
+		}
+		if step.Method == "" {
+			step.Method = http.MethodGet
+		}
+		step.Method = strings.ToUpper(step.Method)
+		if step.Name == "" {
+			step.Name = step.Method + " " + step.URL
+		}
+		if names[step.Name] {
+			return fmt.Errorf("passo %q duplicado no cenário", step.Name)
+		}
+		names[step.Name] = true
+		step.Headers = mergeHeaders(headers, step.Headers)
+		if err := step.parseTemplates(); err != nil {
+			return fmt.Errorf("passo %q: %w", step.Name, err)
+		}
+		steps[i] = step
+	}
+	s.Steps = steps
+	return nil
+}
+
+// mergeHeaders returns the headers of the Runner overridden, name by name, by
+// the ones of a step.
+func mergeHeaders(runner, step http.Header) http.Header {
+	if len(runner) == 0 {
+		return step
+	}
+	merged := runner.Clone()
+	for key, values := range step {
+		merged[key] = values
+	}
+	return merged
+}
+
+// targetSteps turns every weighted target into a single step, so that both
+// modes share the same request path.
+func (r *Runner) targetSteps() ([]Step, error) {
+	steps := make([]Step, len(r.targets))
+	for i, target := range r.targets {
+		steps[i] = Step{
+			Name:        target.Name,
+			Method:      r.method,
+			URL:         target.URL,
+			Headers:     mergeHeaders(r.headers, target.Headers),
+			Body:        r.body,
+			Stream:      r.stream,
+			ContentType: r.contentType,
+		}
+		if target.Method != "" {
+			steps[i].Method = strings.ToUpper(target.Method)
+		}
+		if target.Body != nil {
+			steps[i].Body, steps[i].Stream, steps[i].ContentType = target.Body, nil, target.ContentType
+		}
+		if err := steps[i].parseTemplates(); err != nil {
+			return nil, fmt.Errorf("alvo %s: %w", target.URL, err)
+		}
+	}
+	return steps, nil
+}
@@ -0,0 +1,126 @@
+package loadtest
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// SocketTest stresses a TCP or UDP service that doesn't speak HTTP. Every
+// iteration opens a connection to Address, sends Payload when it is set and,
+// with ReadReply, waits for the first bytes of the response before closing
+// the connection. UDP has no handshake, so its connect time only covers the
+// socket setup.
+type SocketTest struct {
+	Network     string
+	Address     string
+	Concurrency int
+	Requests    int
+	Duration    time.Duration
+	Rate        float64
+	Payload     []byte
+	ReadReply   bool
+	Timeout     time.Duration
+}
+
+func (t *SocketTest) validate() error {
+	if t.Network != "tcp" && t.Network != "udp" {
+		return fmt.Errorf("rede inválida %q, use tcp ou udp", t.Network)
+	}
+	if _, _, err := net.SplitHostPort(t.Address); err != nil {
+		return fmt.Errorf("endereço inválido %q, use host:porta", t.Address)
+	}
+	if t.Concurrency <= 0 {
+		return fmt.Errorf("a concorrência deve ser maior que 0")
+	}
+	if t.Requests < 0 || t.Duration < 0 || t.Rate < 0 {
+		return fmt.Errorf("requests, duração e taxa não podem ser negativos")
+	}
+	if t.Requests == 0 && t.Duration == 0 {
+		return fmt.Errorf("informe o número de requests, a duração ou ambos")
+	}
+	if t.Network == "udp" && len(t.Payload) == 0 {
+		return fmt.Errorf("testes UDP exigem um payload")
+	}
+	return nil
+}
+
+// Run executes the test. It returns once Requests connections were made,
+// Duration elapsed or ctx is canceled.
+func (t *SocketTest) Run(ctx context.Context) (*ConnectionReport, error) {
+	if err := t.validate(); err != nil {
+		return nil, err
+	}
+	if t.Duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, t.Duration)
+		defer cancel()
+	}
+
+	stats := newConnectionStats(0)
+	jobs := make(chan time.Time)
+	start := time.Now()
+	go dispatch(ctx, jobs, t.Requests, constantRate(t.Rate))
+
+	var wg sync.WaitGroup
+	for i := 0; i < t.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			dialer := &net.Dialer{Timeout: t.Timeout}
+			buf := make([]byte, 64*1024)
+			for range jobs {
+				if ctx.Err() != nil {
+					return
+				}
+				t.iterate(ctx, dialer, stats, buf)
+			}
+		}()
+	}
+	wg.Wait()
+	return stats.finish(time.Since(start)), nil
+}
+
+func (t *SocketTest) iterate(ctx context.Context, dialer *net.Dialer, stats *connectionStats, buf []byte) {
+	dialStart := time.Now()
+	conn, err := dialer.DialContext(ctx, t.Network, t.Address)
+	if err != nil {
+		if !stopped(ctx) {
+			stats.attempted()
+			stats.failed(err)
+		}
+		return
+	}
+	defer conn.Close()
+	stats.attempted()
+	stats.connected(time.Since(dialStart))
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+	if len(t.Payload) == 0 {
+		return
+	}
+
+	if t.Timeout > 0 {
+		conn.SetDeadline(time.Now().Add(t.Timeout))
+	}
+	sendStart := time.Now()
+	if _, err := conn.Write(t.Payload); err != nil {
+		if !stopped(ctx) {
+			stats.failed(err)
+		}
+		return
+	}
+	stats.sent()
+	if !t.ReadReply {
+		return
+	}
+	if _, err := conn.Read(buf); err != nil {
+		if !stopped(ctx) {
+			stats.failed(err)
+		}
+		return
+	}
+	stats.received(time.Since(sendStart))
+}
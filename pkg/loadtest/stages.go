@@ -0,0 +1,112 @@
+package loadtest
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Stage is one step of a staged load profile. During Duration the arrival
+// rate moves linearly from the rate of the previous stage (0 for the first
+// one) to Rate, so consecutive stages with the same Rate sustain the load.
+type Stage struct {
+	Duration time.Duration
+	Rate     float64
+}
+
+// StageReport breaks down the requests started during one stage.
+type StageReport struct {
+	Stage
+	StartRate float64
+	RequestStats
+}
+
+func (s StageReport) RequestsPerSecond() float64 {
+	if s.Duration <= 0 {
+		return 0
+	}
+	return float64(s.TotalRequests) / s.Duration.Seconds()
+}
+
+// ParseStages parses a profile such as "1m:50rps,5m:200rps,1m:0rps".
+func ParseStages(spec string) ([]Stage, error) {
+	var stages []Stage
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		rawDuration, rawRate, ok := strings.Cut(part, ":")
+		if !ok {
+			return nil, fmt.Errorf("estágio %q inválido, use o formato duração:taxa (ex: 1m:50rps)", part)
+		}
+		duration, err := time.ParseDuration(strings.TrimSpace(rawDuration))
+		if err != nil || duration <= 0 {
+			return nil, fmt.Errorf("estágio %q: duração inválida %q", part, rawDuration)
+		}
+		rate, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(rawRate), "rps"), 64)
+		if err != nil || rate < 0 {
+			return nil, fmt.Errorf("estágio %q: taxa inválida %q", part, rawRate)
+		}
+		stages = append(stages, Stage{Duration: duration, Rate: rate})
+	}
+	if len(stages) == 0 {
+		return nil, fmt.Errorf("nenhum estágio informado")
+	}
+	return stages, nil
+}
+
+func validateStages(stages []Stage) error {
+	var peak float64
+	for i, stage := range stages {
+		if stage.Duration <= 0 {
+			return fmt.Errorf("a duração do estágio %d deve ser maior que 0", i+1)
+		}
+		if stage.Rate < 0 {
+			return fmt.Errorf("a taxa do estágio %d não pode ser negativa", i+1)
+		}
+		peak = math.Max(peak, stage.Rate)
+	}
+	if peak == 0 {
+		return fmt.Errorf("ao menos um estágio deve ter taxa maior que 0")
+	}
+	return nil
+}
+
+func stagesDuration(stages []Stage) time.Duration {
+	var total time.Duration
+	for _, stage := range stages {
+		total += stage.Duration
+	}
+	return total
+}
+
+// stageSchedule places the i-th arrival where the integral of the rate
+// profile reaches i.
+func stageSchedule(stages []Stage) schedule {
+	return func(i int) (time.Duration, bool) {
+		k := float64(i)
+		var from float64
+		var elapsed time.Duration
+		for _, stage := range stages {
+			seconds := stage.Duration.Seconds()
+			count := (from + stage.Rate) / 2 * seconds
+			if k < count {
+				// Solves from·t + a·t² = k for the linear ramp, in a form that
+				// is stable when a is close to 0.
+				var t float64
+				if k > 0 {
+					a := (stage.Rate - from) / (2 * seconds)
+					t = 2 * k / (from + math.Sqrt(math.Max(0, from*from+4*a*k)))
+				}
+				return elapsed + time.Duration(t*float64(time.Second)), true
+			}
+			k -= count
+			from = stage.Rate
+			elapsed += stage.Duration
+		}
+		return 0, false
+	}
+}
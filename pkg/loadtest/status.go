@@ -0,0 +1,89 @@
+package loadtest
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type statusRange struct {
+	min, max int
+}
+
+// StatusSet is a set of HTTP status codes built from exact codes (200),
+// ranges (200-299) and classes (2xx).
+type StatusSet struct {
+	spec   string
+	ranges []statusRange
+}
+
+var DefaultSuccessCodes = StatusSet{spec: "200", ranges: []statusRange{{200, 200}}}
+
+func ParseStatusSet(spec string) (StatusSet, error) {
+	set := StatusSet{spec: spec}
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.ToLower(strings.TrimSpace(part))
+		if part == "" {
+			continue
+		}
+
+		var r statusRange
+		switch {
+		case len(part) == 3 && strings.HasSuffix(part, "xx"):
+			class, err := strconv.Atoi(part[:1])
+			if err != nil || class < 1 || class > 5 {
+				return StatusSet{}, fmt.Errorf("classe de status inválida: %q", part)
+			}
+			r = statusRange{class * 100, class*100 + 99}
+		case strings.Contains(part, "-"):
+			lo, hi, _ := strings.Cut(part, "-")
+			min, err1 := strconv.Atoi(lo)
+			max, err2 := strconv.Atoi(hi)
+			if err1 != nil || err2 != nil || min > max {
+				return StatusSet{}, fmt.Errorf("intervalo de status inválido: %q", part)
+			}
+			r = statusRange{min, max}
+		default:
+			code, err := strconv.Atoi(part)
+			if err != nil {
+				return StatusSet{}, fmt.Errorf("código de status inválido: %q", part)
+			}
+			r = statusRange{code, code}
+		}
+		if r.min < 100 || r.max > 599 {
+			return StatusSet{}, fmt.Errorf("código de status fora do intervalo 100-599: %q", part)
+		}
+		set.ranges = append(set.ranges, r)
+	}
+	if len(set.ranges) == 0 {
+		return StatusSet{}, fmt.Errorf("nenhum código de status informado")
+	}
+	return set, nil
+}
+
+func (s StatusSet) Contains(code int) bool {
+	for _, r := range s.ranges {
+		if code >= r.min && code <= r.max {
+			return true
+		}
+	}
+	return false
+}
+
+// ContainsClass tells whether every code of class, e.g. 2xx, is in the set.
+func (s StatusSet) ContainsClass(class string) bool {
+	if len(class) != 3 || class[0] < '1' || class[0] > '5' {
+		return false
+	}
+	base := int(class[0]-'0') * 100
+	for code := base; code < base+100; code++ {
+		if !s.Contains(code) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s StatusSet) String() string {
+	return s.spec
+}
@@ -0,0 +1,64 @@
+package loadtest
+
+import (
+	"io"
+	"math/rand"
+	"sync/atomic"
+)
+
+// syntheticBlock is repeated to fill synthetic bodies. It is random so that
+// the body doesn't shrink when a proxy or the server compresses it.
+var syntheticBlock = func() []byte {
+	block := make([]byte, 32<<10)
+	rand.New(rand.NewSource(1)).Read(block)
+	return block
+}()
+
+// SyntheticBody returns a BodyStream of size bytes generated while the
+// request is sent, to stress upload paths without holding the body in memory.
+// A chunked body is sent with Transfer-Encoding: chunked instead of
+// Content-Length.
+func SyntheticBody(size int64, chunked bool) BodyStream {
+	return func() (io.ReadCloser, int64, error) {
+		body := io.NopCloser(&syntheticReader{remaining: size})
+		if chunked {
+			return body, -1, nil
+		}
+		return body, size, nil
+	}
+}
+
+type syntheticReader struct {
+	remaining int64
+	offset    int
+}
+
+func (s *syntheticReader) Read(p []byte) (int, error) {
+	if s.remaining <= 0 {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > s.remaining {
+		p = p[:s.remaining]
+	}
+	var n int
+	for n < len(p) {
+		copied := copy(p[n:], syntheticBlock[s.offset:])
+		n += copied
+		s.offset = (s.offset + copied) % len(syntheticBlock)
+	}
+	s.remaining -= int64(n)
+	return n, nil
+}
+
+// countingBody counts the bytes of a request body read by the transport,
+// which may still be sending it when the response arrives.
+type countingBody struct {
+	io.ReadCloser
+	n atomic.Int64
+}
+
+func (c *countingBody) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.n.Add(int64(n))
+	return n, err
+}
@@ -0,0 +1,38 @@
+package loadtest
+
+import (
+	"math/rand"
+	"net/http"
+)
+
+// Target is a weighted URL. Method, Headers, Body and ContentType override
+// the ones configured on the Runner, so targets can describe different
+// requests; Name then identifies the target in the report.
+type Target struct {
+	URL    string
+	Weight int
+
+	Name        string
+	Method      string
+	Headers     http.Header
+	Body        []byte
+	ContentType string
+}
+
+func pickTarget(targets []Target) int {
+	if len(targets) == 1 {
+		return 0
+	}
+	totalWeight := 0
+	for _, target := range targets {
+		totalWeight += target.Weight
+	}
+	n := rand.Intn(totalWeight)
+	for i, target := range targets {
+		if n < target.Weight {
+			return i
+		}
+		n -= target.Weight
+	}
+	return len(targets) - 1
+}
@@ -0,0 +1,166 @@
+package loadtest
+
+import (
+	"crypto/rand"
+	"fmt"
+	mathrand "math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const randStringAlphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+var timeLayouts = map[string]string{
+	"RFC3339":     time.RFC3339,
+	"RFC3339Nano": time.RFC3339Nano,
+	"RFC1123":     time.RFC1123,
+	"Kitchen":     time.Kitchen,
+	"DateTime":    "2006-01-02 15:04:05",
+	"DateOnly":    "2006-01-02",
+}
+
+// templateFuncs are available in every URL, header and body template.
+var templateFuncs = template.FuncMap{
+	"uuid":       uuid,
+	"randInt":    randInt,
+	"randString": randString,
+	"now":        now,
+}
+
+func uuid() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// randInt returns a random integer in [min, max].
+func randInt(min, max int) (int, error) {
+	if max < min {
+		return 0, fmt.Errorf("randInt: max %d menor que min %d", max, min)
+	}
+	return min + mathrand.Intn(max-min+1), nil
+}
+
+func randString(n int) string {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = randStringAlphabet[mathrand.Intn(len(randStringAlphabet))]
+	}
+	return string(b)
+}
+
+// now formats the current time with a named layout (RFC3339, DateOnly...),
+// "unix", "unixMilli" or any Go reference layout. It defaults to RFC3339.
+func now(layout ...string) string {
+	t := time.Now()
+	if len(layout) == 0 {
+		return t.Format(time.RFC3339)
+	}
+	switch layout[0] {
+	case "unix":
+		return strconv.FormatInt(t.Unix(), 10)
+	case "unixMilli":
+		return strconv.FormatInt(t.UnixMilli(), 10)
+	}
+	if named, ok := timeLayouts[layout[0]]; ok {
+		return t.Format(named)
+	}
+	return t.Format(layout[0])
+}
+
+// stepTemplate holds the parsed placeholders of a Step, so that it is only
+// rendered when one of its fields references a variable.
+type stepTemplate struct {
+	url     *template.Template
+	body    *template.Template
+	headers map[string][]*template.Template
+}
+
+func parseTemplate(name, text string) (*template.Template, error) {
+	if !strings.Contains(text, "{{") {
+		return nil, nil
+	}
+	tmpl, err := template.New(name).Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("template inválido em %s: %w", name, err)
+	}
+	return tmpl, nil
+}
+
+func (s *Step) parseTemplates() error {
+	t := &stepTemplate{headers: make(map[string][]*template.Template)}
+
+	var err error
+	if t.url, err = parseTemplate("url", s.URL); err != nil {
+		return err
+	}
+	if t.body, err = parseTemplate("body", string(s.Body)); err != nil {
+		return err
+	}
+	templated := t.url != nil || t.body != nil
+	for key, values := range s.Headers {
+		tmpls := make([]*template.Template, len(values))
+		for i, value := range values {
+			if tmpls[i], err = parseTemplate("header "+key, value); err != nil {
+				return err
+			}
+			templated = templated || tmpls[i] != nil
+		}
+		t.headers[key] = tmpls
+	}
+
+	if templated {
+		s.tmpl = t
+	}
+	return nil
+}
+
+func executeTemplate(tmpl *template.Template, vars map[string]string) (string, error) {
+	var b strings.Builder
+	if err := tmpl.Execute(&b, vars); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// render returns a copy of the step with its placeholders replaced by vars.
+func (s Step) render(vars map[string]string) (Step, error) {
+	if s.tmpl == nil {
+		return s, nil
+	}
+
+	var err error
+	if s.tmpl.url != nil {
+		if s.URL, err = executeTemplate(s.tmpl.url, vars); err != nil {
+			return s, err
+		}
+	}
+	if s.tmpl.body != nil {
+		body, err := executeTemplate(s.tmpl.body, vars)
+		if err != nil {
+			return s, err
+		}
+		s.Body = []byte(body)
+	}
+
+	headers := make(http.Header, len(s.Headers))
+	for key, values := range s.Headers {
+		rendered := make([]string, len(values))
+		for i, value := range values {
+			rendered[i] = value
+			if tmpl := s.tmpl.headers[key][i]; tmpl != nil {
+				if rendered[i], err = executeTemplate(tmpl, vars); err != nil {
+					return s, err
+				}
+			}
+		}
+		headers[key] = rendered
+	}
+	s.Headers = headers
+	return s, nil
+}
@@ -0,0 +1,155 @@
+package loadtest
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type thresholdMetric struct {
+	kind  string
+	value func(*Report) float64
+}
+
+const (
+	metricPercent  = "percent"
+	metricDuration = "duration"
+	metricNumber   = "number"
+)
+
+var thresholdMetrics = map[string]thresholdMetric{
+	"error-rate":   {metricPercent, func(r *Report) float64 { return r.ErrorRate() }},
+	"success-rate": {metricPercent, func(r *Report) float64 { return r.SuccessRate() }},
+	"rps":          {metricNumber, func(r *Report) float64 { return r.RequestsPerSecond() }},
+	"errors":       {metricNumber, func(r *Report) float64 { return float64(r.TotalRequests - r.SuccessRequests) }},
+	"timeouts":     {metricNumber, func(r *Report) float64 { return float64(r.Timeouts) }},
+	"mean":         {metricDuration, func(r *Report) float64 { return float64(r.Latency.Mean) }},
+	"max":          {metricDuration, func(r *Report) float64 { return float64(r.Latency.Max) }},
+	"p50":          {metricDuration, func(r *Report) float64 { return float64(r.Latency.P50) }},
+	"p90":          {metricDuration, func(r *Report) float64 { return float64(r.Latency.P90) }},
+	"p95":          {metricDuration, func(r *Report) float64 { return float64(r.Latency.P95) }},
+	"p99":          {metricDuration, func(r *Report) float64 { return float64(r.Latency.P99) }},
+}
+
+// Threshold is a failure condition such as "error-rate>1%", "p95>500ms" or
+// "rps<100". The threshold is violated when the condition holds.
+type Threshold struct {
+	expr     string
+	metric   thresholdMetric
+	operator string
+	limit    float64
+}
+
+type ThresholdResult struct {
+	Expression string
+	Actual     string
+	Violated   bool
+}
+
+func ParseThreshold(expr string) (Threshold, error) {
+	compact := strings.ReplaceAll(expr, " ", "")
+	i := strings.IndexAny(compact, "<>")
+	if i <= 0 {
+		return Threshold{}, fmt.Errorf("threshold %q inválido, use o formato métrica>valor ou métrica<valor", expr)
+	}
+
+	name, operator, raw := compact[:i], compact[i:i+1], compact[i+1:]
+	if strings.HasPrefix(raw, "=") {
+		operator += "="
+		raw = raw[1:]
+	}
+
+	metric, ok := thresholdMetrics[name]
+	if !ok {
+		metric, ok = quantileMetric(name)
+	}
+	if !ok {
+		metric, ok = statusClassMetric(name)
+	}
+	if !ok {
+		return Threshold{}, fmt.Errorf("threshold %q: métrica desconhecida %q", expr, name)
+	}
+
+	var limit float64
+	var err error
+	switch metric.kind {
+	case metricPercent:
+		limit, err = strconv.ParseFloat(strings.TrimSuffix(raw, "%"), 64)
+	case metricDuration:
+		var d time.Duration
+		d, err = time.ParseDuration(raw)
+		limit = float64(d)
+	default:
+		limit, err = strconv.ParseFloat(raw, 64)
+	}
+	if err != nil {
+		return Threshold{}, fmt.Errorf("threshold %q: valor inválido %q", expr, raw)
+	}
+
+	return Threshold{expr: compact, metric: metric, operator: operator, limit: limit}, nil
+}
+
+// quantileMetric accepts any percentile written as p<n>, e.g. p99.99 or p75,
+// and reads it from the latency distribution of the report.
+func quantileMetric(name string) (thresholdMetric, bool) {
+	p, err := strconv.ParseFloat(strings.TrimPrefix(name, "p"), 64)
+	if !strings.HasPrefix(name, "p") || err != nil || !(p > 0 && p <= 100) {
+		return thresholdMetric{}, false
+	}
+	return thresholdMetric{metricDuration, func(r *Report) float64 {
+		return float64(r.Distribution.Quantile(p / 100))
+	}}, true
+}
+
+// statusClassMetric accepts the percentage of responses in a status class,
+// written as <class>-rate, e.g. 5xx-rate or 2xx-rate.
+func statusClassMetric(name string) (thresholdMetric, bool) {
+	class, ok := strings.CutSuffix(name, "-rate")
+	if !ok || len(class) != 3 || class[0] < '1' || class[0] > '5' || class[1:] != "xx" {
+		return thresholdMetric{}, false
+	}
+	return thresholdMetric{metricPercent, func(r *Report) float64 {
+		return r.StatusClassRate(class)
+	}}, true
+}
+
+func (t Threshold) String() string {
+	return t.expr
+}
+
+func (t Threshold) Evaluate(report *Report) ThresholdResult {
+	actual := t.metric.value(report)
+
+	var violated bool
+	switch t.operator {
+	case ">":
+		violated = actual > t.limit
+	case ">=":
+		violated = actual >= t.limit
+	case "<":
+		violated = actual < t.limit
+	case "<=":
+		violated = actual <= t.limit
+	}
+
+	result := ThresholdResult{Expression: t.expr, Violated: violated}
+	switch t.metric.kind {
+	case metricPercent:
+		result.Actual = fmt.Sprintf("%.2f%%", actual)
+	case metricDuration:
+		result.Actual = time.Duration(actual).String()
+	default:
+		result.Actual = strconv.FormatFloat(actual, 'f', 2, 64)
+	}
+	return result
+}
+
+func (r *Report) ThresholdsViolated() bool {
+	for _, result := range r.Thresholds {
+		if result.Violated {
+			return true
+		}
+	}
+	return false
+}
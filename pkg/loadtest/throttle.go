@@ -0,0 +1,168 @@
+package loadtest
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// bandwidthProfiles are the network profiles accepted by ParseBandwidth,
+// with the download speeds of the Chrome DevTools presets.
+var bandwidthProfiles = map[string]float64{
+	"slow-3g": 400e3 / 8,
+	"fast-3g": 1.6e6 / 8,
+	"4g":      9e6 / 8,
+}
+
+// ParseBandwidth parses a bandwidth such as "64KB" (bytes per second),
+// "1.5Mbps" (bits per second) or one of the profiles slow-3g, fast-3g and 4g,
+// and returns it in bytes per second.
+func ParseBandwidth(s string) (float64, error) {
+	spec := strings.ToLower(strings.TrimSuffix(strings.TrimSpace(s), "/s"))
+	if rate, ok := bandwidthProfiles[spec]; ok {
+		return rate, nil
+	}
+	unit := 1.0
+	if strings.HasSuffix(spec, "bps") {
+		spec, unit = strings.TrimSuffix(spec, "bps"), 1.0/8
+	} else {
+		spec = strings.TrimSuffix(spec, "b")
+	}
+	for suffix, multiplier := range map[string]float64{"k": 1e3, "m": 1e6, "g": 1e9} {
+		if strings.HasSuffix(spec, suffix) {
+			spec, unit = strings.TrimSuffix(spec, suffix), unit*multiplier
+			break
+		}
+	}
+	value, err := strconv.ParseFloat(spec, 64)
+	if err != nil || value <= 0 {
+		return 0, fmt.Errorf("banda inválida %q, use por exemplo 64KB, 1.5Mbps ou slow-3g", s)
+	}
+	return value * unit, nil
+}
+
+// throttledConn limits the read and write throughput of a connection, like a
+// client on a slow link. Each direction gets the full bandwidth. The pauses
+// end at the deadlines of the connection, and when it is closed, which is how
+// the transport cancels a request when the run stops.
+type throttledConn struct {
+	net.Conn
+	read, write *bandwidthLimiter
+
+	mu            sync.Mutex
+	readDeadline  time.Time
+	writeDeadline time.Time
+	closeOnce     sync.Once
+	closed        chan struct{}
+}
+
+func newThrottledConn(conn net.Conn, rate float64) net.Conn {
+	return &throttledConn{Conn: conn, read: newBandwidthLimiter(rate), write: newBandwidthLimiter(rate), closed: make(chan struct{})}
+}
+
+func (c *throttledConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p[:min(len(p), c.read.chunk)])
+	c.mu.Lock()
+	deadline := c.readDeadline
+	c.mu.Unlock()
+	if waitErr := c.read.wait(n, deadline, c.closed); err == nil {
+		err = waitErr
+	}
+	return n, err
+}
+
+func (c *throttledConn) Write(p []byte) (int, error) {
+	var written int
+	for len(p) > 0 {
+		n, err := c.Conn.Write(p[:min(len(p), c.write.chunk)])
+		written += n
+		c.mu.Lock()
+		deadline := c.writeDeadline
+		c.mu.Unlock()
+		if waitErr := c.write.wait(n, deadline, c.closed); err == nil {
+			err = waitErr
+		}
+		if err != nil {
+			return written, err
+		}
+		p = p[n:]
+	}
+	return written, nil
+}
+
+func (c *throttledConn) Close() error {
+	c.closeOnce.Do(func() { close(c.closed) })
+	return c.Conn.Close()
+}
+
+func (c *throttledConn) SetDeadline(t time.Time) error {
+	c.mu.Lock()
+	c.readDeadline, c.writeDeadline = t, t
+	c.mu.Unlock()
+	return c.Conn.SetDeadline(t)
+}
+
+func (c *throttledConn) SetReadDeadline(t time.Time) error {
+	c.mu.Lock()
+	c.readDeadline = t
+	c.mu.Unlock()
+	return c.Conn.SetReadDeadline(t)
+}
+
+func (c *throttledConn) SetWriteDeadline(t time.Time) error {
+	c.mu.Lock()
+	c.writeDeadline = t
+	c.mu.Unlock()
+	return c.Conn.SetWriteDeadline(t)
+}
+
+// bandwidthLimiter paces transfers to rate bytes per second. Transfers are
+// split in chunks of about 100ms, so that the pace is smooth.
+type bandwidthLimiter struct {
+	rate  float64
+	chunk int
+
+	mu   sync.Mutex
+	next time.Time
+}
+
+func newBandwidthLimiter(rate float64) *bandwidthLimiter {
+	return &bandwidthLimiter{rate: rate, chunk: max(int(rate/10), 512)}
+}
+
+// wait blocks until n more bytes fit in the bandwidth. It gives up with
+// os.ErrDeadlineExceeded at deadline, unless it is zero, and with
+// net.ErrClosed once closed is closed.
+func (l *bandwidthLimiter) wait(n int, deadline time.Time, closed <-chan struct{}) error {
+	if n <= 0 {
+		return nil
+	}
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	l.next = l.next.Add(time.Duration(float64(n) / l.rate * float64(time.Second)))
+	until := l.next
+	l.mu.Unlock()
+
+	var err error
+	if !deadline.IsZero() && deadline.Before(until) {
+		until, err = deadline, os.ErrDeadlineExceeded
+	}
+	if !until.After(now) {
+		return err
+	}
+	timer := time.NewTimer(until.Sub(now))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return err
+	case <-closed:
+		return net.ErrClosed
+	}
+}
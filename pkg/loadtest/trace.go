@@ -0,0 +1,125 @@
+package loadtest
+
+import (
+	"crypto/tls"
+	"net/http/httptrace"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type Phases struct {
+	DNS      time.Duration
+	Connect  time.Duration
+	TLS      time.Duration
+	TTFB     time.Duration
+	Download time.Duration
+}
+
+type PhaseStats struct {
+	DNS      LatencyStats
+	Connect  LatencyStats
+	TLS      LatencyStats
+	TTFB     LatencyStats
+	Download LatencyStats
+}
+
+type requestTrace struct {
+	mu sync.Mutex
+
+	start        time.Time
+	waiting      *atomic.Int64
+	gotConn      bool
+	reused       bool
+	family       IPFamily
+	dnsStart     time.Time
+	dnsDone      time.Time
+	connectStart time.Time
+	connectDone  time.Time
+	tlsStart     time.Time
+	tlsDone      time.Time
+	firstByte    time.Time
+}
+
+func (t *requestTrace) clientTrace() *httptrace.ClientTrace {
+	now := func(field *time.Time) {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		if field.IsZero() {
+			*field = time.Now()
+		}
+	}
+
+	return &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) { now(&t.dnsStart) },
+		DNSDone:  func(httptrace.DNSDoneInfo) { now(&t.dnsDone) },
+		ConnectStart: func(string, string) {
+			now(&t.connectStart)
+		},
+		ConnectDone: func(_, _ string, err error) {
+			if err == nil {
+				now(&t.connectDone)
+			}
+		},
+		TLSHandshakeStart: func() { now(&t.tlsStart) },
+		TLSHandshakeDone: func(_ tls.ConnectionState, err error) {
+			if err == nil {
+				now(&t.tlsDone)
+			}
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.stopWaiting()
+			t.gotConn = true
+			t.reused = info.Reused
+			t.family = connFamily(info.Conn)
+		},
+		GotFirstResponseByte: func() { now(&t.firstByte) },
+	}
+}
+
+// wait counts the request in waiting until it gets a connection or ends.
+func (t *requestTrace) wait(waiting *atomic.Int64) {
+	waiting.Add(1)
+	t.waiting = waiting
+}
+
+// release stops counting a request that ended without a connection.
+func (t *requestTrace) release() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.stopWaiting()
+}
+
+func (t *requestTrace) stopWaiting() {
+	if t.waiting != nil {
+		t.waiting.Add(-1)
+		t.waiting = nil
+	}
+}
+
+func since(start, end time.Time) time.Duration {
+	if start.IsZero() || end.IsZero() {
+		return 0
+	}
+	return end.Sub(start)
+}
+
+func (t *requestTrace) apply(result *Result, bodyDone time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.gotConn {
+		result.ConnReused = t.reused
+		result.NewConn = !t.reused
+		result.IPFamily = t.family
+	}
+	result.Phases = Phases{
+		DNS:      since(t.dnsStart, t.dnsDone),
+		Connect:  since(t.connectStart, t.connectDone),
+		TLS:      since(t.tlsStart, t.tlsDone),
+		TTFB:     since(t.start, t.firstByte),
+		Download: since(t.firstByte, bodyDone),
+	}
+}
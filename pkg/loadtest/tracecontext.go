@@ -0,0 +1,18 @@
+package loadtest
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+)
+
+// injectTraceContext sets a W3C traceparent header starting a new sampled
+// trace, and records its ids in result so the request can be exported as a
+// client span.
+func injectTraceContext(req *http.Request, result *Result) {
+	var ids [24]byte
+	rand.Read(ids[:])
+	result.TraceID = hex.EncodeToString(ids[:16])
+	result.SpanID = hex.EncodeToString(ids[16:])
+	req.Header.Set("traceparent", "00-"+result.TraceID+"-"+result.SpanID+"-01")
+}
@@ -0,0 +1,108 @@
+package loadtest
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/quic-go/quic-go/http3"
+	"golang.org/x/net/http2"
+)
+
+type Protocol string
+
+const (
+	ProtocolHTTP1               Protocol = "http/1.1"
+	ProtocolHTTP2               Protocol = "h2"
+	ProtocolHTTP2PriorKnowledge Protocol = "h2c"
+	ProtocolHTTP3               Protocol = "h3"
+)
+
+// ConnectionLimits tunes the connection pool of the HTTP/1.1 and HTTP/2
+// transports. MaxIdleConns and MaxConnsPerHost are unlimited when 0;
+// MaxIdleConnsPerHost defaults to the number of workers sharing the
+// transport, so that every worker can keep its connection alive.
+type ConnectionLimits struct {
+	MaxIdleConns        int
+	MaxIdleConnsPerHost int
+	MaxConnsPerHost     int
+}
+
+// proxyFunc returns the proxy selection for the HTTP/1.1 and HTTP/2 transports.
+// Unlike http.DefaultTransport, the environment is only consulted on request.
+func (r *Runner) proxyFunc() func(*http.Request) (*url.URL, error) {
+	switch {
+	case r.proxy != nil:
+		return http.ProxyURL(r.proxy)
+	case r.proxyFromEnv:
+		return http.ProxyFromEnvironment
+	default:
+		return nil
+	}
+}
+
+// newTransport creates the transport of a client shared by workers workers.
+func (r *Runner) newTransport(workers int) (http.RoundTripper, error) {
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	switch r.protocol {
+	case ProtocolHTTP1:
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.Proxy = r.proxyFunc()
+		transport.DialContext = r.dialContext(dialer)
+		transport.TLSClientConfig = r.tlsConfig.Clone()
+		transport.DisableKeepAlives = r.disableKeepAlive && r.portExhaustion != PortExhaustionReuse
+		transport.ForceAttemptHTTP2 = false
+		transport.TLSNextProto = make(map[string]func(string, *tls.Conn) http.RoundTripper)
+		r.applyLimits(transport, workers)
+		return transport, nil
+	case ProtocolHTTP2:
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.Proxy = r.proxyFunc()
+		transport.DialContext = r.dialContext(dialer)
+		transport.TLSClientConfig = r.tlsConfig.Clone()
+		transport.DisableKeepAlives = r.disableKeepAlive && r.portExhaustion != PortExhaustionReuse
+		transport.ForceAttemptHTTP2 = true
+		r.applyLimits(transport, workers)
+		if err := http2.ConfigureTransport(transport); err != nil {
+			return nil, err
+		}
+		return transport, nil
+	case ProtocolHTTP2PriorKnowledge:
+		dial := r.dialContext(dialer)
+		return &http2.Transport{
+			AllowHTTP: true,
+			DialTLSContext: func(ctx context.Context, network, addr string, cfg *tls.Config) (net.Conn, error) {
+				return dial(ctx, network, addr)
+			},
+		}, nil
+	case ProtocolHTTP3:
+		return &http3.RoundTripper{
+			TLSClientConfig: r.tlsConfig.Clone(),
+			Dial:            r.dialQUIC,
+		}, nil
+	default:
+		return nil, fmt.Errorf("protocolo desconhecido: %q", r.protocol)
+	}
+}
+
+func (r *Runner) applyLimits(transport *http.Transport, workers int) {
+	transport.MaxIdleConns = r.limits.MaxIdleConns
+	transport.MaxIdleConnsPerHost = r.limits.MaxIdleConnsPerHost
+	if transport.MaxIdleConnsPerHost == 0 {
+		transport.MaxIdleConnsPerHost = workers
+	}
+	transport.MaxConnsPerHost = r.limits.MaxConnsPerHost
+}
+
+// workerClient returns the client of worker id: with WithWorkersPerClient,
+// every n workers share a client.
+func (r *Runner) workerClient(id int) *http.Client {
+	if len(r.clients) == 1 {
+		return r.client
+	}
+	return r.clients[id/r.perClient%len(r.clients)]
+}
@@ -0,0 +1,186 @@
+package loadtest
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"text/template"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+// WebSocketTest opens Connections concurrent WebSocket connections to URL.
+// Each connection sends Message and waits for the next message from the
+// server, measuring the round trip, until Messages were sent by the
+// connection or Duration elapses. Rate limits the messages per second of all
+// connections together; without it every reply is followed by the next
+// message. Message may use the template functions, e.g. {{uuid}}.
+type WebSocketTest struct {
+	URL         string
+	Connections int
+	Message     string
+	Binary      bool
+	Messages    int
+	Duration    time.Duration
+	Rate        float64
+	Timeout     time.Duration
+	Headers     http.Header
+	TLSConfig   *tls.Config
+}
+
+func (t *WebSocketTest) validate() error {
+	u, err := url.Parse(t.URL)
+	if err != nil || (u.Scheme != "ws" && u.Scheme != "wss") || u.Host == "" {
+		return fmt.Errorf("URL inválida %q, use ws:// ou wss://", t.URL)
+	}
+	if t.Connections <= 0 {
+		return fmt.Errorf("o número de conexões deve ser maior que 0")
+	}
+	if t.Messages < 0 || t.Duration < 0 || t.Rate < 0 {
+		return fmt.Errorf("mensagens, duração e taxa não podem ser negativas")
+	}
+	if t.Messages == 0 && t.Duration == 0 {
+		return fmt.Errorf("informe o número de mensagens, a duração ou ambos")
+	}
+	return nil
+}
+
+// Run executes the test. It returns once every connection finished, or ctx
+// is canceled.
+func (t *WebSocketTest) Run(ctx context.Context) (*ConnectionReport, error) {
+	if err := t.validate(); err != nil {
+		return nil, err
+	}
+	message, err := parseTemplate("message", t.Message)
+	if err != nil {
+		return nil, err
+	}
+	if t.Duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, t.Duration)
+		defer cancel()
+	}
+
+	stats := newConnectionStats(t.Connections)
+	var interval time.Duration
+	if t.Rate > 0 {
+		interval = time.Duration(float64(time.Second) * float64(t.Connections) / t.Rate)
+	}
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < t.Connections; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			t.connection(ctx, stats, message, interval)
+		}()
+	}
+	wg.Wait()
+	return stats.finish(time.Since(start)), nil
+}
+
+func (t *WebSocketTest) connection(ctx context.Context, stats *connectionStats, message *template.Template, interval time.Duration) {
+	u, _ := url.Parse(t.URL)
+	origin := "http://" + u.Host
+	if u.Scheme == "wss" {
+		origin = "https://" + u.Host
+	}
+	config, err := websocket.NewConfig(t.URL, origin)
+	if err != nil {
+		stats.failed(err)
+		return
+	}
+	for key, values := range t.Headers {
+		config.Header[key] = values
+	}
+	config.TlsConfig = t.TLSConfig
+	config.Dialer = &net.Dialer{Timeout: t.Timeout}
+
+	dialStart := time.Now()
+	conn, err := config.DialContext(ctx)
+	if err != nil {
+		if !stopped(ctx) {
+			stats.failed(unwrapDialError(err))
+		}
+		return
+	}
+	stats.connected(time.Since(dialStart))
+	defer conn.Close()
+	// Closing the connection unblocks a pending read when the test ends.
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	next := time.Now()
+	for sent := 0; t.Messages == 0 || sent < t.Messages; sent++ {
+		if interval > 0 {
+			if wait := time.Until(next); wait > 0 {
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(wait):
+				}
+			}
+			// A late connection doesn't burst to catch up.
+			next = maxTime(next, time.Now().Add(-interval)).Add(interval)
+		}
+		if ctx.Err() != nil {
+			return
+		}
+
+		payload := t.Message
+		if message != nil {
+			if payload, err = executeTemplate(message, nil); err != nil {
+				stats.failed(err)
+				return
+			}
+		}
+		if t.Timeout > 0 {
+			conn.SetDeadline(time.Now().Add(t.Timeout))
+		}
+		sendStart := time.Now()
+		if t.Binary {
+			err = websocket.Message.Send(conn, []byte(payload))
+		} else {
+			err = websocket.Message.Send(conn, payload)
+		}
+		if err != nil {
+			if !stopped(ctx) {
+				stats.failed(err)
+			}
+			return
+		}
+		stats.sent()
+
+		var reply []byte
+		if err := websocket.Message.Receive(conn, &reply); err != nil {
+			if !stopped(ctx) {
+				stats.failed(err)
+			}
+			return
+		}
+		stats.received(time.Since(sendStart))
+	}
+}
+
+// unwrapDialError keeps the cause of a failed handshake, so that it can be
+// classified.
+func unwrapDialError(err error) error {
+	var dialErr *websocket.DialError
+	if errors.As(err, &dialErr) && dialErr.Err != nil {
+		return dialErr.Err
+	}
+	return err
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
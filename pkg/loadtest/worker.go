@@ -0,0 +1,419 @@
+package loadtest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptrace"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const maxAssertionBodySize = 10 << 20
+
+func (r *Runner) newRequest(ctx context.Context, step Step) (*http.Request, error) {
+	var body io.Reader
+	if len(step.Body) > 0 {
+		body = bytes.NewReader(step.Body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, step.Method, step.URL, body)
+	if err != nil {
+		return nil, err
+	}
+	if step.Stream != nil {
+		if err := setBodyStream(req, step.Stream); err != nil {
+			return nil, fmt.Errorf("falha ao abrir o corpo: %w", err)
+		}
+	}
+	if step.ContentType != "" {
+		req.Header.Set("Content-Type", step.ContentType)
+	}
+	for key, values := range step.Headers {
+		if strings.EqualFold(key, "Host") {
+			req.Host = values[len(values)-1]
+			continue
+		}
+		req.Header[key] = append([]string(nil), values...)
+	}
+	// With an Accept-Encoding of its own the transport leaves the body as is.
+	if len(r.encodings) > 0 && req.Header.Get("Accept-Encoding") == "" {
+		req.Header.Set("Accept-Encoding", strings.Join(r.encodings, ", "))
+	}
+	return req, nil
+}
+
+func (r *Runner) worker(ctx context.Context, jobs <-chan time.Time, results chan<- Result) {
+	id := int(r.workers.Add(1) - 1)
+	client := r.workerClient(id)
+	if r.cookies {
+		jar, _ := cookiejar.New(nil)
+		client = &http.Client{Transport: client.Transport, Timeout: client.Timeout, CheckRedirect: r.checkRedirect, Jar: jar}
+	}
+	if r.conditional {
+		client = &http.Client{Transport: newValidatorCache(client.Transport), Timeout: client.Timeout, CheckRedirect: r.checkRedirect, Jar: client.Jar}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case intended, ok := <-jobs:
+			if !ok {
+				return
+			}
+
+			if !r.waitPorts(ctx) || !r.iterate(ctx, id, client, intended, results) || !r.think(ctx) {
+				return
+			}
+		}
+	}
+}
+
+// iterate runs one scenario iteration, or a single request to a weighted
+// target when no scenario is set. It returns false once ctx is cancelled.
+// intended is the time the dispatcher scheduled the iteration for.
+func (r *Runner) iterate(ctx context.Context, worker int, client *http.Client, intended time.Time, results chan<- Result) bool {
+	var row map[string]string
+	if r.feeder != nil {
+		row = r.feeder.Next()
+	}
+
+	var steps []Step
+	vars := iterationVars(worker, row)
+	switch {
+	case r.source != nil:
+		select {
+		case <-ctx.Done():
+			return false
+		case step, ok := <-r.source:
+			if !ok {
+				return false
+			}
+			step.Headers = mergeHeaders(r.headers, step.Headers)
+			steps = []Step{step}
+		}
+	case r.scenario != nil:
+		steps = r.scenario.Steps
+	default:
+		i := pickTarget(r.targets)
+		steps = r.steps[i : i+1]
+	}
+
+	start := time.Now()
+	for i, step := range steps {
+		if i > 0 && step.Offset == 0 && !r.think(ctx) {
+			return false
+		}
+		if wait := time.Until(start.Add(step.Offset)); step.Offset > 0 && wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return false
+			case <-timer.C:
+			}
+		}
+		result := r.send(ctx, client, step, vars)
+		if result.Error != nil && ctx.Err() != nil {
+			return false
+		}
+		if result.Error != nil && isPortExhaustion(result.Error) {
+			r.exhausted(time.Now())
+		}
+		result.Step = step.Name
+		result.Worker = worker
+		if i == 0 {
+			result.Intended = intended
+		}
+		result.LastStep = i == len(steps)-1 || !r.succeeded(result)
+		results <- result
+		if result.LastStep {
+			break
+		}
+	}
+	return true
+}
+
+// iterationVars are the variables of the templates of an iteration: the row
+// of the feeder and VU, the id of the virtual user. Every iteration gets its
+// own copy, to which a scenario adds its captures.
+func iterationVars(worker int, row map[string]string) map[string]string {
+	vars := make(map[string]string, len(row)+1)
+	for key, value := range row {
+		vars[key] = value
+	}
+	vars["VU"] = strconv.Itoa(worker)
+	return vars
+}
+
+// think pauses the worker between requests for the think time, varied by up
+// to the jitter either way. It returns false once ctx is cancelled.
+func (r *Runner) think(ctx context.Context) bool {
+	pause := r.thinkTime
+	if r.thinkJitter > 0 {
+		pause += time.Duration(rand.Int63n(int64(2*r.thinkJitter)+1)) - r.thinkJitter
+	}
+	if pause <= 0 {
+		return true
+	}
+	timer := time.NewTimer(pause)
+	select {
+	case <-ctx.Done():
+		timer.Stop()
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+func (r *Runner) succeeded(result Result) bool {
+	return result.Error == nil && result.AssertionError == nil && r.success.Contains(result.StatusCode) && !result.grpcFailed()
+}
+
+func (r *Runner) do(ctx context.Context, client *http.Client, step Step, vars map[string]string) Result {
+	// A token refresh blocks every worker, so it happens before the clock
+	// starts instead of inflating the latency of whoever triggered it.
+	var token string
+	if r.tokens != nil {
+		var err error
+		if token, err = r.tokens.get(ctx); err != nil {
+			return Result{Target: step.URL, Start: time.Now(), Error: err}
+		}
+	}
+
+	startTime := time.Now()
+	result := Result{Target: step.URL, Start: startTime}
+	trace := &requestTrace{start: startTime}
+	ctx = context.WithValue(ctx, redirectsKey{}, &result.Redirects)
+
+	step, err := step.render(vars)
+	if err != nil {
+		result.Error = err
+		return result
+	}
+	req, err := r.newRequest(httptrace.WithClientTrace(ctx, trace.clientTrace()), step)
+	if err != nil {
+		result.Error = err
+		result.Duration = time.Since(startTime)
+		return result
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	if r.vuHeader != "" {
+		req.Header.Set(r.vuHeader, vars["VU"])
+	}
+	if r.disableKeepAlive && r.portExhaustion == PortExhaustionReuse {
+		req.Close = !r.reuse.Load()
+	}
+	result.Method = req.Method
+	if r.traceContext {
+		injectTraceContext(req, &result)
+	}
+	if r.hook != nil {
+		if err := r.hook(req, step.Body); err != nil {
+			result.Error = fmt.Errorf("hook do request: %w", err)
+			result.Duration = time.Since(startTime)
+			return result
+		}
+	}
+	sent := &countingBody{}
+	if req.Body != nil && req.Body != http.NoBody {
+		sent.ReadCloser = req.Body
+		req.Body = sent
+	}
+
+	r.inFlight.Add(1)
+	defer r.inFlight.Add(-1)
+	// quic-go doesn't report when it gets a connection.
+	if r.protocol != ProtocolHTTP3 {
+		trace.wait(&r.waitConn)
+		defer trace.release()
+	}
+
+	resp, err := client.Do(req)
+	result.Duration = time.Since(startTime)
+	if err != nil {
+		trace.apply(&result, time.Time{})
+		result.Error = err
+		result.BytesSent = sent.n.Load()
+		return result
+	}
+
+	result.StatusCode = resp.StatusCode
+	result.Conditional = isConditional(resp.Request)
+	if r.tokens != nil && resp.StatusCode == http.StatusUnauthorized {
+		r.tokens.invalidate(token)
+	}
+	result.Proto = resp.Proto
+	var reader io.Reader = resp.Body
+	var decoder *decodingReader
+	if len(r.encodings) > 0 {
+		if decoder = newDecodingReader(resp); decoder != nil {
+			reader = decoder
+		}
+	}
+	var body []byte
+	var n int64
+	switch {
+	case r.bodyMode == BodyRead:
+		body, err = io.ReadAll(reader)
+		n = int64(len(body))
+		body = body[:min(len(body), maxAssertionBodySize)]
+	case len(r.assertions) > 0 || len(step.Assertions) > 0 || len(step.Captures) > 0 || r.check != nil || r.saver != nil || r.samples > 0:
+		body, err = io.ReadAll(io.LimitReader(reader, maxAssertionBodySize))
+		if err == nil {
+			n, err = io.Copy(io.Discard, reader)
+		}
+		n += int64(len(body))
+	default:
+		n, err = io.Copy(io.Discard, reader)
+	}
+	resp.Body.Close()
+	result.Bytes = n
+	result.BytesSent = sent.n.Load()
+	if decoder != nil {
+		result.Encoding = decoder.encoding
+		result.WireBytes = decoder.source.n
+		result.DecodeTime = decoder.decodeTime()
+	}
+	trace.apply(&result, time.Now())
+	if err != nil {
+		result.Error = &bodyReadError{err: err}
+		return result
+	}
+	if isGRPCResponse(resp) {
+		result.GRPCStatus = grpcStatus(resp)
+	}
+
+	if failure := checkAssertions(r.assertions, body); failure != nil {
+		result.AssertionError = failure
+	} else if failure := checkAssertions(step.Assertions, body); failure != nil {
+		result.AssertionError = failure
+	} else if failure := runCaptures(step.Captures, resp.Header, body, vars); failure != nil {
+		result.AssertionError = failure
+	} else if r.check != nil {
+		metrics, err := r.check(&CheckedResponse{
+			Step:     step.Name,
+			Method:   req.Method,
+			URL:      step.URL,
+			Status:   resp.StatusCode,
+			Header:   resp.Header,
+			Body:     body,
+			Duration: result.Duration,
+		})
+		result.Metrics = metrics
+		if err != nil {
+			result.AssertionError = &AssertionError{Assertion: CheckAssertion, Err: err}
+		}
+	}
+	if r.saver != nil || r.samples > 0 {
+		failure := r.failure(result)
+		if r.saver != nil {
+			result.SavedBody = r.saver.save(step, resp, body, failure)
+		}
+		if failure != "" && r.sampled.Add(1) <= int64(r.samples) {
+			result.Sample = newFailureSample(step, resp, body, failure)
+		}
+	}
+	return result
+}
+
+// schedule returns when the i-th iteration is due, as an offset from the
+// start of the run, or false once no more iterations are due.
+type schedule func(i int) (time.Duration, bool)
+
+// constantRate spaces the iterations evenly. Without a rate the iterations
+// aren't paced and constantRate returns nil.
+func constantRate(rate float64) schedule {
+	if rate <= 0 {
+		return nil
+	}
+	interval := time.Duration(float64(time.Second) / rate)
+	return func(i int) (time.Duration, bool) {
+		return time.Duration(i) * interval, true
+	}
+}
+
+// schedule returns the pacing of the run: the stages when they are set,
+// otherwise the constant rate.
+func (r *Runner) schedule() schedule {
+	if len(r.stages) > 0 {
+		return stageSchedule(r.stages)
+	}
+	return constantRate(r.rate)
+}
+
+// dispatch schedules the iterations. Each job carries its intended start time,
+// so the time spent waiting for a free worker is not hidden from the latency
+// when the run is paced.
+func dispatch(ctx context.Context, jobs chan<- time.Time, limit int, next schedule) {
+	defer close(jobs)
+
+	startTime := time.Now()
+	for i := 0; limit == 0 || i < limit; i++ {
+		var intended time.Time
+		if next != nil {
+			offset, ok := next(i)
+			if !ok {
+				return
+			}
+			intended = startTime.Add(offset)
+			if wait := time.Until(intended); wait > 0 {
+				timer := time.NewTimer(wait)
+				select {
+				case <-ctx.Done():
+					timer.Stop()
+					return
+				case <-timer.C:
+				}
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case jobs <- intended:
+		}
+	}
+}
+
+func (r *Runner) startWorkers(ctx context.Context, wg *sync.WaitGroup, spawn func()) {
+	wg.Add(1)
+	spawn()
+
+	if r.rampUp <= 0 || r.concurrency == 1 {
+		wg.Add(r.concurrency - 1)
+		for i := 1; i < r.concurrency; i++ {
+			spawn()
+		}
+		return
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		// A ramp-up shorter than the number of workers starts them all at
+		// once; NewTicker panics with a zero interval.
+		ticker := time.NewTicker(max(r.rampUp/time.Duration(r.concurrency-1), time.Nanosecond))
+		defer ticker.Stop()
+
+		for i := 1; i < r.concurrency; i++ {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				wg.Add(1)
+				spawn()
+			}
+		}
+	}()
+}
@@ -0,0 +1,435 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+
+	"stress-test/pkg/loadtest"
+)
+
+const (
+	postmanModeTargets  = "targets"
+	postmanModeScenario = "scenario"
+)
+
+// postmanConfig selects the requests of a collection and how they run: as
+// weighted targets, each iteration picking one, or as a scenario.
+type postmanConfig struct {
+	Collection  string
+	Environment string
+	Folder      string
+	Mode        string
+}
+
+type postmanKeyValue struct {
+	Key      string `json:"key"`
+	Value    any    `json:"value"`
+	Disabled bool   `json:"disabled"`
+	Enabled  *bool  `json:"enabled"`
+}
+
+func (kv postmanKeyValue) active() bool {
+	return !kv.Disabled && (kv.Enabled == nil || *kv.Enabled)
+}
+
+func (kv postmanKeyValue) value() string {
+	switch value := kv.Value.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+type postmanAuth struct {
+	Type   string            `json:"type"`
+	Basic  []postmanKeyValue `json:"basic"`
+	Bearer []postmanKeyValue `json:"bearer"`
+	APIKey []postmanKeyValue `json:"apikey"`
+}
+
+type postmanItem struct {
+	Name    string          `json:"name"`
+	Item    []postmanItem   `json:"item"`
+	Auth    *postmanAuth    `json:"auth"`
+	Request *postmanRequest `json:"request"`
+}
+
+type postmanRequest struct {
+	Method string            `json:"method"`
+	Header []postmanKeyValue `json:"header"`
+	URL    json.RawMessage   `json:"url"`
+	Auth   *postmanAuth      `json:"auth"`
+	Body   *struct {
+		Mode       string            `json:"mode"`
+		Raw        string            `json:"raw"`
+		URLEncoded []postmanKeyValue `json:"urlencoded"`
+		GraphQL    *struct {
+			Query     string `json:"query"`
+			Variables string `json:"variables"`
+		} `json:"graphql"`
+		Options struct {
+			Raw struct {
+				Language string `json:"language"`
+			} `json:"raw"`
+		} `json:"options"`
+	} `json:"body"`
+}
+
+type postmanCollection struct {
+	Info struct {
+		Name string `json:"name"`
+	} `json:"info"`
+	Item     []postmanItem     `json:"item"`
+	Auth     *postmanAuth      `json:"auth"`
+	Variable []postmanKeyValue `json:"variable"`
+}
+
+var postmanVariable = regexp.MustCompile(`\{\{\s*([^{}\s]+)\s*\}\}`)
+
+// postmanDynamicVariables maps the Postman dynamic variables to the template
+// functions, so they still change on every request.
+var postmanDynamicVariables = map[string]string{
+	"$guid":         "{{uuid}}",
+	"$randomUUID":   "{{uuid}}",
+	"$timestamp":    `{{now "unix"}}`,
+	"$isoTimestamp": "{{now}}",
+	"$randomInt":    "{{randInt 0 1000}}",
+}
+
+// loadPostmanCollection reads a v2.x collection. Environment values take
+// precedence over the collection variables, and folder restricts the
+// requests to the ones inside the folder with that name.
+func loadPostmanCollection(path, environment, folder string) (string, []loadtest.Step, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", nil, err
+	}
+	collection := &postmanCollection{}
+	if err := json.Unmarshal(data, collection); err != nil {
+		return "", nil, err
+	}
+
+	vars := make(map[string]string)
+	for _, variable := range collection.Variable {
+		if variable.active() {
+			vars[variable.Key] = variable.value()
+		}
+	}
+	if environment != "" {
+		data, err := os.ReadFile(environment)
+		if err != nil {
+			return "", nil, err
+		}
+		var env struct {
+			Values []postmanKeyValue `json:"values"`
+		}
+		if err := json.Unmarshal(data, &env); err != nil {
+			return "", nil, fmt.Errorf("ambiente inválido: %w", err)
+		}
+		for _, variable := range env.Values {
+			if variable.active() {
+				vars[variable.Key] = variable.value()
+			}
+		}
+	}
+
+	l := &postmanLoader{vars: vars, names: make(map[string]int)}
+	items := collection.Item
+	auth := collection.Auth
+	if folder != "" {
+		found := findPostmanFolder(collection.Item, folder, collection.Auth)
+		if found == nil {
+			return "", nil, fmt.Errorf("pasta %q não encontrada na coleção", folder)
+		}
+		items, auth = found.Item, found.Auth
+	}
+	if err := l.load(items, "", auth); err != nil {
+		return "", nil, err
+	}
+	if len(l.requests) == 0 {
+		return "", nil, fmt.Errorf("nenhum request encontrado na coleção")
+	}
+	return collection.Info.Name, l.requests, nil
+}
+
+// findPostmanFolder returns the folder with its effective auth.
+func findPostmanFolder(items []postmanItem, name string, auth *postmanAuth) *postmanItem {
+	for _, item := range items {
+		if item.Request != nil {
+			continue
+		}
+		itemAuth := auth
+		if item.Auth != nil {
+			itemAuth = item.Auth
+		}
+		if item.Name == name {
+			item.Auth = itemAuth
+			return &item
+		}
+		if found := findPostmanFolder(item.Item, name, itemAuth); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+type postmanLoader struct {
+	vars     map[string]string
+	names    map[string]int
+	requests []loadtest.Step
+}
+
+// load walks the folders in order; request names are prefixed with their
+// folders and auth is inherited from the closest parent that defines it.
+func (l *postmanLoader) load(items []postmanItem, prefix string, auth *postmanAuth) error {
+	for _, item := range items {
+		name := item.Name
+		if prefix != "" {
+			name = prefix + " / " + name
+		}
+		itemAuth := auth
+		if item.Auth != nil {
+			itemAuth = item.Auth
+		}
+		if item.Request == nil {
+			if err := l.load(item.Item, name, itemAuth); err != nil {
+				return err
+			}
+			continue
+		}
+		if item.Request.Auth != nil {
+			itemAuth = item.Request.Auth
+		}
+		step, err := l.step(item.Request, itemAuth)
+		if err != nil {
+			return fmt.Errorf("request %q: %w", name, err)
+		}
+		// Names identify the requests in the report, so they must be unique.
+		if l.names[name]++; l.names[name] > 1 {
+			name = fmt.Sprintf("%s (%d)", name, l.names[name])
+		}
+		step.Name = name
+		l.requests = append(l.requests, step)
+	}
+	return nil
+}
+
+func (l *postmanLoader) step(request *postmanRequest, auth *postmanAuth) (loadtest.Step, error) {
+	step := loadtest.Step{Method: strings.ToUpper(request.Method), Headers: make(http.Header)}
+	if step.Method == "" {
+		step.Method = http.MethodGet
+	}
+	if !isValidMethod(step.Method) {
+		return step, fmt.Errorf("método inválido: %q", request.Method)
+	}
+
+	var rawURL string
+	if err := json.Unmarshal(request.URL, &rawURL); err != nil {
+		var u struct {
+			Raw string `json:"raw"`
+		}
+		if err := json.Unmarshal(request.URL, &u); err != nil {
+			return step, fmt.Errorf("url inválida")
+		}
+		rawURL = u.Raw
+	}
+	var err error
+	if step.URL, err = l.expand(rawURL); err != nil {
+		return step, err
+	}
+	if step.URL == "" {
+		return step, fmt.Errorf("url é obrigatória")
+	}
+	if !strings.Contains(step.URL, "://") {
+		step.URL = "http://" + step.URL
+	}
+
+	for _, header := range request.Header {
+		if !header.active() {
+			continue
+		}
+		value, err := l.expand(header.value())
+		if err != nil {
+			return step, err
+		}
+		step.Headers.Add(header.Key, value)
+	}
+	if err := l.applyAuth(&step, auth); err != nil {
+		return step, err
+	}
+
+	if body := request.Body; body != nil {
+		var text string
+		switch body.Mode {
+		case "raw":
+			text = body.Raw
+			if body.Options.Raw.Language == "json" {
+				step.ContentType = "application/json"
+			}
+		case "urlencoded":
+			form := url.Values{}
+			for _, field := range body.URLEncoded {
+				if !field.active() {
+					continue
+				}
+				value, err := l.expand(field.value())
+				if err != nil {
+					return step, err
+				}
+				form.Add(field.Key, value)
+			}
+			text = form.Encode()
+			step.ContentType = "application/x-www-form-urlencoded"
+		case "graphql":
+			if body.GraphQL != nil {
+				payload := map[string]any{"query": body.GraphQL.Query}
+				if body.GraphQL.Variables != "" {
+					payload["variables"] = json.RawMessage(body.GraphQL.Variables)
+				}
+				data, err := json.Marshal(payload)
+				if err != nil {
+					return step, fmt.Errorf("variáveis GraphQL inválidas: %w", err)
+				}
+				text = string(data)
+				step.ContentType = "application/json"
+			}
+		case "":
+		default:
+			return step, fmt.Errorf("corpo no modo %q não é suportado", body.Mode)
+		}
+		if body.Mode != "urlencoded" {
+			if text, err = l.expand(text); err != nil {
+				return step, err
+			}
+		}
+		if text != "" {
+			step.Body = []byte(text)
+			if step.ContentType == "" {
+				step.ContentType = detectContentType(step.Body)
+			}
+		}
+	}
+	return step, nil
+}
+
+func (l *postmanLoader) applyAuth(step *loadtest.Step, auth *postmanAuth) error {
+	if auth == nil {
+		return nil
+	}
+	params := func(values []postmanKeyValue) (map[string]string, error) {
+		m := make(map[string]string, len(values))
+		for _, kv := range values {
+			value, err := l.expand(kv.value())
+			if err != nil {
+				return nil, err
+			}
+			m[kv.Key] = value
+		}
+		return m, nil
+	}
+
+	switch auth.Type {
+	case "", "noauth":
+	case "bearer":
+		p, err := params(auth.Bearer)
+		if err != nil {
+			return err
+		}
+		step.Headers.Set("Authorization", "Bearer "+p["token"])
+	case "basic":
+		p, err := params(auth.Basic)
+		if err != nil {
+			return err
+		}
+		credentials := base64.StdEncoding.EncodeToString([]byte(p["username"] + ":" + p["password"]))
+		step.Headers.Set("Authorization", "Basic "+credentials)
+	case "apikey":
+		p, err := params(auth.APIKey)
+		if err != nil {
+			return err
+		}
+		if p["in"] == "query" {
+			separator := "?"
+			if strings.Contains(step.URL, "?") {
+				separator = "&"
+			}
+			step.URL += separator + url.QueryEscape(p["key"]) + "=" + url.QueryEscape(p["value"])
+		} else {
+			step.Headers.Set(p["key"], p["value"])
+		}
+	default:
+		return fmt.Errorf("autenticação %q não é suportada", auth.Type)
+	}
+	return nil
+}
+
+// expand replaces the {{variables}} of text. Variables may reference other
+// variables; an undefined one is an error, since it would otherwise be sent
+// literally. {{.column}} placeholders are left for --data.
+func (l *postmanLoader) expand(text string) (string, error) {
+	for depth := 0; depth < 10; depth++ {
+		changed := false
+		text = postmanVariable.ReplaceAllStringFunc(text, func(match string) string {
+			if value, ok := l.vars[postmanVariable.FindStringSubmatch(match)[1]]; ok {
+				changed = true
+				return value
+			}
+			return match
+		})
+		if !changed {
+			break
+		}
+	}
+
+	var err error
+	text = postmanVariable.ReplaceAllStringFunc(text, func(match string) string {
+		name := postmanVariable.FindStringSubmatch(match)[1]
+		if value, ok := postmanDynamicVariables[name]; ok {
+			return value
+		}
+		if !strings.HasPrefix(name, ".") && err == nil {
+			err = fmt.Errorf("variável {{%s}} não definida", name)
+		}
+		return match
+	})
+	return text, err
+}
+
+// applyPostman loads the collection into config.Targets or config.Scenario.
+func applyPostman(config *Config) error {
+	name, steps, err := loadPostmanCollection(config.Postman.Collection, config.Postman.Environment, config.Postman.Folder)
+	if err != nil {
+		return err
+	}
+	switch config.Postman.Mode {
+	case postmanModeTargets:
+		for _, step := range steps {
+			config.Targets = append(config.Targets, loadtest.Target{
+				Name:        step.Name,
+				URL:         step.URL,
+				Weight:      1,
+				Method:      step.Method,
+				Headers:     step.Headers,
+				Body:        step.Body,
+				ContentType: step.ContentType,
+			})
+		}
+	case postmanModeScenario:
+		if name == "" {
+			name = "postman"
+		}
+		config.Scenario = &loadtest.Scenario{Name: name, Steps: steps}
+	default:
+		return fmt.Errorf("modo %q inválido (use %s ou %s)", config.Postman.Mode, postmanModeTargets, postmanModeScenario)
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"stress-test/pkg/loadtest"
+)
+
+// presets build a stage profile around --rate, the base rate, lasting
+// --duration when it is set or the preset's default duration otherwise.
+var presets = map[string]struct {
+	duration time.Duration
+	stages   func(rate float64, duration time.Duration) []loadtest.Stage
+}{
+	// spike warms up at the base rate, jumps to 5× for a short burst and
+	// then watches the recovery back at the base rate.
+	"spike": {5 * time.Minute, func(rate float64, d time.Duration) []loadtest.Stage {
+		return []loadtest.Stage{
+			{Duration: fraction(d, 0.10), Rate: rate},
+			{Duration: fraction(d, 0.30), Rate: rate},
+			{Duration: fraction(d, 0.05), Rate: 5 * rate},
+			{Duration: fraction(d, 0.10), Rate: 5 * rate},
+			{Duration: fraction(d, 0.05), Rate: rate},
+			{Duration: fraction(d, 0.30), Rate: rate},
+			{Duration: fraction(d, 0.10), Rate: 0},
+		}
+	}},
+	// soak holds the base rate for most of a long run, with short ramps.
+	"soak": {time.Hour, func(rate float64, d time.Duration) []loadtest.Stage {
+		ramp := min(fraction(d, 0.05), 5*time.Minute)
+		return []loadtest.Stage{
+			{Duration: ramp, Rate: rate},
+			{Duration: d - 2*ramp, Rate: rate},
+			{Duration: ramp, Rate: 0},
+		}
+	}},
+}
+
+func fraction(d time.Duration, f float64) time.Duration {
+	return time.Duration(float64(d) * f).Round(time.Second)
+}
+
+// applyPreset replaces --rate and --duration with the stages of the preset.
+// Unless --timeline-interval was given, the timeline is sized to about 600
+// points, so that long runs don't produce huge reports.
+func applyPreset(config *Config, name string) error {
+	preset, ok := presets[name]
+	if !ok {
+		return fmt.Errorf("parâmetro --preset inválido: %q (use spike ou soak)", name)
+	}
+	if config.Rate <= 0 {
+		return fmt.Errorf("--preset exige --rate maior que 0 (a taxa base do perfil)")
+	}
+	duration := preset.duration
+	if config.Duration > 0 {
+		duration = config.Duration
+	}
+	if duration < time.Minute {
+		return fmt.Errorf("--preset exige --duration de pelo menos 1m")
+	}
+	config.Stages = preset.stages(config.Rate, duration)
+	config.Rate = 0
+	config.Duration = 0
+	if config.Resolution == 0 {
+		config.Resolution = max(time.Second, (duration / 600).Round(time.Second))
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"stress-test/pkg/loadtest"
+)
+
+// progressPrinter logs the progress of the run every interval, so the amount
+// of output doesn't depend on the size of the run.
+type progressPrinter struct {
+	unit      string
+	interval  time.Duration
+	start     time.Time
+	completed atomic.Int64
+	total     atomic.Int64
+	done      chan struct{}
+	stopped   chan struct{}
+}
+
+func newProgressPrinter(unit string, interval time.Duration) *progressPrinter {
+	return &progressPrinter{unit: unit, interval: interval, done: make(chan struct{}), stopped: make(chan struct{})}
+}
+
+func (p *progressPrinter) update(completed, total int) {
+	p.completed.Store(int64(completed))
+	p.total.Store(int64(total))
+}
+
+func (p *progressPrinter) run() {
+	p.start = time.Now()
+	go func() {
+		defer close(p.stopped)
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-p.done:
+				return
+			case <-ticker.C:
+				p.print()
+			}
+		}
+	}()
+}
+
+func (p *progressPrinter) stop() {
+	close(p.done)
+	<-p.stopped
+}
+
+func (p *progressPrinter) print() {
+	completed, total := p.completed.Load(), p.total.Load()
+	elapsed := time.Since(p.start)
+	attrs := []any{p.unit, completed}
+	if total > 0 {
+		attrs = append(attrs, "total", total, "percent", fmt.Sprintf("%.1f", float64(completed)/float64(total)*100))
+	}
+	attrs = append(attrs, "elapsed", elapsed.Round(time.Second).String(), "rate", fmt.Sprintf("%.2f", float64(completed)/elapsed.Seconds()))
+	slog.Info("progresso", attrs...)
+}
+
+// logFailures logs every failed request with its cause at debug level, for
+// --verbose.
+func logFailures(success loadtest.StatusSet) func(loadtest.Result) {
+	return func(result loadtest.Result) {
+		attrs := []any{"url", result.Target}
+		if result.Method != "" {
+			attrs = append(attrs, "method", result.Method)
+		}
+		if result.Step != "" {
+			attrs = append(attrs, "step", result.Step)
+		}
+		switch {
+		case result.Error != nil:
+			attrs = append(attrs, "error", result.Error)
+		case result.AssertionError != nil:
+			attrs = append(attrs, "error", result.AssertionError)
+		case !success.Contains(result.StatusCode):
+			attrs = append(attrs, "status", result.StatusCode)
+		case result.GRPCStatus != "" && result.GRPCStatus != "OK":
+			attrs = append(attrs, "grpc_status", result.GRPCStatus)
+		default:
+			return
+		}
+		if result.SavedBody != "" {
+			attrs = append(attrs, "saved", result.SavedBody)
+		}
+		slog.Debug("request com falha", attrs...)
+	}
+}
@@ -0,0 +1,431 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// protoField describes a message field. Kind is the scalar type (int32,
+// string...), "message" or "enum"; TypeName is the fully qualified name of
+// the message or enum.
+type protoField struct {
+	Name     string
+	JSONName string
+	Number   int
+	Kind     string
+	TypeName string
+	Repeated bool
+}
+
+type protoMessage struct {
+	Name     string
+	Fields   []*protoField
+	MapEntry bool
+}
+
+type protoMethod struct {
+	Input           string
+	Output          string
+	ClientStreaming bool
+	ServerStreaming bool
+}
+
+// protoRegistry holds the messages, enums and methods of a set of proto
+// files, indexed by their fully qualified names without the leading dot.
+// Methods are indexed as "package.Service/Method".
+type protoRegistry struct {
+	messages map[string]*protoMessage
+	enums    map[string]map[string]int
+	methods  map[string]*protoMethod
+}
+
+func newProtoRegistry() *protoRegistry {
+	return &protoRegistry{
+		messages: make(map[string]*protoMessage),
+		enums:    make(map[string]map[string]int),
+		methods:  make(map[string]*protoMethod),
+	}
+}
+
+// method accepts both "pkg.Service/Method" and "pkg.Service.Method".
+func (p *protoRegistry) method(name string) (string, *protoMethod, error) {
+	name = strings.TrimPrefix(name, "/")
+	if !strings.Contains(name, "/") {
+		if i := strings.LastIndex(name, "."); i > 0 {
+			name = name[:i] + "/" + name[i+1:]
+		}
+	}
+	method, ok := p.methods[name]
+	if !ok {
+		names := make([]string, 0, len(p.methods))
+		for name := range p.methods {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return "", nil, fmt.Errorf("método %q não encontrado (disponíveis: %s)", name, strings.Join(names, ", "))
+	}
+	return name, method, nil
+}
+
+// encodeJSON encodes a JSON object as the binary form of the message,
+// following the protobuf JSON mapping.
+func (p *protoRegistry) encodeJSON(messageName string, data []byte) ([]byte, error) {
+	var value any
+	decoder := json.NewDecoder(strings.NewReader(string(data)))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return nil, fmt.Errorf("JSON inválido: %w", err)
+	}
+	if value == nil {
+		value = map[string]any{}
+	}
+	return p.encodeMessage(messageName, value)
+}
+
+func (p *protoRegistry) encodeMessage(messageName string, value any) ([]byte, error) {
+	if encoded, ok, err := encodeWellKnown(messageName, value); ok {
+		return encoded, err
+	}
+	message, ok := p.messages[messageName]
+	if !ok {
+		return nil, fmt.Errorf("mensagem %s não encontrada", messageName)
+	}
+	object, ok := value.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%s: esperado um objeto JSON", messageName)
+	}
+
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var buf []byte
+	for _, key := range keys {
+		field := message.field(key)
+		if field == nil {
+			return nil, fmt.Errorf("%s: campo %q não existe", messageName, key)
+		}
+		value := object[key]
+		if value == nil {
+			continue
+		}
+		var err error
+		if buf, err = p.appendField(buf, field, value); err != nil {
+			return nil, fmt.Errorf("%s.%s: %w", messageName, field.Name, err)
+		}
+	}
+	return buf, nil
+}
+
+func (m *protoMessage) field(key string) *protoField {
+	for _, field := range m.Fields {
+		if field.Name == key || field.JSONName == key {
+			return field
+		}
+	}
+	return nil
+}
+
+func (p *protoRegistry) appendField(buf []byte, field *protoField, value any) ([]byte, error) {
+	if !field.Repeated {
+		return p.appendValue(buf, field, value)
+	}
+
+	if entry := p.messages[field.TypeName]; entry != nil && entry.MapEntry {
+		object, ok := value.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("esperado um objeto JSON")
+		}
+		keys := make([]string, 0, len(object))
+		for key := range object {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			encoded, err := p.encodeMessage(field.TypeName, map[string]any{entry.Fields[0].Name: key, entry.Fields[1].Name: object[key]})
+			if err != nil {
+				return nil, err
+			}
+			buf = appendTag(buf, field.Number, 2)
+			buf = binary.AppendUvarint(buf, uint64(len(encoded)))
+			buf = append(buf, encoded...)
+		}
+		return buf, nil
+	}
+
+	items, ok := value.([]any)
+	if !ok {
+		return nil, fmt.Errorf("esperado um array JSON")
+	}
+	if wireType(field.Kind) == 2 {
+		for _, item := range items {
+			var err error
+			if buf, err = p.appendValue(buf, field, item); err != nil {
+				return nil, err
+			}
+		}
+		return buf, nil
+	}
+	// Repeated numbers are packed, which every parser must accept.
+	var packed []byte
+	for _, item := range items {
+		var err error
+		if packed, err = p.appendScalar(packed, field, item); err != nil {
+			return nil, err
+		}
+	}
+	buf = appendTag(buf, field.Number, 2)
+	buf = binary.AppendUvarint(buf, uint64(len(packed)))
+	return append(buf, packed...), nil
+}
+
+func (p *protoRegistry) appendValue(buf []byte, field *protoField, value any) ([]byte, error) {
+	switch field.Kind {
+	case "message":
+		encoded, err := p.encodeMessage(field.TypeName, value)
+		if err != nil {
+			return nil, err
+		}
+		buf = appendTag(buf, field.Number, 2)
+		buf = binary.AppendUvarint(buf, uint64(len(encoded)))
+		return append(buf, encoded...), nil
+	case "string", "bytes":
+		s, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("esperada uma string")
+		}
+		data := []byte(s)
+		if field.Kind == "bytes" {
+			var err error
+			if data, err = base64.StdEncoding.DecodeString(s); err != nil {
+				if data, err = base64.URLEncoding.DecodeString(s); err != nil {
+					return nil, fmt.Errorf("base64 inválido")
+				}
+			}
+		}
+		buf = appendTag(buf, field.Number, 2)
+		buf = binary.AppendUvarint(buf, uint64(len(data)))
+		return append(buf, data...), nil
+	}
+	buf = appendTag(buf, field.Number, wireType(field.Kind))
+	return p.appendScalar(buf, field, value)
+}
+
+// appendScalar appends a number, bool or enum without its tag.
+func (p *protoRegistry) appendScalar(buf []byte, field *protoField, value any) ([]byte, error) {
+	switch field.Kind {
+	case "bool":
+		b, ok := value.(bool)
+		if !ok {
+			return nil, fmt.Errorf("esperado um booleano")
+		}
+		if b {
+			return append(buf, 1), nil
+		}
+		return append(buf, 0), nil
+	case "enum":
+		var n int64
+		switch v := value.(type) {
+		case string:
+			number, ok := p.enums[field.TypeName][v]
+			if !ok {
+				return nil, fmt.Errorf("valor %q não existe no enum %s", v, field.TypeName)
+			}
+			n = int64(number)
+		case json.Number:
+			var err error
+			if n, err = v.Int64(); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("esperado o nome ou número do enum")
+		}
+		return binary.AppendUvarint(buf, uint64(n)), nil
+	case "double", "float":
+		f, err := jsonFloat(value)
+		if err != nil {
+			return nil, err
+		}
+		if field.Kind == "float" {
+			return binary.LittleEndian.AppendUint32(buf, math.Float32bits(float32(f))), nil
+		}
+		return binary.LittleEndian.AppendUint64(buf, math.Float64bits(f)), nil
+	}
+
+	n, err := jsonInt(value)
+	if err != nil {
+		return nil, err
+	}
+	switch field.Kind {
+	case "int32", "int64", "uint32", "uint64":
+		return binary.AppendUvarint(buf, uint64(n)), nil
+	case "sint32", "sint64":
+		return binary.AppendUvarint(buf, uint64(n<<1)^uint64(n>>63)), nil
+	case "fixed32", "sfixed32":
+		return binary.LittleEndian.AppendUint32(buf, uint32(n)), nil
+	case "fixed64", "sfixed64":
+		return binary.LittleEndian.AppendUint64(buf, uint64(n)), nil
+	}
+	return nil, fmt.Errorf("tipo %s não suportado", field.Kind)
+}
+
+// jsonInt accepts numbers and, as the JSON mapping allows for 64-bit
+// integers, strings.
+func jsonInt(value any) (int64, error) {
+	var text string
+	switch v := value.(type) {
+	case json.Number:
+		text = v.String()
+	case string:
+		text = v
+	default:
+		return 0, fmt.Errorf("esperado um número")
+	}
+	if n, err := strconv.ParseInt(text, 10, 64); err == nil {
+		return n, nil
+	}
+	if n, err := strconv.ParseUint(text, 10, 64); err == nil {
+		return int64(n), nil
+	}
+	return 0, fmt.Errorf("número inteiro inválido: %s", text)
+}
+
+func jsonFloat(value any) (float64, error) {
+	switch v := value.(type) {
+	case json.Number:
+		return v.Float64()
+	case string:
+		switch v {
+		case "NaN":
+			return math.NaN(), nil
+		case "Infinity":
+			return math.Inf(1), nil
+		case "-Infinity":
+			return math.Inf(-1), nil
+		}
+		return strconv.ParseFloat(v, 64)
+	}
+	return 0, fmt.Errorf("esperado um número")
+}
+
+func wireType(kind string) int {
+	switch kind {
+	case "double", "fixed64", "sfixed64":
+		return 1
+	case "string", "bytes", "message":
+		return 2
+	case "float", "fixed32", "sfixed32":
+		return 5
+	}
+	return 0
+}
+
+func appendTag(buf []byte, number, wireType int) []byte {
+	return binary.AppendUvarint(buf, uint64(number)<<3|uint64(wireType))
+}
+
+// encodeWellKnown encodes the well-known types that have a special JSON
+// representation, so they don't need their definitions.
+func encodeWellKnown(messageName string, value any) ([]byte, bool, error) {
+	switch messageName {
+	case "google.protobuf.Timestamp":
+		s, ok := value.(string)
+		if !ok {
+			return nil, true, fmt.Errorf("%s: esperada uma data RFC 3339", messageName)
+		}
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return nil, true, err
+		}
+		return secondsNanos(t.Unix(), int64(t.Nanosecond())), true, nil
+	case "google.protobuf.Duration":
+		s, ok := value.(string)
+		if !ok {
+			return nil, true, fmt.Errorf("%s: esperada uma duração como \"1.5s\"", messageName)
+		}
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return nil, true, err
+		}
+		return secondsNanos(int64(d/time.Second), int64(d%time.Second)), true, nil
+	case "google.protobuf.Empty":
+		return nil, true, nil
+	}
+
+	wrappers := map[string]string{
+		"DoubleValue": "double", "FloatValue": "float", "Int64Value": "int64", "UInt64Value": "uint64",
+		"Int32Value": "int32", "UInt32Value": "uint32", "BoolValue": "bool", "StringValue": "string", "BytesValue": "bytes",
+	}
+	if kind, ok := wrappers[strings.TrimPrefix(messageName, "google.protobuf.")]; ok && strings.HasPrefix(messageName, "google.protobuf.") {
+		encoded, err := (&protoRegistry{}).appendValue(nil, &protoField{Name: "value", Number: 1, Kind: kind}, value)
+		return encoded, true, err
+	}
+	return nil, false, nil
+}
+
+func secondsNanos(seconds, nanos int64) []byte {
+	var buf []byte
+	if seconds != 0 {
+		buf = appendTag(buf, 1, 0)
+		buf = binary.AppendUvarint(buf, uint64(seconds))
+	}
+	if nanos != 0 {
+		buf = appendTag(buf, 2, 0)
+		buf = binary.AppendUvarint(buf, uint64(nanos))
+	}
+	return buf
+}
+
+// protoFields calls fn for every field of an encoded message. data holds the
+// payload of length-delimited fields; value holds the other ones.
+func protoFields(b []byte, fn func(number, wireType int, value uint64, data []byte) error) error {
+	for len(b) > 0 {
+		tag, n := binary.Uvarint(b)
+		if n <= 0 {
+			return fmt.Errorf("mensagem protobuf inválida")
+		}
+		b = b[n:]
+		number, wt := int(tag>>3), int(tag&7)
+		var value uint64
+		var data []byte
+		switch wt {
+		case 0:
+			value, n = binary.Uvarint(b)
+			if n <= 0 {
+				return fmt.Errorf("mensagem protobuf inválida")
+			}
+			b = b[n:]
+		case 1:
+			if len(b) < 8 {
+				return fmt.Errorf("mensagem protobuf inválida")
+			}
+			value, b = binary.LittleEndian.Uint64(b), b[8:]
+		case 2:
+			length, n := binary.Uvarint(b)
+			if n <= 0 || uint64(len(b)-n) < length {
+				return fmt.Errorf("mensagem protobuf inválida")
+			}
+			data, b = b[n:n+int(length)], b[n+int(length):]
+		case 5:
+			if len(b) < 4 {
+				return fmt.Errorf("mensagem protobuf inválida")
+			}
+			value, b = uint64(binary.LittleEndian.Uint32(b)), b[4:]
+		default:
+			return fmt.Errorf("tipo de campo protobuf %d não suportado", wt)
+		}
+		if err := fn(number, wt, value, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
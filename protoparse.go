@@ -0,0 +1,569 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+var protoScalars = map[string]bool{
+	"double": true, "float": true, "int32": true, "int64": true, "uint32": true, "uint64": true,
+	"sint32": true, "sint64": true, "fixed32": true, "fixed64": true, "sfixed32": true, "sfixed64": true,
+	"bool": true, "string": true, "bytes": true,
+}
+
+// protoWellKnown are the google/protobuf messages known without their
+// files; encodeWellKnown handles their JSON form.
+var protoWellKnown = []string{
+	"Timestamp", "Duration", "Empty", "DoubleValue", "FloatValue", "Int64Value", "UInt64Value",
+	"Int32Value", "UInt32Value", "BoolValue", "StringValue", "BytesValue",
+}
+
+// protoParser reads .proto files into a registry. Field types are resolved
+// after every file was read, following the protobuf scoping rules.
+type protoParser struct {
+	registry    *protoRegistry
+	importPaths []string
+	loaded      map[string]bool
+	unresolved  []unresolvedField
+	pending     []pendingMethod
+
+	tokens []string
+	pos    int
+	file   string
+	pkg    string
+}
+
+type unresolvedField struct {
+	field *protoField
+	scope string
+}
+
+// pendingMethod holds the types of a method until they are resolved.
+type pendingMethod struct {
+	method        *protoMethod
+	input, output *protoField
+}
+
+func parseProtoFiles(files, importPaths []string) (*protoRegistry, error) {
+	p := &protoParser{registry: newProtoRegistry(), importPaths: importPaths, loaded: make(map[string]bool)}
+	for _, name := range protoWellKnown {
+		p.registry.messages["google.protobuf."+name] = &protoMessage{Name: "google.protobuf." + name}
+	}
+	for _, file := range files {
+		if err := p.load(file, true); err != nil {
+			return nil, err
+		}
+	}
+	for _, u := range p.unresolved {
+		if err := p.resolve(u); err != nil {
+			return nil, err
+		}
+	}
+	for _, m := range p.pending {
+		if m.input.Kind != "message" || m.output.Kind != "message" {
+			return nil, fmt.Errorf("tipos do método devem ser mensagens: %s, %s", m.input.TypeName, m.output.TypeName)
+		}
+		m.method.Input, m.method.Output = m.input.TypeName, m.output.TypeName
+	}
+	return p.registry, nil
+}
+
+func (p *protoParser) load(name string, direct bool) error {
+	path := name
+	if !direct || !fileExists(path) {
+		path = ""
+		for _, dir := range append(p.importPaths, ".") {
+			if candidate := filepath.Join(dir, name); fileExists(candidate) {
+				path = candidate
+				break
+			}
+		}
+	}
+	if path == "" {
+		if strings.HasPrefix(name, "google/protobuf/") {
+			return nil
+		}
+		return fmt.Errorf("arquivo %s não encontrado (informe --import-path)", name)
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		if p.loaded[abs] {
+			return nil
+		}
+		p.loaded[abs] = true
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	tokens, err := tokenizeProto(string(data))
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	saved := *p
+	p.tokens, p.pos, p.file, p.pkg = tokens, 0, path, ""
+	err = p.parseFile()
+	p.tokens, p.pos, p.file, p.pkg = saved.tokens, saved.pos, saved.file, saved.pkg
+	return err
+}
+
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
+
+// tokenizeProto splits a file into identifiers, numbers, strings (kept with
+// their quotes) and symbols, dropping comments.
+func tokenizeProto(text string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(text); {
+		c := text[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case strings.HasPrefix(text[i:], "//"):
+			for i < len(text) && text[i] != '\n' {
+				i++
+			}
+		case strings.HasPrefix(text[i:], "/*"):
+			end := strings.Index(text[i+2:], "*/")
+			if end < 0 {
+				return nil, fmt.Errorf("comentário não terminado")
+			}
+			i += end + 4
+		case c == '"' || c == '\'':
+			j := i + 1
+			for j < len(text) && text[j] != c {
+				if text[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(text) {
+				return nil, fmt.Errorf("string não terminada")
+			}
+			tokens = append(tokens, text[i:j+1])
+			i = j + 1
+		case c == '_' || c == '.' || c == '-' || c == '+' || unicode.IsLetter(rune(c)) || unicode.IsDigit(rune(c)):
+			j := i + 1
+			for j < len(text) && (text[j] == '_' || text[j] == '.' || unicode.IsLetter(rune(text[j])) || unicode.IsDigit(rune(text[j]))) {
+				j++
+			}
+			tokens = append(tokens, text[i:j])
+			i = j
+		default:
+			tokens = append(tokens, string(c))
+			i++
+		}
+	}
+	return tokens, nil
+}
+
+func (p *protoParser) next() string {
+	if p.pos >= len(p.tokens) {
+		return ""
+	}
+	p.pos++
+	return p.tokens[p.pos-1]
+}
+
+func (p *protoParser) peek() string {
+	if p.pos >= len(p.tokens) {
+		return ""
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *protoParser) expect(token string) error {
+	if got := p.next(); got != token {
+		return p.errorf("esperado %q, encontrado %q", token, got)
+	}
+	return nil
+}
+
+func (p *protoParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("%s: %s", p.file, fmt.Sprintf(format, args...))
+}
+
+// skipStatement skips up to the next ";" or balanced "{...}" block.
+func (p *protoParser) skipStatement() error {
+	depth := 0
+	for {
+		switch p.next() {
+		case "":
+			return p.errorf("fim inesperado do arquivo")
+		case "{":
+			depth++
+		case "}":
+			if depth--; depth == 0 {
+				return nil
+			}
+		case ";":
+			if depth == 0 {
+				return nil
+			}
+		}
+	}
+}
+
+func (p *protoParser) parseFile() error {
+	for p.peek() != "" {
+		switch token := p.next(); token {
+		case "syntax", "edition", "option":
+			if err := p.skipStatement(); err != nil {
+				return err
+			}
+		case "package":
+			p.pkg = p.next()
+			if err := p.expect(";"); err != nil {
+				return err
+			}
+		case "import":
+			if p.peek() == "public" || p.peek() == "weak" {
+				p.next()
+			}
+			name, err := unquoteProto(p.next())
+			if err != nil {
+				return p.errorf("import inválido")
+			}
+			if err := p.expect(";"); err != nil {
+				return err
+			}
+			if err := p.load(name, false); err != nil {
+				return err
+			}
+		case "message":
+			if err := p.parseMessage(p.pkg); err != nil {
+				return err
+			}
+		case "enum":
+			if err := p.parseEnum(p.pkg); err != nil {
+				return err
+			}
+		case "service":
+			if err := p.parseService(); err != nil {
+				return err
+			}
+		case "extend":
+			if err := p.skipStatement(); err != nil {
+				return err
+			}
+		case ";":
+		default:
+			return p.errorf("declaração inesperada %q", token)
+		}
+	}
+	return nil
+}
+
+func qualify(scope, name string) string {
+	if scope == "" {
+		return name
+	}
+	return scope + "." + name
+}
+
+func (p *protoParser) parseMessage(scope string) error {
+	message := &protoMessage{Name: qualify(scope, p.next())}
+	p.registry.messages[message.Name] = message
+	if err := p.expect("{"); err != nil {
+		return err
+	}
+	return p.parseMessageBody(message)
+}
+
+func (p *protoParser) parseMessageBody(message *protoMessage) error {
+	for {
+		switch token := p.peek(); token {
+		case "}":
+			p.next()
+			return nil
+		case "":
+			return p.errorf("fim inesperado do arquivo")
+		case ";":
+			p.next()
+		case "message":
+			p.next()
+			if err := p.parseMessage(message.Name); err != nil {
+				return err
+			}
+		case "enum":
+			p.next()
+			if err := p.parseEnum(message.Name); err != nil {
+				return err
+			}
+		case "option", "reserved", "extensions", "extend":
+			if err := p.skipStatement(); err != nil {
+				return err
+			}
+		case "oneof":
+			p.next()
+			p.next()
+			if err := p.expect("{"); err != nil {
+				return err
+			}
+			if err := p.parseMessageBody(message); err != nil {
+				return err
+			}
+		default:
+			if err := p.parseField(message); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (p *protoParser) parseField(message *protoMessage) error {
+	field := &protoField{}
+	typeName := p.next()
+	switch typeName {
+	case "repeated":
+		field.Repeated = true
+		typeName = p.next()
+	case "optional", "required":
+		typeName = p.next()
+	case "group":
+		return p.errorf("campos group não são suportados")
+	}
+
+	if typeName == "map" {
+		if err := p.expect("<"); err != nil {
+			return err
+		}
+		keyType := p.next()
+		if err := p.expect(","); err != nil {
+			return err
+		}
+		valueType := p.next()
+		if err := p.expect(">"); err != nil {
+			return err
+		}
+		name := p.next()
+		entry := &protoMessage{Name: message.Name + "." + mapEntryName(name), MapEntry: true}
+		key := &protoField{Name: "key", JSONName: "key", Number: 1, Kind: keyType}
+		value := &protoField{Name: "value", JSONName: "value", Number: 2}
+		p.setType(value, valueType, message.Name)
+		entry.Fields = []*protoField{key, value}
+		p.registry.messages[entry.Name] = entry
+		field.Name, field.Kind, field.TypeName, field.Repeated = name, "message", entry.Name, true
+	} else {
+		field.Name = p.next()
+		p.setType(field, typeName, message.Name)
+	}
+	field.JSONName = jsonName(field.Name)
+
+	if err := p.expect("="); err != nil {
+		return err
+	}
+	number, err := strconv.Atoi(p.next())
+	if err != nil {
+		return p.errorf("número do campo %s inválido", field.Name)
+	}
+	field.Number = number
+	if p.peek() == "[" {
+		if err := p.parseFieldOptions(field); err != nil {
+			return err
+		}
+	}
+	if err := p.expect(";"); err != nil {
+		return err
+	}
+	message.Fields = append(message.Fields, field)
+	return nil
+}
+
+func (p *protoParser) parseFieldOptions(field *protoField) error {
+	p.next()
+	for depth := 1; depth > 0; {
+		switch token := p.next(); token {
+		case "":
+			return p.errorf("fim inesperado do arquivo")
+		case "[":
+			depth++
+		case "]":
+			depth--
+		case "json_name":
+			if p.peek() == "=" {
+				p.next()
+				if name, err := unquoteProto(p.next()); err == nil {
+					field.JSONName = name
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (p *protoParser) setType(field *protoField, typeName, scope string) {
+	if protoScalars[typeName] {
+		field.Kind = typeName
+		return
+	}
+	field.TypeName = typeName
+	p.unresolved = append(p.unresolved, unresolvedField{field: field, scope: scope})
+}
+
+// resolve looks the type name up from the innermost scope outwards.
+func (p *protoParser) resolve(u unresolvedField) error {
+	name := u.field.TypeName
+	candidates := []string{strings.TrimPrefix(name, ".")}
+	if !strings.HasPrefix(name, ".") {
+		candidates = nil
+		for scope := u.scope; scope != ""; {
+			candidates = append(candidates, scope+"."+name)
+			i := strings.LastIndex(scope, ".")
+			if i < 0 {
+				break
+			}
+			scope = scope[:i]
+		}
+		candidates = append(candidates, name)
+	}
+	for _, candidate := range candidates {
+		if _, ok := p.registry.messages[candidate]; ok {
+			u.field.Kind, u.field.TypeName = "message", candidate
+			return nil
+		}
+		if _, ok := p.registry.enums[candidate]; ok {
+			u.field.Kind, u.field.TypeName = "enum", candidate
+			return nil
+		}
+	}
+	return fmt.Errorf("tipo %s não encontrado (usado em %s)", name, u.scope)
+}
+
+func (p *protoParser) parseEnum(scope string) error {
+	name := qualify(scope, p.next())
+	values := make(map[string]int)
+	p.registry.enums[name] = values
+	if err := p.expect("{"); err != nil {
+		return err
+	}
+	for {
+		switch token := p.next(); token {
+		case "}":
+			return nil
+		case "":
+			return p.errorf("fim inesperado do arquivo")
+		case ";":
+		case "option", "reserved":
+			if err := p.skipStatement(); err != nil {
+				return err
+			}
+		default:
+			if err := p.expect("="); err != nil {
+				return err
+			}
+			number, err := strconv.Atoi(p.next())
+			if err != nil {
+				return p.errorf("valor do enum %s inválido", token)
+			}
+			values[token] = number
+			if p.peek() == "[" {
+				if err := p.parseFieldOptions(&protoField{}); err != nil {
+					return err
+				}
+			}
+			if err := p.expect(";"); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (p *protoParser) parseService() error {
+	service := qualify(p.pkg, p.next())
+	if err := p.expect("{"); err != nil {
+		return err
+	}
+	for {
+		switch token := p.next(); token {
+		case "}":
+			return nil
+		case "":
+			return p.errorf("fim inesperado do arquivo")
+		case ";":
+		case "option":
+			if err := p.skipStatement(); err != nil {
+				return err
+			}
+		case "rpc":
+			name := p.next()
+			method := &protoMethod{}
+			var err error
+			if method.Input, method.ClientStreaming, err = p.parseRPCType(); err != nil {
+				return err
+			}
+			if err := p.expect("returns"); err != nil {
+				return err
+			}
+			if method.Output, method.ServerStreaming, err = p.parseRPCType(); err != nil {
+				return err
+			}
+			if p.peek() == "{" {
+				err = p.skipStatement()
+			} else {
+				err = p.expect(";")
+			}
+			if err != nil {
+				return err
+			}
+			// Method types are resolved like fields of the package scope.
+			input := &protoField{}
+			p.setType(input, method.Input, p.pkg)
+			output := &protoField{}
+			p.setType(output, method.Output, p.pkg)
+			p.registry.methods[service+"/"+name] = method
+			p.pending = append(p.pending, pendingMethod{method, input, output})
+		default:
+			return p.errorf("declaração inesperada %q no serviço", token)
+		}
+	}
+}
+
+func (p *protoParser) parseRPCType() (string, bool, error) {
+	if err := p.expect("("); err != nil {
+		return "", false, err
+	}
+	stream := false
+	name := p.next()
+	if name == "stream" && p.peek() != ")" {
+		stream, name = true, p.next()
+	}
+	return name, stream, p.expect(")")
+}
+
+// unquoteProto accepts both single and double quoted strings.
+func unquoteProto(token string) (string, error) {
+	if len(token) >= 2 && token[0] == '\'' {
+		token = `"` + strings.ReplaceAll(token[1:len(token)-1], `"`, `\"`) + `"`
+	}
+	return strconv.Unquote(token)
+}
+
+// mapEntryName is the name protoc gives to the entry message of a map field.
+func mapEntryName(field string) string {
+	return strings.ToUpper(field[:1]) + jsonName(field)[1:] + "Entry"
+}
+
+// jsonName converts a field name to lowerCamelCase, as protoc does.
+func jsonName(name string) string {
+	var b strings.Builder
+	upper := false
+	for _, r := range name {
+		switch {
+		case r == '_':
+			upper = true
+		case upper:
+			b.WriteRune(unicode.ToUpper(r))
+			upper = false
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
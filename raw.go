@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/csv"
+	"os"
+	"strconv"
+	"time"
+
+	"stress-test/pkg/loadtest"
+)
+
+var rawHeader = []string{"timestamp", "target", "step", "status", "duration_ms", "error", "bytes", "attempts"}
+
+type rawWriter struct {
+	file *os.File
+	csv  *csv.Writer
+	err  error
+}
+
+func newRawWriter(path string) (*rawWriter, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	w := &rawWriter{file: file, csv: csv.NewWriter(file)}
+	w.err = w.csv.Write(rawHeader)
+	return w, nil
+}
+
+func (w *rawWriter) write(result loadtest.Result) {
+	if w.err != nil {
+		return
+	}
+
+	w.err = w.csv.Write([]string{
+		result.Start.Format(time.RFC3339Nano),
+		result.Target,
+		result.Step,
+		strconv.Itoa(result.StatusCode),
+		strconv.FormatFloat(float64(result.Duration)/float64(time.Millisecond), 'f', 3, 64),
+		errorClass(result),
+		strconv.FormatInt(result.Bytes, 10),
+		strconv.Itoa(max(result.Attempts, 1)),
+	})
+}
+
+// errorClass is the class of the error of result (dns, timeout, ...), or
+// assertion when an assertion failed.
+func errorClass(result loadtest.Result) string {
+	switch {
+	case result.Error != nil:
+		return loadtest.ClassifyError(result.Error)
+	case result.AssertionError != nil:
+		return "assertion"
+	}
+	return ""
+}
+
+func (w *rawWriter) close() error {
+	w.csv.Flush()
+	if w.err == nil {
+		w.err = w.csv.Error()
+	}
+	if err := w.file.Close(); w.err == nil {
+		w.err = err
+	}
+	return w.err
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"os/signal"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+	"unicode/utf8"
+)
+
+// maxRecordedBody caps the request bodies kept by record. Larger bodies, and
+// binary ones, which HAR can't hold as text, are left out of the capture.
+const maxRecordedBody = 1 << 20
+
+type recordedEntryKey struct{}
+
+// recorder is a reverse proxy to the target that keeps every request it
+// forwards as a HAR entry.
+type recorder struct {
+	proxy *httputil.ReverseProxy
+	limit int
+	full  chan struct{}
+
+	mu      sync.Mutex
+	entries []harEntry
+	noBody  int
+}
+
+func newRecorder(target *url.URL, insecure bool, limit int) *recorder {
+	rec := &recorder{limit: limit, full: make(chan struct{})}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if insecure {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	rec.proxy = &httputil.ReverseProxy{
+		Transport: transport,
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.SetURL(target)
+			pr.SetXForwarded()
+			// The capture holds the URL of the target, not of the proxy, so
+			// that it is replayed against the target.
+			if entry, ok := pr.In.Context().Value(recordedEntryKey{}).(*harEntry); ok {
+				entry.Request.URL = pr.Out.URL.String()
+			}
+		},
+		ModifyResponse: func(resp *http.Response) error {
+			entry, ok := resp.Request.Context().Value(recordedEntryKey{}).(*harEntry)
+			if !ok {
+				return nil
+			}
+			entry.Timings.Wait = milliseconds(time.Since(entry.StartedDateTime))
+			entry.Response.Status = resp.StatusCode
+			entry.Response.StatusText = http.StatusText(resp.StatusCode)
+			entry.Response.HTTPVersion = resp.Proto
+			entry.Response.Headers = harHeaders(resp.Header)
+			entry.Response.Content.Size = resp.ContentLength
+			entry.Response.Content.MimeType = resp.Header.Get("Content-Type")
+			entry.Response.RedirectURL = resp.Header.Get("Location")
+			entry.Response.HeadersSize = -1
+			entry.Response.BodySize = resp.ContentLength
+			return nil
+		},
+	}
+	return rec
+}
+
+func (rec *recorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	entry := &harEntry{StartedDateTime: time.Now()}
+	entry.Request.Method = r.Method
+	entry.Request.HTTPVersion = r.Proto
+	entry.Request.Headers = harHeaders(r.Header)
+	entry.Request.HeadersSize = -1
+	entry.Request.BodySize = r.ContentLength
+	for name, values := range r.URL.Query() {
+		for _, value := range values {
+			entry.Request.QueryString = append(entry.Request.QueryString, harHeader{Name: name, Value: value})
+		}
+	}
+	for _, cookie := range r.Cookies() {
+		entry.Request.Cookies = append(entry.Request.Cookies, harHeader{Name: cookie.Name, Value: cookie.Value})
+	}
+
+	keepBody := true
+	if r.Body != nil && r.Body != http.NoBody {
+		// The body is read up to the limit and then forwarded whole.
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxRecordedBody+1))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+		switch {
+		case len(body) > maxRecordedBody || !utf8.Valid(body):
+			keepBody = false
+		case len(body) > 0:
+			entry.Request.PostData = &harPostData{MimeType: r.Header.Get("Content-Type"), Text: string(body)}
+			entry.Request.BodySize = int64(len(body))
+		}
+	}
+
+	rec.proxy.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), recordedEntryKey{}, entry)))
+	entry.Time = milliseconds(time.Since(entry.StartedDateTime))
+	entry.Timings.Receive = entry.Time - entry.Timings.Wait
+	rec.add(*entry, keepBody)
+}
+
+func (rec *recorder) add(entry harEntry, keepBody bool) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if rec.limit > 0 && len(rec.entries) >= rec.limit {
+		return
+	}
+	rec.entries = append(rec.entries, entry)
+	if !keepBody {
+		rec.noBody++
+	}
+	if len(rec.entries) == rec.limit {
+		close(rec.full)
+	}
+}
+
+// write saves the entries recorded so far as a HAR file, in the order the
+// requests were started.
+func (rec *recorder) write(path string) (entries, noBody int, err error) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	file := &harFile{}
+	file.Log.Version = "1.2"
+	file.Log.Creator.Name = "stress-test"
+	file.Log.Creator.Version = toolVersion()
+	file.Log.Entries = append([]harEntry{}, rec.entries...)
+	sort.SliceStable(file.Log.Entries, func(i, j int) bool {
+		return file.Log.Entries[i].StartedDateTime.Before(file.Log.Entries[j].StartedDateTime)
+	})
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return 0, 0, err
+	}
+	return len(file.Log.Entries), rec.noBody, os.WriteFile(path, data, 0o644)
+}
+
+// harHeaders lists header sorted by name, as HAR name/value pairs.
+func harHeaders(header http.Header) []harHeader {
+	pairs := []harHeader{}
+	for _, name := range sortedKeys(header) {
+		for _, value := range header[name] {
+			pairs = append(pairs, harHeader{Name: name, Value: value})
+		}
+	}
+	return pairs
+}
+
+// runRecord serves the "record" subcommand.
+func runRecord(args []string) error {
+	fs := flag.NewFlagSet("stress-test record", flag.ExitOnError)
+	var target, listen, output string
+	var duration time.Duration
+	var limit int
+	var insecure bool
+	fs.StringVar(&target, "target", "", "URL do serviço para onde o tráfego gravado é encaminhado (ex: http://localhost:8080)")
+	fs.StringVar(&listen, "listen", "127.0.0.1:8888", "Endereço do proxy de gravação; aponte o cliente (navegador, app, testes) para ele")
+	fs.StringVar(&output, "output", "", "Arquivo HAR onde os requests gravados são salvos, reproduzível com --har")
+	fs.DurationVar(&duration, "duration", 0, "Tempo de gravação (padrão: até Ctrl+C)")
+	fs.IntVar(&limit, "max-requests", 0, "Encerra a gravação após o número de requests informado (0 = sem limite)")
+	fs.BoolVar(&insecure, "insecure", false, "Não valida o certificado TLS do serviço")
+	fs.Parse(args)
+
+	if target == "" || output == "" {
+		return fmt.Errorf("parâmetros --target e --output são obrigatórios")
+	}
+	targetURL, err := url.Parse(target)
+	if err != nil || (targetURL.Scheme != "http" && targetURL.Scheme != "https") || targetURL.Host == "" {
+		return fmt.Errorf("parâmetro --target inválido: %q, use http:// ou https://", target)
+	}
+	if duration < 0 || limit < 0 {
+		return fmt.Errorf("parâmetros --duration e --max-requests não podem ser negativos")
+	}
+	listener, err := net.Listen("tcp", listen)
+	if err != nil {
+		return fmt.Errorf("falha ao escutar em %s: %w", listen, err)
+	}
+
+	rec := newRecorder(targetURL, insecure, limit)
+	server := &http.Server{Handler: rec, ReadHeaderTimeout: 10 * time.Second}
+	go server.Serve(listener)
+	slog.Info("gravando; encerre com Ctrl+C para salvar os requests gravados", "proxy", "http://"+listener.Addr().String(), "target", targetURL)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, duration)
+		defer cancel()
+	}
+	select {
+	case <-ctx.Done():
+	case <-rec.full:
+	}
+	stop()
+	// Requests in flight are recorded once they complete.
+	shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	server.Shutdown(shutdown)
+
+	n, noBody, err := rec.write(output)
+	if err != nil {
+		return fmt.Errorf("falha ao gravar %s: %w", output, err)
+	}
+	slog.Info("requests gravados", "requests", n, "output", output)
+	if noBody > 0 {
+		slog.Warn(fmt.Sprintf("requests gravados sem o corpo (binário ou maior que %d MB)", maxRecordedBody>>20), "requests", noBody)
+	}
+	slog.Info("reproduza com", "command", "stress-test --har="+output+" --respect-timing --requests=<NUM> --concurrency=<NUM>")
+	return nil
+}
@@ -0,0 +1,359 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const (
+	reflectionV1      = "grpc.reflection.v1.ServerReflection"
+	reflectionV1Alpha = "grpc.reflection.v1alpha.ServerReflection"
+)
+
+// reflectionClient queries the gRPC server reflection service, sending one
+// request per stream.
+type reflectionClient struct {
+	client  *http.Client
+	base    string
+	service string
+}
+
+// loadReflection builds a registry with the file that defines the service of
+// method and all of its dependencies, as described by the server.
+func loadReflection(ctx context.Context, client *http.Client, base, method string) (*protoRegistry, error) {
+	service := strings.TrimPrefix(method, "/")
+	if i := strings.Index(service, "/"); i >= 0 {
+		service = service[:i]
+	} else if i := strings.LastIndex(service, "."); i >= 0 {
+		service = service[:i]
+	}
+
+	r := &reflectionClient{client: client, base: base, service: reflectionV1}
+	files, err := r.files(ctx, 4, service)
+	if err != nil {
+		return nil, err
+	}
+
+	registry := newProtoRegistry()
+	loaded := make(map[string]bool)
+	for len(files) > 0 {
+		file := files[0]
+		files = files[1:]
+		name, deps, err := registry.addFileDescriptor(file)
+		if err != nil {
+			return nil, err
+		}
+		loaded[name] = true
+		for _, dep := range deps {
+			if loaded[dep] {
+				continue
+			}
+			// Servers usually send the dependencies with the first
+			// response; the others are requested by name.
+			if pendingFile(files, dep) {
+				continue
+			}
+			more, err := r.files(ctx, 3, dep)
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, more...)
+			loaded[dep] = true
+		}
+	}
+	return registry, nil
+}
+
+func pendingFile(files [][]byte, name string) bool {
+	for _, file := range files {
+		var fileName string
+		protoFields(file, func(number, wireType int, value uint64, data []byte) error {
+			if number == 1 && wireType == 2 {
+				fileName = string(data)
+			}
+			return nil
+		})
+		if fileName == name {
+			return true
+		}
+	}
+	return false
+}
+
+// files sends a ServerReflectionRequest with a file_by_filename (3) or
+// file_containing_symbol (4) query and returns the FileDescriptorProtos of
+// the response.
+func (r *reflectionClient) files(ctx context.Context, query int, name string) ([][]byte, error) {
+	var request []byte
+	request = appendTag(request, query, 2)
+	request = binary.AppendUvarint(request, uint64(len(name)))
+	request = append(request, name...)
+
+	response, err := r.call(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	var files [][]byte
+	var reflectionErr error
+	err = protoFields(response, func(number, wireType int, value uint64, data []byte) error {
+		switch number {
+		case 4:
+			return protoFields(data, func(number, wireType int, value uint64, data []byte) error {
+				if number == 1 {
+					files = append(files, data)
+				}
+				return nil
+			})
+		case 7:
+			reflectionErr = fmt.Errorf("reflexão: %s não encontrado", name)
+			protoFields(data, func(number, wireType int, _ uint64, data []byte) error {
+				if number == 2 {
+					reflectionErr = fmt.Errorf("reflexão: %s", data)
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if reflectionErr != nil {
+		return nil, reflectionErr
+	}
+	return files, nil
+}
+
+func (r *reflectionClient) call(ctx context.Context, message []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.base+"/"+r.service+"/ServerReflectionInfo", bytes.NewReader(grpcFrame(message)))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/grpc")
+	req.Header.Set("TE", "trailers")
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	status := resp.Trailer.Get("Grpc-Status")
+	if status == "" {
+		status = resp.Header.Get("Grpc-Status")
+	}
+	if status == "12" && r.service == reflectionV1 {
+		// Older servers only implement v1alpha.
+		r.service = reflectionV1Alpha
+		return r.call(ctx, message)
+	}
+	if resp.StatusCode != http.StatusOK || status != "0" && status != "" {
+		msg := resp.Trailer.Get("Grpc-Message")
+		if msg == "" {
+			msg = resp.Header.Get("Grpc-Message")
+		}
+		return nil, fmt.Errorf("reflexão indisponível no servidor (HTTP %d, grpc-status %s %s), informe --proto", resp.StatusCode, status, msg)
+	}
+	if len(body) < 5 {
+		return nil, fmt.Errorf("reflexão: resposta vazia")
+	}
+	length := binary.BigEndian.Uint32(body[1:5])
+	if body[0] != 0 || int(length) > len(body)-5 {
+		return nil, fmt.Errorf("reflexão: resposta inválida")
+	}
+	return body[5 : 5+length], nil
+}
+
+// protoTypes maps the FieldDescriptorProto.Type values to the type names.
+var protoTypes = map[uint64]string{
+	1: "double", 2: "float", 3: "int64", 4: "uint64", 5: "int32", 6: "fixed64", 7: "fixed32",
+	8: "bool", 9: "string", 11: "message", 12: "bytes", 13: "uint32", 14: "enum",
+	15: "sfixed32", 16: "sfixed64", 17: "sint32", 18: "sint64",
+}
+
+// addFileDescriptor registers the contents of an encoded FileDescriptorProto
+// and returns its name and dependencies.
+func (p *protoRegistry) addFileDescriptor(file []byte) (string, []string, error) {
+	var name, pkg string
+	var deps []string
+	var messages, enums, services [][]byte
+	err := protoFields(file, func(number, wireType int, value uint64, data []byte) error {
+		switch number {
+		case 1:
+			name = string(data)
+		case 2:
+			pkg = string(data)
+		case 3:
+			deps = append(deps, string(data))
+		case 4:
+			messages = append(messages, data)
+		case 5:
+			enums = append(enums, data)
+		case 6:
+			services = append(services, data)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	for _, message := range messages {
+		if err := p.addDescriptor(pkg, message); err != nil {
+			return "", nil, err
+		}
+	}
+	for _, enum := range enums {
+		if err := p.addEnumDescriptor(pkg, enum); err != nil {
+			return "", nil, err
+		}
+	}
+	for _, service := range services {
+		if err := p.addServiceDescriptor(pkg, service); err != nil {
+			return "", nil, err
+		}
+	}
+	return name, deps, nil
+}
+
+func (p *protoRegistry) addDescriptor(scope string, descriptor []byte) error {
+	message := &protoMessage{}
+	var nested, enums [][]byte
+	err := protoFields(descriptor, func(number, wireType int, value uint64, data []byte) error {
+		switch number {
+		case 1:
+			message.Name = qualify(scope, string(data))
+		case 2:
+			field := &protoField{}
+			err := protoFields(data, func(number, wireType int, value uint64, data []byte) error {
+				switch number {
+				case 1:
+					field.Name = string(data)
+				case 3:
+					field.Number = int(value)
+				case 4:
+					field.Repeated = value == 3
+				case 5:
+					field.Kind = protoTypes[value]
+				case 6:
+					field.TypeName = strings.TrimPrefix(string(data), ".")
+				case 10:
+					field.JSONName = string(data)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			if field.Kind == "" {
+				return fmt.Errorf("campo %s: tipo não suportado", field.Name)
+			}
+			if field.JSONName == "" {
+				field.JSONName = jsonName(field.Name)
+			}
+			message.Fields = append(message.Fields, field)
+		case 3:
+			nested = append(nested, data)
+		case 4:
+			enums = append(enums, data)
+		case 7:
+			return protoFields(data, func(number, wireType int, value uint64, data []byte) error {
+				if number == 7 {
+					message.MapEntry = value != 0
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	p.messages[message.Name] = message
+	for _, descriptor := range nested {
+		if err := p.addDescriptor(message.Name, descriptor); err != nil {
+			return err
+		}
+	}
+	for _, enum := range enums {
+		if err := p.addEnumDescriptor(message.Name, enum); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *protoRegistry) addEnumDescriptor(scope string, descriptor []byte) error {
+	var name string
+	values := make(map[string]int)
+	err := protoFields(descriptor, func(number, wireType int, value uint64, data []byte) error {
+		switch number {
+		case 1:
+			name = qualify(scope, string(data))
+		case 2:
+			var valueName string
+			var valueNumber int32
+			protoFields(data, func(number, wireType int, value uint64, data []byte) error {
+				switch number {
+				case 1:
+					valueName = string(data)
+				case 2:
+					valueNumber = int32(value)
+				}
+				return nil
+			})
+			values[valueName] = int(valueNumber)
+		}
+		return nil
+	})
+	p.enums[name] = values
+	return err
+}
+
+func (p *protoRegistry) addServiceDescriptor(pkg string, descriptor []byte) error {
+	var service string
+	var methods [][]byte
+	err := protoFields(descriptor, func(number, wireType int, value uint64, data []byte) error {
+		switch number {
+		case 1:
+			service = qualify(pkg, string(data))
+		case 2:
+			methods = append(methods, data)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, descriptor := range methods {
+		var name string
+		method := &protoMethod{}
+		err := protoFields(descriptor, func(number, wireType int, value uint64, data []byte) error {
+			switch number {
+			case 1:
+				name = string(data)
+			case 2:
+				method.Input = strings.TrimPrefix(string(data), ".")
+			case 3:
+				method.Output = strings.TrimPrefix(string(data), ".")
+			case 5:
+				method.ClientStreaming = value != 0
+			case 6:
+				method.ServerStreaming = value != 0
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		p.methods[service+"/"+name] = method
+	}
+	return nil
+}
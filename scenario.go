@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"stress-test/pkg/loadtest"
+)
+
+type fileStep struct {
+	Name        string                 `yaml:"name"`
+	Method      string                 `yaml:"method"`
+	URL         string                 `yaml:"url"`
+	Headers     map[string]string      `yaml:"headers"`
+	Body        string                 `yaml:"body"`
+	BodyFile    string                 `yaml:"body_file"`
+	ContentType string                 `yaml:"content_type"`
+	Assertions  fileAssertions         `yaml:"assertions"`
+	Capture     map[string]fileCapture `yaml:"capture"`
+}
+
+type fileCapture struct {
+	JSONPath string `yaml:"json_path"`
+	Regex    string `yaml:"regex"`
+	Header   string `yaml:"header"`
+}
+
+type fileScenario struct {
+	Name  string     `yaml:"name"`
+	Steps []fileStep `yaml:"steps"`
+}
+
+func loadScenarioFile(path string) (*loadtest.Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	file := &fileScenario{}
+	if err := yaml.Unmarshal(data, file); err != nil {
+		return nil, err
+	}
+	if len(file.Steps) == 0 {
+		return nil, fmt.Errorf("cenário sem passos")
+	}
+
+	scenario := &loadtest.Scenario{Name: file.Name}
+	if scenario.Name == "" {
+		scenario.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	for i, fs := range file.Steps {
+		step, err := fs.step(filepath.Dir(path))
+		if err != nil {
+			return nil, fmt.Errorf("steps[%d]: %w", i, err)
+		}
+		scenario.Steps = append(scenario.Steps, step)
+	}
+	return scenario, nil
+}
+
+func (fs *fileStep) step(dir string) (loadtest.Step, error) {
+	step := loadtest.Step{
+		Name:        fs.Name,
+		Method:      strings.ToUpper(strings.TrimSpace(fs.Method)),
+		URL:         fs.URL,
+		ContentType: fs.ContentType,
+	}
+	if step.URL == "" {
+		return step, fmt.Errorf("url é obrigatória")
+	}
+	if step.Method == "" {
+		step.Method = http.MethodGet
+	}
+	if !isValidMethod(step.Method) {
+		return step, fmt.Errorf("método inválido: %q", fs.Method)
+	}
+
+	if len(fs.Headers) > 0 {
+		step.Headers = make(http.Header, len(fs.Headers))
+		for key, value := range fs.Headers {
+			step.Headers.Set(key, value)
+		}
+	}
+
+	if fs.Body != "" && fs.BodyFile != "" {
+		return step, fmt.Errorf("use apenas um entre body e body_file")
+	}
+	if fs.Body != "" {
+		step.Body = []byte(fs.Body)
+	}
+	if fs.BodyFile != "" {
+		path := fs.BodyFile
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return step, err
+		}
+		step.Body = data
+	}
+	if len(step.Body) > 0 && step.ContentType == "" {
+		step.ContentType = detectContentType(step.Body)
+	}
+
+	assertions, err := buildAssertions(fs.Assertions.BodyContains, fs.Assertions.BodyRegex, fs.Assertions.JSONPath)
+	if err != nil {
+		return step, err
+	}
+	step.Assertions = assertions
+
+	names := make([]string, 0, len(fs.Capture))
+	for name := range fs.Capture {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		capture, err := fs.Capture[name].build(name)
+		if err != nil {
+			return step, fmt.Errorf("capture %s: %w", name, err)
+		}
+		step.Captures = append(step.Captures, capture)
+	}
+	return step, nil
+}
+
+func (fc fileCapture) build(name string) (loadtest.Capture, error) {
+	sources := 0
+	for _, source := range []string{fc.JSONPath, fc.Regex, fc.Header} {
+		if source != "" {
+			sources++
+		}
+	}
+	if sources != 1 {
+		return nil, fmt.Errorf("informe exatamente um entre json_path, regex e header")
+	}
+
+	switch {
+	case fc.JSONPath != "":
+		return loadtest.CaptureJSONPath(name, fc.JSONPath)
+	case fc.Regex != "":
+		return loadtest.CaptureRegex(name, fc.Regex)
+	default:
+		return loadtest.CaptureHeader(name, fc.Header), nil
+	}
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"go.starlark.net/lib/json"
+	"go.starlark.net/lib/math"
+	"go.starlark.net/lib/time"
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+
+	"stress-test/pkg/loadtest"
+)
+
+// scriptMetricsKey is the thread local where the metric builtin records values.
+const scriptMetricsKey = "metrics"
+
+// script is a Starlark file loaded with --script. Its globals are frozen once
+// the file runs, so its functions can be called from every worker at once, as
+// long as each call gets its own thread.
+type script struct {
+	path   string
+	before starlark.Callable
+	check  starlark.Callable
+}
+
+func loadScript(path string) (*script, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	s := &script{path: path}
+	globals, err := starlark.ExecFile(s.thread(), path, src, starlark.StringDict{
+		"json":   json.Module,
+		"math":   math.Module,
+		"time":   time.Module,
+		"crypto": cryptoModule,
+		"metric": starlark.NewBuiltin("metric", metric),
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.before, _ = globals["before"].(starlark.Callable)
+	s.check, _ = globals["check"].(starlark.Callable)
+	if s.before == nil && s.check == nil {
+		return nil, fmt.Errorf("o script não define a função before(request) nem check(response)")
+	}
+	return s, nil
+}
+
+func (s *script) thread() *starlark.Thread {
+	return &starlark.Thread{
+		Name: s.path,
+		Print: func(_ *starlark.Thread, msg string) {
+			slog.Info(msg, "script", s.path)
+		},
+	}
+}
+
+// requestHook calls before(request) for every request, which may change its
+// method, url, headers and body, e.g. to sign it. Calling fail fails the
+// request. It returns nil when the script doesn't define before.
+func (s *script) requestHook() loadtest.RequestHook {
+	if s.before == nil {
+		return nil
+	}
+	return func(req *http.Request, body []byte) error {
+		request := newScriptRequest(req, body)
+		if _, err := starlark.Call(s.thread(), s.before, starlark.Tuple{request}, nil); err != nil {
+			return err
+		}
+		return request.apply(req, body)
+	}
+}
+
+// responseCheck calls check(response) for every response. check passes the
+// response by returning None or True, and fails it by returning False or a
+// message, or by calling fail. It returns nil when the script doesn't define
+// check.
+func (s *script) responseCheck() loadtest.ResponseCheck {
+	if s.check == nil {
+		return nil
+	}
+	return func(resp *loadtest.CheckedResponse) (map[string]float64, error) {
+		thread := s.thread()
+		metrics := make(map[string]float64)
+		thread.SetLocal(scriptMetricsKey, metrics)
+		value, err := starlark.Call(thread, s.check, starlark.Tuple{responseValue(resp)}, nil)
+		if err != nil {
+			return metrics, err
+		}
+		switch value := value.(type) {
+		case starlark.NoneType:
+			return metrics, nil
+		case starlark.Bool:
+			if !value {
+				return metrics, errors.New("check retornou False")
+			}
+			return metrics, nil
+		case starlark.String:
+			return metrics, errors.New(string(value))
+		default:
+			return metrics, fmt.Errorf("check deve retornar None, bool ou string, não %s", value.Type())
+		}
+	}
+}
+
+func responseValue(resp *loadtest.CheckedResponse) starlark.Value {
+	headers := starlark.NewDict(len(resp.Header))
+	for name, values := range resp.Header {
+		headers.SetKey(starlark.String(strings.ToLower(name)), starlark.String(strings.Join(values, ", ")))
+	}
+	return starlarkstruct.FromStringDict(starlark.String("response"), starlark.StringDict{
+		"step":        starlark.String(resp.Step),
+		"method":      starlark.String(resp.Method),
+		"url":         starlark.String(resp.URL),
+		"status":      starlark.MakeInt(resp.Status),
+		"headers":     headers,
+		"body":        starlark.String(resp.Body),
+		"duration_ms": starlark.Float(float64(resp.Duration) / 1e6),
+	})
+}
+
+// metric implements metric(name, value), which records a custom metric
+// aggregated in the report.
+func metric(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var name string
+	var value starlark.Value
+	if err := starlark.UnpackPositionalArgs(fn.Name(), args, kwargs, 2, &name, &value); err != nil {
+		return nil, err
+	}
+	v, ok := starlark.AsFloat(value)
+	if !ok {
+		return nil, fmt.Errorf("%s: valor de %q deve ser um número, não %s", fn.Name(), name, value.Type())
+	}
+	metrics, ok := thread.Local(scriptMetricsKey).(map[string]float64)
+	if !ok {
+		return nil, fmt.Errorf("%s: só pode ser chamada durante check", fn.Name())
+	}
+	metrics[name] += v
+	return starlark.None, nil
+}
+
+// scriptRequest is the request given to before, whose fields can be
+// assigned.
+type scriptRequest struct {
+	method  string
+	url     string
+	headers *starlark.Dict
+	body    string
+}
+
+func newScriptRequest(req *http.Request, body []byte) *scriptRequest {
+	headers := starlark.NewDict(len(req.Header))
+	for name, values := range req.Header {
+		headers.SetKey(starlark.String(name), starlark.String(strings.Join(values, ", ")))
+	}
+	return &scriptRequest{method: req.Method, url: req.URL.String(), headers: headers, body: string(body)}
+}
+
+func (r *scriptRequest) String() string        { return fmt.Sprintf("<request %s %s>", r.method, r.url) }
+func (r *scriptRequest) Type() string          { return "request" }
+func (r *scriptRequest) Freeze()               { r.headers.Freeze() }
+func (r *scriptRequest) Truth() starlark.Bool  { return true }
+func (r *scriptRequest) Hash() (uint32, error) { return 0, fmt.Errorf("unhashable: request") }
+func (r *scriptRequest) AttrNames() []string   { return []string{"body", "headers", "method", "url"} }
+
+func (r *scriptRequest) Attr(name string) (starlark.Value, error) {
+	switch name {
+	case "method":
+		return starlark.String(r.method), nil
+	case "url":
+		return starlark.String(r.url), nil
+	case "headers":
+		return r.headers, nil
+	case "body":
+		return starlark.String(r.body), nil
+	}
+	return nil, nil
+}
+
+func (r *scriptRequest) SetField(name string, value starlark.Value) error {
+	if name == "headers" {
+		headers, ok := value.(*starlark.Dict)
+		if !ok {
+			return fmt.Errorf("request.headers deve ser um dict, não %s", value.Type())
+		}
+		r.headers = headers
+		return nil
+	}
+	s, ok := starlark.AsString(value)
+	if !ok {
+		return fmt.Errorf("request.%s deve ser string, não %s", name, value.Type())
+	}
+	switch name {
+	case "method":
+		r.method = s
+	case "url":
+		r.url = s
+	case "body":
+		r.body = s
+	default:
+		return starlark.NoSuchAttrError(fmt.Sprintf("request não tem o campo %s", name))
+	}
+	return nil
+}
+
+// apply copies the changes made by before to req. Headers whose value didn't
+// change keep their original values, even when they had several.
+func (r *scriptRequest) apply(req *http.Request, body []byte) error {
+	if r.url != req.URL.String() {
+		target, err := url.Parse(r.url)
+		if err != nil {
+			return fmt.Errorf("request.url inválida: %w", err)
+		}
+		// A Host set explicitly through the headers is kept.
+		if req.Host == req.URL.Host {
+			req.Host = target.Host
+		}
+		req.URL = target
+	}
+	req.Method = r.method
+	for name := range req.Header {
+		if _, found, _ := r.headers.Get(starlark.String(name)); !found {
+			delete(req.Header, name)
+		}
+	}
+	for _, item := range r.headers.Items() {
+		name, ok1 := starlark.AsString(item[0])
+		value, ok2 := starlark.AsString(item[1])
+		if !ok1 || !ok2 {
+			return fmt.Errorf("request.headers deve ter nomes e valores string, não %s: %s", item[0].Type(), item[1].Type())
+		}
+		if values, ok := req.Header[name]; ok && strings.Join(values, ", ") == value {
+			continue
+		}
+		delete(req.Header, name)
+		req.Header.Set(name, value)
+	}
+	if r.body != string(body) {
+		loadtest.SetRequestBody(req, []byte(r.body))
+	}
+	return nil
+}
+
+// cryptoModule has the primitives needed to sign requests. Digests are
+// returned as raw bytes, to be encoded with hex or base64.
+var cryptoModule = &starlarkstruct.Module{
+	Name: "crypto",
+	Members: starlark.StringDict{
+		"sha256": cryptoFunc("sha256", 1, func(args [][]byte) []byte {
+			sum := sha256.Sum256(args[0])
+			return sum[:]
+		}),
+		"hmac_sha256": cryptoFunc("hmac_sha256", 2, func(args [][]byte) []byte {
+			mac := hmac.New(sha256.New, args[0])
+			mac.Write(args[1])
+			return mac.Sum(nil)
+		}),
+		"hex": cryptoFunc("hex", 1, func(args [][]byte) []byte {
+			return []byte(hex.EncodeToString(args[0]))
+		}),
+		"base64": cryptoFunc("base64", 1, func(args [][]byte) []byte {
+			return []byte(base64.StdEncoding.EncodeToString(args[0]))
+		}),
+	},
+}
+
+// cryptoFunc returns a builtin that takes n strings and returns the result
+// of fn as a string.
+func cryptoFunc(name string, n int, fn func(args [][]byte) []byte) *starlark.Builtin {
+	return starlark.NewBuiltin(name, func(_ *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		if len(args) != n || len(kwargs) > 0 {
+			return nil, fmt.Errorf("%s: espera %d argumento(s), recebeu %d", name, n, len(args)+len(kwargs))
+		}
+		in := make([][]byte, n)
+		for i, arg := range args {
+			s, ok := starlark.AsString(arg)
+			if !ok {
+				return nil, fmt.Errorf("%s: argumento %d deve ser string, não %s", name, i+1, arg.Type())
+			}
+			in[i] = []byte(s)
+		}
+		return starlark.String(fn(in)), nil
+	})
+}
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"stress-test/pkg/loadtest"
+)
+
+// maxServerTests is how many tests the API keeps; the oldest finished ones
+// are dropped to make room for new ones.
+const maxServerTests = 100
+
+const (
+	testRunning   = "running"
+	testCompleted = "completed"
+	testCancelled = "cancelled"
+	testFailed    = "failed"
+)
+
+// serverTest is a test started through the API.
+type serverTest struct {
+	mu        sync.Mutex
+	id        string
+	config    *Config
+	state     string
+	started   time.Time
+	finished  time.Time
+	completed int
+	total     int
+	report    *loadtest.Report
+	err       error
+	cancel    context.CancelFunc
+	done      chan struct{}
+}
+
+type testStatus struct {
+	ID        string      `json:"id"`
+	State     string      `json:"state"`
+	StartedAt time.Time   `json:"started_at"`
+	ElapsedMs float64     `json:"elapsed_ms"`
+	Completed int         `json:"completed"`
+	Total     int         `json:"total,omitempty"`
+	Error     string      `json:"error,omitempty"`
+	Report    *jsonReport `json:"report,omitempty"`
+}
+
+func (t *serverTest) status(withReport bool) testStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	end := t.finished
+	if end.IsZero() {
+		end = time.Now()
+	}
+	status := testStatus{
+		ID:        t.id,
+		State:     t.state,
+		StartedAt: t.started,
+		ElapsedMs: milliseconds(end.Sub(t.started)),
+		Completed: t.completed,
+		Total:     t.total,
+	}
+	if t.err != nil {
+		status.Error = t.err.Error()
+	}
+	if withReport && t.report != nil {
+		report := newJSONReport(t.report)
+		status.Report = &report
+	}
+	return status
+}
+
+// testServer implements --serve: a REST API to start tests, follow their
+// progress and fetch their reports. Only one test runs at a time so that
+// concurrent tests don't skew each other's results.
+type testServer struct {
+	mu     sync.Mutex
+	tests  map[string]*serverTest
+	nextID int
+	active *serverTest
+}
+
+// runServer serves the API on addr. Without a token it only accepts local
+// connections, like the agent.
+func runServer(addr, token string) error {
+	if token == "" && !isLoopback(addr) {
+		return fmt.Errorf("--serve-token é obrigatório para servir a API em %s; sem token use um endereço local (ex: --serve=127.0.0.1:8089)", addr)
+	}
+	s := &testServer{tests: make(map[string]*serverTest)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/tests", s.handleTests)
+	mux.HandleFunc("/tests/", s.handleTest)
+
+	expected := []byte("Bearer " + token)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token != "" && subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) != 1 {
+			writeAPIError(w, http.StatusUnauthorized, fmt.Errorf("token inválido"))
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+
+	slog.Info("API de testes disponível", "addr", addr)
+	server := &http.Server{Addr: addr, Handler: handler, ReadHeaderTimeout: 10 * time.Second}
+	return server.ListenAndServe()
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(v)
+}
+
+func writeAPIError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+func (s *testServer) handleTests(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.mu.Lock()
+		tests := make([]*serverTest, 0, len(s.tests))
+		for _, test := range s.tests {
+			tests = append(tests, test)
+		}
+		s.mu.Unlock()
+		sort.Slice(tests, func(i, j int) bool { return tests[i].started.Before(tests[j].started) })
+		statuses := make([]testStatus, len(tests))
+		for i, test := range tests {
+			statuses[i] = test.status(false)
+		}
+		writeJSON(w, http.StatusOK, statuses)
+	case http.MethodPost:
+		s.start(w, r)
+	default:
+		writeAPIError(w, http.StatusMethodNotAllowed, fmt.Errorf("método não permitido"))
+	}
+}
+
+// start parses the body as a config file that can't reference local files.
+func (s *testServer) start(w http.ResponseWriter, r *http.Request) {
+	config, err := parseServerConfig(r.Body)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	test := &serverTest{config: config, state: testRunning, started: time.Now(), total: config.Requests, done: make(chan struct{})}
+	options := append(config.options(), loadtest.WithProgress(func(completed, total int) {
+		test.mu.Lock()
+		test.completed = completed
+		test.mu.Unlock()
+	}))
+	runner, err := loadtest.New(options...)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	s.mu.Lock()
+	if s.active != nil {
+		s.mu.Unlock()
+		writeAPIError(w, http.StatusConflict, fmt.Errorf("o teste %s ainda está em execução", s.active.id))
+		return
+	}
+	s.prune()
+	s.nextID++
+	test.id = strconv.Itoa(s.nextID)
+	s.tests[test.id] = test
+	s.active = test
+	s.mu.Unlock()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	test.cancel = cancel
+	go func() {
+		report, err := runner.Run(ctx)
+		cancel()
+
+		test.mu.Lock()
+		test.finished = time.Now()
+		test.report, test.err = report, err
+		switch {
+		case err != nil:
+			test.state = testFailed
+		case report.Interrupted:
+			test.state = testCancelled
+		default:
+			test.state = testCompleted
+		}
+		test.mu.Unlock()
+		close(test.done)
+
+		s.mu.Lock()
+		s.active = nil
+		s.mu.Unlock()
+	}()
+
+	w.Header().Set("Location", "/tests/"+test.id)
+	writeJSON(w, http.StatusCreated, test.status(false))
+}
+
+// prune drops the oldest finished tests once the server keeps
+// maxServerTests of them. The caller holds s.mu.
+func (s *testServer) prune() {
+	for len(s.tests) >= maxServerTests {
+		var oldest *serverTest
+		for _, test := range s.tests {
+			if test != s.active && (oldest == nil || test.started.Before(oldest.started)) {
+				oldest = test
+			}
+		}
+		if oldest == nil {
+			return
+		}
+		delete(s.tests, oldest.id)
+	}
+}
+
+func parseServerConfig(body io.Reader) (*Config, error) {
+	data, err := io.ReadAll(io.LimitReader(body, 10<<20))
+	if err != nil {
+		return nil, err
+	}
+	file, err := parseConfigFile(data)
+	if err != nil {
+		return nil, err
+	}
+	if keys := file.fileKeys(); len(keys) > 0 {
+		return nil, fmt.Errorf("a API não aceita caminhos de arquivos: %s", strings.Join(keys, ", "))
+	}
+
+	fs := flag.NewFlagSet("stress-test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	config, err := parseConfig(fs, nil, file)
+	if err != nil {
+		return nil, err
+	}
+	if len(config.Agents) > 0 {
+		return nil, fmt.Errorf("agentes não são suportados pela API")
+	}
+	return config, nil
+}
+
+// handleTest serves /tests/{id}, /tests/{id}/events, /tests/{id}/report and
+// /tests/{id}/report.html.
+func (s *testServer) handleTest(w http.ResponseWriter, r *http.Request) {
+	id, resource, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/tests/"), "/")
+	s.mu.Lock()
+	test := s.tests[id]
+	s.mu.Unlock()
+	if test == nil {
+		writeAPIError(w, http.StatusNotFound, fmt.Errorf("teste %q não encontrado", id))
+		return
+	}
+
+	switch {
+	case resource == "" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, test.status(true))
+	case resource == "" && r.Method == http.MethodDelete:
+		test.cancel()
+		<-test.done
+		writeJSON(w, http.StatusOK, test.status(false))
+	case resource == "events" && r.Method == http.MethodGet:
+		streamEvents(w, r, test)
+	case (resource == "report" || resource == "report.html") && r.Method == http.MethodGet:
+		test.mu.Lock()
+		report := test.report
+		test.mu.Unlock()
+		if report == nil {
+			writeAPIError(w, http.StatusConflict, fmt.Errorf("o relatório do teste %s ainda não está disponível", id))
+			return
+		}
+		if resource == "report.html" {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			renderHTMLReport(w, test.config, report)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		writeJSONReport(w, report)
+	default:
+		writeAPIError(w, http.StatusNotFound, fmt.Errorf("recurso não encontrado"))
+	}
+}
+
+// streamEvents sends the test status every second as server-sent events,
+// until the test finishes or the client disconnects.
+func streamEvents(w http.ResponseWriter, r *http.Request, test *serverTest) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeAPIError(w, http.StatusInternalServerError, fmt.Errorf("streaming não suportado"))
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		status := test.status(false)
+		data, _ := json.Marshal(status)
+		fmt.Fprintf(w, "event: progress\ndata: %s\n\n", data)
+		flusher.Flush()
+		if status.State != testRunning {
+			return
+		}
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-test.done:
+		case <-ticker.C:
+		}
+	}
+}
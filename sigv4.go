@@ -0,0 +1,173 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"stress-test/pkg/loadtest"
+)
+
+// awsCredentials are the static or temporary credentials of an AWS account.
+type awsCredentials struct {
+	accessKey    string
+	secretKey    string
+	sessionToken string
+}
+
+// awsSigV4 signs every request of the test with AWS Signature Version 4.
+type awsSigV4 struct {
+	Enabled bool
+	Region  string
+	Service string
+	creds   *awsCredentialProvider
+}
+
+// awsRegionFromEnv returns the region of AWS_REGION or AWS_DEFAULT_REGION.
+func awsRegionFromEnv() string {
+	if region := os.Getenv("AWS_REGION"); region != "" {
+		return region
+	}
+	return os.Getenv("AWS_DEFAULT_REGION")
+}
+
+// hook returns a loadtest.RequestHook that runs next, if set, and then signs
+// the request, so that the signature covers whatever next changed.
+func (s *awsSigV4) hook(next loadtest.RequestHook) loadtest.RequestHook {
+	return func(req *http.Request, body []byte) error {
+		if next != nil {
+			if err := next(req, body); err != nil {
+				return err
+			}
+		}
+		creds, err := s.creds.get(req.Context())
+		if err != nil {
+			return err
+		}
+		// next may have replaced the body.
+		var payload []byte
+		if req.GetBody != nil {
+			reader, err := req.GetBody()
+			if err != nil {
+				return err
+			}
+			if payload, err = io.ReadAll(reader); err != nil {
+				return err
+			}
+		}
+		signV4(req, sha256Hex(payload), creds, s.Region, s.Service, time.Now())
+		return nil
+	}
+}
+
+// signV4 signs req with AWS Signature Version 4. Every header already set on
+// req is signed, as well as Host. payloadHash is the hex SHA-256 of the body.
+func signV4(req *http.Request, payloadHash string, creds awsCredentials, region, service string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	scope := now.UTC().Format("20060102") + "/" + region + "/" + service + "/aws4_request"
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if creds.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.sessionToken)
+	}
+
+	headers := map[string]string{"host": req.Host}
+	if req.Host == "" {
+		headers["host"] = req.URL.Host
+	}
+	for key, values := range req.Header {
+		trimmed := make([]string, len(values))
+		for i, value := range values {
+			trimmed[i] = strings.Join(strings.Fields(value), " ")
+		}
+		headers[strings.ToLower(key)] = strings.Join(trimmed, ",")
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		canonicalPath(req, service),
+		canonicalQuery(req),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := []byte("AWS4" + creds.secretKey)
+	for _, part := range strings.Split(scope, "/") {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		creds.accessKey, scope, signedHeaders, signature))
+}
+
+// canonicalPath encodes the path once for S3 and twice for every other
+// service, as the signature expects.
+func canonicalPath(req *http.Request, service string) string {
+	path := awsURIEncode(req.URL.Path, false)
+	if service != "s3" {
+		path = awsURIEncode(path, false)
+	}
+	return path
+}
+
+func canonicalQuery(req *http.Request) string {
+	var pairs []string
+	for key, values := range req.URL.Query() {
+		for _, value := range values {
+			pairs = append(pairs, awsURIEncode(key, true)+"="+awsURIEncode(value, true))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// awsURIEncode percent-encodes every byte but the unreserved characters, and
+// the slashes of a path unless encodeSlash is set.
+func awsURIEncode(s string, encodeSlash bool) string {
+	if s == "" && !encodeSlash {
+		return "/"
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '.', c == '_', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"stress-test/pkg/loadtest"
+)
+
+// runSocket serves the "tcp" and "udp" subcommands.
+func runSocket(network string, args []string) error {
+	fs := flag.NewFlagSet("stress-test "+network, flag.ExitOnError)
+	test := &loadtest.SocketTest{Network: network}
+	var payload, payloadFile, output, outputFile string
+	fs.StringVar(&test.Address, "address", "", "Endereço do serviço no formato host:porta")
+	fs.IntVar(&test.Concurrency, "concurrency", 0, "Número de conexões simultâneas")
+	fs.IntVar(&test.Requests, "requests", 0, "Número total de conexões (ou datagramas, em UDP)")
+	fs.DurationVar(&test.Duration, "duration", 0, "Duração máxima do teste (ex: 30s, 5m)")
+	fs.Float64Var(&test.Rate, "rate", 0, "Taxa alvo de conexões por segundo (0 = sem limite)")
+	fs.StringVar(&payload, "payload", "", "Dados enviados em cada conexão; aceita escapes como \\r\\n e \\x00")
+	fs.StringVar(&payloadFile, "payload-file", "", "Arquivo com os dados enviados em cada conexão")
+	fs.BoolVar(&test.ReadReply, "read-reply", false, "Aguarda a resposta do servidor após enviar o payload")
+	fs.DurationVar(&test.Timeout, "timeout", 10*time.Second, "Timeout da conexão e da resposta")
+	fs.StringVar(&output, "output", "text", "Formato do relatório: text ou json")
+	fs.StringVar(&outputFile, "output-file", "", "Arquivo onde o relatório será gravado (padrão: stdout)")
+	fs.Parse(args)
+
+	if test.Address == "" {
+		return fmt.Errorf("parâmetro --address é obrigatório")
+	}
+	if output != "text" && output != "json" {
+		return fmt.Errorf("parâmetro --output inválido: %q (use text ou json)", output)
+	}
+	if payload != "" && payloadFile != "" {
+		return fmt.Errorf("use apenas um entre --payload e --payload-file")
+	}
+	if payloadFile != "" {
+		data, err := os.ReadFile(payloadFile)
+		if err != nil {
+			return fmt.Errorf("erro ao ler --payload-file: %w", err)
+		}
+		test.Payload = data
+	} else if payload != "" {
+		data, err := unescapePayload(payload)
+		if err != nil {
+			return fmt.Errorf("parâmetro --payload inválido: %w", err)
+		}
+		test.Payload = data
+	}
+	if test.ReadReply && len(test.Payload) == 0 {
+		return fmt.Errorf("--read-reply exige --payload ou --payload-file")
+	}
+
+	var out io.Writer = os.Stdout
+	if output == "json" && outputFile == "" {
+		out = os.Stderr
+	}
+	fmt.Fprintf(out, "Iniciando teste %s...\n", network)
+	fmt.Fprintf(out, "Endereço: %s\n", test.Address)
+	if len(test.Payload) > 0 {
+		fmt.Fprintf(out, "Payload: %d bytes\n", len(test.Payload))
+	}
+	if test.ReadReply {
+		fmt.Fprintln(out, "Resposta: aguardada")
+	}
+	if test.Requests > 0 {
+		fmt.Fprintf(out, "Total de conexões: %d\n", test.Requests)
+	}
+	fmt.Fprintf(out, "Concorrência: %d\n", test.Concurrency)
+	if test.Duration > 0 {
+		fmt.Fprintf(out, "Duração: %s\n", test.Duration)
+	}
+	if test.Rate > 0 {
+		fmt.Fprintf(out, "Taxa alvo: %.2f conexões/s\n", test.Rate)
+	}
+	fmt.Fprintln(out)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	report, err := test.Run(ctx)
+	stop()
+	if err != nil {
+		return err
+	}
+	return writeConnectionReport(output, outputFile, report)
+}
+
+// unescapePayload interprets the Go escape sequences of s, so binary and
+// line-based protocols can be written on the command line.
+func unescapePayload(s string) ([]byte, error) {
+	var buf []byte
+	for len(s) > 0 {
+		if s[0] != '\\' {
+			buf = append(buf, s[0])
+			s = s[1:]
+			continue
+		}
+		value, multibyte, tail, err := strconv.UnquoteChar(s, 0)
+		if err != nil {
+			return nil, fmt.Errorf("escape inválido em %q", s)
+		}
+		if multibyte {
+			buf = append(buf, string(value)...)
+		} else {
+			buf = append(buf, byte(value))
+		}
+		s = tail
+	}
+	return buf, nil
+}
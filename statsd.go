@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"stress-test/pkg/loadtest"
+)
+
+// statsdPacketSize keeps every datagram under the usual 1500 bytes MTU.
+const statsdPacketSize = 1400
+
+// statsdSink emits one timing and one counter per result using the DogStatsD
+// tag extension. Metrics are batched into datagrams and flushed every second.
+type statsdSink struct {
+	mu   sync.Mutex
+	conn net.Conn
+	tags string
+	buf  bytes.Buffer
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+func newStatsdSink(addr string, tags []string, run map[string]string) (*statsdSink, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range sortedKeys(run) {
+		tags = append(tags, key+":"+statsdTag(run[key]))
+	}
+	s := &statsdSink{conn: conn, tags: strings.Join(tags, ","), done: make(chan struct{})}
+	s.wg.Add(1)
+	go s.loop()
+	return s, nil
+}
+
+func (s *statsdSink) loop() {
+	defer s.wg.Done()
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			s.mu.Lock()
+			s.flush()
+			s.mu.Unlock()
+		}
+	}
+}
+
+func (s *statsdSink) record(result loadtest.Result) {
+	status := strconv.Itoa(result.StatusCode)
+	if result.Error != nil {
+		status = "error"
+	}
+	tags := "target:" + statsdTag(result.Target) + ",status:" + status
+	if result.Step != "" {
+		tags += ",step:" + statsdTag(result.Step)
+	}
+	if s.tags != "" {
+		tags += "," + s.tags
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.write(fmt.Sprintf("stress_test.requests:1|c|#%s", tags))
+	if result.Error != nil {
+		s.write(fmt.Sprintf("stress_test.errors:1|c|#%s,category:%s", tags, loadtest.ClassifyError(result.Error)))
+		return
+	}
+	s.write(fmt.Sprintf("stress_test.request.duration:%.3f|ms|#%s", float64(result.Duration)/float64(time.Millisecond), tags))
+	if result.AssertionError != nil {
+		s.write(fmt.Sprintf("stress_test.assertion_failures:1|c|#%s", tags))
+	}
+}
+
+// statsdTag removes the characters that delimit metrics and tags.
+func statsdTag(value string) string {
+	return strings.NewReplacer(",", "_", "|", "_", "#", "_", "\n", "_").Replace(value)
+}
+
+func (s *statsdSink) write(metric string) {
+	if s.buf.Len() > 0 && s.buf.Len()+1+len(metric) > statsdPacketSize {
+		s.flush()
+	}
+	if s.buf.Len() > 0 {
+		s.buf.WriteByte('\n')
+	}
+	s.buf.WriteString(metric)
+}
+
+// flush sends the pending metrics. Errors are ignored: like any StatsD client,
+// a missing agent must not affect the test.
+func (s *statsdSink) flush() {
+	if s.buf.Len() == 0 {
+		return
+	}
+	s.conn.Write(s.buf.Bytes())
+	s.buf.Reset()
+}
+
+func (s *statsdSink) close() error {
+	close(s.done)
+	s.wg.Wait()
+	s.mu.Lock()
+	s.flush()
+	s.mu.Unlock()
+	return s.conn.Close()
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"stress-test/pkg/loadtest"
+)
+
+// requestSpec is one line of --stdin.
+type requestSpec struct {
+	Method  string            `json:"method"`
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers"`
+	Body    string            `json:"body"`
+}
+
+func (s *requestSpec) step() (loadtest.Step, error) {
+	step := loadtest.Step{
+		Method: strings.ToUpper(strings.TrimSpace(s.Method)),
+		URL:    s.URL,
+	}
+	if step.URL == "" {
+		return step, fmt.Errorf("url é obrigatória")
+	}
+	if step.Method == "" {
+		step.Method = http.MethodGet
+	}
+	if !isValidMethod(step.Method) {
+		return step, fmt.Errorf("método inválido: %q", s.Method)
+	}
+	if len(s.Headers) > 0 {
+		step.Headers = make(http.Header, len(s.Headers))
+		for key, value := range s.Headers {
+			step.Headers.Set(key, value)
+		}
+	}
+	if s.Body != "" {
+		step.Body = []byte(s.Body)
+		if step.Headers.Get("Content-Type") == "" {
+			step.ContentType = detectContentType(step.Body)
+		}
+	}
+	return step, nil
+}
+
+// readRequestSpecs sends one step per JSON line of r and closes steps at EOF.
+// Invalid lines are reported and skipped so a single bad capture doesn't end
+// the test.
+func readRequestSpecs(r io.Reader, steps chan<- loadtest.Step) {
+	defer close(steps)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 10<<20)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		var spec requestSpec
+		if err := json.Unmarshal([]byte(text), &spec); err != nil {
+			slog.Warn("linha do stdin ignorada", "line", line, "error", err)
+			continue
+		}
+		step, err := spec.step()
+		if err != nil {
+			slog.Warn("linha do stdin ignorada", "line", line, "error", err)
+			continue
+		}
+		steps <- step
+	}
+	if err := scanner.Err(); err != nil {
+		slog.Warn("erro ao ler stdin", "error", err)
+	}
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"stress-test/pkg/loadtest"
+
+	_ "modernc.org/sqlite"
+)
+
+const storeSchema = `
+CREATE TABLE IF NOT EXISTS runs (
+	id               INTEGER PRIMARY KEY AUTOINCREMENT,
+	started_at       TEXT NOT NULL,
+	run_id           TEXT NOT NULL DEFAULT '',
+	name             TEXT NOT NULL DEFAULT '',
+	tags             TEXT NOT NULL,
+	hostname         TEXT NOT NULL,
+	git_sha          TEXT NOT NULL,
+	version          TEXT NOT NULL,
+	interrupted      INTEGER NOT NULL,
+	total_time_ms    REAL NOT NULL,
+	total_requests   INTEGER NOT NULL,
+	success_requests INTEGER NOT NULL,
+	error_rate       REAL NOT NULL,
+	rps              REAL NOT NULL,
+	p50_ms           REAL NOT NULL,
+	p90_ms           REAL NOT NULL,
+	p95_ms           REAL NOT NULL,
+	p99_ms           REAL NOT NULL,
+	report           TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS samples (
+	run_id      INTEGER NOT NULL REFERENCES runs (id),
+	timestamp   TEXT NOT NULL,
+	target      TEXT NOT NULL,
+	step        TEXT NOT NULL,
+	status      INTEGER NOT NULL,
+	duration_ms REAL NOT NULL,
+	error       TEXT NOT NULL,
+	bytes       INTEGER NOT NULL,
+	attempts    INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS samples_run_id ON samples (run_id);
+`
+
+// storeMigrations add the columns created after the first version of the
+// schema to the databases that predate them.
+var storeMigrations = map[string]string{
+	"run_id": `ALTER TABLE runs ADD COLUMN run_id TEXT NOT NULL DEFAULT ''`,
+	"name":   `ALTER TABLE runs ADD COLUMN name TEXT NOT NULL DEFAULT ''`,
+}
+
+// pendingSchema holds the samples of the current run until it is saved. It
+// is a temporary table, so the samples of a run that never finishes are
+// dropped with the connection.
+const pendingSchema = `
+CREATE TEMP TABLE pending_samples (
+	timestamp   TEXT NOT NULL,
+	target      TEXT NOT NULL,
+	step        TEXT NOT NULL,
+	status      INTEGER NOT NULL,
+	duration_ms REAL NOT NULL,
+	error       TEXT NOT NULL,
+	bytes       INTEGER NOT NULL,
+	attempts    INTEGER NOT NULL
+);
+`
+
+const (
+	// storeBatch is how many samples are written per transaction during the
+	// run.
+	storeBatch = 1000
+	// storeFlush bounds how long a sample waits for its batch to fill.
+	storeFlush = time.Second
+)
+
+// storedSample is the part of a result written to the database.
+type storedSample struct {
+	start    time.Time
+	target   string
+	step     string
+	status   int
+	duration time.Duration
+	error    string
+	bytes    int64
+	attempts int
+}
+
+// resultStore persists the summary of every run, and its requests, to a
+// SQLite database that the history subcommand reads.
+type resultStore struct {
+	db      *sql.DB
+	samples chan storedSample
+	done    chan struct{}
+	err     error
+}
+
+func openStore(path string) (*resultStore, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(storeSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("%s não é um banco SQLite válido: %w", path, err)
+	}
+	if err := migrateStore(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("falha ao atualizar o banco %s: %w", path, err)
+	}
+	// The temporary table of pending samples only exists on the connection
+	// that created it.
+	db.SetMaxOpenConns(1)
+	return &resultStore{db: db}, nil
+}
+
+func migrateStore(db *sql.DB) error {
+	rows, err := db.Query(`SELECT name FROM pragma_table_info('runs')`)
+	if err != nil {
+		return err
+	}
+	columns := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return err
+		}
+		columns[name] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, column := range sortedKeys(storeMigrations) {
+		if columns[column] {
+			continue
+		}
+		if _, err := db.Exec(storeMigrations[column]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// begin starts writing the results passed to record, in batches, to the
+// pending samples that save moves to the run.
+func (s *resultStore) begin() error {
+	if _, err := s.db.Exec(pendingSchema); err != nil {
+		return err
+	}
+	insert, err := s.db.Prepare(`INSERT INTO pending_samples (timestamp, target, step, status, duration_ms, error, bytes, attempts)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	s.samples = make(chan storedSample, 4*storeBatch)
+	s.done = make(chan struct{})
+	go s.write(insert)
+	return nil
+}
+
+// write runs on its own goroutine, so that the collector doesn't wait for
+// the database. After an error the remaining samples are drained and
+// dropped, and save reports it.
+func (s *resultStore) write(insert *sql.Stmt) {
+	defer close(s.done)
+	defer insert.Close()
+	ticker := time.NewTicker(storeFlush)
+	defer ticker.Stop()
+
+	batch := make([]storedSample, 0, storeBatch)
+	flush := func() {
+		if len(batch) > 0 && s.err == nil {
+			s.err = s.writeBatch(insert, batch)
+		}
+		batch = batch[:0]
+	}
+	for {
+		select {
+		case sample, ok := <-s.samples:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, sample)
+			if len(batch) == storeBatch {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+func (s *resultStore) writeBatch(insert *sql.Stmt, batch []storedSample) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	stmt := tx.Stmt(insert)
+	for _, sample := range batch {
+		if _, err := stmt.Exec(sample.start.Format(time.RFC3339Nano), sample.target, sample.step, sample.status,
+			milliseconds(sample.duration), sample.error, sample.bytes, sample.attempts); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// record hands result to the writer. It runs on the collector goroutine.
+func (s *resultStore) record(result loadtest.Result) {
+	s.samples <- storedSample{
+		start:    result.Start,
+		target:   result.Target,
+		step:     result.Step,
+		status:   result.StatusCode,
+		duration: result.Duration,
+		error:    errorClass(result),
+		bytes:    result.Bytes,
+		attempts: max(result.Attempts, 1),
+	}
+}
+
+// save waits for the pending samples to be written, then writes the run and
+// moves the samples to it in a single transaction, and returns the id of the
+// run.
+func (s *resultStore) save(report *loadtest.Report) (int64, error) {
+	close(s.samples)
+	<-s.done
+	if s.err != nil {
+		return 0, s.err
+	}
+
+	out := newJSONReport(report)
+	data, err := json.Marshal(out)
+	if err != nil {
+		return 0, err
+	}
+	tags, err := json.Marshal(report.Metadata.Tags)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	run, err := tx.Exec(`INSERT INTO runs (started_at, run_id, name, tags, hostname, git_sha, version, interrupted,
+		total_time_ms, total_requests, success_requests, error_rate, rps, p50_ms, p90_ms, p95_ms, p99_ms, report)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		report.StartTime.UTC().Format(time.RFC3339), report.Metadata.RunID, report.Metadata.Name, string(tags), report.Metadata.Hostname, report.Metadata.GitSHA,
+		report.Metadata.Version, report.Interrupted, out.TotalTimeMs, report.TotalRequests, report.SuccessRequests,
+		report.ErrorRate(), report.RequestsPerSecond(), out.Latency.P50Ms, out.Latency.P90Ms, out.Latency.P95Ms,
+		out.Latency.P99Ms, string(data))
+	if err != nil {
+		return 0, err
+	}
+	id, err := run.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.Exec(`INSERT INTO samples (run_id, timestamp, target, step, status, duration_ms, error, bytes, attempts)
+		SELECT ?, timestamp, target, step, status, duration_ms, error, bytes, attempts FROM pending_samples`, id); err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec(`DELETE FROM pending_samples`); err != nil {
+		return 0, err
+	}
+	return id, tx.Commit()
+}
+
+func (s *resultStore) close() error {
+	return s.db.Close()
+}
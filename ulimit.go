@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+)
+
+// fdHeadroom is the number of descriptors left for everything but the
+// connections to the target: stdio, output files, DNS, the metrics server.
+const fdHeadroom = 64
+
+// checkOpenFiles fails fast when the open files limit of the process
+// (RLIMIT_NOFILE) can't hold a connection per worker, instead of letting the
+// run fail with "too many open files". With raise, it first tries to lift
+// the limit, which above the hard limit requires privileges.
+func checkOpenFiles(connections int, raise bool) error {
+	needed := uint64(connections) + fdHeadroom
+	soft, hard, ok := openFilesLimit()
+	if !ok || soft >= needed {
+		return nil
+	}
+	err := fmt.Errorf("%d conexões simultâneas precisam de cerca de %d descritores de arquivo, mas o limite do processo é %d: aumente-o com \"ulimit -n %d\"",
+		connections, needed, soft, needed)
+	if !raise {
+		return fmt.Errorf("%w ou use --raise-nofile", err)
+	}
+	if raiseErr := setOpenFilesLimit(needed, max(hard, needed)); raiseErr != nil {
+		return fmt.Errorf("%w (falha ao aumentar o limite: %v)", err, raiseErr)
+	}
+	slog.Info("limite de arquivos abertos aumentado", "from", soft, "to", needed)
+	return nil
+}
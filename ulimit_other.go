@@ -0,0 +1,14 @@
+//go:build !linux && !darwin
+
+package main
+
+import "errors"
+
+// Other systems have no RLIMIT_NOFILE, or one this check doesn't read.
+func openFilesLimit() (soft, hard uint64, ok bool) {
+	return 0, 0, false
+}
+
+func setOpenFilesLimit(soft, hard uint64) error {
+	return errors.New("não suportado neste sistema")
+}
@@ -0,0 +1,17 @@
+//go:build linux || darwin
+
+package main
+
+import "syscall"
+
+func openFilesLimit() (soft, hard uint64, ok bool) {
+	var limit syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &limit); err != nil {
+		return 0, 0, false
+	}
+	return limit.Cur, limit.Max, true
+}
+
+func setOpenFilesLimit(soft, hard uint64) error {
+	return syscall.Setrlimit(syscall.RLIMIT_NOFILE, &syscall.Rlimit{Cur: soft, Max: hard})
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"stress-test/pkg/loadtest"
+)
+
+var uploadClient = &http.Client{Timeout: time.Minute}
+
+// parseUploadURL validates a --upload destination: s3://bucket/prefix or
+// gs://bucket/prefix.
+func parseUploadURL(raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "s3" && u.Scheme != "gs") || u.Host == "" {
+		return nil, fmt.Errorf("destino inválido %q, use s3://bucket/prefixo ou gs://bucket/prefixo", raw)
+	}
+	return u, nil
+}
+
+// uploadCredentialsTimeout bounds the check of the S3 credentials before the
+// run, which may query the instance metadata service.
+const uploadCredentialsTimeout = 10 * time.Second
+
+// checkUploadCredentials fails before the run when the reports couldn't be
+// uploaded to dest at its end.
+func checkUploadCredentials(dest *url.URL) error {
+	if dest.Scheme != "s3" {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), uploadCredentialsTimeout)
+	defer cancel()
+	_, err := (&awsCredentialProvider{}).get(ctx)
+	return err
+}
+
+// uploadReports copies the JSON and HTML reports to object storage, under a
+// directory named after the start of the run, and returns their URLs.
+func uploadReports(ctx context.Context, dest *url.URL, config *Config, report *loadtest.Report) ([]string, error) {
+	var jsonReport, htmlReport bytes.Buffer
+	if err := writeJSONReport(&jsonReport, report); err != nil {
+		return nil, err
+	}
+	if err := renderHTMLReport(&htmlReport, config, report); err != nil {
+		return nil, err
+	}
+
+	// Runs of the same --name are grouped, and sorted by start time within it.
+	dir := path.Join(strings.Trim(dest.Path, "/"), report.Metadata.Name, report.Metadata.RunID)
+	var uploaded []string
+	for _, object := range []struct {
+		name        string
+		contentType string
+		data        []byte
+	}{
+		{"report.json", "application/json", jsonReport.Bytes()},
+		{"report.html", "text/html; charset=utf-8", htmlReport.Bytes()},
+	} {
+		key := path.Join(dir, object.name)
+		var err error
+		if dest.Scheme == "s3" {
+			err = putS3(ctx, dest.Host, key, object.contentType, object.data)
+		} else {
+			err = putGCS(ctx, dest.Host, key, object.contentType, object.data)
+		}
+		if err != nil {
+			return uploaded, fmt.Errorf("falha ao enviar %s: %w", object.name, err)
+		}
+		uploaded = append(uploaded, dest.Scheme+"://"+dest.Host+"/"+key)
+	}
+	return uploaded, nil
+}
+
+// browserURL turns the s3:// or gs:// URL of an uploaded object into an
+// https:// URL that can be opened by whoever has access to the bucket.
+func browserURL(object string) string {
+	if rest, ok := strings.CutPrefix(object, "s3://"); ok {
+		bucket, key, _ := strings.Cut(rest, "/")
+		return "https://" + bucket + ".s3.amazonaws.com/" + key
+	}
+	if rest, ok := strings.CutPrefix(object, "gs://"); ok {
+		return "https://storage.cloud.google.com/" + rest
+	}
+	return object
+}
+
+// putS3 uploads an object with the credentials of the standard AWS chain and
+// the region of the AWS environment variables. AWS_ENDPOINT_URL_S3 (or AWS_ENDPOINT_URL) points
+// to S3 compatible services such as MinIO, addressed path-style.
+func putS3(ctx context.Context, bucket, key, contentType string, data []byte) error {
+	creds, err := (&awsCredentialProvider{}).get(ctx)
+	if err != nil {
+		return err
+	}
+	region := awsRegionFromEnv()
+	if region == "" {
+		region = "us-east-1"
+	}
+	endpoint := os.Getenv("AWS_ENDPOINT_URL_S3")
+	if endpoint == "" {
+		endpoint = os.Getenv("AWS_ENDPOINT_URL")
+	}
+	objectPath := "/" + key
+	if endpoint == "" {
+		endpoint = "https://" + bucket + ".s3." + region + ".amazonaws.com"
+	} else {
+		objectPath = "/" + bucket + objectPath
+	}
+
+	req, err := objectRequest(ctx, strings.TrimRight(endpoint, "/"), objectPath, contentType, data)
+	if err != nil {
+		return err
+	}
+	signV4(req, sha256Hex(data), creds, region, "s3", time.Now())
+	return sendObject(req)
+}
+
+// putGCS uploads an object with the access token of GOOGLE_OAUTH_ACCESS_TOKEN
+// or, on Google Cloud, of the service account of the instance.
+func putGCS(ctx context.Context, bucket, key, contentType string, data []byte) error {
+	token := os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN")
+	if token == "" {
+		var err error
+		if token, err = gcpMetadataToken(ctx); err != nil {
+			return fmt.Errorf("defina GOOGLE_OAUTH_ACCESS_TOKEN (token do metadata server indisponível: %w)", err)
+		}
+	}
+	req, err := objectRequest(ctx, "https://storage.googleapis.com", "/"+bucket+"/"+key, contentType, data)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return sendObject(req)
+}
+
+func gcpMetadataToken(ctx context.Context) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		"http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token", nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	resp, err := uploadClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("status %d", resp.StatusCode)
+	}
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", err
+	}
+	return token.AccessToken, nil
+}
+
+func objectRequest(ctx context.Context, endpoint, objectPath, contentType string, data []byte) (*http.Request, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("endpoint inválido %q: %w", endpoint, err)
+	}
+	u.Path = objectPath
+	u.RawPath = awsURIEncode(objectPath, false)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, u.String(), bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	return req, nil
+}
+
+func sendObject(req *http.Request) error {
+	resp, err := uploadClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
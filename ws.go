@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"stress-test/pkg/loadtest"
+)
+
+// runWebSocket serves the "ws" subcommand.
+func runWebSocket(args []string) error {
+	fs := flag.NewFlagSet("stress-test ws", flag.ExitOnError)
+	test := &loadtest.WebSocketTest{Headers: make(http.Header)}
+	var messageFile, output, outputFile string
+	var insecure bool
+	fs.StringVar(&test.URL, "url", "", "URL do servidor WebSocket (ws:// ou wss://)")
+	fs.IntVar(&test.Connections, "connections", 0, "Número de conexões simultâneas")
+	fs.StringVar(&test.Message, "message", "ping", "Mensagem enviada em cada conexão; aceita as funções de template (ex: {{uuid}})")
+	fs.StringVar(&messageFile, "message-file", "", "Arquivo com a mensagem enviada em cada conexão")
+	fs.BoolVar(&test.Binary, "binary", false, "Envia a mensagem como frame binário em vez de texto")
+	fs.IntVar(&test.Messages, "messages", 0, "Número de mensagens enviadas por conexão")
+	fs.DurationVar(&test.Duration, "duration", 0, "Duração do teste (ex: 30s, 5m)")
+	fs.Float64Var(&test.Rate, "rate", 0, "Mensagens por segundo somando todas as conexões (0 = envia a próxima assim que a resposta chega)")
+	fs.DurationVar(&test.Timeout, "timeout", 30*time.Second, "Timeout da conexão e de cada mensagem")
+	fs.Var(headerFlags(test.Headers), "header", "Header enviado no handshake no formato \"Chave: Valor\" (pode ser repetido)")
+	fs.BoolVar(&insecure, "insecure", false, "Não valida o certificado TLS do servidor")
+	fs.StringVar(&output, "output", "text", "Formato do relatório: text ou json")
+	fs.StringVar(&outputFile, "output-file", "", "Arquivo onde o relatório será gravado (padrão: stdout)")
+	fs.Parse(args)
+
+	if test.URL == "" {
+		return fmt.Errorf("parâmetro --url é obrigatório")
+	}
+	if output != "text" && output != "json" {
+		return fmt.Errorf("parâmetro --output inválido: %q (use text ou json)", output)
+	}
+	if messageFile != "" {
+		data, err := os.ReadFile(messageFile)
+		if err != nil {
+			return fmt.Errorf("erro ao ler --message-file: %w", err)
+		}
+		test.Message = string(data)
+	}
+	if insecure {
+		test.TLSConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+
+	var out io.Writer = os.Stdout
+	if output == "json" && outputFile == "" {
+		out = os.Stderr
+	}
+	fmt.Fprintln(out, "Iniciando teste de WebSocket...")
+	fmt.Fprintf(out, "URL: %s\n", test.URL)
+	fmt.Fprintf(out, "Conexões: %d\n", test.Connections)
+	if test.Messages > 0 {
+		fmt.Fprintf(out, "Mensagens por conexão: %d\n", test.Messages)
+	}
+	if test.Duration > 0 {
+		fmt.Fprintf(out, "Duração: %s\n", test.Duration)
+	}
+	if test.Rate > 0 {
+		fmt.Fprintf(out, "Taxa alvo: %.2f mensagens/s\n", test.Rate)
+	}
+	fmt.Fprintln(out)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	report, err := test.Run(ctx)
+	stop()
+	if err != nil {
+		return err
+	}
+
+	return writeConnectionReport(output, outputFile, report)
+}